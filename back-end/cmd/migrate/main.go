@@ -0,0 +1,131 @@
+// Command used to manage the schema outside of the server.
+//
+//	migrate up                applies every pending migration
+//	migrate down [n]          reverts the last n migrations (1 by default)
+//	migrate status            lists the migrations and their state
+//	migrate force <version>   marks a dirty migration as applied, once it was
+//	                          finished by hand
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sipub-test/config"
+	"sipub-test/db"
+	"sipub-test/db/migrations"
+	"strconv"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: migrate [flags] up|down [n]|status|force <version>")
+	fmt.Fprintln(os.Stderr, "accepts the same flags and env vars as the server, run with -h to list them")
+}
+
+func main() {
+	os.Exit(run())
+}
+
+// Returns the exit code instead of calling log.Fatal, so the connection is
+// closed by the deferred CloseDB before exiting. 2 is a usage error
+func run() int {
+	// Shares the config with the server so the DSN only has to be set once
+	cfg, args, err := config.Load("migrate", os.Args[1:], os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		log.Printf("Invalid configuration: %v", err)
+		return 1
+	}
+
+	if len(args) < 1 {
+		usage()
+		return 2
+	}
+
+	if err := db.InitializeDB(cfg.Database.DSN, db.Options{
+		ConnectTimeout: cfg.Database.ConnectTimeout.Std(),
+	}); err != nil {
+		log.Print(err)
+		return 1
+	}
+	defer db.CloseDB()
+
+	migrator, err := migrations.NewMigrator(db.GetDB())
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, migration := range applied {
+			log.Printf("Applied %04d_%s", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Print(err)
+			return 1
+		}
+		if len(applied) == 0 {
+			log.Println("Schema is up to date")
+		}
+	case "down":
+		steps := uint64(1)
+		if len(args) > 1 {
+			steps, err = strconv.ParseUint(args[1], 10, 32)
+			if err != nil || steps == 0 {
+				log.Printf("Invalid number of steps: %s", args[1])
+				return 2
+			}
+		}
+		reverted, err := migrator.Down(ctx, uint(steps))
+		for _, migration := range reverted {
+			log.Printf("Reverted %04d_%s", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Print(err)
+			return 1
+		}
+	case "status":
+		status, err := migrator.Status(ctx)
+		if err != nil {
+			log.Print(err)
+			return 1
+		}
+		for _, s := range status {
+			state := "pending"
+			if s.Dirty {
+				state = "DIRTY"
+			} else if s.Applied {
+				state = "applied"
+			}
+			fmt.Printf("%04d_%-40s %s\n", s.Version, s.Name, state)
+		}
+	case "force":
+		if len(args) < 2 {
+			usage()
+			return 2
+		}
+		version, err := strconv.ParseUint(args[1], 10, 32)
+		if err != nil {
+			log.Printf("Invalid version: %s", args[1])
+			return 2
+		}
+		forced, err := migrator.Force(ctx, uint(version))
+		if err != nil {
+			log.Print(err)
+			return 1
+		}
+		log.Printf("Forced %04d_%s, it is marked as applied", forced.Version, forced.Name)
+	default:
+		usage()
+		return 2
+	}
+	return 0
+}
@@ -1,25 +1,48 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"sipub-test/config"
 	"sipub-test/db"
+	"sipub-test/db/migrations"
 	internal "sipub-test/internal"
 	"sipub-test/internal/address"
+	"sipub-test/internal/auth"
+	"sipub-test/internal/checkout"
 	"sipub-test/internal/delivery"
 	"sipub-test/internal/delivery_product"
+	"sipub-test/internal/delivery_status"
+	"sipub-test/internal/email_verification"
+	"sipub-test/internal/inventory"
+	"sipub-test/internal/mailer"
+	"sipub-test/internal/middleware"
+	"sipub-test/internal/pagination"
 	"sipub-test/internal/payment"
+	"sipub-test/internal/payment_gateway"
 	"sipub-test/internal/product"
+	"sipub-test/internal/refund"
 	"sipub-test/internal/shopping_cart"
+	"sipub-test/internal/unit_of_work"
 	"sipub-test/internal/user"
 	"sipub-test/internal/user_address"
 	"sipub-test/internal/user_delivery"
+	"sipub-test/pkg/clock"
+	"sipub-test/pkg/money"
+	"syscall"
+	"time"
 
 	"github.com/rs/cors"
 )
 
-const portNum string = ":8080"
-
 // Loops through an 'array' of routers and uses the Init method on them.
 // The `Init` method should initialize all of the methods per route.
 func RouterInitializeAll(mux *http.ServeMux, routers ...internal.IRouter) {
@@ -28,32 +51,166 @@ func RouterInitializeAll(mux *http.ServeMux, routers ...internal.IRouter) {
 	}
 }
 
+// Builds the whole graph by hand: repository -> controller -> router. Nothing
+// reaches for globals below this point, so tests can build the same graph with
+// fakes.
+func newRouters(database *sql.DB, clk clock.Clock, gateway payment_gateway.IPaymentGateway, mail mailer.IMailer, verificationTTL time.Duration, authCfg config.AuthConfig, logger *slog.Logger) []internal.IRouter {
+	// Shared by the repositories that write to several tables at once
+	uow := unit_of_work.NewUnitOfWork(database, clk)
+	paymentRepository := payment.NewMySQLPaymentRepository(database, clk)
+	userRepository := user.NewMySQLUserRepository(database, clk)
+	authRepository := auth.NewMySQLAuthRepository(database, clk)
+	guard := auth.NewGuard(authRepository, logger)
+	emailVerificationRepository := email_verification.NewMySQLEmailVerificationRepository(database, clk)
+	verificationSender := email_verification.NewSender(emailVerificationRepository, mail, clk, verificationTTL)
+
+	authController := auth.NewAuthController(authRepository, auth.NewTokenIssuer(clk, authCfg.AccessTokenTTL.Std(), authCfg.RefreshTokenTTL.Std()),
+		auth.NewPasswordResetSender(authRepository, mail, clk, authCfg.PasswordResetTTL.Std()),
+		&auth.LoginValidator{}, &auth.RefreshValidator{}, &auth.PasswordResetValidator{}, &auth.PasswordResetConfirmValidator{}, logger)
+	addressController := address.NewAddressController(address.NewMySQLAddressRepository(database, clk), &address.AddressValidator{}, logger)
+	checkoutController := checkout.NewCheckoutController(checkout.NewMySQLCheckoutRepository(uow, clk), &checkout.CheckoutValidator{}, logger)
+	deliveryController := delivery.NewDeliveryController(delivery.NewMySQLDeliveryRepository(database, clk), &delivery.DeliveryValidator{}, logger)
+	inventoryController := inventory.NewInventoryController(inventory.NewMySQLInventoryRepository(database, uow, clk), &inventory.RestockValidator{}, logger)
+	deliveryStatusController := delivery_status.NewDeliveryStatusController(delivery_status.NewMySQLDeliveryStatusRepository(database, uow, clk), &delivery_status.TransitionValidator{}, logger)
+	deliveryProductController := delivery_product.NewDeliveryProductController(inventory.NewMySQLDeliveryProductRepository(delivery_product.NewMySQLDeliveryProductRepository(database), uow, clk), &delivery_product.DeliveryProductValidator{}, logger)
+	paymentController := payment.NewPaymentController(paymentRepository, &payment.PaymentValidator{}, gateway, &payment.CaptureValidator{}, logger)
+	refundController := refund.NewRefundController(refund.NewMySQLRefundRepository(uow, clk), paymentRepository, gateway, &refund.RefundValidator{}, logger)
+	productController := product.NewProductController(product.NewMySQLProductRepository(database, clk), &product.ProductValidator{}, logger)
+	shoppingCartController := shopping_cart.NewShoppingCartController(shopping_cart.NewMySQLShoppingCartRepository(database, clk), &shopping_cart.ShoppingCartValidator{}, &shopping_cart.ShoppingCartAmountValidator{}, logger)
+	emailVerificationController := email_verification.NewEmailVerificationController(emailVerificationRepository, verificationSender, userRepository, &email_verification.VerifyValidator{}, logger)
+	userController := user.NewUserController(userRepository, &user.UserValidator{}, verificationSender, logger)
+	userAddressController := user_address.NewUserAddressController(user_address.NewMySQLUserAddressRepository(database), logger)
+	userDeliveryController := user_delivery.NewUserDeliveryController(user_delivery.NewMySQLUserDeliveryRepository(database), &user_delivery.UserDeliveryValidator{}, logger)
+
+	return []internal.IRouter{
+		address.NewAddressRouter(addressController),
+		auth.NewAuthRouter(authController, guard),
+		checkout.NewCheckoutRouter(checkoutController),
+		delivery.NewDeliveryRouter(deliveryController),
+		delivery_product.NewDeliveryProductRouter(deliveryProductController),
+		delivery_status.NewDeliveryStatusRouter(deliveryStatusController, guard),
+		email_verification.NewEmailVerificationRouter(emailVerificationController, guard),
+		inventory.NewInventoryRouter(inventoryController),
+		payment.NewPaymentRouter(paymentController),
+		product.NewProductRouter(productController),
+		refund.NewRefundRouter(refundController),
+		shopping_cart.NewShoppingCartRouter(shoppingCartController),
+		user.NewUserRouter(userController, guard),
+		user_address.NewUserAddressRouter(userAddressController),
+		user_delivery.NewUserDeliveryRouter(userDeliveryController),
+	}
+}
+
+func migrate(ctx context.Context) error {
+	migrator, err := migrations.NewMigrator(db.GetDB())
+	if err != nil {
+		return err
+	}
+	if err := migrator.EnsureClean(ctx); err != nil {
+		return err
+	}
+	applied, err := migrator.Up(ctx)
+	for _, migration := range applied {
+		log.Printf("Applied migration %04d_%s", migration.Version, migration.Name)
+	}
+	return err
+}
+
 func main() {
-	dsn := "user:password@tcp(mysql_db:3306)/sipub_test"
-	if err := db.InitializeDB(dsn); err != nil {
+	if err := run(); err != nil {
 		log.Fatal(err)
 	}
+}
+
+// Everything lives in run so the deferred cleanups execute before exiting,
+// log.Fatal inside of it would skip them.
+func run() error {
+	// Everything that used to be hardcoded here comes from the config now, see
+	// config/config.go for the env vars and flags
+	cfg, _, err := config.Load(os.Args[0], os.Args[1:], os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	level, _ := cfg.SlogLevel() // Already validated
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+
+	// Cancelled on the first SIGINT/SIGTERM, a second one kills the process
+	// because stop() restores the default behaviour
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := db.InitializeDB(cfg.Database.DSN, db.Options{
+		MaxOpenConns:    cfg.Database.MaxOpenConns,
+		MaxIdleConns:    cfg.Database.MaxIdleConns,
+		ConnMaxLifetime: cfg.Database.ConnMaxLifetime.Std(),
+		ConnMaxIdleTime: cfg.Database.ConnMaxIdleTime.Std(),
+		ConnectTimeout:  cfg.Database.ConnectTimeout.Std(),
+	}); err != nil {
+		return err
+	}
+	// Closed last, after the server stopped using it
 	defer db.CloseDB()
 
+	// The tables are no longer created by each repository, pending migrations
+	// are applied here. A dirty schema means a migration failed halfway, the
+	// server refuses to start until it is fixed by hand and cleared with
+	// migrate force.
+	if err := migrate(ctx); err != nil {
+		return err
+	}
+
 	corsHandler := cors.New(cors.Options{
-		AllowedOrigins: []string{"*"},
-		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE"},
-		AllowedHeaders: []string{"Content-Type"},
+		AllowedOrigins: cfg.CORS.AllowedOrigins,
+		AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+		AllowedHeaders: []string{"Content-Type", "Authorization", middleware.RequestIDHeader},
+		ExposedHeaders: []string{middleware.RequestIDHeader, pagination.TotalCountHeader, pagination.LinkHeader, "WWW-Authenticate"},
 	})
+	// Only the fake gateway exists for now, Validate refuses any other
+	gateway := payment_gateway.NewFakeGateway(cfg.Payment.FakeGateway.DeclinedCards, money.FromFloat(cfg.Payment.FakeGateway.DeclineAbove, money.HalfUp))
+
+	// Validate only accepts the local mailers for now
+	var mail mailer.IMailer = mailer.NewLogMailer(cfg.Mail.From, slog.Default())
+	if cfg.Mail.Mailer == "file" {
+		mail = mailer.NewFileMailer(cfg.Mail.From, cfg.Mail.Directory, clock.System{})
+	}
+
 	mux := http.NewServeMux()
-	RouterInitializeAll(mux,
-		address.NewAddressRouter(),
-		delivery.NewDeliveryRouter(),
-		delivery_product.NewDeliveryProductRouter(),
-		payment.NewPaymentRouter(),
-		product.NewProductRouter(),
-		shopping_cart.NewShoppingCartRouter(),
-		user.NewUserRouter(),
-		user_address.NewUserAddressRouter(),
-		user_delivery.NewUserDeliveryRouter(),
-	)
-	handler := corsHandler.Handler(mux)
-
-	log.Println("Starting server...")
-	http.ListenAndServe(portNum, handler)
+	RouterInitializeAll(mux, newRouters(db.GetDB(), clock.System{}, gateway, mail, cfg.Mail.VerificationTTL.Std(), cfg.Auth, slog.Default())...)
+	handler := corsHandler.Handler(middleware.RequestID(mux))
+
+	server := &http.Server{
+		Addr:         cfg.Server.Addr,
+		Handler:      handler,
+		ReadTimeout:  cfg.Server.ReadTimeout.Std(),
+		WriteTimeout: cfg.Server.WriteTimeout.Std(),
+		IdleTimeout:  cfg.Server.IdleTimeout.Std(),
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Printf("Starting server on %s...", cfg.Server.Addr)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		// Failed to start (port in use, etc), nothing to drain
+		return fmt.Errorf("server failed: %w", err)
+	case <-ctx.Done():
+		stop()
+		log.Println("Shutting down...")
+	}
+
+	// In-flight requests get until the deadline to finish
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout.Std())
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to drain requests: %w", err)
+	}
+	log.Println("Server stopped")
+	return nil
 }
@@ -0,0 +1,435 @@
+// Configuration of the binaries. Values are loaded with the following
+// precedence (the last one wins):
+//
+//	defaults < config file (YAML or JSON) < environment variables < flags
+//
+// The config file is optional, its path comes from the -config flag or the
+// SIPUB_CONFIG_FILE environment variable.
+
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"sipub-test/pkg/email"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gopkg.in/yaml.v3"
+)
+
+const configFileEnv = "SIPUB_CONFIG_FILE"
+
+type Config struct {
+	Server   ServerConfig   `yaml:"server" json:"server"`
+	Database DatabaseConfig `yaml:"database" json:"database"`
+	CORS     CORSConfig     `yaml:"cors" json:"cors"`
+	Payment  PaymentConfig  `yaml:"payment" json:"payment"`
+	Mail     MailConfig     `yaml:"mail" json:"mail"`
+	Auth     AuthConfig     `yaml:"auth" json:"auth"`
+	LogLevel string         `yaml:"logLevel" json:"logLevel"`
+}
+
+type ServerConfig struct {
+	Addr         string   `yaml:"addr" json:"addr"`
+	ReadTimeout  Duration `yaml:"readTimeout" json:"readTimeout"`
+	WriteTimeout Duration `yaml:"writeTimeout" json:"writeTimeout"`
+	IdleTimeout  Duration `yaml:"idleTimeout" json:"idleTimeout"`
+	// How long in-flight requests have to finish after a SIGINT/SIGTERM
+	ShutdownTimeout Duration `yaml:"shutdownTimeout" json:"shutdownTimeout"`
+}
+
+type DatabaseConfig struct {
+	DSN             string   `yaml:"dsn" json:"dsn"`
+	MaxOpenConns    int      `yaml:"maxOpenConns" json:"maxOpenConns"`
+	MaxIdleConns    int      `yaml:"maxIdleConns" json:"maxIdleConns"`
+	ConnMaxLifetime Duration `yaml:"connMaxLifetime" json:"connMaxLifetime"`
+	ConnMaxIdleTime Duration `yaml:"connMaxIdleTime" json:"connMaxIdleTime"`
+	ConnectTimeout  Duration `yaml:"connectTimeout" json:"connectTimeout"`
+}
+
+type CORSConfig struct {
+	AllowedOrigins []string `yaml:"allowedOrigins" json:"allowedOrigins"`
+}
+
+type PaymentConfig struct {
+	// Only "fake" exists for now, the real providers plug in through
+	// payment_gateway.IPaymentGateway
+	Gateway     string            `yaml:"gateway" json:"gateway"`
+	FakeGateway FakeGatewayConfig `yaml:"fakeGateway" json:"fakeGateway"`
+}
+
+// What the fake gateway refuses, see payment_gateway.FakeGateway
+type FakeGatewayConfig struct {
+	DeclinedCards []string `yaml:"declinedCards" json:"declinedCards"`
+	DeclineAbove  float64  `yaml:"declineAbove" json:"declineAbove"` // 0 accepts any amount
+}
+
+type MailConfig struct {
+	// "log" or "file", the real providers plug in through mailer.IMailer
+	Mailer    string `yaml:"mailer" json:"mailer"`
+	From      string `yaml:"from" json:"from"`
+	Directory string `yaml:"directory" json:"directory"` // Where the file mailer writes
+	// How long the token sent to verify an email works
+	VerificationTTL Duration `yaml:"verificationTTL" json:"verificationTTL"`
+}
+
+// Lifetimes of the tokens of auth
+type AuthConfig struct {
+	AccessTokenTTL   Duration `yaml:"accessTokenTTL" json:"accessTokenTTL"`
+	RefreshTokenTTL  Duration `yaml:"refreshTokenTTL" json:"refreshTokenTTL"` // Extended by every refresh
+	PasswordResetTTL Duration `yaml:"passwordResetTTL" json:"passwordResetTTL"`
+}
+
+// time.Duration that can be written as "15s" in the config file
+type Duration time.Duration
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d Duration) Std() time.Duration { return time.Duration(d) }
+
+// The defaults match the docker-compose setup
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Addr:            ":8080",
+			ReadTimeout:     Duration(10 * time.Second),
+			WriteTimeout:    Duration(15 * time.Second),
+			IdleTimeout:     Duration(60 * time.Second),
+			ShutdownTimeout: Duration(20 * time.Second),
+		},
+		Database: DatabaseConfig{
+			DSN:             "user:password@tcp(mysql_db:3306)/sipub_test",
+			MaxOpenConns:    25,
+			MaxIdleConns:    25,
+			ConnMaxLifetime: Duration(5 * time.Minute),
+			ConnMaxIdleTime: Duration(5 * time.Minute),
+			ConnectTimeout:  Duration(10 * time.Second),
+		},
+		CORS: CORSConfig{
+			AllowedOrigins: []string{"http://localhost:3010"},
+		},
+		Payment: PaymentConfig{
+			Gateway: "fake",
+			FakeGateway: FakeGatewayConfig{
+				DeclinedCards: []string{"4000000000000002"},
+			},
+		},
+		Mail: MailConfig{
+			Mailer:          "log",
+			From:            "no-reply@sipub.local",
+			Directory:       "mail",
+			VerificationTTL: Duration(24 * time.Hour),
+		},
+		Auth: AuthConfig{
+			AccessTokenTTL:   Duration(15 * time.Minute),
+			RefreshTokenTTL:  Duration(30 * 24 * time.Hour),
+			PasswordResetTTL: Duration(time.Hour),
+		},
+		LogLevel: "info",
+	}
+}
+
+// Each setting can be set through an environment variable and a flag
+type setting struct {
+	flag  string
+	env   string
+	usage string
+	set   func(c *Config, value string) error
+}
+
+var settings = []setting{
+	{"addr", "SIPUB_HTTP_ADDR", "address the server listens on", func(c *Config, v string) error {
+		c.Server.Addr = v
+		return nil
+	}},
+	{"read-timeout", "SIPUB_HTTP_READ_TIMEOUT", "maximum duration for reading a request", func(c *Config, v string) error {
+		return c.Server.ReadTimeout.UnmarshalText([]byte(v))
+	}},
+	{"write-timeout", "SIPUB_HTTP_WRITE_TIMEOUT", "maximum duration for writing a response", func(c *Config, v string) error {
+		return c.Server.WriteTimeout.UnmarshalText([]byte(v))
+	}},
+	{"idle-timeout", "SIPUB_HTTP_IDLE_TIMEOUT", "maximum duration of an idle keep-alive connection", func(c *Config, v string) error {
+		return c.Server.IdleTimeout.UnmarshalText([]byte(v))
+	}},
+	{"shutdown-timeout", "SIPUB_HTTP_SHUTDOWN_TIMEOUT", "maximum duration to drain in-flight requests on shutdown", func(c *Config, v string) error {
+		return c.Server.ShutdownTimeout.UnmarshalText([]byte(v))
+	}},
+	{"dsn", "SIPUB_DB_DSN", "MySQL data source name", func(c *Config, v string) error {
+		c.Database.DSN = v
+		return nil
+	}},
+	{"db-max-open-conns", "SIPUB_DB_MAX_OPEN_CONNS", "maximum number of open database connections (0 is unlimited)", func(c *Config, v string) error {
+		return parseInt(v, &c.Database.MaxOpenConns)
+	}},
+	{"db-max-idle-conns", "SIPUB_DB_MAX_IDLE_CONNS", "maximum number of idle database connections", func(c *Config, v string) error {
+		return parseInt(v, &c.Database.MaxIdleConns)
+	}},
+	{"db-conn-max-lifetime", "SIPUB_DB_CONN_MAX_LIFETIME", "maximum duration a database connection is reused", func(c *Config, v string) error {
+		return c.Database.ConnMaxLifetime.UnmarshalText([]byte(v))
+	}},
+	{"db-conn-max-idle-time", "SIPUB_DB_CONN_MAX_IDLE_TIME", "maximum duration a database connection stays idle", func(c *Config, v string) error {
+		return c.Database.ConnMaxIdleTime.UnmarshalText([]byte(v))
+	}},
+	{"db-connect-timeout", "SIPUB_DB_CONNECT_TIMEOUT", "maximum duration to wait for the database on startup", func(c *Config, v string) error {
+		return c.Database.ConnectTimeout.UnmarshalText([]byte(v))
+	}},
+	{"cors-allowed-origins", "SIPUB_CORS_ALLOWED_ORIGINS", "comma separated list of allowed origins", func(c *Config, v string) error {
+		c.CORS.AllowedOrigins = splitList(v)
+		return nil
+	}},
+	{"payment-gateway", "SIPUB_PAYMENT_GATEWAY", "payment provider, only fake for now", func(c *Config, v string) error {
+		c.Payment.Gateway = v
+		return nil
+	}},
+	{"fake-gateway-declined-cards", "SIPUB_FAKE_GATEWAY_DECLINED_CARDS", "comma separated card numbers the fake gateway declines", func(c *Config, v string) error {
+		c.Payment.FakeGateway.DeclinedCards = splitList(v)
+		return nil
+	}},
+	{"fake-gateway-decline-above", "SIPUB_FAKE_GATEWAY_DECLINE_ABOVE", "amounts the fake gateway declines (0 accepts any amount)", func(c *Config, v string) error {
+		return parseFloat(v, &c.Payment.FakeGateway.DeclineAbove)
+	}},
+	{"mailer", "SIPUB_MAILER", "where emails go, log or file", func(c *Config, v string) error {
+		c.Mail.Mailer = v
+		return nil
+	}},
+	{"mail-from", "SIPUB_MAIL_FROM", "sender address of the emails", func(c *Config, v string) error {
+		c.Mail.From = v
+		return nil
+	}},
+	{"mail-directory", "SIPUB_MAIL_DIRECTORY", "directory the file mailer writes the emails to", func(c *Config, v string) error {
+		c.Mail.Directory = v
+		return nil
+	}},
+	{"email-verification-ttl", "SIPUB_EMAIL_VERIFICATION_TTL", "how long an email verification token works", func(c *Config, v string) error {
+		return c.Mail.VerificationTTL.UnmarshalText([]byte(v))
+	}},
+	{"access-token-ttl", "SIPUB_ACCESS_TOKEN_TTL", "how long an access token works", func(c *Config, v string) error {
+		return c.Auth.AccessTokenTTL.UnmarshalText([]byte(v))
+	}},
+	{"refresh-token-ttl", "SIPUB_REFRESH_TOKEN_TTL", "how long a session lasts without being refreshed", func(c *Config, v string) error {
+		return c.Auth.RefreshTokenTTL.UnmarshalText([]byte(v))
+	}},
+	{"password-reset-ttl", "SIPUB_PASSWORD_RESET_TTL", "how long a password reset token works", func(c *Config, v string) error {
+		return c.Auth.PasswordResetTTL.UnmarshalText([]byte(v))
+	}},
+	{"log-level", "SIPUB_LOG_LEVEL", "debug, info, warn or error", func(c *Config, v string) error {
+		c.LogLevel = v
+		return nil
+	}},
+}
+
+func parseInt(value string, target *int) error {
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("invalid integer %q", value)
+	}
+	*target = parsed
+	return nil
+}
+
+func parseFloat(value string, target *float64) error {
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("invalid number %q", value)
+	}
+	*target = parsed
+	return nil
+}
+
+func splitList(value string) []string {
+	list := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// Loads the configuration and returns it with the arguments that are left
+// after the flags (used by the migrate command). lookupEnv is usually
+// os.LookupEnv, it is a parameter so tests don't depend on the environment.
+func Load(name string, args []string, lookupEnv func(string) (string, bool)) (Config, []string, error) {
+	cfg := Default()
+
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	configFile := flags.String("config", "", "path to a YAML or JSON config file (env "+configFileEnv+")")
+	values := map[string]*string{}
+	for _, s := range settings {
+		values[s.flag] = flags.String(s.flag, "", s.usage+" (env "+s.env+")")
+	}
+	if err := flags.Parse(args); err != nil {
+		return Config{}, nil, err
+	}
+
+	// Config file
+	path := *configFile
+	if path == "" {
+		path, _ = lookupEnv(configFileEnv)
+	}
+	if path != "" {
+		if err := loadFile(path, &cfg); err != nil {
+			return Config{}, nil, err
+		}
+	}
+
+	// Environment variables
+	for _, s := range settings {
+		if value, ok := lookupEnv(s.env); ok && value != "" {
+			if err := s.set(&cfg, value); err != nil {
+				return Config{}, nil, fmt.Errorf("invalid %s: %w", s.env, err)
+			}
+		}
+	}
+
+	// Flags, only the ones that were explicitly passed
+	var flagErr error
+	flags.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.flag == f.Name {
+				if err := s.set(&cfg, *values[s.flag]); err != nil {
+					flagErr = errors.Join(flagErr, fmt.Errorf("invalid -%s: %w", s.flag, err))
+				}
+			}
+		}
+	})
+	if flagErr != nil {
+		return Config{}, nil, flagErr
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, nil, err
+	}
+	return cfg, flags.Args(), nil
+}
+
+func loadFile(path string, cfg *Config) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, cfg)
+	case ".json":
+		err = json.Unmarshal(content, cfg)
+	default:
+		return fmt.Errorf("unsupported config file extension: %s", path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse config file: %w", err)
+	}
+	return nil
+}
+
+// Returns every invalid value at once, so they can all be fixed in one go
+func (c Config) Validate() error {
+	var errs []error
+
+	if _, _, err := net.SplitHostPort(c.Server.Addr); err != nil {
+		errs = append(errs, fmt.Errorf("server.addr: %w", err))
+	}
+	if c.Server.ReadTimeout <= 0 {
+		errs = append(errs, errors.New("server.readTimeout must be positive"))
+	}
+	if c.Server.WriteTimeout <= 0 {
+		errs = append(errs, errors.New("server.writeTimeout must be positive"))
+	}
+	if c.Server.IdleTimeout <= 0 {
+		errs = append(errs, errors.New("server.idleTimeout must be positive"))
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("server.shutdownTimeout must be positive"))
+	}
+
+	if _, err := mysql.ParseDSN(c.Database.DSN); err != nil {
+		errs = append(errs, fmt.Errorf("database.dsn: %w", err))
+	}
+	if c.Database.MaxOpenConns < 0 {
+		errs = append(errs, errors.New("database.maxOpenConns can't be negative"))
+	}
+	if c.Database.MaxIdleConns < 0 {
+		errs = append(errs, errors.New("database.maxIdleConns can't be negative"))
+	}
+	if c.Database.MaxOpenConns > 0 && c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		errs = append(errs, errors.New("database.maxIdleConns can't be greater than database.maxOpenConns"))
+	}
+	if c.Database.ConnMaxLifetime < 0 {
+		errs = append(errs, errors.New("database.connMaxLifetime can't be negative"))
+	}
+	if c.Database.ConnMaxIdleTime < 0 {
+		errs = append(errs, errors.New("database.connMaxIdleTime can't be negative"))
+	}
+	if c.Database.ConnectTimeout <= 0 {
+		errs = append(errs, errors.New("database.connectTimeout must be positive"))
+	}
+
+	if len(c.CORS.AllowedOrigins) == 0 {
+		errs = append(errs, errors.New("cors.allowedOrigins can't be empty"))
+	}
+
+	if c.Payment.Gateway != "fake" {
+		errs = append(errs, fmt.Errorf("payment.gateway: %q is not one of fake", c.Payment.Gateway))
+	}
+	if c.Payment.FakeGateway.DeclineAbove < 0 {
+		errs = append(errs, errors.New("payment.fakeGateway.declineAbove can't be negative"))
+	}
+
+	if c.Mail.Mailer != "log" && c.Mail.Mailer != "file" {
+		errs = append(errs, fmt.Errorf("mail.mailer: %q is not one of log or file", c.Mail.Mailer))
+	}
+	if _, err := email.Normalize(c.Mail.From); err != nil {
+		errs = append(errs, fmt.Errorf("mail.from: %w", err))
+	}
+	if c.Mail.Mailer == "file" && c.Mail.Directory == "" {
+		errs = append(errs, errors.New("mail.directory can't be empty with the file mailer"))
+	}
+	if c.Mail.VerificationTTL <= 0 {
+		errs = append(errs, errors.New("mail.verificationTTL must be positive"))
+	}
+
+	if c.Auth.AccessTokenTTL <= 0 {
+		errs = append(errs, errors.New("auth.accessTokenTTL must be positive"))
+	}
+	if c.Auth.RefreshTokenTTL < c.Auth.AccessTokenTTL {
+		errs = append(errs, errors.New("auth.refreshTokenTTL can't be shorter than auth.accessTokenTTL"))
+	}
+	if c.Auth.PasswordResetTTL <= 0 {
+		errs = append(errs, errors.New("auth.passwordResetTTL must be positive"))
+	}
+
+	if _, err := c.SlogLevel(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c Config) SlogLevel() (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		return 0, fmt.Errorf("logLevel: %q is not one of debug, info, warn or error", c.LogLevel)
+	}
+	return level, nil
+}
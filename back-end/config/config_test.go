@@ -0,0 +1,111 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"sipub-test/config"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Builds a lookupEnv function out of a map, so the tests don't read the real
+// environment
+func envFrom(values map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := values[key]
+		return value, ok
+	}
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("ShouldUseDefaults", func(t *testing.T) {
+		cfg, args, err := config.Load("test", []string{}, envFrom(nil))
+
+		assert.NoError(t, err, "Defaults should be valid")
+		assert.Equal(t, config.Default(), cfg)
+		assert.Empty(t, args)
+	})
+	t.Run("ShouldApplyPrecedence", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		content := "server:\n  addr: \":9000\"\n  readTimeout: 3s\ndatabase:\n  maxOpenConns: 10\n  maxIdleConns: 5\nlogLevel: warn\n"
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("failed to write config file: %v", err)
+		}
+		env := envFrom(map[string]string{
+			"SIPUB_CONFIG_FILE":       path,
+			"SIPUB_HTTP_ADDR":         ":9001",
+			"SIPUB_DB_MAX_OPEN_CONNS": "20",
+		})
+
+		cfg, args, err := config.Load("test", []string{"-addr", ":9002", "up"}, env)
+
+		assert.NoError(t, err)
+		assert.Equal(t, ":9002", cfg.Server.Addr, "Flags should override env vars")
+		assert.Equal(t, 20, cfg.Database.MaxOpenConns, "Env vars should override the file")
+		assert.Equal(t, 3*time.Second, cfg.Server.ReadTimeout.Std(), "The file should override defaults")
+		assert.Equal(t, "warn", cfg.LogLevel)
+		assert.Equal(t, 15*time.Second, cfg.Server.WriteTimeout.Std(), "Missing values should keep the default")
+		assert.Equal(t, []string{"up"}, args, "Positional arguments should be returned")
+	})
+	t.Run("ShouldLoadJSONFile", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.json")
+		content := `{"cors": {"allowedOrigins": ["https://a.com", "https://b.com"]}}`
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("failed to write config file: %v", err)
+		}
+
+		cfg, _, err := config.Load("test", []string{"-config", path}, envFrom(nil))
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"https://a.com", "https://b.com"}, cfg.CORS.AllowedOrigins)
+	})
+	t.Run("ShouldSplitOriginsList", func(t *testing.T) {
+		env := envFrom(map[string]string{"SIPUB_CORS_ALLOWED_ORIGINS": "https://a.com, https://b.com,"})
+
+		cfg, _, err := config.Load("test", []string{}, env)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"https://a.com", "https://b.com"}, cfg.CORS.AllowedOrigins)
+	})
+	t.Run("ShouldRejectInvalidValues", func(t *testing.T) {
+		_, _, err := config.Load("test", []string{"-read-timeout", "soon"}, envFrom(nil))
+		assert.Error(t, err, "Invalid durations should be rejected")
+
+		_, _, err = config.Load("test", []string{}, envFrom(map[string]string{"SIPUB_DB_MAX_OPEN_CONNS": "many"}))
+		assert.Error(t, err, "Invalid integers should be rejected")
+
+		_, _, err = config.Load("test", []string{"-fake-gateway-decline-above", "lots"}, envFrom(nil))
+		assert.Error(t, err, "Invalid numbers should be rejected")
+
+		_, _, err = config.Load("test", []string{"-config", "config.toml"}, envFrom(nil))
+		assert.Error(t, err, "Unknown file formats should be rejected")
+	})
+}
+
+func TestValidate(t *testing.T) {
+	t.Run("ShouldReportEveryInvalidValue", func(t *testing.T) {
+		cfg := config.Default()
+		cfg.Server.Addr = "8080"
+		cfg.Database.DSN = "not a dsn"
+		cfg.Database.MaxOpenConns = 5
+		cfg.Database.MaxIdleConns = 10
+		cfg.CORS.AllowedOrigins = []string{}
+		cfg.Payment.Gateway = "stripe"
+		cfg.Mail.Mailer = "smtp"
+		cfg.Mail.From = "no-reply"
+		cfg.Mail.VerificationTTL = 0
+		cfg.Auth.AccessTokenTTL = config.Duration(time.Hour)
+		cfg.Auth.RefreshTokenTTL = config.Duration(time.Minute)
+		cfg.Auth.PasswordResetTTL = 0
+		cfg.LogLevel = "verbose"
+
+		err := cfg.Validate()
+
+		assert.Error(t, err)
+		for _, field := range []string{"server.addr", "database.dsn", "database.maxIdleConns", "cors.allowedOrigins", "payment.gateway", "mail.mailer", "mail.from", "mail.verificationTTL", "auth.refreshTokenTTL", "auth.passwordResetTTL", "logLevel"} {
+			assert.Contains(t, err.Error(), field)
+		}
+	})
+}
@@ -1,8 +1,9 @@
-// This file handles the db and context with a singleton approach, perhaps not
-// the best with clean-architecture, but it gives nice results fast. Ideally
-// the db and ctx should be injected through a dependency of each repository,
-// and/or usecase but that would introduce more boilerplate and even more
-// complexity to an already somewhat complex architecture.
+// Opens and closes the connection pool of the process. Only the entry points
+// (cmd/sipub-test and cmd/migrate) call GetDB, main hands the pool to
+// newRouters, which injects it into every repository and the unit of work.
+//
+// There is no global context, every repository method receives the context of
+// the request so a client disconnecting (or a deadline) cancels the query.
 
 package db
 
@@ -11,23 +12,51 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 )
 
-var (
-	db  *sql.DB
-	ctx context.Context
-)
+var db *sql.DB
+
+// Connection pool settings, a zero value keeps the database/sql default
+type Options struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+	ConnectTimeout  time.Duration // How long the first ping can take
+}
 
-func InitializeDB(dsn string) error {
-	var err error
-	db, err = sql.Open("mysql", dsn)
+func InitializeDB(dsn string, options Options) error {
+	// The timestamps are written and read as UTC whatever the DSN says, the
+	// DATETIME columns don't keep the zone
+	config, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return fmt.Errorf("invalid database dsn: %v", err)
+	}
+	config.Loc = time.UTC
+	config.ParseTime = true
+
+	db, err = sql.Open("mysql", config.FormatDSN())
 	if err != nil {
 		return fmt.Errorf("failed to open database: %v", err)
 	}
 
-	if err = db.Ping(); err != nil {
+	db.SetMaxOpenConns(options.MaxOpenConns)
+	if options.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(options.MaxIdleConns)
+	}
+	db.SetConnMaxLifetime(options.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(options.ConnMaxIdleTime)
+
+	pingCtx := context.Background()
+	if options.ConnectTimeout > 0 {
+		var cancel context.CancelFunc
+		pingCtx, cancel = context.WithTimeout(pingCtx, options.ConnectTimeout)
+		defer cancel()
+	}
+	if err = db.PingContext(pingCtx); err != nil {
 		return fmt.Errorf("failed to ping database: %v", err)
 	}
 
@@ -44,12 +73,3 @@ func CloseDB() {
 		log.Printf("Failed to close database: %v", err)
 	}
 }
-
-// https://pkg.go.dev/context#Context
-func InitializeContext() {
-	ctx = context.Background()
-}
-
-func GetContext() context.Context {
-	return ctx
-}
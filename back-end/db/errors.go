@@ -0,0 +1,69 @@
+package db
+
+import (
+	"errors"
+	"regexp"
+	"sipub-test/pkg/apperror"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// https://dev.mysql.com/doc/mysql-errors/8.0/en/server-error-reference.html
+const (
+	mysqlDuplicateEntry     = 1062 // ER_DUP_ENTRY
+	mysqlRowIsReferenced    = 1451 // ER_ROW_IS_REFERENCED_2, deleting a parent
+	mysqlNoReferencedRow    = 1452 // ER_NO_REFERENCED_ROW_2, inserting an orphan
+	mysqlRowIsReferencedOld = 1217 // Same as 1451 on older servers
+	mysqlNoReferencedRowOld = 1216 // Same as 1452 on older servers
+)
+
+// Keeps the driver error (for the logs) while only exposing the message of
+// the sentinel, the raw MySQL message contains table and constraint names
+type TranslatedError struct {
+	Kind  error
+	Cause error
+	Key   string // Unique key of a duplicate entry, without the table
+}
+
+// Duplicate entry 'a@a.com' for key 'users.uq_users_email', older servers
+// leave the table out
+var duplicateKeyPattern = regexp.MustCompile(`for key '([^']+)'`)
+
+func (e *TranslatedError) Error() string { return e.Kind.Error() }
+
+func (e *TranslatedError) Unwrap() []error { return []error{e.Kind, e.Cause} }
+
+// Converts the MySQL errors the repositories care about into the apperror
+// sentinels, anything else is returned untouched
+func TranslateError(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return err
+	}
+	switch mysqlErr.Number {
+	case mysqlDuplicateEntry:
+		return &TranslatedError{Kind: apperror.ErrDuplicate, Cause: err, Key: duplicateKey(mysqlErr.Message)}
+	case mysqlRowIsReferenced, mysqlNoReferencedRow, mysqlRowIsReferencedOld, mysqlNoReferencedRowOld:
+		return &TranslatedError{Kind: apperror.ErrForeignKey, Cause: err}
+	}
+	return err
+}
+
+func duplicateKey(message string) string {
+	match := duplicateKeyPattern.FindStringSubmatch(message)
+	if match == nil {
+		return ""
+	}
+	return match[1][strings.LastIndex(match[1], ".")+1:]
+}
+
+// The unique key a translated duplicate entry violated, empty for any other
+// error. Lets a table with many unique keys tell which value is taken
+func DuplicateKey(err error) string {
+	var translated *TranslatedError
+	if errors.As(err, &translated) && errors.Is(translated.Kind, apperror.ErrDuplicate) {
+		return translated.Key
+	}
+	return ""
+}
@@ -0,0 +1,51 @@
+package db_test
+
+import (
+	"errors"
+	"fmt"
+	"sipub-test/db"
+	"sipub-test/pkg/apperror"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTranslateError(t *testing.T) {
+	t.Run("ShouldTranslateDuplicateEntry", func(t *testing.T) {
+		driverErr := &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'a@a.com' for key 'users.email'"}
+
+		err := db.TranslateError(driverErr)
+
+		assert.ErrorIs(t, err, apperror.ErrDuplicate)
+		assert.ErrorIs(t, err, driverErr, "The driver error should be kept for the logs")
+		assert.NotContains(t, err.Error(), "users.email", "The message shouldn't expose the schema")
+	})
+	t.Run("ShouldKeepTheDuplicateKey", func(t *testing.T) {
+		for message, expected := range map[string]string{
+			"Duplicate entry 'a@a.com' for key 'users.uq_users_email'": "uq_users_email",
+			"Duplicate entry 'a@a.com' for key 'uq_users_email'":       "uq_users_email",
+			"Duplicate entry": "",
+		} {
+			err := db.TranslateError(fmt.Errorf("failed: %w", &mysql.MySQLError{Number: 1062, Message: message}))
+
+			assert.Equal(t, expected, db.DuplicateKey(fmt.Errorf("wrapped: %w", err)), message)
+		}
+		assert.Empty(t, db.DuplicateKey(errors.New("connection refused")))
+	})
+	t.Run("ShouldTranslateForeignKeys", func(t *testing.T) {
+		for _, number := range []uint16{1451, 1452} {
+			err := db.TranslateError(fmt.Errorf("failed: %w", &mysql.MySQLError{Number: number}))
+
+			assert.ErrorIs(t, err, apperror.ErrForeignKey, "Error %d should be a foreign key error", number)
+		}
+	})
+	t.Run("ShouldKeepOtherErrors", func(t *testing.T) {
+		driverErr := &mysql.MySQLError{Number: 1146, Message: "Table doesn't exist"}
+		otherErr := errors.New("connection refused")
+
+		assert.Equal(t, driverErr, db.TranslateError(driverErr))
+		assert.Equal(t, otherErr, db.TranslateError(otherErr))
+		assert.Nil(t, db.TranslateError(nil))
+	})
+}
@@ -0,0 +1,41 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// What the repositories need to run their queries. Both *sql.DB and *sql.Tx
+// implement it, so the same repository can run on its own or as part of a
+// transaction (see internal/unit_of_work)
+type Executor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Runs fn in a transaction. When the executor already is one, as in the
+// repositories built by the unit of work, fn joins it instead: its writes
+// commit or roll back with the rest of the unit of work
+func WithTx(ctx context.Context, executor Executor, fn func(tx Executor) error) error {
+	database, ok := executor.(interface {
+		BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+	})
+	if !ok {
+		return fn(executor)
+	}
+
+	tx, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", TranslateError(err))
+	}
+	return nil
+}
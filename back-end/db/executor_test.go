@@ -0,0 +1,66 @@
+package db_test
+
+import (
+	"context"
+	"errors"
+	"sipub-test/db"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithTx(t *testing.T) {
+	t.Run("ShouldCommitOnSuccess", func(t *testing.T) {
+		database, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer database.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE products`).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err = db.WithTx(context.Background(), database, func(tx db.Executor) error {
+			_, err := tx.ExecContext(context.Background(), `UPDATE products SET stock = 1`)
+			return err
+		})
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("ShouldRollBackOnError", func(t *testing.T) {
+		database, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer database.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+
+		failure := errors.New("failure")
+		err = db.WithTx(context.Background(), database, func(tx db.Executor) error {
+			return failure
+		})
+
+		assert.ErrorIs(t, err, failure, "The error of fn should be returned as is")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("ShouldJoinTheCurrentTransaction", func(t *testing.T) {
+		database, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer database.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE products`).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		tx, err := database.Begin()
+		assert.NoError(t, err)
+		err = db.WithTx(context.Background(), tx, func(inner db.Executor) error {
+			assert.Same(t, tx, inner)
+			_, err := inner.ExecContext(context.Background(), `UPDATE products SET stock = 1`)
+			return err
+		})
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet(), "The caller's transaction should be left open")
+	})
+}
@@ -0,0 +1,341 @@
+// Versioned schema migrations. Each migration is a pair of files inside sql/
+// named NNNN_description.up.sql and NNNN_description.down.sql, the number is
+// the version and it defines the order in which they are applied. The applied
+// versions are tracked in the schema_migrations table.
+//
+// MySQL can't run DDL inside a transaction, so a migration is marked as dirty
+// before it runs and only cleaned after all of its statements succeed. A dirty
+// schema means something failed halfway and it has to be fixed by hand, then
+// cleared with Force, before the server is allowed to start again.
+
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+var (
+	ErrDirty = errors.New("schema is dirty")
+
+	fileNamePattern = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+)
+
+const (
+	// Used with GET_LOCK so two instances don't migrate at the same time
+	lockName    = "schema_migrations"
+	lockTimeout = 10 // seconds
+
+	createTrackingTableQuery = `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INT UNSIGNED NOT NULL,
+		name VARCHAR(255) NOT NULL,
+		dirty BOOLEAN NOT NULL DEFAULT TRUE,
+		appliedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (version)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`
+)
+
+type Migration struct {
+	Version uint
+	Name    string
+	Up      []string // Statements, executed in order
+	Down    []string
+}
+
+// Used to report which migrations were applied
+type MigrationStatus struct {
+	Version uint
+	Name    string
+	Applied bool
+	Dirty   bool
+}
+
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+func NewMigrator(db *sql.DB) (*Migrator, error) {
+	migrations, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Reads the embedded migrations and returns them sorted by version. Every
+// version must have both an up and a down file.
+func Load() ([]Migration, error) {
+	entries, err := fs.ReadDir(files, "sql")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := map[uint]*Migration{}
+	for _, entry := range entries {
+		matches := fileNamePattern.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			return nil, fmt.Errorf("invalid migration file name: %s", entry.Name())
+		}
+		version, err := strconv.ParseUint(matches[1], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version: %s", entry.Name())
+		}
+		content, err := fs.ReadFile(files, "sql/"+entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		migration, exists := byVersion[uint(version)]
+		if !exists {
+			migration = &Migration{Version: uint(version), Name: matches[2]}
+			byVersion[uint(version)] = migration
+		}
+		if migration.Name != matches[2] {
+			return nil, fmt.Errorf("migration %d has two different names", version)
+		}
+		if matches[3] == "up" {
+			migration.Up = splitStatements(string(content))
+		} else {
+			migration.Down = splitStatements(string(content))
+		}
+	}
+
+	migrations := []Migration{}
+	for _, migration := range byVersion {
+		if migration.Up == nil || migration.Down == nil {
+			return nil, fmt.Errorf("migration %d is missing its up or down file", migration.Version)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Removes the comment lines and splits the file on ";". None of the migrations
+// use ";" inside of a string, so there is no need for a real parser.
+func splitStatements(content string) []string {
+	var builder strings.Builder
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "--") {
+			continue
+		}
+		builder.WriteString(line + "\n")
+	}
+
+	statements := []string{}
+	for _, statement := range strings.Split(builder.String(), ";") {
+		if statement = strings.TrimSpace(statement); statement != "" {
+			statements = append(statements, statement)
+		}
+	}
+	return statements
+}
+
+// Applies every pending migration and returns the ones that were applied
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	applied := []Migration{}
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		states, err := m.states(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			dirty, exists := states[migration.Version]
+			if exists && dirty {
+				return fmt.Errorf("%w: version %d", ErrDirty, migration.Version)
+			}
+			if exists {
+				continue
+			}
+			if err := m.apply(ctx, conn, migration); err != nil {
+				return err
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Reverts the last `steps` applied migrations and returns the reverted ones
+func (m *Migrator) Down(ctx context.Context, steps uint) ([]Migration, error) {
+	reverted := []Migration{}
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		states, err := m.states(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && uint(len(reverted)) < steps; i-- {
+			migration := m.migrations[i]
+			dirty, exists := states[migration.Version]
+			if !exists {
+				continue
+			}
+			if dirty {
+				return fmt.Errorf("%w: version %d", ErrDirty, migration.Version)
+			}
+			if err := m.revert(ctx, conn, migration); err != nil {
+				return err
+			}
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Returns the state of every known migration
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	states, err := m.states(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	status := []MigrationStatus{}
+	for _, migration := range m.migrations {
+		dirty, applied := states[migration.Version]
+		status = append(status, MigrationStatus{
+			Version: migration.Version,
+			Name:    migration.Name,
+			Applied: applied,
+			Dirty:   dirty,
+		})
+	}
+	return status, nil
+}
+
+// Marks a migration as applied and clean without running it, once a dirty one
+// was finished by hand. Returns the forced migration
+func (m *Migrator) Force(ctx context.Context, version uint) (Migration, error) {
+	var forced *Migration
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			forced = &m.migrations[i]
+		}
+	}
+	if forced == nil {
+		return Migration{}, fmt.Errorf("unknown migration version %d", version)
+	}
+
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		if _, err := conn.ExecContext(ctx, createTrackingTableQuery); err != nil {
+			return fmt.Errorf("failed to create schema_migrations: %w", err)
+		}
+		query := `INSERT INTO schema_migrations (version, name, dirty) VALUES (?, ?, FALSE) ON DUPLICATE KEY UPDATE dirty = FALSE`
+		if _, err := conn.ExecContext(ctx, query, forced.Version, forced.Name); err != nil {
+			return fmt.Errorf("failed to force migration %d: %w", forced.Version, err)
+		}
+		return nil
+	})
+	return *forced, err
+}
+
+// Returns ErrDirty if any migration failed halfway
+func (m *Migrator) EnsureClean(ctx context.Context) error {
+	status, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	for _, s := range status {
+		if s.Dirty {
+			return fmt.Errorf("%w: version %d (%s) needs to be fixed manually and forced", ErrDirty, s.Version, s.Name)
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, migration Migration) error {
+	insertQuery := `INSERT INTO schema_migrations (version, name, dirty) VALUES (?, ?, TRUE)`
+	if _, err := conn.ExecContext(ctx, insertQuery, migration.Version, migration.Name); err != nil {
+		return fmt.Errorf("failed to mark migration %d: %w", migration.Version, err)
+	}
+	for _, statement := range migration.Up {
+		if _, err := conn.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("failed to apply migration %d (%s): %w", migration.Version, migration.Name, err)
+		}
+	}
+	cleanQuery := `UPDATE schema_migrations SET dirty = FALSE WHERE version = ?`
+	if _, err := conn.ExecContext(ctx, cleanQuery, migration.Version); err != nil {
+		return fmt.Errorf("failed to clean migration %d: %w", migration.Version, err)
+	}
+	return nil
+}
+
+func (m *Migrator) revert(ctx context.Context, conn *sql.Conn, migration Migration) error {
+	dirtyQuery := `UPDATE schema_migrations SET dirty = TRUE WHERE version = ?`
+	if _, err := conn.ExecContext(ctx, dirtyQuery, migration.Version); err != nil {
+		return fmt.Errorf("failed to mark migration %d: %w", migration.Version, err)
+	}
+	for _, statement := range migration.Down {
+		if _, err := conn.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("failed to revert migration %d (%s): %w", migration.Version, migration.Name, err)
+		}
+	}
+	deleteQuery := `DELETE FROM schema_migrations WHERE version = ?`
+	if _, err := conn.ExecContext(ctx, deleteQuery, migration.Version); err != nil {
+		return fmt.Errorf("failed to remove migration %d: %w", migration.Version, err)
+	}
+	return nil
+}
+
+// Returns version : dirty for every applied (or partially applied) migration
+func (m *Migrator) states(ctx context.Context, conn *sql.Conn) (map[uint]bool, error) {
+	if _, err := conn.ExecContext(ctx, createTrackingTableQuery); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	rows, err := conn.QueryContext(ctx, `SELECT version, dirty FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	states := map[uint]bool{}
+	for rows.Next() {
+		var version uint
+		var dirty bool
+		if err := rows.Scan(&version, &dirty); err != nil {
+			return nil, fmt.Errorf("failed to scan schema_migrations: %w", err)
+		}
+		states[version] = dirty
+	}
+	return states, rows.Err()
+}
+
+// GET_LOCK is bound to the connection, so everything that runs while holding
+// it has to use the same *sql.Conn
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	var locked sql.NullInt64
+	if err := conn.QueryRowContext(ctx, `SELECT GET_LOCK(?, ?)`, lockName, lockTimeout).Scan(&locked); err != nil {
+		return fmt.Errorf("failed to lock schema_migrations: %w", err)
+	}
+	if !locked.Valid || locked.Int64 != 1 {
+		return errors.New("failed to lock schema_migrations: another migration is running")
+	}
+	defer conn.ExecContext(context.Background(), `SELECT RELEASE_LOCK(?)`, lockName)
+
+	return fn(conn)
+}
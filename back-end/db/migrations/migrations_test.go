@@ -0,0 +1,163 @@
+package migrations_test
+
+import (
+	"context"
+	"regexp"
+	"sipub-test/db/migrations"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	loaded, err := migrations.Load()
+
+	assert.NoError(t, err, "Embedded migrations should be valid")
+	assert.NotEmpty(t, loaded)
+	for i, migration := range loaded {
+		// Versions should be sequential, starting at 1
+		assert.Equal(t, uint(i+1), migration.Version, "Versions should be sequential")
+		assert.NotEmpty(t, migration.Up, "Up should contain statements")
+		assert.NotEmpty(t, migration.Down, "Down should contain statements")
+	}
+}
+
+func TestMigratorUp(t *testing.T) {
+	t.Run("ShouldApplyOnlyPendingMigrations", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		loaded, _ := migrations.Load()
+		last := loaded[len(loaded)-1]
+
+		// Everything but the last migration was applied
+		applied := sqlmock.NewRows([]string{"version", "dirty"})
+		for _, migration := range loaded[:len(loaded)-1] {
+			applied.AddRow(migration.Version, false)
+		}
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT GET_LOCK(?, ?)`)).
+			WillReturnRows(sqlmock.NewRows([]string{"lock"}).AddRow(1))
+		mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations`).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(`SELECT version, dirty FROM schema_migrations`).
+			WillReturnRows(applied)
+		mock.ExpectExec(`INSERT INTO schema_migrations`).
+			WithArgs(last.Version, last.Name).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		for _, statement := range last.Up {
+			mock.ExpectExec(regexp.QuoteMeta(statement)).
+				WillReturnResult(sqlmock.NewResult(0, 0))
+		}
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE schema_migrations SET dirty = FALSE WHERE version = ?`)).
+			WithArgs(last.Version).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta(`SELECT RELEASE_LOCK(?)`)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		migrator, err := migrations.NewMigrator(db)
+		assert.NoError(t, err)
+
+		result, err := migrator.Up(context.Background())
+
+		assert.NoError(t, err, "Shouldn't contain any errors")
+		assert.Len(t, result, 1, "Only the last migration should be applied")
+		assert.Equal(t, last.Version, result[0].Version)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("ShouldRefuseDirtySchema", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT GET_LOCK(?, ?)`)).
+			WillReturnRows(sqlmock.NewRows([]string{"lock"}).AddRow(1))
+		mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations`).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(`SELECT version, dirty FROM schema_migrations`).
+			WillReturnRows(sqlmock.NewRows([]string{"version", "dirty"}).AddRow(1, true))
+		mock.ExpectExec(regexp.QuoteMeta(`SELECT RELEASE_LOCK(?)`)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		migrator, err := migrations.NewMigrator(db)
+		assert.NoError(t, err)
+
+		result, err := migrator.Up(context.Background())
+
+		assert.ErrorIs(t, err, migrations.ErrDirty, "Should refuse to migrate a dirty schema")
+		assert.Len(t, result, 0, "Nothing should be applied")
+	})
+}
+
+func TestMigratorEnsureClean(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock DB: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT version, dirty FROM schema_migrations`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "dirty"}).AddRow(1, false).AddRow(2, true))
+
+	migrator, err := migrations.NewMigrator(db)
+	assert.NoError(t, err)
+
+	err = migrator.EnsureClean(context.Background())
+
+	assert.ErrorIs(t, err, migrations.ErrDirty, "A dirty version should be reported")
+}
+
+func TestMigratorForce(t *testing.T) {
+	t.Run("ShouldCleanTheVersion", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		loaded, _ := migrations.Load()
+		last := loaded[len(loaded)-1]
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT GET_LOCK(?, ?)`)).
+			WillReturnRows(sqlmock.NewRows([]string{"lock"}).AddRow(1))
+		mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations`).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO schema_migrations (version, name, dirty) VALUES (?, ?, FALSE) ON DUPLICATE KEY UPDATE dirty = FALSE`)).
+			WithArgs(last.Version, last.Name).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec(regexp.QuoteMeta(`SELECT RELEASE_LOCK(?)`)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		migrator, err := migrations.NewMigrator(db)
+		assert.NoError(t, err)
+
+		forced, err := migrator.Force(context.Background(), last.Version)
+
+		assert.NoError(t, err, "Shouldn't contain any errors")
+		assert.Equal(t, last.Name, forced.Name)
+		assert.NoError(t, mock.ExpectationsWereMet(), "No statement of the migration should run")
+	})
+	t.Run("ShouldRefuseUnknownVersions", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		migrator, err := migrations.NewMigrator(db)
+		assert.NoError(t, err)
+
+		_, err = migrator.Force(context.Background(), 9999)
+
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet(), "Nothing should be written")
+	})
+}
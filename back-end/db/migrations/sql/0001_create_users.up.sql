@@ -0,0 +1,14 @@
+-- createdAt is a string because it is simpler to handle. It uses this format
+-- 2006-01-02 15:04:05 (19 chars)
+-- IF NOT EXISTS is kept so databases created before the migrations existed can
+-- adopt this version without being recreated
+CREATE TABLE IF NOT EXISTS users (
+    id CHAR(36) NOT NULL,
+    isActive BOOLEAN NOT NULL DEFAULT TRUE,
+    isDeleted BOOLEAN NOT NULL DEFAULT FALSE,
+    createdAt CHAR(19) NOT NULL,
+    email CHAR(100) NOT NULL,
+    cpf CHAR(11) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    PRIMARY KEY (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
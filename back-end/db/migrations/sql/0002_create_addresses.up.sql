@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS addresses (
+    id CHAR(36) NOT NULL,
+    isActive BOOLEAN NOT NULL DEFAULT TRUE,
+    isDeleted BOOLEAN NOT NULL DEFAULT FALSE,
+    createdAt CHAR(19) NOT NULL,
+    street VARCHAR(255) NOT NULL,
+    number VARCHAR(50) NOT NULL,
+    neighborhood VARCHAR(255) NOT NULL,
+    complement VARCHAR(255),
+    city VARCHAR(255) NOT NULL,
+    state CHAR(10) NOT NULL,
+    country VARCHAR(100) NOT NULL,
+    latitude DECIMAL(10, 8),
+    longitude DECIMAL(11, 8),
+    name VARCHAR(255),
+    PRIMARY KEY (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
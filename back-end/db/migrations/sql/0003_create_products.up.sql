@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS products (
+    id CHAR(36) NOT NULL,
+    isActive BOOLEAN NOT NULL DEFAULT TRUE,
+    isDeleted BOOLEAN NOT NULL DEFAULT FALSE,
+    createdAt CHAR(19) NOT NULL,
+    weightGrams FLOAT NOT NULL,
+    price FLOAT NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    PRIMARY KEY (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
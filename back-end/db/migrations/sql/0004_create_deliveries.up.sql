@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS deliveries (
+    id CHAR(36) NOT NULL,
+    isActive BOOLEAN NOT NULL DEFAULT TRUE,
+    isDeleted BOOLEAN NOT NULL DEFAULT FALSE,
+    createdAt CHAR(19) NOT NULL,
+    user_id CHAR(36) NOT NULL,
+    address_id CHAR(36) NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (address_id) REFERENCES addresses(id) ON DELETE CASCADE,
+    PRIMARY KEY (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
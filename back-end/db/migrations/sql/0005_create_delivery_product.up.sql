@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS delivery_product (
+    id CHAR(36) NOT NULL,
+    delivery_id CHAR(36) NOT NULL,
+    product_id CHAR(36) NOT NULL,
+    product_amount INT UNSIGNED NOT NULL,
+    FOREIGN KEY (delivery_id) REFERENCES deliveries(id) ON DELETE CASCADE,
+    FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE,
+    PRIMARY KEY (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,11 @@
+-- delivery_id is CHAR(16) to match the table that was already deployed, it is
+-- fixed by 0010_fix_payments_delivery_id
+CREATE TABLE IF NOT EXISTS payments (
+    id CHAR(36) NOT NULL,
+    isDeleted BOOLEAN NOT NULL DEFAULT FALSE,
+    createdAt CHAR(19) NOT NULL,
+    delivery_id CHAR(16) NOT NULL,
+    value FLOAT NOT NULL,
+    FOREIGN KEY (delivery_id) REFERENCES deliveries(id) ON DELETE CASCADE,
+    PRIMARY KEY (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS shopping_cart;
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS user_address (
+    id CHAR(36) NOT NULL,
+    user_id CHAR(36) NOT NULL,
+    address_id CHAR(36) NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (address_id) REFERENCES addresses(id) ON DELETE CASCADE,
+    PRIMARY KEY (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
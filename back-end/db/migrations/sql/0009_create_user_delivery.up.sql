@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS user_delivery (
+    id CHAR(36) NOT NULL,
+    delivery_id CHAR(36) NOT NULL,
+    user_id CHAR(36) NOT NULL,
+    FOREIGN KEY (delivery_id) REFERENCES deliveries(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    PRIMARY KEY (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,3 @@
+ALTER TABLE payments DROP FOREIGN KEY fk_payments_delivery;
+ALTER TABLE payments MODIFY delivery_id CHAR(16) NOT NULL;
+ALTER TABLE payments ADD CONSTRAINT payments_ibfk_1 FOREIGN KEY (delivery_id) REFERENCES deliveries(id) ON DELETE CASCADE;
@@ -0,0 +1,7 @@
+-- payments.delivery_id was created as CHAR(16) while deliveries.id is a
+-- CHAR(36) uuid, so no payment could ever be inserted. The foreign key has to be
+-- dropped before the column can be changed. payments_ibfk_1 is the name MySQL
+-- gave to the unnamed constraint from 0006
+ALTER TABLE payments DROP FOREIGN KEY payments_ibfk_1;
+ALTER TABLE payments MODIFY delivery_id CHAR(36) NOT NULL;
+ALTER TABLE payments ADD CONSTRAINT fk_payments_delivery FOREIGN KEY (delivery_id) REFERENCES deliveries(id) ON DELETE CASCADE;
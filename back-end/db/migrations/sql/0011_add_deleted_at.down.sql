@@ -0,0 +1,5 @@
+ALTER TABLE payments DROP INDEX idx_payments_is_deleted, DROP COLUMN deletedAt;
+ALTER TABLE deliveries DROP INDEX idx_deliveries_is_deleted, DROP COLUMN deletedAt;
+ALTER TABLE products DROP INDEX idx_products_is_deleted, DROP COLUMN deletedAt;
+ALTER TABLE addresses DROP INDEX idx_addresses_is_deleted, DROP COLUMN deletedAt;
+ALTER TABLE users DROP INDEX idx_users_is_deleted, DROP COLUMN deletedAt;
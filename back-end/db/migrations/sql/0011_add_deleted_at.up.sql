@@ -0,0 +1,8 @@
+-- DELETE now flips isDeleted instead of removing the row, deletedAt records
+-- when it happened (same format as createdAt) and is cleared on restore. The
+-- index keeps the default "not deleted" listing cheap
+ALTER TABLE users ADD COLUMN deletedAt CHAR(19) NULL, ADD INDEX idx_users_is_deleted (isDeleted);
+ALTER TABLE addresses ADD COLUMN deletedAt CHAR(19) NULL, ADD INDEX idx_addresses_is_deleted (isDeleted);
+ALTER TABLE products ADD COLUMN deletedAt CHAR(19) NULL, ADD INDEX idx_products_is_deleted (isDeleted);
+ALTER TABLE deliveries ADD COLUMN deletedAt CHAR(19) NULL, ADD INDEX idx_deliveries_is_deleted (isDeleted);
+ALTER TABLE payments ADD COLUMN deletedAt CHAR(19) NULL, ADD INDEX idx_payments_is_deleted (isDeleted);
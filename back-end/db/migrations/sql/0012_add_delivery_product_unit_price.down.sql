@@ -0,0 +1 @@
+ALTER TABLE delivery_product DROP COLUMN unit_price;
@@ -0,0 +1,4 @@
+-- Price of the product when the delivery was checked out, so changing the
+-- product later doesn't change what was charged. Rows created before the
+-- checkout existed have no snapshot
+ALTER TABLE delivery_product ADD COLUMN unit_price FLOAT NULL;
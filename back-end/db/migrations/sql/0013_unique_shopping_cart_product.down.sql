@@ -0,0 +1,3 @@
+-- MySQL may have dropped the user_id index in favour of the unique key, the
+-- foreign key needs one of them to exist. Merged lines are not split back
+ALTER TABLE shopping_cart ADD INDEX idx_shopping_cart_user (user_id), DROP INDEX uq_shopping_cart_user_product;
@@ -0,0 +1,20 @@
+-- A user has a single cart line per product, adding the same product again
+-- increments product_amount. Existing duplicates are merged into the line with
+-- the smallest id before the unique key is created
+UPDATE shopping_cart c
+JOIN (
+    SELECT MIN(id) AS id, SUM(product_amount) AS product_amount
+    FROM shopping_cart
+    GROUP BY user_id, product_id
+    HAVING COUNT(*) > 1
+) merged ON merged.id = c.id
+SET c.product_amount = merged.product_amount;
+DELETE c
+FROM shopping_cart c
+JOIN (
+    SELECT user_id, product_id, MIN(id) AS id
+    FROM shopping_cart
+    GROUP BY user_id, product_id
+    HAVING COUNT(*) > 1
+) kept ON kept.user_id = c.user_id AND kept.product_id = c.product_id AND kept.id <> c.id;
+ALTER TABLE shopping_cart ADD UNIQUE KEY uq_shopping_cart_user_product (user_id, product_id);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS stock_movements;
+ALTER TABLE shopping_cart DROP COLUMN reservedUntil;
+ALTER TABLE products DROP COLUMN stock, DROP COLUMN reserved;
@@ -0,0 +1,26 @@
+-- stock is what is in the warehouse, reserved is the part of it already taken
+-- by checked out deliveries that were not confirmed yet. Existing products
+-- start without stock and have to be restocked
+ALTER TABLE products
+    ADD COLUMN stock INT UNSIGNED NOT NULL DEFAULT 0,
+    ADD COLUMN reserved INT UNSIGNED NOT NULL DEFAULT 0;
+
+-- Adding a product to the cart holds it until reservedUntil, other users can't
+-- add or check out the held amount meanwhile
+ALTER TABLE shopping_cart ADD COLUMN reservedUntil CHAR(19) NULL;
+
+-- Every change to products.stock or products.reserved. quantity is signed, the
+-- reason tells which of the two columns it changed
+CREATE TABLE IF NOT EXISTS stock_movements (
+    id CHAR(36) NOT NULL,
+    product_id CHAR(36) NOT NULL,
+    delivery_id CHAR(36) NULL,
+    quantity INT NOT NULL,
+    reason VARCHAR(20) NOT NULL,
+    createdAt CHAR(19) NOT NULL,
+    FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE,
+    FOREIGN KEY (delivery_id) REFERENCES deliveries(id) ON DELETE CASCADE,
+    INDEX idx_stock_movements_product (product_id, createdAt),
+    INDEX idx_stock_movements_delivery (delivery_id, reason),
+    PRIMARY KEY (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
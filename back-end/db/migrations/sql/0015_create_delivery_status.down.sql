@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS delivery_status_history;
+ALTER TABLE deliveries DROP INDEX idx_deliveries_status, DROP COLUMN status;
@@ -0,0 +1,17 @@
+-- Existing deliveries have no history, they start as pending like new ones
+ALTER TABLE deliveries
+    ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    ADD INDEX idx_deliveries_status (status);
+
+-- One row per transition. actor is whoever asked for it, as sent by the client
+CREATE TABLE IF NOT EXISTS delivery_status_history (
+    id CHAR(36) NOT NULL,
+    delivery_id CHAR(36) NOT NULL,
+    fromStatus VARCHAR(20) NOT NULL,
+    toStatus VARCHAR(20) NOT NULL,
+    actor VARCHAR(255) NULL,
+    createdAt CHAR(19) NOT NULL,
+    FOREIGN KEY (delivery_id) REFERENCES deliveries(id) ON DELETE CASCADE,
+    INDEX idx_delivery_status_history_delivery (delivery_id, createdAt),
+    PRIMARY KEY (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1 @@
+ALTER TABLE payments DROP INDEX idx_payments_status, DROP COLUMN status, DROP COLUMN gatewayReference, DROP COLUMN failureReason;
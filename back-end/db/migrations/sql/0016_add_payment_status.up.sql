@@ -0,0 +1,8 @@
+-- Payments created before the gateway were never charged, they start as
+-- pending like the new ones. gatewayReference is the provider's ID of the
+-- authorization, failureReason the last refusal
+ALTER TABLE payments
+    ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    ADD COLUMN gatewayReference VARCHAR(64) NULL,
+    ADD COLUMN failureReason VARCHAR(255) NULL,
+    ADD INDEX idx_payments_status (status);
@@ -0,0 +1,3 @@
+-- Charged on top of the products, the payments of a delivery have to add up
+-- to both
+ALTER TABLE deliveries ADD COLUMN shippingFee FLOAT NOT NULL DEFAULT 0;
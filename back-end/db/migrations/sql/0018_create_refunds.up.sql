@@ -0,0 +1,13 @@
+-- Money given back from a captured payment, one row per refund. The payment
+-- only becomes refunded once the rows add up to its value
+CREATE TABLE IF NOT EXISTS refunds (
+    id CHAR(36) NOT NULL,
+    payment_id CHAR(36) NOT NULL,
+    amount FLOAT NOT NULL,
+    reason VARCHAR(32) NOT NULL,
+    note VARCHAR(255) NULL,
+    createdAt CHAR(19) NOT NULL,
+    FOREIGN KEY (payment_id) REFERENCES payments(id) ON DELETE CASCADE,
+    INDEX idx_refunds_payment (payment_id, createdAt),
+    PRIMARY KEY (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,5 @@
+ALTER TABLE deliveries MODIFY COLUMN shippingFee FLOAT NOT NULL DEFAULT 0;
+ALTER TABLE delivery_product MODIFY COLUMN unit_price FLOAT NULL;
+ALTER TABLE refunds MODIFY COLUMN amount FLOAT NOT NULL;
+ALTER TABLE payments MODIFY COLUMN value FLOAT NOT NULL;
+ALTER TABLE products MODIFY COLUMN price FLOAT NOT NULL;
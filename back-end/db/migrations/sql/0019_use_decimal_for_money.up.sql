@@ -0,0 +1,7 @@
+-- FLOAT can't hold most cents exactly, the amounts become DECIMAL. MySQL
+-- rounds the stored values to the nearest cent while converting them
+ALTER TABLE products MODIFY COLUMN price DECIMAL(12,2) NOT NULL;
+ALTER TABLE payments MODIFY COLUMN value DECIMAL(12,2) NOT NULL;
+ALTER TABLE refunds MODIFY COLUMN amount DECIMAL(12,2) NOT NULL;
+ALTER TABLE delivery_product MODIFY COLUMN unit_price DECIMAL(12,2) NULL;
+ALTER TABLE deliveries MODIFY COLUMN shippingFee DECIMAL(12,2) NOT NULL DEFAULT 0;
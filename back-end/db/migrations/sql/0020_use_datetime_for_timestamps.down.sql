@@ -0,0 +1,33 @@
+ALTER TABLE deliveries DROP COLUMN updatedAt;
+ALTER TABLE products DROP COLUMN updatedAt;
+ALTER TABLE addresses DROP COLUMN updatedAt;
+ALTER TABLE users DROP COLUMN updatedAt;
+
+-- Back to strings in the time zone of the session, without the fraction
+ALTER TABLE refunds MODIFY COLUMN createdAt VARCHAR(26) NOT NULL;
+UPDATE refunds SET createdAt = DATE_FORMAT(COALESCE(CONVERT_TZ(createdAt, '+00:00', @@session.time_zone), createdAt), '%Y-%m-%d %H:%i:%s');
+ALTER TABLE refunds MODIFY COLUMN createdAt CHAR(19) NOT NULL;
+ALTER TABLE delivery_status_history MODIFY COLUMN createdAt VARCHAR(26) NOT NULL;
+UPDATE delivery_status_history SET createdAt = DATE_FORMAT(COALESCE(CONVERT_TZ(createdAt, '+00:00', @@session.time_zone), createdAt), '%Y-%m-%d %H:%i:%s');
+ALTER TABLE delivery_status_history MODIFY COLUMN createdAt CHAR(19) NOT NULL;
+ALTER TABLE stock_movements MODIFY COLUMN createdAt VARCHAR(26) NOT NULL;
+UPDATE stock_movements SET createdAt = DATE_FORMAT(COALESCE(CONVERT_TZ(createdAt, '+00:00', @@session.time_zone), createdAt), '%Y-%m-%d %H:%i:%s');
+ALTER TABLE stock_movements MODIFY COLUMN createdAt CHAR(19) NOT NULL;
+ALTER TABLE shopping_cart MODIFY COLUMN reservedUntil VARCHAR(26) NULL;
+UPDATE shopping_cart SET reservedUntil = DATE_FORMAT(COALESCE(CONVERT_TZ(reservedUntil, '+00:00', @@session.time_zone), reservedUntil), '%Y-%m-%d %H:%i:%s');
+ALTER TABLE shopping_cart MODIFY COLUMN reservedUntil CHAR(19) NULL;
+ALTER TABLE payments MODIFY COLUMN createdAt VARCHAR(26) NOT NULL, MODIFY COLUMN deletedAt VARCHAR(26) NULL;
+UPDATE payments SET createdAt = DATE_FORMAT(COALESCE(CONVERT_TZ(createdAt, '+00:00', @@session.time_zone), createdAt), '%Y-%m-%d %H:%i:%s'), deletedAt = DATE_FORMAT(COALESCE(CONVERT_TZ(deletedAt, '+00:00', @@session.time_zone), deletedAt), '%Y-%m-%d %H:%i:%s');
+ALTER TABLE payments MODIFY COLUMN createdAt CHAR(19) NOT NULL, MODIFY COLUMN deletedAt CHAR(19) NULL;
+ALTER TABLE deliveries MODIFY COLUMN createdAt VARCHAR(26) NOT NULL, MODIFY COLUMN deletedAt VARCHAR(26) NULL;
+UPDATE deliveries SET createdAt = DATE_FORMAT(COALESCE(CONVERT_TZ(createdAt, '+00:00', @@session.time_zone), createdAt), '%Y-%m-%d %H:%i:%s'), deletedAt = DATE_FORMAT(COALESCE(CONVERT_TZ(deletedAt, '+00:00', @@session.time_zone), deletedAt), '%Y-%m-%d %H:%i:%s');
+ALTER TABLE deliveries MODIFY COLUMN createdAt CHAR(19) NOT NULL, MODIFY COLUMN deletedAt CHAR(19) NULL;
+ALTER TABLE products MODIFY COLUMN createdAt VARCHAR(26) NOT NULL, MODIFY COLUMN deletedAt VARCHAR(26) NULL;
+UPDATE products SET createdAt = DATE_FORMAT(COALESCE(CONVERT_TZ(createdAt, '+00:00', @@session.time_zone), createdAt), '%Y-%m-%d %H:%i:%s'), deletedAt = DATE_FORMAT(COALESCE(CONVERT_TZ(deletedAt, '+00:00', @@session.time_zone), deletedAt), '%Y-%m-%d %H:%i:%s');
+ALTER TABLE products MODIFY COLUMN createdAt CHAR(19) NOT NULL, MODIFY COLUMN deletedAt CHAR(19) NULL;
+ALTER TABLE addresses MODIFY COLUMN createdAt VARCHAR(26) NOT NULL, MODIFY COLUMN deletedAt VARCHAR(26) NULL;
+UPDATE addresses SET createdAt = DATE_FORMAT(COALESCE(CONVERT_TZ(createdAt, '+00:00', @@session.time_zone), createdAt), '%Y-%m-%d %H:%i:%s'), deletedAt = DATE_FORMAT(COALESCE(CONVERT_TZ(deletedAt, '+00:00', @@session.time_zone), deletedAt), '%Y-%m-%d %H:%i:%s');
+ALTER TABLE addresses MODIFY COLUMN createdAt CHAR(19) NOT NULL, MODIFY COLUMN deletedAt CHAR(19) NULL;
+ALTER TABLE users MODIFY COLUMN createdAt VARCHAR(26) NOT NULL, MODIFY COLUMN deletedAt VARCHAR(26) NULL;
+UPDATE users SET createdAt = DATE_FORMAT(COALESCE(CONVERT_TZ(createdAt, '+00:00', @@session.time_zone), createdAt), '%Y-%m-%d %H:%i:%s'), deletedAt = DATE_FORMAT(COALESCE(CONVERT_TZ(deletedAt, '+00:00', @@session.time_zone), deletedAt), '%Y-%m-%d %H:%i:%s');
+ALTER TABLE users MODIFY COLUMN createdAt CHAR(19) NOT NULL, MODIFY COLUMN deletedAt CHAR(19) NULL;
@@ -0,0 +1,36 @@
+-- The timestamps were CHAR(19) strings in the local time of the server. They
+-- become DATETIME(6) in UTC, assuming the session time zone of MySQL is the
+-- one the server was running in (CONVERT_TZ gives NULL for unknown zones, the
+-- value is kept as it was then)
+ALTER TABLE users MODIFY COLUMN createdAt DATETIME(6) NOT NULL, MODIFY COLUMN deletedAt DATETIME(6) NULL;
+UPDATE users SET createdAt = COALESCE(CONVERT_TZ(createdAt, @@session.time_zone, '+00:00'), createdAt), deletedAt = COALESCE(CONVERT_TZ(deletedAt, @@session.time_zone, '+00:00'), deletedAt);
+ALTER TABLE addresses MODIFY COLUMN createdAt DATETIME(6) NOT NULL, MODIFY COLUMN deletedAt DATETIME(6) NULL;
+UPDATE addresses SET createdAt = COALESCE(CONVERT_TZ(createdAt, @@session.time_zone, '+00:00'), createdAt), deletedAt = COALESCE(CONVERT_TZ(deletedAt, @@session.time_zone, '+00:00'), deletedAt);
+ALTER TABLE products MODIFY COLUMN createdAt DATETIME(6) NOT NULL, MODIFY COLUMN deletedAt DATETIME(6) NULL;
+UPDATE products SET createdAt = COALESCE(CONVERT_TZ(createdAt, @@session.time_zone, '+00:00'), createdAt), deletedAt = COALESCE(CONVERT_TZ(deletedAt, @@session.time_zone, '+00:00'), deletedAt);
+ALTER TABLE deliveries MODIFY COLUMN createdAt DATETIME(6) NOT NULL, MODIFY COLUMN deletedAt DATETIME(6) NULL;
+UPDATE deliveries SET createdAt = COALESCE(CONVERT_TZ(createdAt, @@session.time_zone, '+00:00'), createdAt), deletedAt = COALESCE(CONVERT_TZ(deletedAt, @@session.time_zone, '+00:00'), deletedAt);
+ALTER TABLE payments MODIFY COLUMN createdAt DATETIME(6) NOT NULL, MODIFY COLUMN deletedAt DATETIME(6) NULL;
+UPDATE payments SET createdAt = COALESCE(CONVERT_TZ(createdAt, @@session.time_zone, '+00:00'), createdAt), deletedAt = COALESCE(CONVERT_TZ(deletedAt, @@session.time_zone, '+00:00'), deletedAt);
+ALTER TABLE shopping_cart MODIFY COLUMN reservedUntil DATETIME(6) NULL;
+UPDATE shopping_cart SET reservedUntil = COALESCE(CONVERT_TZ(reservedUntil, @@session.time_zone, '+00:00'), reservedUntil);
+ALTER TABLE stock_movements MODIFY COLUMN createdAt DATETIME(6) NOT NULL;
+UPDATE stock_movements SET createdAt = COALESCE(CONVERT_TZ(createdAt, @@session.time_zone, '+00:00'), createdAt);
+ALTER TABLE delivery_status_history MODIFY COLUMN createdAt DATETIME(6) NOT NULL;
+UPDATE delivery_status_history SET createdAt = COALESCE(CONVERT_TZ(createdAt, @@session.time_zone, '+00:00'), createdAt);
+ALTER TABLE refunds MODIFY COLUMN createdAt DATETIME(6) NOT NULL;
+UPDATE refunds SET createdAt = COALESCE(CONVERT_TZ(createdAt, @@session.time_zone, '+00:00'), createdAt);
+
+-- Changed by every update, rows that were never updated start at createdAt
+ALTER TABLE users ADD COLUMN updatedAt DATETIME(6) NULL;
+UPDATE users SET updatedAt = createdAt;
+ALTER TABLE users MODIFY COLUMN updatedAt DATETIME(6) NOT NULL;
+ALTER TABLE addresses ADD COLUMN updatedAt DATETIME(6) NULL;
+UPDATE addresses SET updatedAt = createdAt;
+ALTER TABLE addresses MODIFY COLUMN updatedAt DATETIME(6) NOT NULL;
+ALTER TABLE products ADD COLUMN updatedAt DATETIME(6) NULL;
+UPDATE products SET updatedAt = createdAt;
+ALTER TABLE products MODIFY COLUMN updatedAt DATETIME(6) NOT NULL;
+ALTER TABLE deliveries ADD COLUMN updatedAt DATETIME(6) NULL;
+UPDATE deliveries SET updatedAt = createdAt;
+ALTER TABLE deliveries MODIFY COLUMN updatedAt DATETIME(6) NOT NULL;
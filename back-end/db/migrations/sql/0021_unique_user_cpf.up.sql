@@ -0,0 +1,4 @@
+-- The CPF identifies a single person. Soft deleted users keep theirs, so a
+-- restore never collides. Users already sharing a CPF make this fail, they
+-- have to be merged by hand since their addresses and deliveries differ
+ALTER TABLE users ADD UNIQUE KEY uq_users_cpf (cpf);
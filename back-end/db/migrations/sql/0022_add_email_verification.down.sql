@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS email_verification_tokens;
+-- The emails stay normalized, only the schema goes back
+ALTER TABLE users
+    DROP COLUMN emailVerifiedAt,
+    DROP INDEX uq_users_email,
+    MODIFY COLUMN email CHAR(100) NOT NULL;
@@ -0,0 +1,24 @@
+-- Emails are compared trimmed and lower cased, the existing rows are brought
+-- to that form before the unique key. Users already sharing an email make it
+-- fail and have to be merged by hand, like the CPF in 0021. The column grows
+-- to the 254 characters an address can have
+UPDATE users SET email = LOWER(TRIM(email));
+ALTER TABLE users
+    MODIFY COLUMN email VARCHAR(254) NOT NULL,
+    ADD UNIQUE KEY uq_users_email (email),
+    ADD COLUMN emailVerifiedAt DATETIME(6) NULL;
+
+-- Tokens mailed to confirm an email, only their SHA-256 is kept. A token is
+-- tied to the address it was sent to, changing the email makes it useless.
+-- Issuing a new token or verifying deletes the previous ones of the user
+CREATE TABLE IF NOT EXISTS email_verification_tokens (
+    id CHAR(36) NOT NULL,
+    user_id CHAR(36) NOT NULL,
+    email VARCHAR(254) NOT NULL,
+    tokenHash CHAR(64) NOT NULL,
+    expiresAt DATETIME(6) NOT NULL,
+    createdAt DATETIME(6) NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE KEY uq_email_verification_tokens_hash (tokenHash),
+    PRIMARY KEY (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,35 @@
+-- bcrypt hash of the password. The users created before it have none and
+-- can't log in until they reset it with POST /auth/password-reset
+ALTER TABLE users
+    ADD COLUMN passwordHash VARCHAR(255) NULL;
+
+-- One row per login. Only the SHA-256 of the tokens is kept, the access token
+-- is sent in every request and expires quickly, the refresh token is traded
+-- for a new pair. Logging out or resetting the password sets revokedAt
+CREATE TABLE IF NOT EXISTS sessions (
+    id CHAR(36) NOT NULL,
+    user_id CHAR(36) NOT NULL,
+    accessTokenHash CHAR(64) NOT NULL,
+    accessExpiresAt DATETIME(6) NOT NULL,
+    refreshTokenHash CHAR(64) NOT NULL,
+    refreshExpiresAt DATETIME(6) NOT NULL,
+    createdAt DATETIME(6) NOT NULL,
+    revokedAt DATETIME(6) NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE KEY uq_sessions_access (accessTokenHash),
+    UNIQUE KEY uq_sessions_refresh (refreshTokenHash),
+    PRIMARY KEY (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Mailed by POST /auth/password-reset, like email_verification_tokens only
+-- the last one sent works and it is deleted once used
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+    id CHAR(36) NOT NULL,
+    user_id CHAR(36) NOT NULL,
+    tokenHash CHAR(64) NOT NULL,
+    expiresAt DATETIME(6) NOT NULL,
+    createdAt DATETIME(6) NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE KEY uq_password_reset_tokens_hash (tokenHash),
+    PRIMARY KEY (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
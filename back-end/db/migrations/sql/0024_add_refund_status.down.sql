@@ -0,0 +1,3 @@
+-- Refunds the gateway never gave back would count as given back again
+DELETE FROM refunds WHERE status <> 'completed';
+ALTER TABLE refunds DROP COLUMN status;
@@ -0,0 +1,4 @@
+-- A refund is reserved as pending before the gateway is called, and completed
+-- or failed once it answers. The rows already there were refunded by the
+-- gateway before being written, so they start completed
+ALTER TABLE refunds ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT 'completed';
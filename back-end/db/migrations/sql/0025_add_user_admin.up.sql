@@ -0,0 +1,3 @@
+-- Admins can act on every user (bulk delete, restore). Nobody is an admin by
+-- default, they are promoted by hand with an UPDATE
+ALTER TABLE users ADD COLUMN isAdmin BOOLEAN NOT NULL DEFAULT FALSE;
@@ -1,23 +1,38 @@
 package address
 
+import (
+	"context"
+	"sipub-test/internal/filter"
+	"sipub-test/internal/pagination"
+)
+
 type IAddressRepository interface {
 	// Returns the created address
-	Create(AddressParams) (AddressModel, error)
+	Create(ctx context.Context, params AddressParams) (AddressModel, error)
 
 	// Returns the found addresses
 	// NOTE: reusing the same type for a filter and a "constructor" is not
 	// ideal at all, but it will save on code repetition
-	GetAll(filter AddressParams) ([]AddressModel, error)
+	GetAll(ctx context.Context, where filter.Filter, page pagination.Page) ([]AddressModel, error)
+
+	// Returns how many rows match the filter, ignoring the page
+	Count(ctx context.Context, where filter.Filter) (uint, error)
 
 	// Returns the found address
-	GetOne(id string) (AddressModel, error)
+	GetOne(ctx context.Context, id string) (AddressModel, error)
 
-	// Returns amount of deleted addresses
-	DeleteOne(id string) (uint, error)
+	// Soft deletes, returns amount of deleted addresses
+	DeleteOne(ctx context.Context, id string) (uint, error)
 
-	// Returns amount of deleted addresses
-	DeleteAll(filter AddressParams) (uint, error)
+	// Soft deletes, returns amount of deleted addresses
+	DeleteAll(ctx context.Context, where filter.Filter) (uint, error)
 
 	// Returns the updated address
-	Update(id string, newAddress AddressParams) (AddressModel, error)
+	Update(ctx context.Context, id string, newAddress AddressParams) (AddressModel, error)
+
+	// Undoes a soft delete, returns the restored address
+	Restore(ctx context.Context, id string) (AddressModel, error)
+
+	// Removes the row for good, whether it was soft deleted or not
+	Purge(ctx context.Context, id string) (uint, error)
 }
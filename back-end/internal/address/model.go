@@ -1,5 +1,7 @@
 package address
 
+import "sipub-test/pkg/timestamp"
+
 // This is what will be used to create/find/update the address model. The
 // fields are used as pointers so they can be nullified
 type AddressParams struct {
@@ -22,6 +24,8 @@ type AddressParams struct {
 type AddressDTO struct {
 	Id           string  `json:"Id"`
 	CreatedAt    string  `json:"CreatedAt"`
+	UpdatedAt    string  `json:"UpdatedAt"`
+	DeletedAt    *string `json:"DeletedAt,omitempty"`
 	Street       string  `json:"Street"`
 	Number       string  `json:"Number"`
 	Neighborhood string  `json:"Neighborhood"`
@@ -40,8 +44,10 @@ type AddressModel struct {
 	// inheritance. Explained in COMMENTS.md
 	id        string // ID will be a uuid
 	isActive  bool
-	isDeleted bool // Soft deletion
-	createdAt string
+	isDeleted bool            // Soft deletion
+	deletedAt *timestamp.Time // Set while soft deleted
+	createdAt timestamp.Time
+	updatedAt timestamp.Time // Changed by every update
 
 	street       string
 	number       string
@@ -59,7 +65,9 @@ type AddressModel struct {
 func (a *AddressModel) ToDTO() AddressDTO {
 	dtoAddress := AddressDTO{
 		Id:           a.id,
-		CreatedAt:    a.createdAt,
+		CreatedAt:    a.createdAt.String(),
+		UpdatedAt:    a.updatedAt.String(),
+		DeletedAt:    timestamp.Format(a.deletedAt),
 		Street:       a.street,
 		Number:       a.number,
 		Neighborhood: a.neighborhood,
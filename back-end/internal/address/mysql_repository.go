@@ -1,65 +1,66 @@
 package address
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
-	"log"
 	"sipub-test/db"
+	"sipub-test/internal/filter"
+	"sipub-test/internal/pagination"
+	"sipub-test/pkg/apperror"
+	"sipub-test/pkg/clock"
 	"sipub-test/pkg/nilcheck"
-	"time"
+	"sipub-test/pkg/timestamp"
 
 	"github.com/google/uuid"
 )
 
 type MySQLAddressRepository struct {
-	db *sql.DB
+	db    db.Executor
+	clock clock.Clock
 }
 
-// Mainly used for testing, but could be used elsewhere
-func (r *MySQLAddressRepository) SetDB(db *sql.DB) { r.db = db }
-
-func (r *MySQLAddressRepository) createNewAddressTableIfNoneExists() {
-	r.db = db.GetDB()
-
-	createTableQuery := `
-	CREATE TABLE IF NOT EXISTS addresses (
-		id CHAR(36) NOT NULL,
-		isActive BOOLEAN NOT NULL DEFAULT TRUE,
-		isDeleted BOOLEAN NOT NULL DEFAULT FALSE,
-        createdAt CHAR(19) NOT NULL,
-        street VARCHAR(255) NOT NULL,
-        number VARCHAR(50) NOT NULL,
-        neighborhood VARCHAR(255) NOT NULL,
-        complement VARCHAR(255),
-        city VARCHAR(255) NOT NULL,
-        state CHAR(10) NOT NULL,
-        country VARCHAR(100) NOT NULL,
-        latitude DECIMAL(10, 8),
-        longitude DECIMAL(11, 8),
-        name VARCHAR(255),
-        PRIMARY KEY (id)
-	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`
-	// https://dev.mysql.com/doc/refman/8.4/en/innodb-benefits.html
-	// Mainly using InnoDB because it supports foreing keys
-	// createdAt is a string because it is simpler to handle. It uses this
-	// format 2006-01-02 15:04:05 (19 chars)
+func NewMySQLAddressRepository(db db.Executor, clock clock.Clock) *MySQLAddressRepository {
+	return &MySQLAddressRepository{db: db, clock: clock}
+}
 
-	if _, err := r.db.Exec(createTableQuery); err != nil {
-		log.Fatalf("Failed to create table: %v", err)
-	}
+// Fields the list can be filtered and deleted by
+var filterFields = filter.Schema{
+	"IsActive":     {Column: "isActive", Type: filter.Bool, Operators: []filter.Operator{filter.In}},
+	"IsDeleted":    {Column: "isDeleted", Type: filter.Bool, Operators: []filter.Operator{filter.Eq}},
+	"CreatedAt":    {Column: "createdAt", Type: filter.Time, Operators: filter.TimeOperators},
+	"Name":         {Column: "name", Type: filter.String, Operators: []filter.Operator{filter.Like, filter.Eq}},
+	"Street":       {Column: "street", Type: filter.String, Operators: []filter.Operator{filter.Like, filter.Eq}},
+	"Number":       {Column: "number", Type: filter.String, Operators: []filter.Operator{filter.Eq}},
+	"Neighborhood": {Column: "neighborhood", Type: filter.String, Operators: []filter.Operator{filter.Like, filter.Eq}},
+	"Complement":   {Column: "complement", Type: filter.String, Operators: []filter.Operator{filter.Like, filter.Eq}},
+	"City":         {Column: "city", Type: filter.String, Operators: []filter.Operator{filter.Like, filter.Eq}},
+	"State":        {Column: "state", Type: filter.String, Operators: []filter.Operator{filter.Eq, filter.In}},
+	"Country":      {Column: "country", Type: filter.String, Operators: []filter.Operator{filter.Like, filter.Eq}},
+	"Latitude":     {Column: "latitude", Type: filter.Number, Operators: filter.NumberOperators},
+	"Longitude":    {Column: "longitude", Type: filter.Number, Operators: filter.NumberOperators},
 }
 
-func NewMySQLAddressRepository() *MySQLAddressRepository {
-	repo := &MySQLAddressRepository{db: db.GetDB()}
-	repo.createNewAddressTableIfNoneExists()
-	return repo
+// Columns the list can be sorted by
+var sortFields = pagination.Fields{
+	Table:    "addresses",
+	IDColumn: "id",
+	Columns: map[string]string{
+		"Name":      "COALESCE(name, '')", // Nullable, NULL would never match the cursor
+		"Street":    "street",
+		"City":      "city",
+		"State":     "state",
+		"Country":   "country",
+		"CreatedAt": "createdAt",
+	},
+	Default: []pagination.Sort{{Field: "CreatedAt", Column: "createdAt", Desc: true}},
 }
 
-func (r *MySQLAddressRepository) Create(params AddressParams) (AddressModel, error) {
+func (r *MySQLAddressRepository) Create(ctx context.Context, params AddressParams) (AddressModel, error) {
 	id := uuid.NewString()
 
-	timeCreated := time.Now().Format("2006-01-02 15:04:05")
+	timeCreated := timestamp.New(r.clock.Now())
 
 	// Fields might be nil, but they need to be passed empty/defaulted non nil fields
 	model := AddressModel{
@@ -67,6 +68,7 @@ func (r *MySQLAddressRepository) Create(params AddressParams) (AddressModel, err
 		isActive:     *params.IsActive,
 		isDeleted:    *params.IsDeleted,
 		createdAt:    timeCreated,
+		updatedAt:    timeCreated,
 		street:       *params.Street,
 		number:       *params.Number,
 		neighborhood: *params.Neighborhood,
@@ -80,43 +82,23 @@ func (r *MySQLAddressRepository) Create(params AddressParams) (AddressModel, err
 	}
 
 	query := `INSERT INTO addresses 
-		(id, isActive, isDeleted, createdAt, street, number, neighborhood, complement, city, state, country, latitude, longitude, name)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+		(id, isActive, isDeleted, createdAt, updatedAt, street, number, neighborhood, complement, city, state, country, latitude, longitude, name)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
-	_, err := r.db.Exec(query, id, model.isActive, model.isDeleted, timeCreated, model.street, model.number, model.neighborhood, model.complement, model.city, model.state, model.country, model.latitude, model.longitude, model.name)
+	_, err := r.db.ExecContext(ctx, query, id, model.isActive, model.isDeleted, timeCreated, timeCreated, model.street, model.number, model.neighborhood, model.complement, model.city, model.state, model.country, model.latitude, model.longitude, model.name)
 	if err != nil {
-		return AddressModel{}, fmt.Errorf("failed to create address: %w", err)
+		return AddressModel{}, fmt.Errorf("failed to create address: %w", db.TranslateError(err))
 	}
 
 	return model, nil
 }
 
-func (r *MySQLAddressRepository) GetAll(filter AddressParams) ([]AddressModel, error) {
-	query := `SELECT id, isActive, isDeleted, createdAt, street, number, neighborhood, complement, city, state, country, latitude, longitude, name FROM addresses WHERE 1=1`
-	args := []interface{}{}
+func (r *MySQLAddressRepository) GetAll(ctx context.Context, where filter.Filter, page pagination.Page) ([]AddressModel, error) {
+	conditions, args := where.SQL()
+	query := `SELECT id, isActive, isDeleted, createdAt, updatedAt, street, number, neighborhood, complement, city, state, country, latitude, longitude, name, deletedAt FROM addresses WHERE 1=1` + conditions
+	query, args = sortFields.Apply(page, query, args)
 
-	if filter.IsActive != nil {
-		query += " AND isActive = ?"
-		args = append(args, *filter.IsActive)
-	}
-	if filter.IsDeleted != nil {
-		query += " AND isDeleted = ?"
-		args = append(args, *filter.IsDeleted)
-	}
-	if filter.Street != nil {
-		query += " AND street LIKE ?"
-		args = append(args, "%"+*filter.Street+"%")
-	}
-	if filter.City != nil {
-		query += " AND city LIKE ?"
-		args = append(args, "%"+*filter.City+"%")
-	}
-	if filter.State != nil {
-		query += " AND state = ?"
-		args = append(args, *filter.State)
-	}
-
-	rows, err := r.db.Query(query, args...)
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get addresses: %w", err)
 	}
@@ -129,6 +111,7 @@ func (r *MySQLAddressRepository) GetAll(filter AddressParams) ([]AddressModel, e
 			&address.isActive,
 			&address.isDeleted,
 			&address.createdAt,
+			&address.updatedAt,
 			&address.street,
 			&address.number,
 			&address.neighborhood,
@@ -138,7 +121,8 @@ func (r *MySQLAddressRepository) GetAll(filter AddressParams) ([]AddressModel, e
 			&address.country,
 			&address.latitude,
 			&address.longitude,
-			&address.name)
+			&address.name,
+			&address.deletedAt)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan address: %w", err)
 		}
@@ -148,15 +132,27 @@ func (r *MySQLAddressRepository) GetAll(filter AddressParams) ([]AddressModel, e
 	return addresses, nil
 }
 
-func (r *MySQLAddressRepository) GetOne(id string) (AddressModel, error) {
-	query := `SELECT id, isActive, isDeleted, createdAt, street, number, neighborhood, complement, city, state, country, latitude, longitude, name FROM addresses WHERE id = ?`
+func (r *MySQLAddressRepository) Count(ctx context.Context, where filter.Filter) (uint, error) {
+	conditions, args := where.SQL()
+	query := `SELECT COUNT(*) FROM addresses WHERE 1=1` + conditions
+
+	var count uint
+	if err := r.db.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count addresses: %w", err)
+	}
+	return count, nil
+}
+
+func (r *MySQLAddressRepository) GetOne(ctx context.Context, id string) (AddressModel, error) {
+	query := `SELECT id, isActive, isDeleted, createdAt, updatedAt, street, number, neighborhood, complement, city, state, country, latitude, longitude, name FROM addresses WHERE id = ? AND isDeleted = FALSE`
 
 	var address AddressModel
-	row := r.db.QueryRow(query, id)
+	row := r.db.QueryRowContext(ctx, query, id)
 	err := row.Scan(&address.id,
 		&address.isActive,
 		&address.isDeleted,
 		&address.createdAt,
+		&address.updatedAt,
 		&address.street,
 		&address.number,
 		&address.neighborhood,
@@ -169,84 +165,48 @@ func (r *MySQLAddressRepository) GetOne(id string) (AddressModel, error) {
 		&address.name)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return AddressModel{}, fmt.Errorf("address not found")
+			return AddressModel{}, fmt.Errorf("address %w", apperror.ErrNotFound)
 		}
 		return AddressModel{}, fmt.Errorf("failed to get address: %w", err)
 	}
 	return address, nil
 }
 
-func (r *MySQLAddressRepository) DeleteOne(id string) (uint, error) {
-	query := `DELETE FROM addresses WHERE id = ?`
-	res, err := r.db.Exec(query, id)
+func (r *MySQLAddressRepository) DeleteOne(ctx context.Context, id string) (uint, error) {
+	deletedAt := timestamp.New(r.clock.Now())
+	query := `UPDATE addresses SET isDeleted = TRUE, deletedAt = ? WHERE id = ? AND isDeleted = FALSE`
+	res, err := r.db.ExecContext(ctx, query, deletedAt, id)
 	if err != nil {
-		return 0, fmt.Errorf("failed to delete address: %w", err)
+		return 0, fmt.Errorf("failed to delete address: %w", db.TranslateError(err))
 	}
 	count, _ := res.RowsAffected()
 	if count == 0 {
-		return 0, fmt.Errorf("no address found with the given ID")
+		return 0, fmt.Errorf("address %w", apperror.ErrNotFound)
 	}
 	return uint(count), nil
 }
 
-func (r *MySQLAddressRepository) DeleteAll(filter AddressParams) (uint, error) {
-	query := `DELETE FROM addresses WHERE 1=1`
-	args := []interface{}{}
+func (r *MySQLAddressRepository) DeleteAll(ctx context.Context, where filter.Filter) (uint, error) {
+	conditions, args := where.SQL()
+	query := `UPDATE addresses SET isDeleted = TRUE, deletedAt = ? WHERE 1=1` + conditions
+	args = append([]interface{}{timestamp.New(r.clock.Now())}, args...)
 
-	if filter.IsActive != nil {
-		query += " AND isActive = ?"
-		args = append(args, *filter.IsActive)
-	}
-	if filter.IsDeleted != nil {
-		query += " AND isDeleted = ?"
-		args = append(args, *filter.IsDeleted)
-	}
-	if filter.Street != nil {
-		query += " AND street LIKE ?"
-		args = append(args, "%"+*filter.Street+"%")
-	}
-	if filter.Number != nil {
-		query += " AND number = ?"
-		args = append(args, *filter.Number)
-	}
-	if filter.Neighborhood != nil {
-		query += " AND neighborhood LIKE ?"
-		args = append(args, "%"+*filter.Neighborhood+"%")
-	}
-	if filter.City != nil {
-		query += " AND city LIKE ?"
-		args = append(args, "%"+*filter.City+"%")
-	}
-	if filter.State != nil {
-		query += " AND state = ?"
-		args = append(args, *filter.State)
-	}
-	if filter.Country != nil {
-		query += " AND country LIKE ?"
-		args = append(args, "%"+*filter.Country+"%")
-	}
-	if filter.Name != nil {
-		query += " AND name LIKE ?"
-		args = append(args, "%"+*filter.Name+"%")
-	}
-
-	res, err := r.db.Exec(query, args...)
+	res, err := r.db.ExecContext(ctx, query, args...)
 	if err != nil {
-		return 0, fmt.Errorf("failed to delete addresses: %w", err)
+		return 0, fmt.Errorf("failed to delete addresses: %w", db.TranslateError(err))
 	}
 	count, _ := res.RowsAffected()
 	return uint(count), nil
 }
 
-func (r *MySQLAddressRepository) Update(id string, newAddress AddressParams) (AddressModel, error) {
-	previousAddress, err := r.GetOne(id)
+func (r *MySQLAddressRepository) Update(ctx context.Context, id string, newAddress AddressParams) (AddressModel, error) {
+	previousAddress, err := r.GetOne(ctx, id)
 	if err != nil {
 		return AddressModel{}, err
 	}
 	// This will check nil arguments and change only the non-nil ones
 	updatedAddress := AddressModel{
 		isActive:     nilcheck.NotNilBool(newAddress.IsActive, previousAddress.isActive),
-		isDeleted:    nilcheck.NotNilBool(newAddress.IsDeleted, previousAddress.isDeleted),
 		street:       nilcheck.NotNilString(newAddress.Street, previousAddress.street),
 		number:       nilcheck.NotNilString(newAddress.Number, previousAddress.number),
 		neighborhood: nilcheck.NotNilString(newAddress.Neighborhood, previousAddress.neighborhood),
@@ -259,13 +219,12 @@ func (r *MySQLAddressRepository) Update(id string, newAddress AddressParams) (Ad
 		name:         nilcheck.NotNilString(newAddress.Name, previousAddress.name),
 	}
 	query := `UPDATE addresses 
-		SET isActive = ?, isDeleted = ?, street = ?, number = ?, neighborhood = ?, complement = ?, city = ?, state = ?, country = ?, latitude = ?, longitude = ?, name = ? 
-		WHERE id = ?`
+		SET isActive = ?, street = ?, number = ?, neighborhood = ?, complement = ?, city = ?, state = ?, country = ?, latitude = ?, longitude = ?, name = ?, updatedAt = ?
+		WHERE id = ? AND isDeleted = FALSE`
 
 	_,
-		err = r.db.Exec(query,
+		err = r.db.ExecContext(ctx, query,
 		updatedAddress.isActive,
-		updatedAddress.isDeleted,
 		updatedAddress.street,
 		updatedAddress.number,
 		updatedAddress.neighborhood,
@@ -276,9 +235,36 @@ func (r *MySQLAddressRepository) Update(id string, newAddress AddressParams) (Ad
 		updatedAddress.latitude,
 		updatedAddress.longitude,
 		updatedAddress.name,
+		timestamp.New(r.clock.Now()),
 		id)
 	if err != nil {
-		return AddressModel{}, fmt.Errorf("failed to update address: %w", err)
+		return AddressModel{}, fmt.Errorf("failed to update address: %w", db.TranslateError(err))
+	}
+	return r.GetOne(ctx, id)
+}
+
+func (r *MySQLAddressRepository) Restore(ctx context.Context, id string) (AddressModel, error) {
+	query := `UPDATE addresses SET isDeleted = FALSE, deletedAt = NULL WHERE id = ? AND isDeleted = TRUE`
+	res, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return AddressModel{}, fmt.Errorf("failed to restore address: %w", db.TranslateError(err))
+	}
+	count, _ := res.RowsAffected()
+	if count == 0 {
+		return AddressModel{}, fmt.Errorf("deleted address %w", apperror.ErrNotFound)
+	}
+	return r.GetOne(ctx, id)
+}
+
+func (r *MySQLAddressRepository) Purge(ctx context.Context, id string) (uint, error) {
+	query := `DELETE FROM addresses WHERE id = ?`
+	res, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge address: %w", db.TranslateError(err))
 	}
-	return r.GetOne(id)
+	count, _ := res.RowsAffected()
+	if count == 0 {
+		return 0, fmt.Errorf("address %w", apperror.ErrNotFound)
+	}
+	return uint(count), nil
 }
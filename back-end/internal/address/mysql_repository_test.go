@@ -1,10 +1,16 @@
 package address_test
 
 import (
+	"context"
 	"regexp"
 	"sipub-test/internal/address"
+	"sipub-test/internal/filter"
+	"sipub-test/internal/pagination"
+	"sipub-test/pkg/clock"
 	testhelper "sipub-test/pkg/test_helper"
+	"sipub-test/pkg/timestamp"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
@@ -18,8 +24,7 @@ func TestCreateAddress(t *testing.T) {
 		}
 		defer db.Close()
 
-		repo := &address.MySQLAddressRepository{}
-		repo.SetDB(db)
+		repo := address.NewMySQLAddressRepository(db, clock.System{})
 
 		params := address.AddressParams{
 			IsActive:     testhelper.BoolPointer(true),
@@ -33,10 +38,10 @@ func TestCreateAddress(t *testing.T) {
 		}
 
 		mock.ExpectExec(`INSERT INTO addresses`).
-			WithArgs(sqlmock.AnyArg(), true, false, sqlmock.AnyArg(), "Main Street", "123", "Downtown", "", "Metropolis", "NY", "USA", float64(0), float64(0), "").
+			WithArgs(sqlmock.AnyArg(), true, false, sqlmock.AnyArg(), sqlmock.AnyArg(), "Main Street", "123", "Downtown", "", "Metropolis", "NY", "USA", float64(0), float64(0), "").
 			WillReturnResult(sqlmock.NewResult(1, 1))
 
-		addr, err := repo.Create(params)
+		addr, err := repo.Create(context.Background(), params)
 
 		assert.NoError(t, err, "Shouldn't contain any errors")
 		assert.Equal(t, *params.IsActive, addr.GetIsActive())
@@ -53,17 +58,16 @@ func TestGetAllAddresses(t *testing.T) {
 		}
 		defer db.Close()
 
-		repo := &address.MySQLAddressRepository{}
-		repo.SetDB(db)
+		repo := address.NewMySQLAddressRepository(db, clock.System{})
 
-		rows := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "street", "number", "neighborhood", "complement", "city", "state", "country", "latitude", "longitude", "name"}).
-			AddRow("123", true, false, "2025-01-15 12:00:00", "Main Street", "123", "Downtown", "", "Metropolis", "NY", "USA", 0, 0, "")
+		rows := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "updatedAt", "street", "number", "neighborhood", "complement", "city", "state", "country", "latitude", "longitude", "name", "deletedAt"}).
+			AddRow("123", true, false, "2025-01-15 12:00:00", "2025-01-15 12:00:00", "Main Street", "123", "Downtown", "", "Metropolis", "NY", "USA", 0, 0, "", nil)
 
-		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, street, number, neighborhood, complement, city, state, country, latitude, longitude, name FROM addresses WHERE 1=1`).
+		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, updatedAt, street, number, neighborhood, complement, city, state, country, latitude, longitude, name, deletedAt FROM addresses WHERE 1=1`).
 			WillReturnRows(rows)
 
-		filter := address.AddressParams{}
-		addresses, err := repo.GetAll(filter)
+		where := filter.Filter{}
+		addresses, err := repo.GetAll(context.Background(), where, pagination.Page{})
 
 		assert.NoError(t, err, "Should have no errors")
 		assert.Len(t, addresses, 1, "Length should be 1")
@@ -78,17 +82,16 @@ func TestGetAddressByID(t *testing.T) {
 	}
 	defer db.Close()
 
-	repo := &address.MySQLAddressRepository{}
-	repo.SetDB(db)
+	repo := address.NewMySQLAddressRepository(db, clock.System{})
 
-	rows := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "street", "number", "neighborhood", "complement", "city", "state", "country", "latitude", "longitude", "name"}).
-		AddRow("123", true, false, "2025-01-15 12:00:00", "Main Street", "123", "Downtown", 0, "Metropolis", "NY", "USA", 0, 0, "")
+	rows := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "updatedAt", "street", "number", "neighborhood", "complement", "city", "state", "country", "latitude", "longitude", "name"}).
+		AddRow("123", true, false, "2025-01-15 12:00:00", "2025-01-15 12:00:00", "Main Street", "123", "Downtown", 0, "Metropolis", "NY", "USA", 0, 0, "")
 
-	mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, street, number, neighborhood, complement, city, state, country, latitude, longitude, name FROM addresses WHERE id = ?`).
+	mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, updatedAt, street, number, neighborhood, complement, city, state, country, latitude, longitude, name FROM addresses WHERE id = ?`).
 		WithArgs("123").
 		WillReturnRows(rows)
 
-	address, err := repo.GetOne("123")
+	address, err := repo.GetOne(context.Background(), "123")
 
 	assert.NoError(t, err, "Should have no errors")
 	assert.Equal(t, "123", address.GetID(), "ID should match")
@@ -103,14 +106,15 @@ func TestDeleteAddress(t *testing.T) {
 	}
 	defer db.Close()
 
-	repo := &address.MySQLAddressRepository{}
-	repo.SetDB(db)
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	repo := address.NewMySQLAddressRepository(db, clock.Fixed(now))
 
-	mock.ExpectExec(`DELETE FROM addresses WHERE id = ?`).
-		WithArgs("123").
+	// The row is only flagged, Purge is what removes it
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE addresses SET isDeleted = TRUE, deletedAt = ? WHERE id = ? AND isDeleted = FALSE`)).
+		WithArgs(timestamp.New(now), "123").
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	count, err := repo.DeleteOne("123")
+	count, err := repo.DeleteOne(context.Background(), "123")
 
 	assert.NoError(t, err)
 	assert.Equal(t, uint(1), count)
@@ -124,8 +128,7 @@ func TestUpdateAddress(t *testing.T) {
 		}
 		defer db.Close()
 
-		repo := &address.MySQLAddressRepository{}
-		repo.SetDB(db)
+		repo := address.NewMySQLAddressRepository(db, clock.System{})
 
 		newParams := address.AddressParams{
 			IsActive:  testhelper.BoolPointer(false),
@@ -136,27 +139,27 @@ func TestUpdateAddress(t *testing.T) {
 		}
 
 		// Initial SELECT for GetOne
-		rows := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "street", "number", "neighborhood", "complement", "city", "state", "country", "latitude", "longitude", "name"}).
-			AddRow("123", true, false, "2025-01-15 12:00:00", "Main Street", "123", "Downtown", "", "Metropolis", "NY", "USA", 0, 0, "")
+		rows := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "updatedAt", "street", "number", "neighborhood", "complement", "city", "state", "country", "latitude", "longitude", "name"}).
+			AddRow("123", true, false, "2025-01-15 12:00:00", "2025-01-15 12:00:00", "Main Street", "123", "Downtown", "", "Metropolis", "NY", "USA", 0, 0, "")
 
-		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, street, number, neighborhood, complement, city, state, country, latitude, longitude, name FROM addresses WHERE id = ?`).
+		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, updatedAt, street, number, neighborhood, complement, city, state, country, latitude, longitude, name FROM addresses WHERE id = ?`).
 			WithArgs("123").
 			WillReturnRows(rows)
 
 		// UPDATE query
-		mock.ExpectExec(regexp.QuoteMeta(`UPDATE addresses SET isActive = ?, isDeleted = ?, street = ?, number = ?, neighborhood = ?, complement = ?, city = ?, state = ?, country = ?, latitude = ?, longitude = ?, name = ? WHERE id = ?`)).
-			WithArgs(false, true, "Updated Street", "123", "Downtown", "", "Gotham", "NY", "USA", float64(0), float64(0), "", "123").
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE addresses SET isActive = ?, street = ?, number = ?, neighborhood = ?, complement = ?, city = ?, state = ?, country = ?, latitude = ?, longitude = ?, name = ?, updatedAt = ? WHERE id = ? AND isDeleted = FALSE`)).
+			WithArgs(false, "Updated Street", "123", "Downtown", "", "Gotham", "NY", "USA", float64(0), float64(0), "", sqlmock.AnyArg(), "123").
 			WillReturnResult(sqlmock.NewResult(1, 1))
 
 		// Final SELECT for updated address
-		updatedRows := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "street", "number", "neighborhood", "complement", "city", "state", "country", "latitude", "longitude", "name"}).
-			AddRow("123", false, true, "2025-01-15 12:00:00", "Updated Street", "123", "Downtown", float64(0), "Gotham", "NY", "USA", float64(0), float64(0), "")
+		updatedRows := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "updatedAt", "street", "number", "neighborhood", "complement", "city", "state", "country", "latitude", "longitude", "name"}).
+			AddRow("123", false, true, "2025-01-15 12:00:00", "2025-01-15 12:00:00", "Updated Street", "123", "Downtown", float64(0), "Gotham", "NY", "USA", float64(0), float64(0), "")
 
-		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, street, number, neighborhood, complement, city, state, country, latitude, longitude, name FROM addresses WHERE id = ?`).
+		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, updatedAt, street, number, neighborhood, complement, city, state, country, latitude, longitude, name FROM addresses WHERE id = ?`).
 			WithArgs("123").
 			WillReturnRows(updatedRows)
 
-		address, err := repo.Update("123", newParams)
+		address, err := repo.Update(context.Background(), "123", newParams)
 
 		assert.NoError(t, err, "Should contain no errors")
 		assert.Equal(t, "123", address.GetID(), "ID should remain the same")
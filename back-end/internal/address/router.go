@@ -7,14 +7,11 @@ import (
 
 type AddressRouter struct {
 	baseEndPoint string
-	controller   internal.IController
+	controller   internal.ISoftDeleteController
 }
 
-func NewAddressRouter() AddressRouter {
-	router := AddressRouter{
-		controller: NewAddressController(),
-	}
-	return router
+func NewAddressRouter(controller internal.ISoftDeleteController) AddressRouter {
+	return AddressRouter{controller: controller}
 }
 
 func (r AddressRouter) Init(mux *http.ServeMux) {
@@ -26,6 +23,8 @@ func (r AddressRouter) Init(mux *http.ServeMux) {
 	r.deleteAll(mux)
 	r.deleteOne(mux)
 	r.update(mux)
+	r.restore(mux)
+	r.purge(mux)
 }
 
 func (r AddressRouter) create(mux *http.ServeMux) {
@@ -51,3 +50,11 @@ func (r AddressRouter) deleteOne(mux *http.ServeMux) {
 func (r AddressRouter) update(mux *http.ServeMux) {
 	mux.HandleFunc("PUT "+r.baseEndPoint+"/{id}", r.controller.Update)
 }
+
+func (r AddressRouter) restore(mux *http.ServeMux) {
+	mux.HandleFunc("POST "+r.baseEndPoint+"/{id}/restore", r.controller.Restore)
+}
+
+func (r AddressRouter) purge(mux *http.ServeMux) {
+	mux.HandleFunc("DELETE "+r.baseEndPoint+"/{id}/purge", r.controller.Purge)
+}
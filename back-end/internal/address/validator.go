@@ -1,67 +1,46 @@
 package address
 
 import (
-	"errors"
+	"sipub-test/pkg/apperror"
 )
 
 type AddressValidator struct{}
 
 func (v *AddressValidator) Validate(address AddressParams) error {
 	if address.IsActive == nil {
-		return errors.New("Invalid IsActive")
+		return apperror.NewValidationError("IsActive", "is required")
 	}
 	if address.IsDeleted == nil {
-		return errors.New("Invalid IsDeleted")
-	}
-
-	if address.Street == nil {
-		return errors.New("Invalid Street")
-	}
-	if *address.Street == "" {
-		return errors.New("Street cannot be empty")
-	}
-
-	if address.Number == nil {
-		return errors.New("Invalid Number")
-	}
-	if *address.Number == "" {
-		return errors.New("Number cannot be empty")
-	}
-
-	if address.Neighborhood == nil {
-		return errors.New("Invalid Neighborhood")
-	}
-	if *address.Neighborhood == "" {
-		return errors.New("Neighborhood cannot be empty")
-	}
-
-	if address.City == nil {
-		return errors.New("Invalid City")
-	}
-	if *address.City == "" {
-		return errors.New("City cannot be empty")
-	}
-
-	if address.State == nil {
-		return errors.New("Invalid State")
-	}
-	if *address.State == "" {
-		return errors.New("State cannot be empty")
-	}
-
-	if address.Country == nil {
-		return errors.New("Invalid Country")
-	}
-	if *address.Country == "" {
-		return errors.New("Country cannot be empty")
+		return apperror.NewValidationError("IsDeleted", "is required")
+	}
+
+	// Every text field is required and can't be empty
+	textFields := []struct {
+		name  string
+		value *string
+	}{
+		{"Street", address.Street},
+		{"Number", address.Number},
+		{"Neighborhood", address.Neighborhood},
+		{"City", address.City},
+		{"State", address.State},
+		{"Country", address.Country},
+	}
+	for _, field := range textFields {
+		if field.value == nil {
+			return apperror.NewValidationError(field.name, "is required")
+		}
+		if *field.value == "" {
+			return apperror.NewValidationError(field.name, "can't be empty")
+		}
 	}
 
 	if address.Latitude == nil {
-		return errors.New("Invalid Latitude")
+		return apperror.NewValidationError("Latitude", "is required")
 	}
 
 	if address.Longitude == nil {
-		return errors.New("Invalid Longitude")
+		return apperror.NewValidationError("Longitude", "is required")
 	}
 
 	return nil
@@ -0,0 +1,17 @@
+package auth
+
+import "context"
+
+type sessionKey struct{}
+
+// Used by the Guard, and by the tests that call a controller directly
+func WithSession(ctx context.Context, session Session) context.Context {
+	return context.WithValue(ctx, sessionKey{}, session)
+}
+
+// ok is false when the request went through no Guard, or through
+// Guard.Optional without a token
+func SessionFrom(ctx context.Context) (Session, bool) {
+	session, ok := ctx.Value(sessionKey{}).(Session)
+	return session, ok
+}
@@ -0,0 +1,186 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sipub-test/internal"
+	"sipub-test/internal/middleware"
+	"sipub-test/internal/response"
+	"sipub-test/pkg/apperror"
+	"sipub-test/pkg/email"
+	"sipub-test/pkg/password"
+)
+
+// The same error for an unknown email and a wrong password, so the login
+// can't be used to find out who has an account
+var errInvalidCredentials = fmt.Errorf("invalid email or password: %w", apperror.ErrUnauthorized)
+
+type AuthController struct {
+	repository            IAuthRepository
+	issuer                *TokenIssuer
+	resetSender           *PasswordResetSender
+	loginValidator        internal.IValidator[LoginParams]
+	refreshValidator      internal.IValidator[RefreshParams]
+	resetValidator        internal.IValidator[PasswordResetParams]
+	resetConfirmValidator internal.IValidator[PasswordResetConfirmParams]
+	logger                *slog.Logger
+}
+
+func NewAuthController(repository IAuthRepository, issuer *TokenIssuer, resetSender *PasswordResetSender, loginValidator internal.IValidator[LoginParams], refreshValidator internal.IValidator[RefreshParams], resetValidator internal.IValidator[PasswordResetParams], resetConfirmValidator internal.IValidator[PasswordResetConfirmParams], logger *slog.Logger) *AuthController {
+	return &AuthController{
+		repository:            repository,
+		issuer:                issuer,
+		resetSender:           resetSender,
+		loginValidator:        loginValidator,
+		refreshValidator:      refreshValidator,
+		resetValidator:        resetValidator,
+		resetConfirmValidator: resetConfirmValidator,
+		logger:                logger,
+	}
+}
+
+// Trades the email and the password for a new session
+func (c *AuthController) Login(w http.ResponseWriter, r *http.Request) {
+	var loginParams LoginParams
+	if err := json.NewDecoder(r.Body).Decode(&loginParams); err != nil {
+		response.BadRequest(w, r, "invalid request body: "+err.Error())
+		return
+	}
+	if err := c.loginValidator.Validate(loginParams); err != nil {
+		response.Error(w, r, c.logger, err)
+		return
+	}
+
+	address, err := email.Normalize(*loginParams.Email)
+	if err != nil {
+		response.Error(w, r, c.logger, errInvalidCredentials)
+		return
+	}
+	userID, passwordHash, err := c.repository.GetCredentials(r.Context(), address)
+	if errors.Is(err, apperror.ErrNotFound) {
+		password.MatchesNothing(*loginParams.Password)
+		response.Error(w, r, c.logger, errInvalidCredentials)
+		return
+	}
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
+	}
+	if !password.Matches(passwordHash, *loginParams.Password) {
+		response.Error(w, r, c.logger, errInvalidCredentials)
+		return
+	}
+
+	tokens, err := c.issuer.Issue()
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
+	}
+	if _, err := c.repository.CreateSession(r.Context(), userID, tokens); err != nil {
+		response.Error(w, r, c.logger, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, tokens.ToDTO())
+}
+
+// Trades the refresh token for a new pair, the old refresh token can't be
+// used again
+func (c *AuthController) Refresh(w http.ResponseWriter, r *http.Request) {
+	var refreshParams RefreshParams
+	if err := json.NewDecoder(r.Body).Decode(&refreshParams); err != nil {
+		response.BadRequest(w, r, "invalid request body: "+err.Error())
+		return
+	}
+	if err := c.refreshValidator.Validate(refreshParams); err != nil {
+		response.Error(w, r, c.logger, err)
+		return
+	}
+
+	tokens, err := c.issuer.Issue()
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
+	}
+	if _, err := c.repository.RefreshSession(r.Context(), *refreshParams.RefreshToken, tokens); err != nil {
+		response.Error(w, r, c.logger, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, tokens.ToDTO())
+}
+
+// Revokes the session of the access token, behind Guard.Session
+func (c *AuthController) Logout(w http.ResponseWriter, r *http.Request) {
+	session, ok := SessionFrom(r.Context())
+	if !ok {
+		response.Error(w, r, c.logger, fmt.Errorf("missing session: %w", apperror.ErrUnauthorized))
+		return
+	}
+	if err := c.repository.RevokeSession(r.Context(), session.ID); err != nil {
+		response.Error(w, r, c.logger, err)
+		return
+	}
+
+	response.Empty(w, http.StatusNoContent)
+}
+
+// Mails a reset token. The response is the same whether the email has an
+// account or not
+func (c *AuthController) RequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var resetParams PasswordResetParams
+	if err := json.NewDecoder(r.Body).Decode(&resetParams); err != nil {
+		response.BadRequest(w, r, "invalid request body: "+err.Error())
+		return
+	}
+	if err := c.resetValidator.Validate(resetParams); err != nil {
+		response.Error(w, r, c.logger, err)
+		return
+	}
+	address, err := email.Normalize(*resetParams.Email)
+	if err != nil {
+		response.Error(w, r, c.logger, apperror.NewValidationError("Email", err.Error()))
+		return
+	}
+
+	err = c.resetSender.Send(r.Context(), address)
+	if errors.Is(err, apperror.ErrNotFound) {
+		c.logger.InfoContext(r.Context(), "password reset asked for an unknown email",
+			"requestID", middleware.GetRequestID(r.Context()),
+		)
+	} else if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
+	}
+
+	response.Empty(w, http.StatusAccepted)
+}
+
+// Sets the new password with the mailed token, every session of the user is
+// revoked
+func (c *AuthController) ConfirmPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var confirmParams PasswordResetConfirmParams
+	if err := json.NewDecoder(r.Body).Decode(&confirmParams); err != nil {
+		response.BadRequest(w, r, "invalid request body: "+err.Error())
+		return
+	}
+	if err := c.resetConfirmValidator.Validate(confirmParams); err != nil {
+		response.Error(w, r, c.logger, err)
+		return
+	}
+
+	passwordHash, err := password.Hash(*confirmParams.Password)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
+	}
+	if err := c.repository.ResetPassword(r.Context(), *confirmParams.Token, passwordHash); err != nil {
+		response.Error(w, r, c.logger, err)
+		return
+	}
+
+	response.Empty(w, http.StatusNoContent)
+}
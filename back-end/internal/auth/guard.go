@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sipub-test/internal/response"
+	"sipub-test/pkg/apperror"
+	"strings"
+)
+
+// Wraps the handlers that need a session. The access token is read from the
+// "Authorization: Bearer <token>" header and the session is put in the
+// request context, see SessionFrom
+type Guard struct {
+	repository IAuthRepository
+	logger     *slog.Logger
+}
+
+func NewGuard(repository IAuthRepository, logger *slog.Logger) *Guard {
+	return &Guard{repository: repository, logger: logger}
+}
+
+// Refuses the requests without a valid access token
+func (g *Guard) Session(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session, err := g.authenticate(r)
+		if err != nil {
+			response.Error(w, r, g.logger, err)
+			return
+		}
+		next(w, r.WithContext(WithSession(r.Context(), session)))
+	}
+}
+
+// Only lets the user of the {id} in the path through, a user can't change
+// another one
+func (g *Guard) Self(next http.HandlerFunc) http.HandlerFunc {
+	return g.Session(func(w http.ResponseWriter, r *http.Request) {
+		session, _ := SessionFrom(r.Context())
+		if session.UserID != r.PathValue("id") {
+			response.Error(w, r, g.logger, fmt.Errorf("only the user itself can do this: %w", apperror.ErrForbidden))
+			return
+		}
+		next(w, r)
+	})
+}
+
+// For the routes that act on every user, only admins get through
+func (g *Guard) Admin(next http.HandlerFunc) http.HandlerFunc {
+	return g.Session(func(w http.ResponseWriter, r *http.Request) {
+		session, _ := SessionFrom(r.Context())
+		if !session.IsAdmin {
+			response.Error(w, r, g.logger, fmt.Errorf("only admins can do this: %w", apperror.ErrForbidden))
+			return
+		}
+		next(w, r)
+	})
+}
+
+// For the public routes that show more to the user itself. Without the
+// header the request goes through without a session, an invalid token is
+// still refused
+func (g *Guard) Optional(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			next(w, r)
+			return
+		}
+		g.Session(next)(w, r)
+	}
+}
+
+func (g *Guard) authenticate(r *http.Request) (Session, error) {
+	scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return Session{}, fmt.Errorf("missing bearer token: %w", apperror.ErrUnauthorized)
+	}
+	return g.repository.GetSession(r.Context(), token)
+}
@@ -0,0 +1,143 @@
+package auth_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sipub-test/internal/auth"
+	"sipub-test/pkg/apperror"
+	testhelper "sipub-test/pkg/test_helper"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Only knows the access tokens "access-123", of user-123, and "admin-123", of
+// the admin admin-123
+type fakeRepository struct {
+	auth.IAuthRepository
+}
+
+func (f *fakeRepository) GetSession(ctx context.Context, accessToken string) (auth.Session, error) {
+	switch accessToken {
+	case "access-123":
+		return auth.Session{ID: "session-123", UserID: "user-123"}, nil
+	case "admin-123":
+		return auth.Session{ID: "session-456", UserID: "admin-123", IsAdmin: true}, nil
+	}
+	return auth.Session{}, apperror.ErrUnauthorized
+}
+
+// Answers 200 with the user id of the session, if any
+func echoSession(w http.ResponseWriter, r *http.Request) {
+	session, _ := auth.SessionFrom(r.Context())
+	w.Write([]byte(session.UserID))
+}
+
+func request(id string, authorization string) *http.Request {
+	r := httptest.NewRequest(http.MethodPut, "/u/"+id, nil)
+	r.SetPathValue("id", id)
+	if authorization != "" {
+		r.Header.Set("Authorization", authorization)
+	}
+	return r
+}
+
+func TestGuardSession(t *testing.T) {
+	guard := auth.NewGuard(&fakeRepository{}, testhelper.DiscardLogger())
+
+	for name, c := range map[string]struct {
+		authorization string
+		status        int
+	}{
+		"ShouldAcceptAValidToken":      {"Bearer access-123", http.StatusOK},
+		"ShouldAcceptTheSchemeAnyCase": {"bearer access-123", http.StatusOK},
+		"ShouldRefuseAMissingHeader":   {"", http.StatusUnauthorized},
+		"ShouldRefuseAnotherScheme":    {"Basic access-123", http.StatusUnauthorized},
+		"ShouldRefuseAnEmptyToken":     {"Bearer ", http.StatusUnauthorized},
+		"ShouldRefuseAnUnknownToken":   {"Bearer access-456", http.StatusUnauthorized},
+	} {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+
+			guard.Session(echoSession)(w, request("user-123", c.authorization))
+
+			assert.Equal(t, c.status, w.Code)
+			if c.status == http.StatusOK {
+				assert.Equal(t, "user-123", w.Body.String(), "The session should be in the context")
+			}
+		})
+	}
+}
+
+func TestGuardSelf(t *testing.T) {
+	guard := auth.NewGuard(&fakeRepository{}, testhelper.DiscardLogger())
+
+	t.Run("ShouldLetTheUserItselfThrough", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		guard.Self(echoSession)(w, request("user-123", "Bearer access-123"))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+	t.Run("ShouldRefuseAnotherUser", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		guard.Self(echoSession)(w, request("user-456", "Bearer access-123"))
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+	t.Run("ShouldAskForASessionFirst", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		guard.Self(echoSession)(w, request("user-456", ""))
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
+
+func TestGuardAdmin(t *testing.T) {
+	guard := auth.NewGuard(&fakeRepository{}, testhelper.DiscardLogger())
+
+	t.Run("ShouldLetAdminsThrough", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		guard.Admin(echoSession)(w, request("user-123", "Bearer admin-123"))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+	t.Run("ShouldRefuseOtherUsers", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		guard.Admin(echoSession)(w, request("user-123", "Bearer access-123"))
+
+		assert.Equal(t, http.StatusForbidden, w.Code, "Not even on itself")
+	})
+	t.Run("ShouldAskForASessionFirst", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		guard.Admin(echoSession)(w, request("user-123", ""))
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
+
+func TestGuardOptional(t *testing.T) {
+	guard := auth.NewGuard(&fakeRepository{}, testhelper.DiscardLogger())
+
+	t.Run("ShouldLetAnonymousRequestsThrough", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		guard.Optional(echoSession)(w, request("user-123", ""))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Body.String())
+	})
+	t.Run("ShouldStillRefuseAnInvalidToken", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		guard.Optional(echoSession)(w, request("user-123", "Bearer access-456"))
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
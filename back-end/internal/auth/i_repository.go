@@ -0,0 +1,33 @@
+package auth
+
+import (
+	"context"
+	"sipub-test/pkg/timestamp"
+)
+
+type IAuthRepository interface {
+	// Returns the id and the password hash of the active user with the email.
+	// ErrNotFound when there is none, or when the user has no password yet
+	GetCredentials(ctx context.Context, email string) (string, string, error)
+
+	CreateSession(ctx context.Context, userID string, tokens Tokens) (Session, error)
+
+	// Trades the refresh token for the new tokens, the previous pair stops
+	// working. ErrUnauthorized when the refresh token is unknown, expired or
+	// was revoked
+	RefreshSession(ctx context.Context, refreshToken string, tokens Tokens) (Session, error)
+
+	// ErrUnauthorized when the access token is unknown, expired or was
+	// revoked, or when the user was deleted or deactivated since the login
+	GetSession(ctx context.Context, accessToken string) (Session, error)
+
+	RevokeSession(ctx context.Context, sessionID string) error
+
+	// Replaces the reset tokens of the user with the email and returns its
+	// id. ErrNotFound when no user has the email
+	IssuePasswordReset(ctx context.Context, email string, token string, expiresAt timestamp.Time) (string, error)
+
+	// Sets the password of the user the token was sent to and revokes all of
+	// its sessions. The token can't be used twice
+	ResetPassword(ctx context.Context, token string, passwordHash string) error
+}
@@ -0,0 +1,61 @@
+package auth
+
+import "sipub-test/pkg/timestamp"
+
+// Body of POST /auth/login
+type LoginParams struct {
+	Email    *string
+	Password *string
+}
+
+// Body of POST /auth/refresh
+type RefreshParams struct {
+	RefreshToken *string
+}
+
+// Body of POST /auth/password-reset, the token is mailed to this email
+type PasswordResetParams struct {
+	Email *string
+}
+
+// Body of POST /auth/password-reset/confirm
+type PasswordResetConfirmParams struct {
+	Token    *string
+	Password *string
+}
+
+// Returned by the login and the refresh. The access token goes in the
+// Authorization header as "Bearer <AccessToken>"
+type TokensDTO struct {
+	TokenType        string `json:"TokenType"`
+	AccessToken      string `json:"AccessToken"`
+	AccessExpiresAt  string `json:"AccessExpiresAt"`
+	RefreshToken     string `json:"RefreshToken"`
+	RefreshExpiresAt string `json:"RefreshExpiresAt"`
+}
+
+// The session a request was made with, see Guard
+type Session struct {
+	ID      string
+	UserID  string
+	IsAdmin bool // Only read by GetSession, the guard is the one that needs it
+}
+
+// A new pair of tokens. They are only sent to the client, the repository
+// stores their hash
+type Tokens struct {
+	access           string
+	accessExpiresAt  timestamp.Time
+	refresh          string
+	refreshExpiresAt timestamp.Time
+}
+
+func (t Tokens) ToDTO() TokensDTO {
+	return TokensDTO{
+		TokenType:        "Bearer",
+		AccessToken:      t.access,
+		AccessExpiresAt:  t.accessExpiresAt.String(),
+		RefreshToken:     t.refresh,
+		RefreshExpiresAt: t.refreshExpiresAt.String(),
+	}
+}
@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sipub-test/db"
+	"sipub-test/pkg/apperror"
+	"sipub-test/pkg/clock"
+	"sipub-test/pkg/randtoken"
+	"sipub-test/pkg/timestamp"
+
+	"github.com/google/uuid"
+)
+
+type MySQLAuthRepository struct {
+	db    db.Executor
+	clock clock.Clock
+}
+
+func NewMySQLAuthRepository(db db.Executor, clock clock.Clock) *MySQLAuthRepository {
+	return &MySQLAuthRepository{db: db, clock: clock}
+}
+
+func (r *MySQLAuthRepository) GetCredentials(ctx context.Context, email string) (string, string, error) {
+	var userID string
+	var passwordHash sql.NullString
+	query := `SELECT id, passwordHash FROM users WHERE email = ? AND isDeleted = FALSE AND isActive = TRUE`
+	err := r.db.QueryRowContext(ctx, query, email).Scan(&userID, &passwordHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", "", fmt.Errorf("user %w", apperror.ErrNotFound)
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get user: %w", err)
+	}
+	if !passwordHash.Valid {
+		return "", "", fmt.Errorf("password %w", apperror.ErrNotFound)
+	}
+	return userID, passwordHash.String, nil
+}
+
+func (r *MySQLAuthRepository) CreateSession(ctx context.Context, userID string, tokens Tokens) (Session, error) {
+	session := Session{ID: uuid.NewString(), UserID: userID}
+	query := `INSERT INTO sessions (id, user_id, accessTokenHash, accessExpiresAt, refreshTokenHash, refreshExpiresAt, createdAt) VALUES (?, ?, ?, ?, ?, ?, ?)`
+	_, err := r.db.ExecContext(ctx, query, session.ID, userID, randtoken.Hash(tokens.access), tokens.accessExpiresAt,
+		randtoken.Hash(tokens.refresh), tokens.refreshExpiresAt, timestamp.New(r.clock.Now()))
+	if err != nil {
+		return Session{}, fmt.Errorf("failed to create session: %w", db.TranslateError(err))
+	}
+	return session, nil
+}
+
+func (r *MySQLAuthRepository) RefreshSession(ctx context.Context, refreshToken string, tokens Tokens) (Session, error) {
+	now := timestamp.New(r.clock.Now())
+	var session Session
+	err := db.WithTx(ctx, r.db, func(tx db.Executor) error {
+		// Locked so the same refresh token can't be traded twice at once
+		var expiresAt timestamp.Time
+		query := `SELECT s.id, s.user_id, s.refreshExpiresAt FROM sessions s JOIN users u ON u.id = s.user_id WHERE s.refreshTokenHash = ? AND s.revokedAt IS NULL AND u.isDeleted = FALSE AND u.isActive = TRUE FOR UPDATE`
+		err := tx.QueryRowContext(ctx, query, randtoken.Hash(refreshToken)).Scan(&session.ID, &session.UserID, &expiresAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("refresh token is invalid or was already used: %w", apperror.ErrUnauthorized)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get session: %w", err)
+		}
+		if !now.Time().Before(expiresAt.Time()) {
+			return fmt.Errorf("refresh token has expired, log in again: %w", apperror.ErrUnauthorized)
+		}
+
+		query = `UPDATE sessions SET accessTokenHash = ?, accessExpiresAt = ?, refreshTokenHash = ?, refreshExpiresAt = ? WHERE id = ?`
+		_, err = tx.ExecContext(ctx, query, randtoken.Hash(tokens.access), tokens.accessExpiresAt, randtoken.Hash(tokens.refresh), tokens.refreshExpiresAt, session.ID)
+		if err != nil {
+			return fmt.Errorf("failed to refresh session: %w", db.TranslateError(err))
+		}
+		return nil
+	})
+	if err != nil {
+		return Session{}, err
+	}
+	return session, nil
+}
+
+func (r *MySQLAuthRepository) GetSession(ctx context.Context, accessToken string) (Session, error) {
+	var session Session
+	query := `SELECT s.id, s.user_id, u.isAdmin FROM sessions s JOIN users u ON u.id = s.user_id WHERE s.accessTokenHash = ? AND s.revokedAt IS NULL AND s.accessExpiresAt > ? AND u.isDeleted = FALSE AND u.isActive = TRUE`
+	err := r.db.QueryRowContext(ctx, query, randtoken.Hash(accessToken), timestamp.New(r.clock.Now())).Scan(&session.ID, &session.UserID, &session.IsAdmin)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Session{}, fmt.Errorf("access token is invalid or has expired: %w", apperror.ErrUnauthorized)
+	}
+	if err != nil {
+		return Session{}, fmt.Errorf("failed to get session: %w", err)
+	}
+	return session, nil
+}
+
+func (r *MySQLAuthRepository) RevokeSession(ctx context.Context, sessionID string) error {
+	query := `UPDATE sessions SET revokedAt = ? WHERE id = ? AND revokedAt IS NULL`
+	if _, err := r.db.ExecContext(ctx, query, timestamp.New(r.clock.Now()), sessionID); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLAuthRepository) IssuePasswordReset(ctx context.Context, email string, token string, expiresAt timestamp.Time) (string, error) {
+	id := uuid.NewString()
+	createdAt := timestamp.New(r.clock.Now())
+
+	var userID string
+	err := db.WithTx(ctx, r.db, func(tx db.Executor) error {
+		query := `SELECT id FROM users WHERE email = ? AND isDeleted = FALSE FOR UPDATE`
+		err := tx.QueryRowContext(ctx, query, email).Scan(&userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("user %w", apperror.ErrNotFound)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get user: %w", err)
+		}
+
+		// Only the last token sent works
+		if _, err := tx.ExecContext(ctx, `DELETE FROM password_reset_tokens WHERE user_id = ?`, userID); err != nil {
+			return fmt.Errorf("failed to delete previous tokens: %w", err)
+		}
+		query = `INSERT INTO password_reset_tokens (id, user_id, tokenHash, expiresAt, createdAt) VALUES (?, ?, ?, ?, ?)`
+		if _, err := tx.ExecContext(ctx, query, id, userID, randtoken.Hash(token), expiresAt, createdAt); err != nil {
+			return fmt.Errorf("failed to create token: %w", db.TranslateError(err))
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return userID, nil
+}
+
+func (r *MySQLAuthRepository) ResetPassword(ctx context.Context, token string, passwordHash string) error {
+	now := timestamp.New(r.clock.Now())
+	return db.WithTx(ctx, r.db, func(tx db.Executor) error {
+		var userID string
+		var expiresAt timestamp.Time
+		query := `SELECT t.user_id, t.expiresAt FROM password_reset_tokens t JOIN users u ON u.id = t.user_id WHERE t.tokenHash = ? AND u.isDeleted = FALSE FOR UPDATE`
+		err := tx.QueryRowContext(ctx, query, randtoken.Hash(token)).Scan(&userID, &expiresAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			return apperror.NewValidationError("Token", "is invalid or was already used")
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get token: %w", err)
+		}
+		if now.Time().After(expiresAt.Time()) {
+			return apperror.NewValidationError("Token", "has expired, ask for a new one")
+		}
+
+		if _, err := tx.ExecContext(ctx, `UPDATE users SET passwordHash = ?, updatedAt = ? WHERE id = ?`, passwordHash, now, userID); err != nil {
+			return fmt.Errorf("failed to reset password: %w", db.TranslateError(err))
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM password_reset_tokens WHERE user_id = ?`, userID); err != nil {
+			return fmt.Errorf("failed to delete used tokens: %w", err)
+		}
+		// Whoever knew the old password is logged out
+		if _, err := tx.ExecContext(ctx, `UPDATE sessions SET revokedAt = ? WHERE user_id = ? AND revokedAt IS NULL`, now, userID); err != nil {
+			return fmt.Errorf("failed to revoke sessions: %w", err)
+		}
+		return nil
+	})
+}
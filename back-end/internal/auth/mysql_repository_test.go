@@ -0,0 +1,219 @@
+package auth_test
+
+import (
+	"context"
+	"regexp"
+	"sipub-test/internal/auth"
+	"sipub-test/pkg/apperror"
+	"sipub-test/pkg/clock"
+	"sipub-test/pkg/randtoken"
+	"sipub-test/pkg/timestamp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var now = clock.Fixed(time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC))
+
+func TestGetCredentials(t *testing.T) {
+	t.Run("ShouldReturnTheHash", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := auth.NewMySQLAuthRepository(db, now)
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, passwordHash FROM users WHERE email = ? AND isDeleted = FALSE AND isActive = TRUE`)).
+			WithArgs("ana@example.com").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "passwordHash"}).AddRow("user-123", "$2a$10$hash"))
+
+		userID, hash, err := repo.GetCredentials(context.Background(), "ana@example.com")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "user-123", userID)
+		assert.Equal(t, "$2a$10$hash", hash)
+	})
+	t.Run("ShouldReturnNotFoundWithoutAPassword", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := auth.NewMySQLAuthRepository(db, now)
+		mock.ExpectQuery(`SELECT id, passwordHash FROM users`).
+			WithArgs("ana@example.com").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "passwordHash"}).AddRow("user-123", nil))
+
+		_, _, err = repo.GetCredentials(context.Background(), "ana@example.com")
+
+		assert.ErrorIs(t, err, apperror.ErrNotFound, "Users created before the passwords have to reset it")
+	})
+}
+
+func TestCreateSession(t *testing.T) {
+	t.Run("ShouldOnlyStoreTheHashes", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := auth.NewMySQLAuthRepository(db, now)
+		tokens, err := auth.NewTokenIssuer(now, 15*time.Minute, time.Hour).Issue()
+		assert.NoError(t, err)
+		dto := tokens.ToDTO()
+		mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO sessions (id, user_id, accessTokenHash, accessExpiresAt, refreshTokenHash, refreshExpiresAt, createdAt) VALUES (?, ?, ?, ?, ?, ?, ?)`)).
+			WithArgs(sqlmock.AnyArg(), "user-123", randtoken.Hash(dto.AccessToken), timestamp.New(now.Now().Add(15*time.Minute)),
+				randtoken.Hash(dto.RefreshToken), timestamp.New(now.Now().Add(time.Hour)), timestamp.New(now.Now())).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		session, err := repo.CreateSession(context.Background(), "user-123", tokens)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "user-123", session.UserID)
+		assert.NotEmpty(t, session.ID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestRefreshSession(t *testing.T) {
+	expectSession := func(mock sqlmock.Sqlmock, refreshExpiresAt string) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT s.id, s.user_id, s.refreshExpiresAt FROM sessions s JOIN users u ON u.id = s.user_id WHERE s.refreshTokenHash = ? AND s.revokedAt IS NULL AND u.isDeleted = FALSE AND u.isActive = TRUE FOR UPDATE`)).
+			WithArgs(randtoken.Hash("refresh-123")).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "refreshExpiresAt"}).AddRow("session-123", "user-123", refreshExpiresAt))
+	}
+
+	t.Run("ShouldReplaceBothTokens", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := auth.NewMySQLAuthRepository(db, now)
+		tokens, _ := auth.NewTokenIssuer(now, 15*time.Minute, time.Hour).Issue()
+		dto := tokens.ToDTO()
+		expectSession(mock, "2025-01-15 13:00:00")
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE sessions SET accessTokenHash = ?, accessExpiresAt = ?, refreshTokenHash = ?, refreshExpiresAt = ? WHERE id = ?`)).
+			WithArgs(randtoken.Hash(dto.AccessToken), sqlmock.AnyArg(), randtoken.Hash(dto.RefreshToken), sqlmock.AnyArg(), "session-123").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		session, err := repo.RefreshSession(context.Background(), "refresh-123", tokens)
+
+		assert.NoError(t, err)
+		assert.Equal(t, auth.Session{ID: "session-123", UserID: "user-123"}, session)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("ShouldRefuseAnExpiredToken", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := auth.NewMySQLAuthRepository(db, now)
+		tokens, _ := auth.NewTokenIssuer(now, 15*time.Minute, time.Hour).Issue()
+		expectSession(mock, "2025-01-15 12:00:00")
+		mock.ExpectRollback()
+
+		_, err = repo.RefreshSession(context.Background(), "refresh-123", tokens)
+
+		assert.ErrorIs(t, err, apperror.ErrUnauthorized)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("ShouldRefuseAnUnknownToken", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := auth.NewMySQLAuthRepository(db, now)
+		tokens, _ := auth.NewTokenIssuer(now, 15*time.Minute, time.Hour).Issue()
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT s.id`).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "refreshExpiresAt"}))
+		mock.ExpectRollback()
+
+		_, err = repo.RefreshSession(context.Background(), "already-used", tokens)
+
+		assert.ErrorIs(t, err, apperror.ErrUnauthorized)
+	})
+}
+
+func TestGetSession(t *testing.T) {
+	t.Run("ShouldOnlyFindActiveSessions", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := auth.NewMySQLAuthRepository(db, now)
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT s.id, s.user_id, u.isAdmin FROM sessions s JOIN users u ON u.id = s.user_id WHERE s.accessTokenHash = ? AND s.revokedAt IS NULL AND s.accessExpiresAt > ? AND u.isDeleted = FALSE AND u.isActive = TRUE`)).
+			WithArgs(randtoken.Hash("access-123"), timestamp.New(now.Now())).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "isAdmin"}))
+
+		_, err = repo.GetSession(context.Background(), "access-123")
+
+		assert.ErrorIs(t, err, apperror.ErrUnauthorized)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestResetPassword(t *testing.T) {
+	expectToken := func(mock sqlmock.Sqlmock, expiresAt string) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT t.user_id, t.expiresAt FROM password_reset_tokens t JOIN users u ON u.id = t.user_id WHERE t.tokenHash = ? AND u.isDeleted = FALSE FOR UPDATE`)).
+			WithArgs(randtoken.Hash("token-123")).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "expiresAt"}).AddRow("user-123", expiresAt))
+	}
+
+	t.Run("ShouldSetThePasswordAndRevokeTheSessions", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := auth.NewMySQLAuthRepository(db, now)
+		expectToken(mock, "2025-01-15 13:00:00")
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE users SET passwordHash = ?, updatedAt = ? WHERE id = ?`)).
+			WithArgs("$2a$10$hash", timestamp.New(now.Now()), "user-123").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM password_reset_tokens WHERE user_id = ?`)).
+			WithArgs("user-123").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE sessions SET revokedAt = ? WHERE user_id = ? AND revokedAt IS NULL`)).
+			WithArgs(timestamp.New(now.Now()), "user-123").
+			WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.ExpectCommit()
+
+		err = repo.ResetPassword(context.Background(), "token-123", "$2a$10$hash")
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("ShouldRejectAnExpiredToken", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := auth.NewMySQLAuthRepository(db, now)
+		expectToken(mock, "2025-01-15 11:59:59")
+		mock.ExpectRollback()
+
+		err = repo.ResetPassword(context.Background(), "token-123", "$2a$10$hash")
+
+		assert.ErrorIs(t, err, apperror.ErrValidation)
+		assert.NoError(t, mock.ExpectationsWereMet(), "The password shouldn't change")
+	})
+}
@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"sipub-test/internal/mailer"
+	"sipub-test/pkg/clock"
+	"sipub-test/pkg/randtoken"
+	"sipub-test/pkg/timestamp"
+	"time"
+)
+
+// Issues a password reset token and mails it to the user
+type PasswordResetSender struct {
+	repository IAuthRepository
+	mailer     mailer.IMailer
+	clock      clock.Clock
+	ttl        time.Duration // How long the token works
+}
+
+func NewPasswordResetSender(repository IAuthRepository, mailer mailer.IMailer, clock clock.Clock, ttl time.Duration) *PasswordResetSender {
+	return &PasswordResetSender{repository: repository, mailer: mailer, clock: clock, ttl: ttl}
+}
+
+// ErrNotFound when no user has the email, nothing is mailed then
+func (s *PasswordResetSender) Send(ctx context.Context, address string) error {
+	token, err := randtoken.New()
+	if err != nil {
+		return err
+	}
+	expiresAt := timestamp.New(s.clock.Now().Add(s.ttl))
+
+	if _, err := s.repository.IssuePasswordReset(ctx, address, token, expiresAt); err != nil {
+		return err
+	}
+
+	message := mailer.Message{
+		To:      address,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Use the token below to choose a new password, it expires at %s.\n\n%s\n\nSend it as {\"Token\": \"...\", \"Password\": \"...\"} to POST /auth/password-reset/confirm. If you didn't ask for it, ignore this email.\n",
+			expiresAt, token),
+	}
+	if err := s.mailer.Send(ctx, message); err != nil {
+		return fmt.Errorf("failed to send password reset email: %w", err)
+	}
+	return nil
+}
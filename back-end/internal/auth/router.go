@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"net/http"
+)
+
+// Sessions aren't a resource, there is no CRUD
+type IAuthController interface {
+	Login(http.ResponseWriter, *http.Request)
+	Refresh(http.ResponseWriter, *http.Request)
+	Logout(http.ResponseWriter, *http.Request)
+	RequestPasswordReset(http.ResponseWriter, *http.Request)
+	ConfirmPasswordReset(http.ResponseWriter, *http.Request)
+}
+
+type AuthRouter struct {
+	baseEndPoint string
+	controller   IAuthController
+	guard        *Guard
+}
+
+func NewAuthRouter(controller IAuthController, guard *Guard) AuthRouter {
+	return AuthRouter{controller: controller, guard: guard}
+}
+
+func (r AuthRouter) Init(mux *http.ServeMux) {
+	r.baseEndPoint = "/auth"
+
+	r.login(mux)
+	r.refresh(mux)
+	r.logout(mux)
+	r.requestPasswordReset(mux)
+	r.confirmPasswordReset(mux)
+}
+
+func (r AuthRouter) login(mux *http.ServeMux) {
+	mux.HandleFunc("POST "+r.baseEndPoint+"/login", r.controller.Login)
+}
+
+func (r AuthRouter) refresh(mux *http.ServeMux) {
+	mux.HandleFunc("POST "+r.baseEndPoint+"/refresh", r.controller.Refresh)
+}
+
+func (r AuthRouter) logout(mux *http.ServeMux) {
+	mux.HandleFunc("POST "+r.baseEndPoint+"/logout", r.guard.Session(r.controller.Logout))
+}
+
+func (r AuthRouter) requestPasswordReset(mux *http.ServeMux) {
+	mux.HandleFunc("POST "+r.baseEndPoint+"/password-reset", r.controller.RequestPasswordReset)
+}
+
+func (r AuthRouter) confirmPasswordReset(mux *http.ServeMux) {
+	mux.HandleFunc("POST "+r.baseEndPoint+"/password-reset/confirm", r.controller.ConfirmPasswordReset)
+}
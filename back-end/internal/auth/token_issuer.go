@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"sipub-test/pkg/clock"
+	"sipub-test/pkg/randtoken"
+	"sipub-test/pkg/timestamp"
+	"time"
+)
+
+// Creates the tokens of a login or a refresh
+type TokenIssuer struct {
+	clock      clock.Clock
+	accessTTL  time.Duration // Sent in every request, so it is short lived
+	refreshTTL time.Duration // How long a session lasts without being used
+}
+
+func NewTokenIssuer(clock clock.Clock, accessTTL time.Duration, refreshTTL time.Duration) *TokenIssuer {
+	return &TokenIssuer{clock: clock, accessTTL: accessTTL, refreshTTL: refreshTTL}
+}
+
+func (i *TokenIssuer) Issue() (Tokens, error) {
+	access, err := randtoken.New()
+	if err != nil {
+		return Tokens{}, err
+	}
+	refresh, err := randtoken.New()
+	if err != nil {
+		return Tokens{}, err
+	}
+	now := i.clock.Now()
+	return Tokens{
+		access:           access,
+		accessExpiresAt:  timestamp.New(now.Add(i.accessTTL)),
+		refresh:          refresh,
+		refreshExpiresAt: timestamp.New(now.Add(i.refreshTTL)),
+	}, nil
+}
@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"sipub-test/pkg/apperror"
+	"sipub-test/pkg/password"
+)
+
+type LoginValidator struct{}
+
+// The password rules aren't checked here, an old password that no longer
+// follows them should still log in
+func (v *LoginValidator) Validate(params LoginParams) error {
+	if err := required("Email", params.Email); err != nil {
+		return err
+	}
+	return required("Password", params.Password)
+}
+
+type RefreshValidator struct{}
+
+func (v *RefreshValidator) Validate(params RefreshParams) error {
+	return required("RefreshToken", params.RefreshToken)
+}
+
+type PasswordResetValidator struct{}
+
+func (v *PasswordResetValidator) Validate(params PasswordResetParams) error {
+	return required("Email", params.Email)
+}
+
+type PasswordResetConfirmValidator struct{}
+
+func (v *PasswordResetConfirmValidator) Validate(params PasswordResetConfirmParams) error {
+	if err := required("Token", params.Token); err != nil {
+		return err
+	}
+	if err := required("Password", params.Password); err != nil {
+		return err
+	}
+	if err := password.Validate(*params.Password); err != nil {
+		return apperror.NewValidationError("Password", err.Error())
+	}
+	return nil
+}
+
+// Helper functions
+
+func required(field string, value *string) error {
+	if value == nil {
+		return apperror.NewValidationError(field, "is required")
+	}
+	if *value == "" {
+		return apperror.NewValidationError(field, "can't be empty")
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package checkout
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sipub-test/internal"
+	"sipub-test/internal/response"
+)
+
+type CheckoutController struct {
+	repository ICheckoutRepository
+	validator  internal.IValidator[CheckoutParams]
+	logger     *slog.Logger
+}
+
+func NewCheckoutController(repository ICheckoutRepository, validator internal.IValidator[CheckoutParams], logger *slog.Logger) *CheckoutController {
+	return &CheckoutController{repository: repository, validator: validator, logger: logger}
+}
+
+func (c *CheckoutController) Checkout(w http.ResponseWriter, r *http.Request) {
+	var checkoutParams CheckoutParams
+	err := json.NewDecoder(r.Body).Decode(&checkoutParams)
+	if err != nil {
+		response.BadRequest(w, r, "invalid request body: "+err.Error())
+		return
+	}
+
+	if err := c.validator.Validate(checkoutParams); err != nil {
+		response.Error(w, r, c.logger, err)
+		return
+	}
+
+	checkout, err := c.repository.Checkout(r.Context(), checkoutParams)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
+	}
+
+	response.JSON(w, http.StatusCreated, checkout.ToDTO())
+}
@@ -0,0 +1,9 @@
+package checkout
+
+import "context"
+
+type ICheckoutRepository interface {
+	// Turns the user's shopping cart into a delivery, its products and a
+	// payment, emptying the cart. Either everything is created or nothing is
+	Checkout(ctx context.Context, params CheckoutParams) (CheckoutModel, error)
+}
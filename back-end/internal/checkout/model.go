@@ -0,0 +1,65 @@
+package checkout
+
+import "sipub-test/pkg/money"
+
+// What the client sends to check out its shopping cart. The fields are
+// pointers so the validator can tell a missing field from an empty one
+type CheckoutParams struct {
+	UserID    *string
+	AddressID *string
+}
+
+type CheckoutItemDTO struct {
+	ProductID     string      `json:"ProductID"`
+	ProductAmount uint        `json:"ProductAmount"`
+	UnitPrice     money.Money `json:"UnitPrice"`
+	Subtotal      money.Money `json:"Subtotal"`
+}
+
+type CheckoutDTO struct {
+	DeliveryID string            `json:"DeliveryID"`
+	PaymentID  string            `json:"PaymentID"`
+	CreatedAt  string            `json:"CreatedAt"`
+	Items      []CheckoutItemDTO `json:"Items"`
+	Total      money.Money       `json:"Total"`
+}
+
+// A cart line, with the price the product had when it was checked out
+type CheckoutItemModel struct {
+	cartID        string // shopping_cart row, removed by the checkout
+	productID     string
+	productAmount uint
+	unitPrice     money.Money
+}
+
+// Everything created by a checkout
+type CheckoutModel struct {
+	deliveryID string
+	paymentID  string
+	createdAt  string
+	items      []CheckoutItemModel
+	total      money.Money
+}
+
+func (c *CheckoutModel) ToDTO() CheckoutDTO {
+	dtoCheckout := CheckoutDTO{
+		DeliveryID: c.deliveryID,
+		PaymentID:  c.paymentID,
+		CreatedAt:  c.createdAt,
+		Items:      []CheckoutItemDTO{},
+		Total:      c.total,
+	}
+	for _, item := range c.items {
+		dtoCheckout.Items = append(dtoCheckout.Items, CheckoutItemDTO{
+			ProductID:     item.productID,
+			ProductAmount: item.productAmount,
+			UnitPrice:     item.unitPrice,
+			Subtotal:      item.subtotal(),
+		})
+	}
+	return dtoCheckout
+}
+
+func (i *CheckoutItemModel) subtotal() money.Money {
+	return i.unitPrice.Mul(int64(i.productAmount))
+}
@@ -0,0 +1,208 @@
+package checkout
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sipub-test/db"
+	"sipub-test/internal/delivery"
+	"sipub-test/internal/delivery_product"
+	"sipub-test/internal/inventory"
+	"sipub-test/internal/payment"
+	"sipub-test/internal/unit_of_work"
+	"sipub-test/internal/user_delivery"
+	"sipub-test/pkg/apperror"
+	"sipub-test/pkg/clock"
+	"sipub-test/pkg/timestamp"
+	"strings"
+)
+
+type MySQLCheckoutRepository struct {
+	uow   *unit_of_work.UnitOfWork
+	clock clock.Clock
+}
+
+func NewMySQLCheckoutRepository(uow *unit_of_work.UnitOfWork, clock clock.Clock) *MySQLCheckoutRepository {
+	return &MySQLCheckoutRepository{uow: uow, clock: clock}
+}
+
+func (r *MySQLCheckoutRepository) Checkout(ctx context.Context, params CheckoutParams) (CheckoutModel, error) {
+	var model CheckoutModel
+	isActive, isDeleted := true, false
+	err := r.uow.Do(ctx, func(ctx context.Context, repos unit_of_work.Repositories) error {
+		if _, err := repos.User.GetOne(ctx, *params.UserID); err != nil {
+			return err
+		}
+		if err := checkAddress(ctx, repos.Tx, *params.UserID, *params.AddressID); err != nil {
+			return err
+		}
+
+		now := timestamp.New(r.clock.Now())
+		items, err := lockCart(ctx, repos.Tx, *params.UserID, now)
+		if err != nil {
+			return err
+		}
+
+		createdDelivery, err := repos.Delivery.Create(ctx, delivery.DeliveryParams{
+			IsActive:  &isActive,
+			IsDeleted: &isDeleted,
+			UserID:    params.UserID,
+			AddressID: params.AddressID,
+		})
+		if err != nil {
+			return err
+		}
+		deliveryDTO := createdDelivery.ToDTO()
+		model = CheckoutModel{
+			deliveryID: deliveryDTO.Id,
+			createdAt:  deliveryDTO.CreatedAt,
+			items:      items,
+		}
+
+		for _, item := range items {
+			_, err := repos.DeliveryProduct.Create(ctx, delivery_product.DeliveryProductParams{
+				DeliveryID:    &model.deliveryID,
+				ProductID:     &item.productID,
+				ProductAmount: &item.productAmount,
+				UnitPrice:     &item.unitPrice,
+			})
+			if err != nil {
+				return err
+			}
+			model.total = model.total.Add(item.subtotal())
+		}
+
+		stockItems := make([]inventory.Item, len(items))
+		for i, item := range items {
+			stockItems[i] = inventory.Item{ProductID: item.productID, Quantity: item.productAmount}
+		}
+		if err := inventory.Reserve(ctx, repos.Tx, model.deliveryID, stockItems, now); err != nil {
+			return err
+		}
+
+		_, err = repos.UserDelivery.Create(ctx, user_delivery.UserDeliveryParams{
+			DeliveryID: &model.deliveryID,
+			UserID:     params.UserID,
+		})
+		if err != nil {
+			return err
+		}
+
+		createdPayment, err := repos.Payment.Create(ctx, payment.PaymentParams{
+			IsDeleted:  &isDeleted,
+			DeliveryID: &model.deliveryID,
+			Value:      &model.total,
+		})
+		if err != nil {
+			return err
+		}
+		model.paymentID = createdPayment.ToDTO().Id
+
+		return clearCart(ctx, repos.Tx, items)
+	})
+	if err != nil {
+		return CheckoutModel{}, err
+	}
+	return model, nil
+}
+
+// The address must exist and be one of the user's addresses
+func checkAddress(ctx context.Context, tx db.Executor, userID, addressID string) error {
+	var found int
+	query := `
+		SELECT 1
+		FROM
+			addresses a
+		JOIN
+			user_address ua ON ua.address_id = a.id
+		WHERE
+			a.id = ? AND ua.user_id = ? AND a.isDeleted = FALSE
+		LIMIT 1`
+	err := tx.QueryRowContext(ctx, query, addressID, userID).Scan(&found)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("address %w", apperror.ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get address: %w", err)
+	}
+	return nil
+}
+
+// Reads the cart with the current product prices and stock. The cart and
+// product rows are locked, so a concurrent checkout of the same cart waits for
+// this one and finds it empty, and the stock can't change before it is reserved
+func lockCart(ctx context.Context, tx db.Executor, userID string, now timestamp.Time) ([]CheckoutItemModel, error) {
+	// held is what the carts of other users are still holding
+	query := `
+		SELECT
+			c.id, c.product_id, c.product_amount, p.price, p.isActive, p.isDeleted,
+			CAST(p.stock AS SIGNED) - CAST(p.reserved AS SIGNED) - COALESCE((
+				SELECT SUM(o.product_amount) FROM shopping_cart o
+				WHERE o.product_id = c.product_id AND o.user_id <> c.user_id AND o.reservedUntil > ?
+			), 0) AS available
+		FROM
+			shopping_cart c
+		JOIN
+			products p ON p.id = c.product_id
+		WHERE
+			c.user_id = ?
+		ORDER BY
+			c.id
+		FOR UPDATE`
+	rows, err := tx.QueryContext(ctx, query, now, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get shopping cart: %w", err)
+	}
+	defer rows.Close()
+
+	var items []CheckoutItemModel
+	for rows.Next() {
+		var item CheckoutItemModel
+		var isActive, isDeleted bool
+		var available int64
+		err := rows.Scan(&item.cartID,
+			&item.productID,
+			&item.productAmount,
+			&item.unitPrice,
+			&isActive,
+			&isDeleted,
+			&available,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan shopping cart: %w", err)
+		}
+		if !isActive || isDeleted {
+			return nil, fmt.Errorf("product %s is no longer available: %w", item.productID, apperror.ErrConflict)
+		}
+		if int64(item.productAmount) > available {
+			return nil, fmt.Errorf("not enough stock for product %s: %w", item.productID, apperror.ErrConflict)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate shopping cart: %w", err)
+	}
+
+	if len(items) == 0 {
+		return nil, apperror.NewValidationError("UserID", "has an empty shopping cart")
+	}
+	return items, nil
+}
+
+// Only the rows that were read are removed, anything added to the cart after
+// it was locked stays there
+func clearCart(ctx context.Context, tx db.Executor, items []CheckoutItemModel) error {
+	placeholders := make([]string, len(items))
+	args := make([]interface{}, len(items))
+	for i, item := range items {
+		placeholders[i] = "?"
+		args[i] = item.cartID
+	}
+	query := `DELETE FROM shopping_cart WHERE id IN (` + strings.Join(placeholders, ", ") + `)`
+	_, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to clear shopping cart: %w", db.TranslateError(err))
+	}
+	return nil
+}
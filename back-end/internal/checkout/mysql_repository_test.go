@@ -0,0 +1,220 @@
+package checkout_test
+
+import (
+	"context"
+	"errors"
+	"sipub-test/internal/checkout"
+	"sipub-test/internal/unit_of_work"
+	"sipub-test/pkg/apperror"
+	"sipub-test/pkg/clock"
+	"sipub-test/pkg/money"
+	testhelper "sipub-test/pkg/test_helper"
+	"sipub-test/pkg/timestamp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var checkoutParams = checkout.CheckoutParams{
+	UserID:    testhelper.StringPointer("user-123"),
+	AddressID: testhelper.StringPointer("address-123"),
+}
+
+func expectOwnership(mock sqlmock.Sqlmock) {
+	mock.ExpectBegin()
+	expectUser(mock)
+	mock.ExpectQuery(`SELECT 1 FROM\s+addresses a\s+JOIN\s+user_address ua`).
+		WithArgs("address-123", "user-123").
+		WillReturnRows(sqlmock.NewRows([]string{"1"}).AddRow(1))
+}
+
+func expectUser(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(`FROM users WHERE id = \? AND isDeleted = FALSE`).
+		WithArgs("user-123").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "updatedAt", "email", "cpf", "name", "emailVerifiedAt"}).
+			AddRow("user-123", true, false, "2025-01-15 12:00:00", "2025-01-15 12:00:00", "testuser@example.com", "12345678909", "Test User", nil))
+}
+
+func cartColumns() []string {
+	return []string{"id", "product_id", "product_amount", "price", "isActive", "isDeleted", "available"}
+}
+
+func TestCheckout(t *testing.T) {
+	t.Run("ValidCheckout", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+		createdAt := timestamp.New(now)
+		repo := checkout.NewMySQLCheckoutRepository(unit_of_work.NewUnitOfWork(db, clock.Fixed(now)), clock.Fixed(now))
+
+		expectOwnership(mock)
+		mock.ExpectQuery(`SELECT\s+c.id, c.product_id, c.product_amount, p.price, p.isActive, p.isDeleted,\s+CAST\(p.stock AS SIGNED\)(.|\n)*FROM\s+shopping_cart c(.|\n)*FOR UPDATE`).
+			WithArgs(sqlmock.AnyArg() /*now*/, "user-123").
+			WillReturnRows(sqlmock.NewRows(cartColumns()).
+				AddRow("cart-1", "product-1", 2, 19.99, true, false, 10).
+				AddRow("cart-2", "product-2", 1, 5.5, true, false, 10))
+		mock.ExpectExec(`INSERT INTO deliveries`).
+			WithArgs(sqlmock.AnyArg(), true, false, createdAt, createdAt, "user-123", "address-123", money.Zero).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(`INSERT INTO delivery_product`).
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "product-1", uint(2), money.FromCents(1999)).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(`INSERT INTO delivery_product`).
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "product-2", uint(1), money.FromCents(550)).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(`UPDATE products SET reserved = reserved \+ \? WHERE id = \?`).
+			WithArgs(uint(2), "product-1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`INSERT INTO stock_movements`).
+			WithArgs(sqlmock.AnyArg(), "product-1", sqlmock.AnyArg(), 2, "reservation", createdAt).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(`UPDATE products SET reserved = reserved \+ \? WHERE id = \?`).
+			WithArgs(uint(1), "product-2").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`INSERT INTO stock_movements`).
+			WithArgs(sqlmock.AnyArg(), "product-2", sqlmock.AnyArg(), 1, "reservation", createdAt).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(`INSERT INTO user_delivery`).
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "user-123").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		// The payment covers the whole delivery
+		mock.ExpectQuery(`SELECT shippingFee FROM deliveries`).
+			WillReturnRows(sqlmock.NewRows([]string{"shippingFee"}).AddRow(0))
+		mock.ExpectQuery(`FROM\s+delivery_product dp`).
+			WillReturnRows(sqlmock.NewRows([]string{"total"}).AddRow(45.48))
+		mock.ExpectQuery(`FROM\s+payments`).
+			WillReturnRows(sqlmock.NewRows([]string{"paid", "pending"}).AddRow(0, 0))
+		mock.ExpectExec(`INSERT INTO payments`).
+			WithArgs(sqlmock.AnyArg(), false, createdAt, sqlmock.AnyArg(), money.FromCents(4548)).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(`DELETE FROM shopping_cart WHERE id IN \(\?, \?\)`).
+			WithArgs("cart-1", "cart-2").
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectCommit()
+
+		created, err := repo.Checkout(context.Background(), checkoutParams)
+
+		assert.NoError(t, err, "Shouldn't contain any errors")
+		dto := created.ToDTO()
+		assert.NotEmpty(t, dto.DeliveryID)
+		assert.NotEmpty(t, dto.PaymentID)
+		assert.Len(t, dto.Items, 2)
+		assert.Equal(t, money.FromCents(3998), dto.Items[0].Subtotal)
+		assert.Equal(t, money.FromCents(4548), dto.Total)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("ShouldRejectAnEmptyCart", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := checkout.NewMySQLCheckoutRepository(unit_of_work.NewUnitOfWork(db, clock.System{}), clock.System{})
+
+		expectOwnership(mock)
+		mock.ExpectQuery(`FROM\s+shopping_cart c`).
+			WithArgs(sqlmock.AnyArg() /*now*/, "user-123").
+			WillReturnRows(sqlmock.NewRows(cartColumns()))
+		mock.ExpectRollback()
+
+		_, err = repo.Checkout(context.Background(), checkoutParams)
+
+		assert.True(t, errors.Is(err, apperror.ErrValidation), "An empty cart can't be checked out")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("ShouldRejectUnavailableProducts", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := checkout.NewMySQLCheckoutRepository(unit_of_work.NewUnitOfWork(db, clock.System{}), clock.System{})
+
+		expectOwnership(mock)
+		mock.ExpectQuery(`FROM\s+shopping_cart c`).
+			WithArgs(sqlmock.AnyArg() /*now*/, "user-123").
+			WillReturnRows(sqlmock.NewRows(cartColumns()).
+				AddRow("cart-1", "product-1", 2, 19.99, false, false, 10))
+		mock.ExpectRollback()
+
+		_, err = repo.Checkout(context.Background(), checkoutParams)
+
+		assert.True(t, errors.Is(err, apperror.ErrConflict), "Inactive products can't be bought")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("ShouldRejectProductsWithoutStock", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := checkout.NewMySQLCheckoutRepository(unit_of_work.NewUnitOfWork(db, clock.System{}), clock.System{})
+
+		expectOwnership(mock)
+		mock.ExpectQuery(`FROM\s+shopping_cart c`).
+			WithArgs(sqlmock.AnyArg(), "user-123").
+			WillReturnRows(sqlmock.NewRows(cartColumns()).
+				AddRow("cart-1", "product-1", 2, 19.99, true, false, 1))
+		mock.ExpectRollback()
+
+		_, err = repo.Checkout(context.Background(), checkoutParams)
+
+		assert.True(t, errors.Is(err, apperror.ErrConflict), "Only 1 of the 2 products is available")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("ShouldRollbackWhenAnInsertFails", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := checkout.NewMySQLCheckoutRepository(unit_of_work.NewUnitOfWork(db, clock.System{}), clock.System{})
+
+		expectOwnership(mock)
+		mock.ExpectQuery(`FROM\s+shopping_cart c`).
+			WithArgs(sqlmock.AnyArg() /*now*/, "user-123").
+			WillReturnRows(sqlmock.NewRows(cartColumns()).
+				AddRow("cart-1", "product-1", 2, 19.99, true, false, 10))
+		mock.ExpectExec(`INSERT INTO deliveries`).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(`INSERT INTO delivery_product`).
+			WillReturnError(errors.New("connection lost"))
+		mock.ExpectRollback()
+
+		_, err = repo.Checkout(context.Background(), checkoutParams)
+
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet(), "Nothing should be committed")
+	})
+	t.Run("ShouldRequireTheUsersAddress", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := checkout.NewMySQLCheckoutRepository(unit_of_work.NewUnitOfWork(db, clock.System{}), clock.System{})
+
+		mock.ExpectBegin()
+		expectUser(mock)
+		mock.ExpectQuery(`FROM\s+addresses a`).
+			WithArgs("address-123", "user-123").
+			WillReturnRows(sqlmock.NewRows([]string{"1"}))
+		mock.ExpectRollback()
+
+		_, err = repo.Checkout(context.Background(), checkoutParams)
+
+		assert.True(t, errors.Is(err, apperror.ErrNotFound))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,29 @@
+package checkout
+
+import (
+	"net/http"
+)
+
+// The checkout is not a CRUD resource, so it has its own controller interface
+type ICheckoutController interface {
+	Checkout(http.ResponseWriter, *http.Request)
+}
+
+type CheckoutRouter struct {
+	baseEndPoint string
+	controller   ICheckoutController
+}
+
+func NewCheckoutRouter(controller ICheckoutController) CheckoutRouter {
+	return CheckoutRouter{controller: controller}
+}
+
+func (r CheckoutRouter) Init(mux *http.ServeMux) {
+	r.baseEndPoint = "/checkout"
+
+	r.checkout(mux)
+}
+
+func (r CheckoutRouter) checkout(mux *http.ServeMux) {
+	mux.HandleFunc("POST "+r.baseEndPoint, r.controller.Checkout)
+}
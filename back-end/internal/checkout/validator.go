@@ -0,0 +1,17 @@
+package checkout
+
+import (
+	"sipub-test/pkg/apperror"
+)
+
+type CheckoutValidator struct{}
+
+func (v *CheckoutValidator) Validate(checkout CheckoutParams) error {
+	if checkout.UserID == nil || *checkout.UserID == "" {
+		return apperror.NewValidationError("UserID", "is required")
+	}
+	if checkout.AddressID == nil || *checkout.AddressID == "" {
+		return apperror.NewValidationError("AddressID", "is required")
+	}
+	return nil
+}
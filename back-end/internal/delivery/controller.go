@@ -2,173 +2,123 @@ package delivery
 
 import (
 	"encoding/json"
-	"fmt"
+	"log/slog"
 	"net/http"
-	"strings"
+	"sipub-test/internal"
+	"sipub-test/internal/filter"
+	"sipub-test/internal/pagination"
+	"sipub-test/internal/response"
 )
 
 type DeliveryController struct {
-	// TODO
 	repository IDeliveryRepository
+	validator  internal.IValidator[DeliveryParams]
+	logger     *slog.Logger
 }
 
-// Used for testing
-func (c *DeliveryController) SetRepository(repo IDeliveryRepository) {
-	c.repository = repo
-}
-
-func NewDeliveryController() *DeliveryController {
-	return &DeliveryController{repository: NewMySQLDeliveryRepository()}
+func NewDeliveryController(repository IDeliveryRepository, validator internal.IValidator[DeliveryParams], logger *slog.Logger) *DeliveryController {
+	return &DeliveryController{repository: repository, validator: validator, logger: logger}
 }
 
 func (c *DeliveryController) Create(w http.ResponseWriter, r *http.Request) {
 	var deliveryParam DeliveryParams
 	err := json.NewDecoder(r.Body).Decode(&deliveryParam)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		response.BadRequest(w, r, "invalid request body: "+err.Error())
 		return
 	}
 
-	// Validation
-
-	createdDelivery, err := c.repository.Create(deliveryParam)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := c.validator.Validate(deliveryParam); err != nil {
+		response.Error(w, r, c.logger, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-
-	if err := json.NewEncoder(w).Encode(createdDelivery.ToDTO()); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	createdDelivery, err := c.repository.Create(r.Context(), deliveryParam)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
 		return
 	}
+
+	response.JSON(w, http.StatusCreated, createdDelivery.ToDTO())
 }
 
 func (c *DeliveryController) GetAll(w http.ResponseWriter, r *http.Request) {
-	var deliveryParams DeliveryParams
 	queryParams := r.URL.Query()
 
-	// First it checks to see if the values in the querystring are valid
-	for key := range queryParams {
-		if strings.ToLower(key) != "isactive" &&
-			strings.ToLower(key) != "isdeleted" &&
-			strings.ToLower(key) != "createdat" &&
-			strings.ToLower(key) != "userid" &&
-			strings.ToLower(key) != "addressid" {
-			http.Error(w, fmt.Sprintf("Invalid query parameter: %s", key), http.StatusBadRequest)
-			return
-		}
-	}
-
-	// If the values are valid it will check what each value is
-	if isActive := queryParams.Get("IsActive"); isActive != "" {
-		value := isActive == "true"
-		*deliveryParams.IsActive = value
-	}
-
-	if isDeleted := queryParams.Get("IsDeleted"); isDeleted != "" {
-		value := isDeleted == "true"
-		*deliveryParams.IsDeleted = value
-	}
-
-	if createdAt := queryParams.Get("CreatedAt"); createdAt != "" {
-		*deliveryParams.CreatedAt = createdAt
+	// The accepted fields and operators are declared in filterFields
+	where, err := filter.Parse(queryParams, filterFields)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
 	}
 
-	if userID := queryParams.Get("UserID"); userID != "" {
-		*deliveryParams.UserID = userID
+	page, err := pagination.Parse(queryParams, sortFields)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
 	}
 
-	if addressID := queryParams.Get("AddressID"); addressID != "" {
-		*deliveryParams.AddressID = addressID
+	foundDeliveryes, err := c.repository.GetAll(r.Context(), where, page)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
 	}
+	foundDeliveryes, hasMore := pagination.Trim(page, foundDeliveryes)
 
-	// It now passes the delivery param as a "filter" and gets the found deliveries
-	foundDeliveryes, err := c.repository.GetAll(deliveryParams)
+	total, err := c.repository.Count(r.Context(), where)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		response.Error(w, r, c.logger, err)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
 
 	dtoFoundDeliveries := []DeliveryDTO{}
 	for i := 0; i < len(foundDeliveryes); i++ {
 		dtoFoundDeliveries = append(dtoFoundDeliveries, foundDeliveryes[i].ToDTO())
 	}
-	// Returns the DTO deliveries
-	if err := json.NewEncoder(w).Encode(dtoFoundDeliveries); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	var lastID string
+	if hasMore {
+		lastID = dtoFoundDeliveries[len(dtoFoundDeliveries)-1].Id
 	}
+	pagination.WriteHeaders(w, r, page, total, hasMore, lastID)
+	// Returns the DTO deliveries
+	response.JSON(w, http.StatusOK, dtoFoundDeliveries)
 }
 
 func (c *DeliveryController) GetOne(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
-	delivery, err := c.repository.GetOne(id)
+	delivery, err := c.repository.GetOne(r.Context(), id)
 	if err != nil {
-		fmt.Println(err)
-		http.Error(w, err.Error(), http.StatusNotFound) // The Id was not found but the request did go though
-		return
-	}
-	if err := json.NewEncoder(w).Encode(delivery.ToDTO()); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		response.Error(w, r, c.logger, err)
 		return
 	}
+	response.JSON(w, http.StatusOK, delivery.ToDTO())
 }
 
 func (c *DeliveryController) DeleteAll(w http.ResponseWriter, r *http.Request) {
-	var deliveryParams DeliveryParams
-	queryParams := r.URL.Query()
-
-	if isActive := queryParams.Get("IsActive"); isActive != "" {
-		value := isActive == "true"
-		*deliveryParams.IsActive = value
-	}
-
-	if isDeleted := queryParams.Get("IsDeleted"); isDeleted != "" {
-		value := isDeleted == "true"
-		*deliveryParams.IsDeleted = value
-	}
-
-	if createdAt := queryParams.Get("CreatedAt"); createdAt != "" {
-		*deliveryParams.CreatedAt = createdAt
-	}
-
-	if userID := queryParams.Get("UserID"); userID != "" {
-		*deliveryParams.UserID = userID
+	where, err := filter.Parse(r.URL.Query(), filterFields)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
 	}
 
-	if addressID := queryParams.Get("AddressID"); addressID != "" {
-		*deliveryParams.AddressID = addressID
-	}
-	count, err := c.repository.DeleteAll(deliveryParams)
+	count, err := c.repository.DeleteAll(r.Context(), where)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		response.Error(w, r, c.logger, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(count); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
+	response.JSON(w, http.StatusOK, count)
 }
 
 func (c *DeliveryController) DeleteOne(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
-	count, err := c.repository.DeleteOne(id)
+	count, err := c.repository.DeleteOne(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound) // Did go through, none found
+		response.Error(w, r, c.logger, err)
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(count); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
+	response.JSON(w, http.StatusOK, count)
 }
 
 func (c *DeliveryController) Update(w http.ResponseWriter, r *http.Request) {
@@ -176,18 +126,36 @@ func (c *DeliveryController) Update(w http.ResponseWriter, r *http.Request) {
 	var deliveryParams DeliveryParams
 	err := json.NewDecoder(r.Body).Decode(&deliveryParams)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		response.BadRequest(w, r, "invalid request body: "+err.Error())
 		return
 	}
-	delivery, err := c.repository.Update(id, deliveryParams)
+	delivery, err := c.repository.Update(r.Context(), id, deliveryParams)
 	if err != nil {
-		fmt.Println(err)
-		http.Error(w, err.Error(), http.StatusNotFound) // Did go through, none found
+		response.Error(w, r, c.logger, err)
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(delivery.ToDTO()); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	response.JSON(w, http.StatusOK, delivery.ToDTO())
+}
+
+func (c *DeliveryController) Restore(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	delivery, err := c.repository.Restore(r.Context(), id)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
 	}
+
+	response.JSON(w, http.StatusOK, delivery.ToDTO())
+}
+
+func (c *DeliveryController) Purge(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	count, err := c.repository.Purge(r.Context(), id)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, count)
 }
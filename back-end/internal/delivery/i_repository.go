@@ -1,23 +1,38 @@
 package delivery
 
+import (
+	"context"
+	"sipub-test/internal/filter"
+	"sipub-test/internal/pagination"
+)
+
 type IDeliveryRepository interface {
 	// Returns the created delivery
-	Create(DeliveryParams) (DeliveryModel, error)
+	Create(ctx context.Context, params DeliveryParams) (DeliveryModel, error)
 
 	// Returns the found deliveriees
 	// NOTE: reusing the same type for a filter and a "constructor" is not
 	// ideal at all, but it will save on code repetition
-	GetAll(filter DeliveryParams) ([]DeliveryModel, error)
+	GetAll(ctx context.Context, where filter.Filter, page pagination.Page) ([]DeliveryModel, error)
+
+	// Returns how many rows match the filter, ignoring the page
+	Count(ctx context.Context, where filter.Filter) (uint, error)
 
 	// Returns the found delivery
-	GetOne(id string) (DeliveryModel, error)
+	GetOne(ctx context.Context, id string) (DeliveryModel, error)
 
-	// Returns amount of deleted deliveries
-	DeleteOne(id string) (uint, error)
+	// Soft deletes, returns amount of deleted deliveries
+	DeleteOne(ctx context.Context, id string) (uint, error)
 
-	// Returns amount of deleted deliveries
-	DeleteAll(filter DeliveryParams) (uint, error)
+	// Soft deletes, returns amount of deleted deliveries
+	DeleteAll(ctx context.Context, where filter.Filter) (uint, error)
 
 	// Returns the updated delivery
-	Update(id string, newDelivery DeliveryParams) (DeliveryModel, error)
+	Update(ctx context.Context, id string, newDelivery DeliveryParams) (DeliveryModel, error)
+
+	// Undoes a soft delete, returns the restored delivery
+	Restore(ctx context.Context, id string) (DeliveryModel, error)
+
+	// Removes the row for good, whether it was soft deleted or not
+	Purge(ctx context.Context, id string) (uint, error)
 }
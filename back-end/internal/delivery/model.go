@@ -1,5 +1,50 @@
 package delivery
 
+import (
+	"sipub-test/pkg/money"
+	"sipub-test/pkg/timestamp"
+)
+
+// Where a delivery stands. New deliveries start as pending and only move along
+// the transitions below
+type Status string
+
+const (
+	StatusPending    Status = "pending"
+	StatusPaid       Status = "paid"
+	StatusDispatched Status = "dispatched"
+	StatusInTransit  Status = "in_transit"
+	StatusDelivered  Status = "delivered"
+	StatusCancelled  Status = "cancelled"
+	StatusRefunded   Status = "refunded" // Delivered, then every payment was given back
+)
+
+// Allowed moves from each status. Cancelled and refunded are final
+var transitions = map[Status][]Status{
+	StatusPending:    {StatusPaid, StatusCancelled},
+	StatusPaid:       {StatusDispatched, StatusCancelled},
+	StatusDispatched: {StatusInTransit, StatusCancelled},
+	StatusInTransit:  {StatusDelivered},
+	StatusDelivered:  {StatusRefunded},
+}
+
+func (s Status) IsValid() bool {
+	switch s {
+	case StatusPending, StatusPaid, StatusDispatched, StatusInTransit, StatusDelivered, StatusCancelled, StatusRefunded:
+		return true
+	}
+	return false
+}
+
+func (s Status) CanMoveTo(next Status) bool {
+	for _, allowed := range transitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
 // This is what will be used to create/find/update the delivery model. The
 // fields are used as pointers so they can be nullified
 type DeliveryParams struct {
@@ -7,18 +52,23 @@ type DeliveryParams struct {
 	IsDeleted *bool
 	CreatedAt *string
 
-	UserID    *string
-	AddressID *string
+	UserID      *string
+	AddressID   *string
+	ShippingFee *money.Money
 }
 
 type DeliveryDTO struct {
-	Id        string `json:"Id"`
-	IsActive  bool   `json:"IsActive"`
-	IsDeleted bool   `json:"IsDeleted"`
-	CreatedAt string `json:"CreatedAt"`
+	Id        string  `json:"Id"`
+	IsActive  bool    `json:"IsActive"`
+	IsDeleted bool    `json:"IsDeleted"`
+	CreatedAt string  `json:"CreatedAt"`
+	UpdatedAt string  `json:"UpdatedAt"`
+	DeletedAt *string `json:"DeletedAt,omitempty"`
 
-	UserID    string `json:"UserID"`
-	AddressID string `json:"AddressID"`
+	UserID      string      `json:"UserID"`
+	AddressID   string      `json:"AddressID"`
+	Status      string      `json:"Status"`
+	ShippingFee money.Money `json:"ShippingFee"`
 }
 
 type DeliveryModel struct {
@@ -26,21 +76,30 @@ type DeliveryModel struct {
 	// inheritance. Explained in COMMENTS.md
 	id        string // ID will be a uuid
 	isActive  bool
-	isDeleted bool // Soft deletion
-	createdAt string
+	isDeleted bool            // Soft deletion
+	deletedAt *timestamp.Time // Set while soft deleted
+	createdAt timestamp.Time
+	updatedAt timestamp.Time // Changed by every update and status change
 
-	userID    string
-	addressID string
+	userID      string
+	addressID   string
+	status      Status      // Only changed through the transitions
+	shippingFee money.Money // Charged on top of the products
 }
 
 func (d *DeliveryModel) ToDTO() DeliveryDTO {
 	dtoDelivery := DeliveryDTO{
 		Id:        d.id,
-		CreatedAt: d.createdAt,
+		CreatedAt: d.createdAt.String(),
+		UpdatedAt: d.updatedAt.String(),
+		DeletedAt: timestamp.Format(d.deletedAt),
 		IsActive:  d.isActive,
 		IsDeleted: d.isDeleted,
 		UserID:    d.userID,
 		AddressID: d.addressID,
+		Status:    string(d.status),
+
+		ShippingFee: d.shippingFee,
 	}
 	return dtoDelivery
 }
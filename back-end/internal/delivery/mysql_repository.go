@@ -1,99 +1,86 @@
 package delivery
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
-	"log"
 	"sipub-test/db"
+	"sipub-test/internal/filter"
+	"sipub-test/internal/pagination"
+	"sipub-test/pkg/apperror"
+	"sipub-test/pkg/clock"
+	"sipub-test/pkg/money"
 	"sipub-test/pkg/nilcheck"
-	"time"
+	"sipub-test/pkg/timestamp"
 
 	"github.com/google/uuid"
 )
 
 type MySQLDeliveryRepository struct {
-	db *sql.DB
+	db    db.Executor
+	clock clock.Clock
 }
 
-// Mainly used for testing, but could be used elsewhere
-func (r *MySQLDeliveryRepository) SetDB(db *sql.DB) { r.db = db }
-
-func (r *MySQLDeliveryRepository) createNewDeliveryTableIfNoneExists() {
-	r.db = db.GetDB()
-
-	createTableQuery := `
-	CREATE TABLE IF NOT EXISTS deliveries (
-		id CHAR(36) NOT NULL,
-		isActive BOOLEAN NOT NULL DEFAULT TRUE,
-		isDeleted BOOLEAN NOT NULL DEFAULT FALSE,
-        createdAt CHAR(19) NOT NULL,
-        user_id CHAR(36) NOT NULL,
-        address_id char(36) NOT NULL,
-        FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
-        FOREIGN KEY (address_id) REFERENCES addresses(id) ON DELETE CASCADE,
-        PRIMARY KEY (id)
-	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`
-	// https://dev.mysql.com/doc/refman/8.4/en/innodb-benefits.html
-	// Mainly using InnoDB because it supports foreing keys
-	// createdAt is a string because it is simpler to handle. It uses this
-	// format 2006-01-02 15:04:05 (19 chars)
-
-	if _, err := r.db.Exec(createTableQuery); err != nil {
-		log.Fatalf("Failed to create table: %v", err)
-	}
+func NewMySQLDeliveryRepository(db db.Executor, clock clock.Clock) *MySQLDeliveryRepository {
+	return &MySQLDeliveryRepository{db: db, clock: clock}
+}
+
+// Fields the list can be filtered and deleted by
+var filterFields = filter.Schema{
+	"IsActive":  {Column: "isActive", Type: filter.Bool, Operators: []filter.Operator{filter.In}},
+	"IsDeleted": {Column: "isDeleted", Type: filter.Bool, Operators: []filter.Operator{filter.Eq}},
+	"CreatedAt": {Column: "createdAt", Type: filter.Time, Operators: filter.TimeOperators},
+	"UserID":    {Column: "user_id", Type: filter.String, Operators: []filter.Operator{filter.Eq, filter.In}},
+	"AddressID": {Column: "address_id", Type: filter.String, Operators: []filter.Operator{filter.Eq, filter.In}},
+	"Status":    {Column: "status", Type: filter.String, Operators: []filter.Operator{filter.Eq, filter.In}},
 }
 
-func NewMySQLDeliveryRepository() *MySQLDeliveryRepository {
-	repo := &MySQLDeliveryRepository{db: db.GetDB()}
-	repo.createNewDeliveryTableIfNoneExists()
-	return repo
+// Columns the list can be sorted by
+var sortFields = pagination.Fields{
+	Table:    "deliveries",
+	IDColumn: "id",
+	Columns: map[string]string{
+		"CreatedAt": "createdAt",
+	},
+	Default: []pagination.Sort{{Field: "CreatedAt", Column: "createdAt", Desc: true}},
 }
 
-func (r *MySQLDeliveryRepository) Create(params DeliveryParams) (DeliveryModel, error) {
+func (r *MySQLDeliveryRepository) Create(ctx context.Context, params DeliveryParams) (DeliveryModel, error) {
 	id := uuid.NewString()
 
-	timeCreated := time.Now().Format("2006-01-02 15:04:05")
+	timeCreated := timestamp.New(r.clock.Now())
 
 	// Fields might be nil, but they need to be passed empty/defaulted non nil fields
 	model := DeliveryModel{
 		id:        id,
 		isActive:  nilcheck.NotNilBool(params.IsActive, false),
-		isDeleted: nilcheck.NotNilBool(params.IsDeleted, true),
+		isDeleted: nilcheck.NotNilBool(params.IsDeleted, false),
 		createdAt: timeCreated,
+		updatedAt: timeCreated,
 		userID:    *params.UserID,
 		addressID: *params.AddressID,
+		status:    StatusPending, // Column default
+
+		shippingFee: nilcheck.NotNilMoney(params.ShippingFee, money.Zero),
 	}
-	fmt.Println(model)
 
-	query := `INSERT INTO deliveries (id, isActive, isDeleted, createdAt, user_id, address_id) VALUES (?, ?, ?, ?, ?, ?)`
+	query := `INSERT INTO deliveries (id, isActive, isDeleted, createdAt, updatedAt, user_id, address_id, shippingFee) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
 
-	_, err := r.db.Exec(query, id, model.isActive, model.isDeleted, timeCreated, model.userID, model.addressID)
+	_, err := r.db.ExecContext(ctx, query, id, model.isActive, model.isDeleted, timeCreated, timeCreated, model.userID, model.addressID, model.shippingFee)
 	if err != nil {
-		return DeliveryModel{}, fmt.Errorf("failed to create delivery: %w", err)
+		return DeliveryModel{}, fmt.Errorf("failed to create delivery: %w", db.TranslateError(err))
 	}
 
 	return model, nil
 }
 
-func (r *MySQLDeliveryRepository) GetAll(filter DeliveryParams) ([]DeliveryModel, error) {
-	query := `SELECT id, isActive, isDeleted, createdAt, user_id, address_id FROM deliveries WHERE 1=1`
-	args := []interface{}{}
+func (r *MySQLDeliveryRepository) GetAll(ctx context.Context, where filter.Filter, page pagination.Page) ([]DeliveryModel, error) {
+	conditions, args := where.SQL()
+	query := `SELECT id, isActive, isDeleted, createdAt, updatedAt, user_id, address_id, deletedAt, status, shippingFee FROM deliveries WHERE 1=1` + conditions
+	query, args = sortFields.Apply(page, query, args)
 
-	if filter.IsActive != nil {
-		query += " AND isActive = ?"
-		args = append(args, *filter.IsActive)
-	}
-	if filter.IsDeleted != nil {
-		query += " AND isDeleted = ?"
-		args = append(args, *filter.IsDeleted)
-	}
-	if filter.UserID != nil {
-		query += " AND user_id = ?"
-		args = append(args, *filter.UserID)
-	}
-
-	rows, err := r.db.Query(query, args...)
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get deliveries: %w", err)
 	}
@@ -106,8 +93,12 @@ func (r *MySQLDeliveryRepository) GetAll(filter DeliveryParams) ([]DeliveryModel
 			&delivery.isActive,
 			&delivery.isDeleted,
 			&delivery.createdAt,
+			&delivery.updatedAt,
 			&delivery.userID,
-			&delivery.addressID)
+			&delivery.addressID,
+			&delivery.deletedAt,
+			&delivery.status,
+			&delivery.shippingFee)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan delivery: %w", err)
 		}
@@ -117,85 +108,116 @@ func (r *MySQLDeliveryRepository) GetAll(filter DeliveryParams) ([]DeliveryModel
 	return deliveries, nil
 }
 
-func (r *MySQLDeliveryRepository) GetOne(id string) (DeliveryModel, error) {
-	query := `SELECT id, isActive, isDeleted, createdAt, user_id, address_id FROM deliveries WHERE id = ?`
+func (r *MySQLDeliveryRepository) Count(ctx context.Context, where filter.Filter) (uint, error) {
+	conditions, args := where.SQL()
+	query := `SELECT COUNT(*) FROM deliveries WHERE 1=1` + conditions
+
+	var count uint
+	if err := r.db.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count deliveries: %w", err)
+	}
+	return count, nil
+}
+
+func (r *MySQLDeliveryRepository) GetOne(ctx context.Context, id string) (DeliveryModel, error) {
+	query := `SELECT id, isActive, isDeleted, createdAt, updatedAt, user_id, address_id, status, shippingFee FROM deliveries WHERE id = ? AND isDeleted = FALSE`
 
 	var delivery DeliveryModel
-	row := r.db.QueryRow(query, id)
+	row := r.db.QueryRowContext(ctx, query, id)
 	err := row.Scan(&delivery.id,
 		&delivery.isActive,
 		&delivery.isDeleted,
 		&delivery.createdAt,
+		&delivery.updatedAt,
 		&delivery.userID,
-		&delivery.addressID)
+		&delivery.addressID,
+		&delivery.status,
+		&delivery.shippingFee)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return DeliveryModel{}, fmt.Errorf("delivery not found")
+			return DeliveryModel{}, fmt.Errorf("delivery %w", apperror.ErrNotFound)
 		}
 		return DeliveryModel{}, fmt.Errorf("failed to get delivery: %w", err)
 	}
 	return delivery, nil
 }
 
-func (r *MySQLDeliveryRepository) DeleteOne(id string) (uint, error) {
-	query := `DELETE FROM deliveries WHERE id = ?`
-	res, err := r.db.Exec(query, id)
+func (r *MySQLDeliveryRepository) DeleteOne(ctx context.Context, id string) (uint, error) {
+	deletedAt := timestamp.New(r.clock.Now())
+	query := `UPDATE deliveries SET isDeleted = TRUE, deletedAt = ? WHERE id = ? AND isDeleted = FALSE`
+	res, err := r.db.ExecContext(ctx, query, deletedAt, id)
 	if err != nil {
-		return 0, fmt.Errorf("failed to delete delivery: %w", err)
+		return 0, fmt.Errorf("failed to delete delivery: %w", db.TranslateError(err))
 	}
 	count, _ := res.RowsAffected()
 	if count == 0 {
-		return 0, fmt.Errorf("no delivery found with the given ID")
+		return 0, fmt.Errorf("delivery %w", apperror.ErrNotFound)
 	}
 	return uint(count), nil
 }
 
-func (r *MySQLDeliveryRepository) DeleteAll(filter DeliveryParams) (uint, error) {
-	query := `DELETE FROM deliveries WHERE 1=1`
-	args := []interface{}{}
-
-	if filter.IsActive != nil {
-		query += " AND isActive = ?"
-		args = append(args, *filter.IsActive)
-	}
-	if filter.IsDeleted != nil {
-		query += " AND isDeleted = ?"
-		args = append(args, *filter.IsDeleted)
-	}
-	if filter.UserID != nil {
-		query += " AND user_id = ?"
-		args = append(args, *filter.UserID)
-	}
+func (r *MySQLDeliveryRepository) DeleteAll(ctx context.Context, where filter.Filter) (uint, error) {
+	conditions, args := where.SQL()
+	query := `UPDATE deliveries SET isDeleted = TRUE, deletedAt = ? WHERE 1=1` + conditions
+	args = append([]interface{}{timestamp.New(r.clock.Now())}, args...)
 
-	res, err := r.db.Exec(query, args...)
+	res, err := r.db.ExecContext(ctx, query, args...)
 	if err != nil {
-		return 0, fmt.Errorf("failed to delete deliveries: %w", err)
+		return 0, fmt.Errorf("failed to delete deliveries: %w", db.TranslateError(err))
 	}
 	count, _ := res.RowsAffected()
 	return uint(count), nil
 }
 
-func (r *MySQLDeliveryRepository) Update(id string, newDelivery DeliveryParams) (DeliveryModel, error) {
-	previousDelivery, err := r.GetOne(id)
+func (r *MySQLDeliveryRepository) Update(ctx context.Context, id string, newDelivery DeliveryParams) (DeliveryModel, error) {
+	previousDelivery, err := r.GetOne(ctx, id)
 	if err != nil {
 		return DeliveryModel{}, err
 	}
 	// This will check nil arguments and change only the non-nil ones. CANNOT UPDATE USERID
 	updatedDelivery := DeliveryModel{
 		isActive:  nilcheck.NotNilBool(newDelivery.IsActive, previousDelivery.isActive),
-		isDeleted: nilcheck.NotNilBool(newDelivery.IsDeleted, previousDelivery.isDeleted),
 		addressID: nilcheck.NotNilString(newDelivery.AddressID, previousDelivery.addressID),
+
+		shippingFee: nilcheck.NotNilMoney(newDelivery.ShippingFee, previousDelivery.shippingFee),
 	}
-	query := `UPDATE deliveries SET isActive = ?, isDeleted = ?, address_id = ? WHERE id = ?`
+	query := `UPDATE deliveries SET isActive = ?, address_id = ?, shippingFee = ?, updatedAt = ? WHERE id = ? AND isDeleted = FALSE`
 
 	_,
-		err = r.db.Exec(query,
+		err = r.db.ExecContext(ctx, query,
 		updatedDelivery.isActive,
-		updatedDelivery.isDeleted,
 		updatedDelivery.addressID,
+		updatedDelivery.shippingFee,
+		timestamp.New(r.clock.Now()),
 		id)
 	if err != nil {
-		return DeliveryModel{}, fmt.Errorf("failed to update deliveries: %w", err)
+		return DeliveryModel{}, fmt.Errorf("failed to update deliveries: %w", db.TranslateError(err))
+	}
+	return r.GetOne(ctx, id)
+}
+
+func (r *MySQLDeliveryRepository) Restore(ctx context.Context, id string) (DeliveryModel, error) {
+	query := `UPDATE deliveries SET isDeleted = FALSE, deletedAt = NULL WHERE id = ? AND isDeleted = TRUE`
+	res, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return DeliveryModel{}, fmt.Errorf("failed to restore delivery: %w", db.TranslateError(err))
 	}
-	return r.GetOne(id)
+	count, _ := res.RowsAffected()
+	if count == 0 {
+		return DeliveryModel{}, fmt.Errorf("deleted delivery %w", apperror.ErrNotFound)
+	}
+	return r.GetOne(ctx, id)
+}
+
+func (r *MySQLDeliveryRepository) Purge(ctx context.Context, id string) (uint, error) {
+	query := `DELETE FROM deliveries WHERE id = ?`
+	res, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge delivery: %w", db.TranslateError(err))
+	}
+	count, _ := res.RowsAffected()
+	if count == 0 {
+		return 0, fmt.Errorf("delivery %w", apperror.ErrNotFound)
+	}
+	return uint(count), nil
 }
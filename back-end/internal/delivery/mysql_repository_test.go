@@ -1,11 +1,18 @@
 package delivery_test
 
 import (
+	"context"
 	"fmt"
 	"regexp"
 	"sipub-test/internal/delivery"
+	"sipub-test/internal/filter"
+	"sipub-test/internal/pagination"
+	"sipub-test/pkg/clock"
+	"sipub-test/pkg/money"
 	testhelper "sipub-test/pkg/test_helper"
+	"sipub-test/pkg/timestamp"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
@@ -19,8 +26,7 @@ func TestCreateDelivery(t *testing.T) {
 		}
 		defer db.Close()
 
-		repo := &delivery.MySQLDeliveryRepository{}
-		repo.SetDB(db)
+		repo := delivery.NewMySQLDeliveryRepository(db, clock.System{})
 
 		params := delivery.DeliveryParams{
 			IsActive:  testhelper.BoolPointer(true),
@@ -29,23 +35,47 @@ func TestCreateDelivery(t *testing.T) {
 			AddressID: testhelper.StringPointer("address-123"),
 		}
 
-		sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "email", "cpf", "name"}).
-			AddRow("user-123", true, false, "2025-01-15 12:00:00", "testuser@example.com", "12345678901", "Test User")
+		sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "updatedAt", "email", "cpf", "name", "emailVerifiedAt"}).
+			AddRow("user-123", true, false, "2025-01-15 12:00:00", "2025-01-15 12:00:00", "testuser@example.com", "12345678909", "Test User", nil)
 
-		mock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "street", "number", "neighborhood", "complement", "city", "state", "country", "latitude", "longitude", "name"}).
-			AddRow("address-123", true, false, "2025-01-15 12:00:00", "Main Street", "123", "Downtown", "", "Metropolis", "NY", "USA", 0, 0, "")
+		mock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "updatedAt", "street", "number", "neighborhood", "complement", "city", "state", "country", "latitude", "longitude", "name"}).
+			AddRow("address-123", true, false, "2025-01-15 12:00:00", "2025-01-15 12:00:00", "Main Street", "123", "Downtown", "", "Metropolis", "NY", "USA", 0, 0, "")
 
 		mock.ExpectExec(`INSERT INTO deliveries`).
-			WithArgs(sqlmock.AnyArg() /* id generated by function */, true, false, sqlmock.AnyArg() /*time*/, "user-123", "address-123").
+			WithArgs(sqlmock.AnyArg() /* id generated by function */, true, false, sqlmock.AnyArg() /*time*/, sqlmock.AnyArg(), "user-123", "address-123", money.Zero).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 
-		delivery, err := repo.Create(params)
+		delivery, err := repo.Create(context.Background(), params)
 
 		assert.NoError(t, err, "Shouldn't contain any errors")
 		assert.Equal(t, *params.IsActive, delivery.ToDTO().IsActive)
 		assert.Equal(t, *params.UserID, delivery.ToDTO().UserID)
 		assert.Equal(t, *params.AddressID, delivery.ToDTO().AddressID)
 	})
+	t.Run("ShouldNotBeDeletedByDefault", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := delivery.NewMySQLDeliveryRepository(db, clock.System{})
+
+		params := delivery.DeliveryParams{
+			UserID:    testhelper.StringPointer("user-123"),
+			AddressID: testhelper.StringPointer("address-123"),
+		}
+
+		mock.ExpectExec(`INSERT INTO deliveries`).
+			WithArgs(sqlmock.AnyArg(), false, false, sqlmock.AnyArg(), sqlmock.AnyArg(), "user-123", "address-123", money.Zero).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		delivery, err := repo.Create(context.Background(), params)
+
+		assert.NoError(t, err, "Shouldn't contain any errors")
+		assert.False(t, delivery.ToDTO().IsDeleted, "Omitting IsDeleted shouldn't create a deleted delivery")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
 }
 
 func TestGetAllDeliveries(t *testing.T) {
@@ -56,17 +86,16 @@ func TestGetAllDeliveries(t *testing.T) {
 		}
 		defer db.Close()
 
-		repo := &delivery.MySQLDeliveryRepository{}
-		repo.SetDB(db)
+		repo := delivery.NewMySQLDeliveryRepository(db, clock.System{})
 
-		rows := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "user_id", "address_id"}).
-			AddRow("delivery-123", true, false, "2025-01-15 12:00:00", "user-123", "address-123")
+		rows := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "updatedAt", "user_id", "address_id", "deletedAt", "status", "shippingFee"}).
+			AddRow("delivery-123", true, false, "2025-01-15 12:00:00", "2025-01-15 12:00:00", "user-123", "address-123", nil, "pending", 0)
 
-		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, user_id, address_id FROM deliveries`).
+		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, updatedAt, user_id, address_id, deletedAt, status, shippingFee FROM deliveries`).
 			WillReturnRows(rows)
 
-		filter := delivery.DeliveryParams{}
-		deliveries, err := repo.GetAll(filter)
+		where := filter.Filter{}
+		deliveries, err := repo.GetAll(context.Background(), where, pagination.Page{})
 
 		assert.NoError(t, err, "Should have no errors")
 		assert.Len(t, deliveries, 1, "Length should be 1")
@@ -82,14 +111,13 @@ func TestGetAllDeliveries(t *testing.T) {
 		}
 		defer db.Close()
 
-		repo := &delivery.MySQLDeliveryRepository{}
-		repo.SetDB(db)
+		repo := delivery.NewMySQLDeliveryRepository(db, clock.System{})
 
-		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, user_id, address_id FROM deliveries`).
+		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, updatedAt, user_id, address_id, deletedAt, status, shippingFee FROM deliveries`).
 			WillReturnError(fmt.Errorf("failed to get deliveries"))
 
-		filter := delivery.DeliveryParams{UserID: testhelper.StringPointer("nonexistent-user")}
-		deliveries, err := repo.GetAll(filter)
+		where := testhelper.FilterEqual("UserID", "user_id", "nonexistent-user")
+		deliveries, err := repo.GetAll(context.Background(), where, pagination.Page{})
 
 		assert.Error(t, err, "Should have an error")
 		assert.Len(t, deliveries, 0, "Length should be 0")
@@ -103,22 +131,22 @@ func TestGetDeliveryByID(t *testing.T) {
 	}
 	defer db.Close()
 
-	repo := &delivery.MySQLDeliveryRepository{}
-	repo.SetDB(db)
+	repo := delivery.NewMySQLDeliveryRepository(db, clock.System{})
 
-	rows := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "user_id", "address_id"}).
-		AddRow("delivery-123", true, false, "2025-01-15 12:00:00", "user-123", "address-123")
+	rows := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "updatedAt", "user_id", "address_id", "status", "shippingFee"}).
+		AddRow("delivery-123", true, false, "2025-01-15 12:00:00", "2025-01-15 12:00:00", "user-123", "address-123", "pending", 0)
 
-	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, isActive, isDeleted, createdAt, user_id, address_id FROM deliveries WHERE id = ?`)).
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, isActive, isDeleted, createdAt, updatedAt, user_id, address_id, status, shippingFee FROM deliveries WHERE id = ?`)).
 		WithArgs("delivery-123").
 		WillReturnRows(rows)
 
-	delivery, err := repo.GetOne("delivery-123")
+	delivery, err := repo.GetOne(context.Background(), "delivery-123")
 
 	assert.NoError(t, err, "Should have no errors")
 	assert.Equal(t, "delivery-123", delivery.ToDTO().Id, "ID should match")
 	assert.Equal(t, "user-123", delivery.ToDTO().UserID, "UserID should match")
 	assert.Equal(t, "address-123", delivery.ToDTO().AddressID, "AddressID should match")
+	assert.Equal(t, "pending", delivery.ToDTO().Status, "Status should match")
 }
 
 func TestDeleteDelivery(t *testing.T) {
@@ -128,14 +156,15 @@ func TestDeleteDelivery(t *testing.T) {
 	}
 	defer db.Close()
 
-	repo := &delivery.MySQLDeliveryRepository{}
-	repo.SetDB(db)
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	repo := delivery.NewMySQLDeliveryRepository(db, clock.Fixed(now))
 
-	mock.ExpectExec(`DELETE FROM deliveries WHERE id = ?`).
-		WithArgs("delivery-123").
+	// The row is only flagged, Purge is what removes it
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE deliveries SET isDeleted = TRUE, deletedAt = ? WHERE id = ? AND isDeleted = FALSE`)).
+		WithArgs(timestamp.New(now), "delivery-123").
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	count, err := repo.DeleteOne("delivery-123")
+	count, err := repo.DeleteOne(context.Background(), "delivery-123")
 
 	assert.NoError(t, err)
 	assert.Equal(t, uint(1), count, "1 row should be affected")
@@ -149,13 +178,12 @@ func TestUpdateDelivery(t *testing.T) {
 		}
 		defer db.Close()
 
-		repo := &delivery.MySQLDeliveryRepository{}
-		repo.SetDB(db)
+		repo := delivery.NewMySQLDeliveryRepository(db, clock.System{})
 
-		existingRows := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "user_id", "address_id"}).
-			AddRow("delivery-123", true, false, "2025-01-15 12:00:00", "user-123", "address-123")
+		existingRows := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "updatedAt", "user_id", "address_id", "status", "shippingFee"}).
+			AddRow("delivery-123", true, false, "2025-01-15 12:00:00", "2025-01-15 12:00:00", "user-123", "address-123", "pending", 0)
 
-		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, user_id, address_id FROM deliveries WHERE id = ?`).
+		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, updatedAt, user_id, address_id, status, shippingFee FROM deliveries WHERE id = ?`).
 			WithArgs("delivery-123").
 			WillReturnRows(existingRows)
 
@@ -164,18 +192,18 @@ func TestUpdateDelivery(t *testing.T) {
 			AddressID: testhelper.StringPointer("new-address-123"),
 		}
 
-		mock.ExpectExec(regexp.QuoteMeta(`UPDATE deliveries SET isActive = ?, isDeleted = ?, address_id = ? WHERE id = ?`)).
-			WithArgs(false, false, "new-address-123", "delivery-123").
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE deliveries SET isActive = ?, address_id = ?, shippingFee = ?, updatedAt = ? WHERE id = ? AND isDeleted = FALSE`)).
+			WithArgs(false, "new-address-123", money.Zero, sqlmock.AnyArg(), "delivery-123").
 			WillReturnResult(sqlmock.NewResult(1, 1))
 
-		updatedRows := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "user_id", "address_id"}).
-			AddRow("delivery-123", false, false, "2025-01-15 12:00:00", "user-123", "new-address-123")
+		updatedRows := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "updatedAt", "user_id", "address_id", "status", "shippingFee"}).
+			AddRow("delivery-123", false, false, "2025-01-15 12:00:00", "2025-01-15 12:00:00", "user-123", "new-address-123", "pending", 0)
 
-		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, user_id, address_id FROM deliveries WHERE id = ?`).
+		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, updatedAt, user_id, address_id, status, shippingFee FROM deliveries WHERE id = ?`).
 			WithArgs("delivery-123").
 			WillReturnRows(updatedRows)
 
-		delivery, err := repo.Update("delivery-123", newParams)
+		delivery, err := repo.Update(context.Background(), "delivery-123", newParams)
 
 		assert.NoError(t, err)
 		assert.Equal(t, "delivery-123", delivery.ToDTO().Id, "ID should match")
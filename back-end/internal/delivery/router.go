@@ -7,14 +7,11 @@ import (
 
 type DeliveryRouter struct {
 	baseEndPoint string
-	controller   internal.IController
+	controller   internal.ISoftDeleteController
 }
 
-func NewDeliveryRouter() DeliveryRouter {
-	router := DeliveryRouter{
-		controller: NewDeliveryController(),
-	}
-	return router
+func NewDeliveryRouter(controller internal.ISoftDeleteController) DeliveryRouter {
+	return DeliveryRouter{controller: controller}
 }
 
 func (r DeliveryRouter) Init(mux *http.ServeMux) {
@@ -26,6 +23,8 @@ func (r DeliveryRouter) Init(mux *http.ServeMux) {
 	r.deleteAll(mux)
 	r.deleteOne(mux)
 	r.update(mux)
+	r.restore(mux)
+	r.purge(mux)
 }
 
 func (r DeliveryRouter) create(mux *http.ServeMux) {
@@ -51,3 +50,11 @@ func (r DeliveryRouter) deleteOne(mux *http.ServeMux) {
 func (r DeliveryRouter) update(mux *http.ServeMux) {
 	mux.HandleFunc("PUT "+r.baseEndPoint+"/{id}", r.controller.Update)
 }
+
+func (r DeliveryRouter) restore(mux *http.ServeMux) {
+	mux.HandleFunc("POST "+r.baseEndPoint+"/{id}/restore", r.controller.Restore)
+}
+
+func (r DeliveryRouter) purge(mux *http.ServeMux) {
+	mux.HandleFunc("DELETE "+r.baseEndPoint+"/{id}/purge", r.controller.Purge)
+}
@@ -1,7 +1,7 @@
 package delivery
 
 import (
-	"errors"
+	"sipub-test/pkg/apperror"
 )
 
 type DeliveryValidator struct{}
@@ -10,7 +10,10 @@ type DeliveryValidator struct{}
 
 func (v *DeliveryValidator) Validate(delivery DeliveryParams) error {
 	if delivery.UserID == nil {
-		return errors.New("Empty UserID")
+		return apperror.NewValidationError("UserID", "is required")
+	}
+	if delivery.ShippingFee != nil && delivery.ShippingFee.IsNegative() {
+		return apperror.NewValidationError("ShippingFee", "can't be negative")
 	}
 	return nil
 }
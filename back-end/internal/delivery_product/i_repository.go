@@ -1,22 +1,31 @@
 package delivery_product
 
+import (
+	"context"
+	"sipub-test/internal/filter"
+	"sipub-test/internal/pagination"
+)
+
 type IDeliveryProductRepository interface {
 	// Returns the created deliveryProduct
-	Create(DeliveryProductParams) (DeliveryProductModel, error)
+	Create(ctx context.Context, params DeliveryProductParams) (DeliveryProductModel, error)
 
 	// Returns the found deliveryProducts
 	// NOTE: reusing the same type for a filter and a "constructor" is not
 	// ideal at all, but it will save on code repetition
-	GetAll(filter DeliveryProductParams) ([]DeliveryProductModel, error)
+	GetAll(ctx context.Context, where filter.Filter, page pagination.Page) ([]DeliveryProductModel, error)
+
+	// Returns how many rows match the filter, ignoring the page
+	Count(ctx context.Context, where filter.Filter) (uint, error)
 
 	// Returns the found deliveryProduct
-	GetOne(id string) (DeliveryProductModel, error)
+	GetOne(ctx context.Context, id string) (DeliveryProductModel, error)
 
 	// Returns amount of deleted deliveryProduct
-	DeleteOne(id string) (uint, error)
+	DeleteOne(ctx context.Context, id string) (uint, error)
 
 	// Returns amount of deleted deliveryProduct
-	DeleteAll(filter DeliveryProductParams) (uint, error)
+	DeleteAll(ctx context.Context, where filter.Filter) (uint, error)
 
 	// Not used, delivery-product should not be updated
 	// Update(id string, newDeliveryProduct DeliveryProductParams) (DeliveryProductModel, error)
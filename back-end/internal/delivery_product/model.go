@@ -1,11 +1,14 @@
 package delivery_product
 
+import "sipub-test/pkg/money"
+
 // This is what will be used to create/find/update the deliveryProduct model. The
 // fields are used as pointers so they can be nullified
 type DeliveryProductParams struct {
 	DeliveryID       *string
 	ProductID     *string
 	ProductAmount *uint
+	UnitPrice     *money.Money `json:"-"` // Price snapshot, only the checkout fills it in. A client could pick its own price otherwise
 }
 
 type DeliveryProductDTO struct {
@@ -13,6 +16,7 @@ type DeliveryProductDTO struct {
 	DeliveryID       string `json:"DeliveryID"`
 	ProductID     string `json:"ProductID"`
 	ProductAmount uint   `json:"ProductAmount"`
+	UnitPrice     *money.Money `json:"UnitPrice,omitempty"`
 }
 
 type DeliveryProductModel struct {
@@ -20,6 +24,7 @@ type DeliveryProductModel struct {
 	deliveryID       string
 	productID     string
 	productAmount uint
+	unitPrice     *money.Money // Snapshot taken by the checkout
 }
 
 func (d *DeliveryProductModel) ToDTO() DeliveryProductDTO {
@@ -28,6 +33,7 @@ func (d *DeliveryProductModel) ToDTO() DeliveryProductDTO {
 		DeliveryID:       d.deliveryID,
 		ProductID:     d.productID,
 		ProductAmount: d.productAmount,
+		UnitPrice:     d.unitPrice,
 	}
 	return dtoDelivery
 }
@@ -1,52 +1,43 @@
 package delivery_product
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
-	"log"
 	"sipub-test/db"
+	"sipub-test/internal/filter"
+	"sipub-test/internal/pagination"
+	"sipub-test/pkg/apperror"
 
 	"github.com/google/uuid"
 )
 
-type MySQLDeliveryRepository struct {
-	db *sql.DB
+type MySQLDeliveryProductRepository struct {
+	db db.Executor
 }
 
-// Mainly used for testing, but could be used elsewhere
-func (r *MySQLDeliveryRepository) SetDB(db *sql.DB) { r.db = db }
-
-func (r *MySQLDeliveryRepository) createNewDeliveryTableIfNoneExists() {
-	r.db = db.GetDB()
-
-	createTableQuery := `
-	CREATE TABLE IF NOT EXISTS delivery_product (
-		id CHAR(36) NOT NULL,
-        delivery_id char(36) NOT NULL,
-        product_id char(36) NOT NULL,
-        product_amount INT UNSIGNED NOT NULL,
-        FOREIGN KEY (delivery_id) REFERENCES deliveries(id) ON DELETE CASCADE,
-        FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE,
-        PRIMARY KEY (id)
-	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`
-	// https://dev.mysql.com/doc/refman/8.4/en/innodb-benefits.html
-	// Mainly using InnoDB because it supports foreing keys
-	// createdAt is a string because it is simpler to handle. It uses this
-	// format 2006-01-02 15:04:05 (19 chars)
-
-	if _, err := r.db.Exec(createTableQuery); err != nil {
-		log.Fatalf("Failed to create table: %v", err)
-	}
+func NewMySQLDeliveryProductRepository(db db.Executor) *MySQLDeliveryProductRepository {
+	return &MySQLDeliveryProductRepository{db: db}
+}
+
+// Fields the list can be filtered and deleted by
+var filterFields = filter.Schema{
+	"DeliveryID":    {Column: "delivery_id", Type: filter.String, Operators: []filter.Operator{filter.Eq, filter.In}},
+	"ProductID":     {Column: "product_id", Type: filter.String, Operators: []filter.Operator{filter.Eq, filter.In}},
+	"ProductAmount": {Column: "product_amount", Type: filter.Integer, Operators: filter.IntegerOperators},
 }
 
-func NewMySQLDeliveryRepository() *MySQLDeliveryRepository {
-	repo := &MySQLDeliveryRepository{db: db.GetDB()}
-	repo.createNewDeliveryTableIfNoneExists()
-	return repo
+// Columns the list can be sorted by
+var sortFields = pagination.Fields{
+	Table:    "delivery_product",
+	IDColumn: "id",
+	Columns: map[string]string{
+		"ProductAmount": "product_amount",
+	},
 }
 
-func (r *MySQLDeliveryRepository) Create(params DeliveryProductParams) (DeliveryProductModel, error) {
+func (r *MySQLDeliveryProductRepository) Create(ctx context.Context, params DeliveryProductParams) (DeliveryProductModel, error) {
 	id := uuid.NewString()
 
 	// Fields might be nil, but they need to be passed empty/defaulted non nil fields
@@ -55,30 +46,25 @@ func (r *MySQLDeliveryRepository) Create(params DeliveryProductParams) (Delivery
 		deliveryID:    *params.DeliveryID,
 		productID:     *params.ProductID,
 		productAmount: *params.ProductAmount,
+		unitPrice:     params.UnitPrice,
 	}
 
-	query := `INSERT INTO delivery_product (id, delivery_id, product_id, product_amount) VALUES (?, ?, ?, ?)`
+	query := `INSERT INTO delivery_product (id, delivery_id, product_id, product_amount, unit_price) VALUES (?, ?, ?, ?, ?)`
 
-	_, err := r.db.Exec(query, id, model.deliveryID, model.productID, model.productAmount) // todo
+	_, err := r.db.ExecContext(ctx, query, id, model.deliveryID, model.productID, model.productAmount, model.unitPrice)
 	if err != nil {
-		fmt.Println(err)
-		return DeliveryProductModel{}, fmt.Errorf("failed to create delivery: %w", err)
+		return DeliveryProductModel{}, fmt.Errorf("failed to create delivery: %w", db.TranslateError(err))
 	}
 
 	return model, nil
 }
 
-func (r *MySQLDeliveryRepository) GetAll(filter DeliveryProductParams) ([]DeliveryProductModel, error) {
-	query := `SELECT id, delivery_id, product_id, product_amount FROM delivery_product WHERE 1=1`
-	args := []interface{}{}
+func (r *MySQLDeliveryProductRepository) GetAll(ctx context.Context, where filter.Filter, page pagination.Page) ([]DeliveryProductModel, error) {
+	conditions, args := where.SQL()
+	query := `SELECT id, delivery_id, product_id, product_amount, unit_price FROM delivery_product WHERE 1=1` + conditions
+	query, args = sortFields.Apply(page, query, args)
 
-	// Only looks for deliveryid
-	if filter.DeliveryID != nil {
-		query += " AND delivery_id = ?"
-		args = append(args, *filter.DeliveryID)
-	}
-
-	rows, err := r.db.Query(query, args...)
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get deliveryProduct: %w", err)
 	}
@@ -87,7 +73,7 @@ func (r *MySQLDeliveryRepository) GetAll(filter DeliveryProductParams) ([]Delive
 	var deliveryProduct []DeliveryProductModel
 	for rows.Next() {
 		var delivery DeliveryProductModel
-		err := rows.Scan(&delivery.id, &delivery.deliveryID, &delivery.productID, &delivery.productAmount) // todo
+		err := rows.Scan(&delivery.id, &delivery.deliveryID, &delivery.productID, &delivery.productAmount, &delivery.unitPrice)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan deliveryProduct: %w", err)
 		}
@@ -97,46 +83,52 @@ func (r *MySQLDeliveryRepository) GetAll(filter DeliveryProductParams) ([]Delive
 	return deliveryProduct, nil
 }
 
-func (r *MySQLDeliveryRepository) GetOne(id string) (DeliveryProductModel, error) {
-	query := `SELECT id, delivery_id, product_id, product_amount FROM delivery_product WHERE id = ?`
+func (r *MySQLDeliveryProductRepository) Count(ctx context.Context, where filter.Filter) (uint, error) {
+	conditions, args := where.SQL()
+	query := `SELECT COUNT(*) FROM delivery_product WHERE 1=1` + conditions
+
+	var count uint
+	if err := r.db.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count delivery_product: %w", err)
+	}
+	return count, nil
+}
+
+func (r *MySQLDeliveryProductRepository) GetOne(ctx context.Context, id string) (DeliveryProductModel, error) {
+	query := `SELECT id, delivery_id, product_id, product_amount, unit_price FROM delivery_product WHERE id = ?`
 
 	var delivery DeliveryProductModel
-	row := r.db.QueryRow(query, id)
-	err := row.Scan(&delivery.id, &delivery.deliveryID, &delivery.productID, &delivery.productAmount)
+	row := r.db.QueryRowContext(ctx, query, id)
+	err := row.Scan(&delivery.id, &delivery.deliveryID, &delivery.productID, &delivery.productAmount, &delivery.unitPrice)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return DeliveryProductModel{}, fmt.Errorf("delivery not found")
+			return DeliveryProductModel{}, fmt.Errorf("delivery product %w", apperror.ErrNotFound)
 		}
 		return DeliveryProductModel{}, fmt.Errorf("failed to get delivery: %w", err)
 	}
 	return delivery, nil
 }
 
-func (r *MySQLDeliveryRepository) DeleteOne(id string) (uint, error) {
+func (r *MySQLDeliveryProductRepository) DeleteOne(ctx context.Context, id string) (uint, error) {
 	query := `DELETE FROM delivery_product WHERE id = ?`
-	res, err := r.db.Exec(query, id)
+	res, err := r.db.ExecContext(ctx, query, id)
 	if err != nil {
-		return 0, fmt.Errorf("failed to delete delivery: %w", err)
+		return 0, fmt.Errorf("failed to delete delivery: %w", db.TranslateError(err))
 	}
 	count, _ := res.RowsAffected()
 	if count == 0 {
-		return 0, fmt.Errorf("no delivery found with the given ID")
+		return 0, fmt.Errorf("delivery product %w", apperror.ErrNotFound)
 	}
 	return uint(count), nil
 }
 
-func (r *MySQLDeliveryRepository) DeleteAll(filter DeliveryProductParams) (uint, error) {
-	query := `DELETE FROM delivery_product WHERE 1=1`
-	args := []interface{}{}
-
-	if filter.DeliveryID != nil {
-		query += " AND user_id = ?"
-		args = append(args, *filter.DeliveryID)
-	}
+func (r *MySQLDeliveryProductRepository) DeleteAll(ctx context.Context, where filter.Filter) (uint, error) {
+	conditions, args := where.SQL()
+	query := `DELETE FROM delivery_product WHERE 1=1` + conditions
 
-	res, err := r.db.Exec(query, args...)
+	res, err := r.db.ExecContext(ctx, query, args...)
 	if err != nil {
-		return 0, fmt.Errorf("failed to delete delivery_product: %w", err)
+		return 0, fmt.Errorf("failed to delete delivery_product: %w", db.TranslateError(err))
 	}
 	count, _ := res.RowsAffected()
 	return uint(count), nil
@@ -1,9 +1,14 @@
 package delivery_product_test
 
 import (
+	"context"
 	"regexp"
 	"sipub-test/internal/delivery"
 	"sipub-test/internal/delivery_product"
+	"sipub-test/internal/filter"
+	"sipub-test/internal/pagination"
+	"sipub-test/pkg/clock"
+	"sipub-test/pkg/money"
 	testhelper "sipub-test/pkg/test_helper"
 	"testing"
 
@@ -19,20 +24,19 @@ func TestCreateDeliveryProduct(t *testing.T) {
 		}
 		defer db.Close()
 
-		repo := &delivery_product.MySQLDeliveryRepository{}
-		repo.SetDB(db)
+		repo := delivery_product.NewMySQLDeliveryProductRepository(db)
 
 		params := delivery_product.DeliveryProductParams{
-			DeliveryID:       testhelper.StringPointer("order-123"),
+			DeliveryID:    testhelper.StringPointer("order-123"),
 			ProductID:     testhelper.StringPointer("product-123"),
 			ProductAmount: testhelper.UintPointer(5),
 		}
 
-		mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO delivery_product (id, delivery_id, product_id, product_amount) VALUES (?, ?, ?, ?)`)).
-			WithArgs(sqlmock.AnyArg(), "order-123", "product-123", 5).
+		mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO delivery_product (id, delivery_id, product_id, product_amount, unit_price) VALUES (?, ?, ?, ?, ?)`)).
+			WithArgs(sqlmock.AnyArg(), "order-123", "product-123", 5, nil).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 
-		result, err := repo.Create(params)
+		result, err := repo.Create(context.Background(), params)
 
 		assert.NoError(t, err, "Shouldn't contain any errors")
 		assert.Equal(t, "order-123", result.ToDTO().DeliveryID, "DeliveryID should match")
@@ -49,17 +53,16 @@ func TestGetAllDeliveryProducts(t *testing.T) {
 		}
 		defer db.Close()
 
-		repo := &delivery_product.MySQLDeliveryRepository{}
-		repo.SetDB(db)
+		repo := delivery_product.NewMySQLDeliveryProductRepository(db)
 
-		rows := sqlmock.NewRows([]string{"id", "delivery_id", "product_id", "product_amount"}).
-			AddRow("delivery-123", "order-123", "product-123", 5)
+		rows := sqlmock.NewRows([]string{"id", "delivery_id", "product_id", "product_amount", "unit_price"}).
+			AddRow("delivery-123", "order-123", "product-123", 5, 19.99)
 
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, delivery_id, product_id, product_amount FROM delivery_product WHERE 1=1`)).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, delivery_id, product_id, product_amount, unit_price FROM delivery_product WHERE 1=1`)).
 			WillReturnRows(rows)
 
-		filter := delivery_product.DeliveryProductParams{}
-		results, err := repo.GetAll(filter)
+		where := filter.Filter{}
+		results, err := repo.GetAll(context.Background(), where, pagination.Page{})
 
 		assert.NoError(t, err, "Shouldn't contain any errors")
 		assert.Len(t, results, 1, "Result length should be 1")
@@ -75,22 +78,22 @@ func TestGetDeliveryProductByID(t *testing.T) {
 	}
 	defer db.Close()
 
-	repo := &delivery_product.MySQLDeliveryRepository{}
-	repo.SetDB(db)
+	repo := delivery_product.NewMySQLDeliveryProductRepository(db)
 
-	rows := sqlmock.NewRows([]string{"id", "delivery_id", "product_id", "product_amount"}).
-		AddRow("delivery-123", "order-123", "product-123", 5)
+	rows := sqlmock.NewRows([]string{"id", "delivery_id", "product_id", "product_amount", "unit_price"}).
+		AddRow("delivery-123", "order-123", "product-123", 5, 19.99)
 
-	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, delivery_id, product_id, product_amount FROM delivery_product WHERE id = ?`)).
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, delivery_id, product_id, product_amount, unit_price FROM delivery_product WHERE id = ?`)).
 		WithArgs("delivery-123").
 		WillReturnRows(rows)
 
-	result, err := repo.GetOne("delivery-123")
+	result, err := repo.GetOne(context.Background(), "delivery-123")
 
 	assert.NoError(t, err, "Shouldn't contain any errors")
 	assert.Equal(t, "delivery-123", result.ToDTO().Id, "ID should match")
 	assert.Equal(t, "order-123", result.ToDTO().DeliveryID, "DeliveryID should match")
 	assert.Equal(t, "product-123", result.ToDTO().ProductID, "ProductID should match")
+	assert.Equal(t, money.FromCents(1999), *result.ToDTO().UnitPrice, "The price snapshot should be read")
 }
 
 func TestDeleteDeliveryProduct(t *testing.T) {
@@ -100,14 +103,13 @@ func TestDeleteDeliveryProduct(t *testing.T) {
 	}
 	defer db.Close()
 
-	repo := &delivery_product.MySQLDeliveryRepository{}
-	repo.SetDB(db)
+	repo := delivery_product.NewMySQLDeliveryProductRepository(db)
 
 	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM delivery_product WHERE id = ?`)).
 		WithArgs("delivery-123").
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	count, err := repo.DeleteOne("delivery-123")
+	count, err := repo.DeleteOne(context.Background(), "delivery-123")
 
 	assert.NoError(t, err, "Shouldn't contain any errors")
 	assert.Equal(t, uint(1), count, "Affected row count should be 1")
@@ -121,13 +123,12 @@ func TestUpdateDelivery(t *testing.T) {
 		}
 		defer db.Close()
 
-		repo := &delivery.MySQLDeliveryRepository{}
-		repo.SetDB(db)
+		repo := delivery.NewMySQLDeliveryRepository(db, clock.System{})
 
-		existingRows := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "user_id", "address_id"}).
-			AddRow("delivery-123", true, false, "2025-01-15 12:00:00", "user-123", "address-123")
+		existingRows := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "updatedAt", "user_id", "address_id", "status", "shippingFee"}).
+			AddRow("delivery-123", true, false, "2025-01-15 12:00:00", "2025-01-15 12:00:00", "user-123", "address-123", "pending", 0)
 
-		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, user_id, address_id FROM deliveries WHERE id = ?`).
+		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, updatedAt, user_id, address_id, status, shippingFee FROM deliveries WHERE id = ?`).
 			WithArgs("delivery-123").
 			WillReturnRows(existingRows)
 
@@ -136,18 +137,18 @@ func TestUpdateDelivery(t *testing.T) {
 			AddressID: testhelper.StringPointer("new-address-123"),
 		}
 
-		mock.ExpectExec(regexp.QuoteMeta(`UPDATE deliveries SET isActive = ?, isDeleted = ?, address_id = ? WHERE id = ?`)).
-			WithArgs(false, false, "new-address-123", "delivery-123").
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE deliveries SET isActive = ?, address_id = ?, shippingFee = ?, updatedAt = ? WHERE id = ? AND isDeleted = FALSE`)).
+			WithArgs(false, "new-address-123", money.Zero, sqlmock.AnyArg(), "delivery-123").
 			WillReturnResult(sqlmock.NewResult(1, 1))
 
-		updatedRows := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "user_id", "address_id"}).
-			AddRow("delivery-123", false, false, "2025-01-15 12:00:00", "user-123", "new-address-123")
+		updatedRows := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "updatedAt", "user_id", "address_id", "status", "shippingFee"}).
+			AddRow("delivery-123", false, false, "2025-01-15 12:00:00", "2025-01-15 12:00:00", "user-123", "new-address-123", "pending", 0)
 
-		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, user_id, address_id FROM deliveries WHERE id = ?`).
+		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, updatedAt, user_id, address_id, status, shippingFee FROM deliveries WHERE id = ?`).
 			WithArgs("delivery-123").
 			WillReturnRows(updatedRows)
 
-		delivery, err := repo.Update("delivery-123", newParams)
+		delivery, err := repo.Update(context.Background(), "delivery-123", newParams)
 
 		assert.NoError(t, err)
 		assert.Equal(t, "delivery-123", delivery.ToDTO().Id, "ID should match")
@@ -10,11 +10,8 @@ type DeliveryProductRouter struct {
 	controller   internal.IController
 }
 
-func NewDeliveryProductRouter() DeliveryProductRouter {
-	router := DeliveryProductRouter{
-		controller: NewDeliveryProductController(),
-	}
-	return router
+func NewDeliveryProductRouter(controller internal.IController) DeliveryProductRouter {
+	return DeliveryProductRouter{controller: controller}
 }
 
 func (r DeliveryProductRouter) Init(mux *http.ServeMux) {
@@ -1,7 +1,7 @@
 package delivery_product
 
 import (
-	"errors"
+	"sipub-test/pkg/apperror"
 )
 
 type DeliveryProductValidator struct{}
@@ -10,7 +10,7 @@ type DeliveryProductValidator struct{}
 
 func (v *DeliveryProductValidator) Validate(delivery DeliveryProductParams) error {
 	if delivery.DeliveryID == nil {
-		return errors.New("Empty DeliveryID")
+		return apperror.NewValidationError("DeliveryID", "is required")
 	}
 	return nil
 }
@@ -0,0 +1,69 @@
+package delivery_status
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sipub-test/internal"
+	"sipub-test/internal/auth"
+	"sipub-test/internal/response"
+	"sipub-test/pkg/apperror"
+)
+
+type DeliveryStatusController struct {
+	repository IDeliveryStatusRepository
+	validator  internal.IValidator[TransitionParams]
+	logger     *slog.Logger
+}
+
+func NewDeliveryStatusController(repository IDeliveryStatusRepository, validator internal.IValidator[TransitionParams], logger *slog.Logger) *DeliveryStatusController {
+	return &DeliveryStatusController{repository: repository, validator: validator, logger: logger}
+}
+
+func (c *DeliveryStatusController) Transition(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	var transitionParams TransitionParams
+	err := json.NewDecoder(r.Body).Decode(&transitionParams)
+	if err != nil {
+		response.BadRequest(w, r, "invalid request body: "+err.Error())
+		return
+	}
+
+	if err := c.validator.Validate(transitionParams); err != nil {
+		response.Error(w, r, c.logger, err)
+		return
+	}
+
+	// The router lets the request through auth.Guard.Session, the history
+	// records who asked for the change
+	session, ok := auth.SessionFrom(r.Context())
+	if !ok {
+		response.Error(w, r, c.logger, fmt.Errorf("changing the status needs a session: %w", apperror.ErrUnauthorized))
+		return
+	}
+	transitionParams.Actor = &session.UserID
+
+	change, err := c.repository.Transition(r.Context(), id, transitionParams)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
+	}
+
+	response.JSON(w, http.StatusCreated, change.ToDTO())
+}
+
+func (c *DeliveryStatusController) History(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	changes, err := c.repository.History(r.Context(), id)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
+	}
+
+	dtoChanges := []StatusChangeDTO{}
+	for i := 0; i < len(changes); i++ {
+		dtoChanges = append(dtoChanges, changes[i].ToDTO())
+	}
+	response.JSON(w, http.StatusOK, dtoChanges)
+}
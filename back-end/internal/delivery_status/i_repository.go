@@ -0,0 +1,14 @@
+package delivery_status
+
+import (
+	"context"
+)
+
+type IDeliveryStatusRepository interface {
+	// Moves the delivery to another status, returns the recorded change.
+	// Moves missing from the transition table are a conflict
+	Transition(ctx context.Context, deliveryID string, params TransitionParams) (StatusChangeModel, error)
+
+	// Returns the changes of a delivery, oldest first
+	History(ctx context.Context, deliveryID string) ([]StatusChangeModel, error)
+}
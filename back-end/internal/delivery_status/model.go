@@ -0,0 +1,43 @@
+package delivery_status
+
+import (
+	"sipub-test/internal/delivery"
+	"sipub-test/pkg/timestamp"
+)
+
+// What the client sends to move a delivery
+type TransitionParams struct {
+	Status *string
+	Actor  *string `json:"-"` // The logged in user, filled in by the controller
+}
+
+type StatusChangeDTO struct {
+	Id         string  `json:"Id"`
+	DeliveryID string  `json:"DeliveryID"`
+	FromStatus string  `json:"FromStatus"`
+	ToStatus   string  `json:"ToStatus"`
+	Actor      *string `json:"Actor,omitempty"`
+	CreatedAt  string  `json:"CreatedAt"`
+}
+
+// A row of delivery_status_history
+type StatusChangeModel struct {
+	id         string // ID will be a uuid
+	deliveryID string
+	fromStatus delivery.Status
+	toStatus   delivery.Status
+	actor      *string
+	createdAt  timestamp.Time
+}
+
+func (s *StatusChangeModel) ToDTO() StatusChangeDTO {
+	dtoChange := StatusChangeDTO{
+		Id:         s.id,
+		DeliveryID: s.deliveryID,
+		FromStatus: string(s.fromStatus),
+		ToStatus:   string(s.toStatus),
+		Actor:      s.actor,
+		CreatedAt:  s.createdAt.String(),
+	}
+	return dtoChange
+}
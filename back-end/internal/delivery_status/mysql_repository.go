@@ -0,0 +1,137 @@
+package delivery_status
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sipub-test/db"
+	"sipub-test/internal/delivery"
+	"sipub-test/internal/inventory"
+	"sipub-test/internal/unit_of_work"
+	"sipub-test/pkg/apperror"
+	"sipub-test/pkg/clock"
+	"sipub-test/pkg/timestamp"
+
+	"github.com/google/uuid"
+)
+
+type MySQLDeliveryStatusRepository struct {
+	db    *sql.DB
+	uow   *unit_of_work.UnitOfWork
+	clock clock.Clock
+}
+
+func NewMySQLDeliveryStatusRepository(db *sql.DB, uow *unit_of_work.UnitOfWork, clock clock.Clock) *MySQLDeliveryStatusRepository {
+	return &MySQLDeliveryStatusRepository{db: db, uow: uow, clock: clock}
+}
+
+func (r *MySQLDeliveryStatusRepository) Transition(ctx context.Context, deliveryID string, params TransitionParams) (StatusChangeModel, error) {
+	var change StatusChangeModel
+	createdAt := timestamp.New(r.clock.Now())
+	err := r.uow.Do(ctx, func(ctx context.Context, repos unit_of_work.Repositories) error {
+		from, err := Lock(ctx, repos.Tx, deliveryID)
+		if err != nil {
+			return err
+		}
+		change, err = Move(ctx, repos.Tx, deliveryID, from, delivery.Status(*params.Status), params.Actor, createdAt)
+		return err
+	})
+	if err != nil {
+		return StatusChangeModel{}, err
+	}
+	return change, nil
+}
+
+// Returns the status of the delivery, locked until tx ends so two changes of
+// the same delivery can't both start from it
+func Lock(ctx context.Context, tx db.Executor, deliveryID string) (delivery.Status, error) {
+	var status delivery.Status
+	query := `SELECT status FROM deliveries WHERE id = ? AND isDeleted = FALSE FOR UPDATE`
+	err := tx.QueryRowContext(ctx, query, deliveryID).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("delivery %w", apperror.ErrNotFound)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get delivery: %w", err)
+	}
+	return status, nil
+}
+
+// Moves a delivery locked by Lock and records the change. Meant to run inside
+// a transaction, like the refunds that close a delivery
+func Move(ctx context.Context, tx db.Executor, deliveryID string, from delivery.Status, to delivery.Status, actor *string, createdAt timestamp.Time) (StatusChangeModel, error) {
+	change := StatusChangeModel{
+		id:         uuid.NewString(),
+		deliveryID: deliveryID,
+		fromStatus: from,
+		toStatus:   to,
+		actor:      actor,
+		createdAt:  createdAt,
+	}
+	if !from.CanMoveTo(to) {
+		return StatusChangeModel{}, fmt.Errorf("delivery can't move from %s to %s: %w", from, to, apperror.ErrConflict)
+	}
+
+	// The stock reserved by the checkout leaves the warehouse with the
+	// delivery, and comes back if it is cancelled
+	var err error
+	switch to {
+	case delivery.StatusDispatched:
+		_, err = inventory.Sell(ctx, tx, deliveryID, createdAt)
+	case delivery.StatusCancelled:
+		_, err = inventory.Cancel(ctx, tx, deliveryID, createdAt)
+	}
+	if err != nil {
+		return StatusChangeModel{}, err
+	}
+
+	query := `UPDATE deliveries SET status = ?, updatedAt = ? WHERE id = ?`
+	if _, err := tx.ExecContext(ctx, query, to, createdAt, deliveryID); err != nil {
+		return StatusChangeModel{}, fmt.Errorf("failed to update delivery status: %w", db.TranslateError(err))
+	}
+
+	query = `INSERT INTO delivery_status_history (id, delivery_id, fromStatus, toStatus, actor, createdAt) VALUES (?, ?, ?, ?, ?, ?)`
+	_, err = tx.ExecContext(ctx, query, change.id, deliveryID, from, to, actor, createdAt)
+	if err != nil {
+		return StatusChangeModel{}, fmt.Errorf("failed to record delivery status: %w", db.TranslateError(err))
+	}
+	return change, nil
+}
+
+func (r *MySQLDeliveryStatusRepository) History(ctx context.Context, deliveryID string) ([]StatusChangeModel, error) {
+	var id string
+	query := `SELECT id FROM deliveries WHERE id = ?`
+	err := r.db.QueryRowContext(ctx, query, deliveryID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("delivery %w", apperror.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get delivery: %w", err)
+	}
+
+	query = `SELECT id, delivery_id, fromStatus, toStatus, actor, createdAt FROM delivery_status_history WHERE delivery_id = ? ORDER BY createdAt ASC`
+	rows, err := r.db.QueryContext(ctx, query, deliveryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get delivery history: %w", err)
+	}
+	defer rows.Close()
+
+	var changes []StatusChangeModel
+	for rows.Next() {
+		var change StatusChangeModel
+		err := rows.Scan(&change.id,
+			&change.deliveryID,
+			&change.fromStatus,
+			&change.toStatus,
+			&change.actor,
+			&change.createdAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan delivery history: %w", err)
+		}
+		changes = append(changes, change)
+	}
+
+	return changes, nil
+}
@@ -0,0 +1,214 @@
+package delivery_status_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sipub-test/internal/delivery_status"
+	"sipub-test/internal/unit_of_work"
+	"sipub-test/pkg/apperror"
+	"sipub-test/pkg/clock"
+	testhelper "sipub-test/pkg/test_helper"
+	"sipub-test/pkg/timestamp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var now = clock.Fixed(time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC))
+
+var createdAt = timestamp.New(now.Now())
+
+func expectStatus(mock sqlmock.Sqlmock, status string) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT status FROM deliveries WHERE id = ? AND isDeleted = FALSE FOR UPDATE`)).
+		WithArgs("delivery-123").
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(status))
+}
+
+func expectTransition(mock sqlmock.Sqlmock, from string, to string, actor interface{}) {
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE deliveries SET status = ?, updatedAt = ? WHERE id = ?`)).
+		WithArgs(to, createdAt, "delivery-123").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO delivery_status_history`).
+		WithArgs(sqlmock.AnyArg(), "delivery-123", from, to, actor, createdAt).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+}
+
+func TestTransition(t *testing.T) {
+	t.Run("ValidTransition", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := delivery_status.NewMySQLDeliveryStatusRepository(db, unit_of_work.NewUnitOfWork(db, now), now)
+
+		expectStatus(mock, "pending")
+		expectTransition(mock, "pending", "paid", "user-123")
+
+		change, err := repo.Transition(context.Background(), "delivery-123", delivery_status.TransitionParams{
+			Status: testhelper.StringPointer("paid"),
+			Actor:  testhelper.StringPointer("user-123"),
+		})
+
+		assert.NoError(t, err, "Shouldn't contain any errors")
+		assert.Equal(t, "pending", change.ToDTO().FromStatus)
+		assert.Equal(t, "paid", change.ToDTO().ToStatus)
+		assert.Equal(t, "user-123", *change.ToDTO().Actor)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("ShouldTakeTheStockOnDispatch", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := delivery_status.NewMySQLDeliveryStatusRepository(db, unit_of_work.NewUnitOfWork(db, now), now)
+
+		expectStatus(mock, "paid")
+		mock.ExpectQuery(`SELECT product_id, quantity, reason FROM stock_movements`).
+			WithArgs("delivery-123").
+			WillReturnRows(sqlmock.NewRows([]string{"product_id", "quantity", "reason"}).AddRow("product-123", 2, "reservation"))
+		mock.ExpectExec(`UPDATE products SET stock = stock - \?, reserved = reserved - \?`).
+			WithArgs(uint(2), uint(2), "product-123").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`INSERT INTO stock_movements`).
+			WithArgs(sqlmock.AnyArg(), "product-123", "delivery-123", -2, "sale", createdAt).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		expectTransition(mock, "paid", "dispatched", nil)
+
+		_, err = repo.Transition(context.Background(), "delivery-123", delivery_status.TransitionParams{
+			Status: testhelper.StringPointer("dispatched"),
+		})
+
+		assert.NoError(t, err, "Shouldn't contain any errors")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("ShouldGiveTheStockBackOnCancel", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := delivery_status.NewMySQLDeliveryStatusRepository(db, unit_of_work.NewUnitOfWork(db, now), now)
+
+		expectStatus(mock, "pending")
+		mock.ExpectQuery(`SELECT product_id, quantity, reason FROM stock_movements`).
+			WithArgs("delivery-123").
+			WillReturnRows(sqlmock.NewRows([]string{"product_id", "quantity", "reason"}).AddRow("product-123", 2, "reservation"))
+		mock.ExpectExec(`UPDATE products SET reserved = reserved - \?`).
+			WithArgs(uint(2), "product-123").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`INSERT INTO stock_movements`).
+			WithArgs(sqlmock.AnyArg(), "product-123", "delivery-123", -2, "release", createdAt).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		expectTransition(mock, "pending", "cancelled", nil)
+
+		_, err = repo.Transition(context.Background(), "delivery-123", delivery_status.TransitionParams{
+			Status: testhelper.StringPointer("cancelled"),
+		})
+
+		assert.NoError(t, err, "Shouldn't contain any errors")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("ShouldRejectIllegalMoves", func(t *testing.T) {
+		for _, move := range []struct{ from, to string }{
+			{"pending", "delivered"},
+			{"delivered", "cancelled"},
+			{"cancelled", "pending"},
+			{"refunded", "cancelled"},
+			{"paid", "paid"},
+		} {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("failed to create mock DB: %v", err)
+			}
+
+			repo := delivery_status.NewMySQLDeliveryStatusRepository(db, unit_of_work.NewUnitOfWork(db, now), now)
+
+			expectStatus(mock, move.from)
+			mock.ExpectRollback()
+
+			_, err = repo.Transition(context.Background(), "delivery-123", delivery_status.TransitionParams{
+				Status: testhelper.StringPointer(move.to),
+			})
+
+			assert.True(t, errors.Is(err, apperror.ErrConflict), "%s -> %s should be rejected", move.from, move.to)
+			assert.NoError(t, mock.ExpectationsWereMet(), "Nothing should be written")
+			db.Close()
+		}
+	})
+	t.Run("ShouldReportUnknownDeliveries", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := delivery_status.NewMySQLDeliveryStatusRepository(db, unit_of_work.NewUnitOfWork(db, now), now)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT status FROM deliveries`).
+			WithArgs("missing").
+			WillReturnRows(sqlmock.NewRows([]string{"status"}))
+		mock.ExpectRollback()
+
+		_, err = repo.Transition(context.Background(), "missing", delivery_status.TransitionParams{
+			Status: testhelper.StringPointer("paid"),
+		})
+
+		assert.True(t, errors.Is(err, apperror.ErrNotFound))
+	})
+}
+
+func TestHistory(t *testing.T) {
+	t.Run("ValidHistory", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := delivery_status.NewMySQLDeliveryStatusRepository(db, unit_of_work.NewUnitOfWork(db, now), now)
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id FROM deliveries WHERE id = ?`)).
+			WithArgs("delivery-123").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("delivery-123"))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, delivery_id, fromStatus, toStatus, actor, createdAt FROM delivery_status_history WHERE delivery_id = ? ORDER BY createdAt ASC`)).
+			WithArgs("delivery-123").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "delivery_id", "fromStatus", "toStatus", "actor", "createdAt"}).
+				AddRow("change-1", "delivery-123", "pending", "paid", "user-123", "2025-01-15 12:00:00").
+				AddRow("change-2", "delivery-123", "paid", "dispatched", nil, "2025-01-15 13:00:00"))
+
+		changes, err := repo.History(context.Background(), "delivery-123")
+
+		assert.NoError(t, err, "Shouldn't contain any errors")
+		assert.Len(t, changes, 2)
+		assert.Equal(t, "paid", changes[0].ToDTO().ToStatus)
+		assert.Nil(t, changes[1].ToDTO().Actor)
+	})
+	t.Run("ShouldReportUnknownDeliveries", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := delivery_status.NewMySQLDeliveryStatusRepository(db, unit_of_work.NewUnitOfWork(db, now), now)
+
+		mock.ExpectQuery(`SELECT id FROM deliveries`).
+			WithArgs("missing").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		_, err = repo.History(context.Background(), "missing")
+
+		assert.True(t, errors.Is(err, apperror.ErrNotFound))
+	})
+}
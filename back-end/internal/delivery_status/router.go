@@ -0,0 +1,40 @@
+package delivery_status
+
+import (
+	"net/http"
+	"sipub-test/internal/auth"
+)
+
+// The status only changes through transitions, so it has its own controller
+// interface instead of the deliveries' Update
+type IDeliveryStatusController interface {
+	Transition(http.ResponseWriter, *http.Request)
+
+	History(http.ResponseWriter, *http.Request)
+}
+
+// Changing the status needs a session, the history is public
+type DeliveryStatusRouter struct {
+	baseEndPoint string
+	controller   IDeliveryStatusController
+	guard        *auth.Guard
+}
+
+func NewDeliveryStatusRouter(controller IDeliveryStatusController, guard *auth.Guard) DeliveryStatusRouter {
+	return DeliveryStatusRouter{controller: controller, guard: guard}
+}
+
+func (r DeliveryStatusRouter) Init(mux *http.ServeMux) {
+	r.baseEndPoint = "/deliveries/{id}"
+
+	r.transition(mux)
+	r.history(mux)
+}
+
+func (r DeliveryStatusRouter) transition(mux *http.ServeMux) {
+	mux.HandleFunc("POST "+r.baseEndPoint+"/transitions", r.guard.Session(r.controller.Transition))
+}
+
+func (r DeliveryStatusRouter) history(mux *http.ServeMux) {
+	mux.HandleFunc("GET "+r.baseEndPoint+"/history", r.controller.History)
+}
@@ -0,0 +1,18 @@
+package delivery_status
+
+import (
+	"sipub-test/internal/delivery"
+	"sipub-test/pkg/apperror"
+)
+
+type TransitionValidator struct{}
+
+func (v *TransitionValidator) Validate(transition TransitionParams) error {
+	if transition.Status == nil || *transition.Status == "" {
+		return apperror.NewValidationError("Status", "is required")
+	}
+	if !delivery.Status(*transition.Status).IsValid() {
+		return apperror.NewValidationError("Status", "must be one of pending, paid, dispatched, in_transit, delivered, cancelled or refunded")
+	}
+	return nil
+}
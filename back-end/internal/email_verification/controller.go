@@ -0,0 +1,63 @@
+package email_verification
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sipub-test/internal"
+	"sipub-test/internal/response"
+	"sipub-test/internal/user"
+)
+
+type EmailVerificationController struct {
+	repository IEmailVerificationRepository
+	sender     *Sender
+	users      user.IUserRepository
+	validator  internal.IValidator[VerifyParams]
+	logger     *slog.Logger
+}
+
+func NewEmailVerificationController(repository IEmailVerificationRepository, sender *Sender, users user.IUserRepository, validator internal.IValidator[VerifyParams], logger *slog.Logger) *EmailVerificationController {
+	return &EmailVerificationController{repository: repository, sender: sender, users: users, validator: validator, logger: logger}
+}
+
+// Checks the token that was mailed to the user, returns the user with
+// EmailVerifiedAt set
+func (c *EmailVerificationController) Verify(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	var verifyParams VerifyParams
+	err := json.NewDecoder(r.Body).Decode(&verifyParams)
+	if err != nil {
+		response.BadRequest(w, r, "invalid request body: "+err.Error())
+		return
+	}
+
+	if err := c.validator.Validate(verifyParams); err != nil {
+		response.Error(w, r, c.logger, err)
+		return
+	}
+
+	if err := c.repository.Verify(r.Context(), id, *verifyParams.Token); err != nil {
+		response.Error(w, r, c.logger, err)
+		return
+	}
+
+	verified, err := c.users.GetOne(r.Context(), id)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, verified.ToDTO())
+}
+
+// Mails a new token, the previous ones stop working
+func (c *EmailVerificationController) Resend(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	expiresAt, err := c.sender.Send(r.Context(), id)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
+	}
+
+	response.JSON(w, http.StatusAccepted, SentDTO{ExpiresAt: expiresAt.String()})
+}
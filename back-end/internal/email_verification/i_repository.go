@@ -0,0 +1,18 @@
+package email_verification
+
+import (
+	"context"
+	"sipub-test/pkg/timestamp"
+)
+
+type IEmailVerificationRepository interface {
+	// Replaces the tokens of the user with one for its current email, and
+	// returns that email so the token can be mailed to it. An email that is
+	// already verified is a conflict
+	Issue(ctx context.Context, userID string, token string, expiresAt timestamp.Time) (string, error)
+
+	// Marks the email of the user as verified. The token must not be expired
+	// and must have been sent to the email the user has now, it can't be used
+	// twice
+	Verify(ctx context.Context, userID string, token string) error
+}
@@ -0,0 +1,11 @@
+package email_verification
+
+// Body of POST /u/{id}/verify-email, the token that was mailed to the user
+type VerifyParams struct {
+	Token *string
+}
+
+// Returned when a token is sent, the token itself only goes by email
+type SentDTO struct {
+	ExpiresAt string `json:"ExpiresAt"`
+}
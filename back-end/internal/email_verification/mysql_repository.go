@@ -0,0 +1,91 @@
+package email_verification
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sipub-test/db"
+	"sipub-test/pkg/apperror"
+	"sipub-test/pkg/clock"
+	"sipub-test/pkg/randtoken"
+	"sipub-test/pkg/timestamp"
+
+	"github.com/google/uuid"
+)
+
+type MySQLEmailVerificationRepository struct {
+	db    db.Executor
+	clock clock.Clock
+}
+
+func NewMySQLEmailVerificationRepository(db db.Executor, clock clock.Clock) *MySQLEmailVerificationRepository {
+	return &MySQLEmailVerificationRepository{db: db, clock: clock}
+}
+
+func (r *MySQLEmailVerificationRepository) Issue(ctx context.Context, userID string, token string, expiresAt timestamp.Time) (string, error) {
+	id := uuid.NewString()
+	createdAt := timestamp.New(r.clock.Now())
+
+	var address string
+	err := db.WithTx(ctx, r.db, func(tx db.Executor) error {
+		// Locked so a verification can't finish between the check and the
+		// new token
+		var verified bool
+		query := `SELECT email, emailVerifiedAt IS NOT NULL FROM users WHERE id = ? AND isDeleted = FALSE FOR UPDATE`
+		err := tx.QueryRowContext(ctx, query, userID).Scan(&address, &verified)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("user %w", apperror.ErrNotFound)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get user: %w", err)
+		}
+		if verified {
+			return fmt.Errorf("email is already verified: %w", apperror.ErrConflict)
+		}
+
+		// Only the last token sent works
+		if _, err := tx.ExecContext(ctx, `DELETE FROM email_verification_tokens WHERE user_id = ?`, userID); err != nil {
+			return fmt.Errorf("failed to delete previous tokens: %w", err)
+		}
+		query = `INSERT INTO email_verification_tokens (id, user_id, email, tokenHash, expiresAt, createdAt) VALUES (?, ?, ?, ?, ?, ?)`
+		if _, err := tx.ExecContext(ctx, query, id, userID, address, randtoken.Hash(token), expiresAt, createdAt); err != nil {
+			return fmt.Errorf("failed to create token: %w", db.TranslateError(err))
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return address, nil
+}
+
+func (r *MySQLEmailVerificationRepository) Verify(ctx context.Context, userID string, token string) error {
+	now := timestamp.New(r.clock.Now())
+	return db.WithTx(ctx, r.db, func(tx db.Executor) error {
+		var sentTo, address string
+		var expiresAt timestamp.Time
+		query := `SELECT t.email, t.expiresAt, u.email FROM email_verification_tokens t JOIN users u ON u.id = t.user_id WHERE t.user_id = ? AND t.tokenHash = ? AND u.isDeleted = FALSE FOR UPDATE`
+		err := tx.QueryRowContext(ctx, query, userID, randtoken.Hash(token)).Scan(&sentTo, &expiresAt, &address)
+		if errors.Is(err, sql.ErrNoRows) {
+			return apperror.NewValidationError("Token", "is invalid or was already used")
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get token: %w", err)
+		}
+		if now.Time().After(expiresAt.Time()) {
+			return apperror.NewValidationError("Token", "has expired, ask for a new one")
+		}
+		if sentTo != address {
+			return apperror.NewValidationError("Token", "was sent to an email the user no longer has")
+		}
+
+		if _, err := tx.ExecContext(ctx, `UPDATE users SET emailVerifiedAt = ? WHERE id = ?`, now, userID); err != nil {
+			return fmt.Errorf("failed to verify email: %w", db.TranslateError(err))
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM email_verification_tokens WHERE user_id = ?`, userID); err != nil {
+			return fmt.Errorf("failed to delete used tokens: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,178 @@
+package email_verification_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"sipub-test/internal/email_verification"
+	"sipub-test/pkg/apperror"
+	"sipub-test/pkg/clock"
+	"sipub-test/pkg/timestamp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var now = clock.Fixed(time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC))
+
+var expiresAt = timestamp.New(now.Now().Add(24 * time.Hour))
+
+// What the table keeps of "token-123"
+var tokenHash = func() string {
+	sum := sha256.Sum256([]byte("token-123"))
+	return hex.EncodeToString(sum[:])
+}()
+
+func expectUser(mock sqlmock.Sqlmock, verified bool) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT email, emailVerifiedAt IS NOT NULL FROM users WHERE id = ? AND isDeleted = FALSE FOR UPDATE`)).
+		WithArgs("user-123").
+		WillReturnRows(sqlmock.NewRows([]string{"email", "verified"}).AddRow("ana@example.com", verified))
+}
+
+func expectToken(mock sqlmock.Sqlmock, sentTo string, expiresAt string) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT t.email, t.expiresAt, u.email FROM email_verification_tokens t JOIN users u ON u.id = t.user_id WHERE t.user_id = ? AND t.tokenHash = ? AND u.isDeleted = FALSE FOR UPDATE`)).
+		WithArgs("user-123", tokenHash).
+		WillReturnRows(sqlmock.NewRows([]string{"email", "expiresAt", "email"}).AddRow(sentTo, expiresAt, "ana@example.com"))
+}
+
+func TestIssue(t *testing.T) {
+	t.Run("ShouldReplaceThePreviousTokens", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := email_verification.NewMySQLEmailVerificationRepository(db, now)
+		expectUser(mock, false)
+		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM email_verification_tokens WHERE user_id = ?`)).
+			WithArgs("user-123").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO email_verification_tokens (id, user_id, email, tokenHash, expiresAt, createdAt) VALUES (?, ?, ?, ?, ?, ?)`)).
+			WithArgs(sqlmock.AnyArg(), "user-123", "ana@example.com", tokenHash, expiresAt, timestamp.New(now.Now())).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		address, err := repo.Issue(context.Background(), "user-123", "token-123", expiresAt)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "ana@example.com", address)
+		assert.NoError(t, mock.ExpectationsWereMet(), "Only the hash of the token should be stored")
+	})
+	t.Run("ShouldRefuseAVerifiedEmail", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := email_verification.NewMySQLEmailVerificationRepository(db, now)
+		expectUser(mock, true)
+		mock.ExpectRollback()
+
+		_, err = repo.Issue(context.Background(), "user-123", "token-123", expiresAt)
+
+		assert.ErrorIs(t, err, apperror.ErrConflict)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("ShouldReturnNotFoundForAnUnknownUser", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := email_verification.NewMySQLEmailVerificationRepository(db, now)
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT email`).
+			WithArgs("user-123").
+			WillReturnRows(sqlmock.NewRows([]string{"email", "verified"}))
+		mock.ExpectRollback()
+
+		_, err = repo.Issue(context.Background(), "user-123", "token-123", expiresAt)
+
+		assert.ErrorIs(t, err, apperror.ErrNotFound)
+	})
+}
+
+func TestVerify(t *testing.T) {
+	t.Run("ShouldVerifyAndUseTheToken", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := email_verification.NewMySQLEmailVerificationRepository(db, now)
+		expectToken(mock, "ana@example.com", "2025-01-16 12:00:00")
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE users SET emailVerifiedAt = ? WHERE id = ?`)).
+			WithArgs(timestamp.New(now.Now()), "user-123").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM email_verification_tokens WHERE user_id = ?`)).
+			WithArgs("user-123").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err = repo.Verify(context.Background(), "user-123", "token-123")
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("ShouldRejectAnUnknownToken", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := email_verification.NewMySQLEmailVerificationRepository(db, now)
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT t.email`).
+			WithArgs("user-123", tokenHash).
+			WillReturnRows(sqlmock.NewRows([]string{"email", "expiresAt", "email"}))
+		mock.ExpectRollback()
+
+		err = repo.Verify(context.Background(), "user-123", "token-123")
+
+		assert.ErrorIs(t, err, apperror.ErrValidation)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("ShouldRejectAnExpiredToken", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := email_verification.NewMySQLEmailVerificationRepository(db, now)
+		expectToken(mock, "ana@example.com", "2025-01-15 11:59:59")
+		mock.ExpectRollback()
+
+		err = repo.Verify(context.Background(), "user-123", "token-123")
+
+		assert.ErrorIs(t, err, apperror.ErrValidation)
+		assert.Contains(t, err.Error(), "expired")
+		assert.NoError(t, mock.ExpectationsWereMet(), "The email shouldn't be verified")
+	})
+	t.Run("ShouldRejectATokenSentToAnotherEmail", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := email_verification.NewMySQLEmailVerificationRepository(db, now)
+		expectToken(mock, "old@example.com", "2025-01-16 12:00:00")
+		mock.ExpectRollback()
+
+		err = repo.Verify(context.Background(), "user-123", "token-123")
+
+		assert.ErrorIs(t, err, apperror.ErrValidation)
+		assert.NoError(t, mock.ExpectationsWereMet(), "The new email shouldn't be verified")
+	})
+}
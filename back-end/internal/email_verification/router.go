@@ -0,0 +1,39 @@
+package email_verification
+
+import (
+	"net/http"
+	"sipub-test/internal/auth"
+)
+
+// Tokens are only created by the server and mailed, there is no CRUD
+type IEmailVerificationController interface {
+	Verify(http.ResponseWriter, *http.Request)
+	Resend(http.ResponseWriter, *http.Request)
+}
+
+type EmailVerificationRouter struct {
+	baseEndPoint string
+	controller   IEmailVerificationController
+	guard        *auth.Guard
+}
+
+func NewEmailVerificationRouter(controller IEmailVerificationController, guard *auth.Guard) EmailVerificationRouter {
+	return EmailVerificationRouter{controller: controller, guard: guard}
+}
+
+func (r EmailVerificationRouter) Init(mux *http.ServeMux) {
+	r.baseEndPoint = "/u/{id}/verify-email"
+
+	r.verify(mux)
+	r.resend(mux)
+}
+
+// The mailed token is enough, the user may not be logged in on the device
+// that opened the email
+func (r EmailVerificationRouter) verify(mux *http.ServeMux) {
+	mux.HandleFunc("POST "+r.baseEndPoint, r.controller.Verify)
+}
+
+func (r EmailVerificationRouter) resend(mux *http.ServeMux) {
+	mux.HandleFunc("POST "+r.baseEndPoint+"/resend", r.guard.Self(r.controller.Resend))
+}
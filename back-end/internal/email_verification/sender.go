@@ -0,0 +1,49 @@
+package email_verification
+
+import (
+	"context"
+	"fmt"
+	"sipub-test/internal/mailer"
+	"sipub-test/pkg/clock"
+	"sipub-test/pkg/randtoken"
+	"sipub-test/pkg/timestamp"
+	"time"
+)
+
+// Issues a token and mails it. Used when a user is created or changes the
+// email, and when the user asks for a new token
+type Sender struct {
+	repository IEmailVerificationRepository
+	mailer     mailer.IMailer
+	clock      clock.Clock
+	ttl        time.Duration // How long the token works
+}
+
+func NewSender(repository IEmailVerificationRepository, mailer mailer.IMailer, clock clock.Clock, ttl time.Duration) *Sender {
+	return &Sender{repository: repository, mailer: mailer, clock: clock, ttl: ttl}
+}
+
+// Returns when the token expires
+func (s *Sender) Send(ctx context.Context, userID string) (timestamp.Time, error) {
+	token, err := randtoken.New()
+	if err != nil {
+		return timestamp.Time{}, err
+	}
+	expiresAt := timestamp.New(s.clock.Now().Add(s.ttl))
+
+	address, err := s.repository.Issue(ctx, userID, token, expiresAt)
+	if err != nil {
+		return timestamp.Time{}, err
+	}
+
+	message := mailer.Message{
+		To:      address,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf("Use the token below to verify your email, it expires at %s.\n\n%s\n\nSend it as {\"Token\": \"...\"} to POST /u/%s/verify-email.\n",
+			expiresAt, token, userID),
+	}
+	if err := s.mailer.Send(ctx, message); err != nil {
+		return timestamp.Time{}, fmt.Errorf("failed to send verification email: %w", err)
+	}
+	return expiresAt, nil
+}
@@ -0,0 +1,71 @@
+package email_verification_test
+
+import (
+	"context"
+	"errors"
+	"sipub-test/internal/email_verification"
+	"sipub-test/internal/mailer"
+	"sipub-test/pkg/timestamp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Keeps the token instead of hashing it, so the test can compare it with the
+// one that was mailed
+type fakeRepository struct {
+	tokens    []string
+	expiresAt timestamp.Time
+	err       error
+}
+
+func (f *fakeRepository) Issue(ctx context.Context, userID string, token string, expiresAt timestamp.Time) (string, error) {
+	f.tokens = append(f.tokens, token)
+	f.expiresAt = expiresAt
+	return "ana@example.com", f.err
+}
+
+func (f *fakeRepository) Verify(ctx context.Context, userID string, token string) error {
+	return nil
+}
+
+type mailerSpy struct {
+	sent []mailer.Message
+}
+
+func (m *mailerSpy) Send(ctx context.Context, message mailer.Message) error {
+	m.sent = append(m.sent, message)
+	return nil
+}
+
+func TestSenderSend(t *testing.T) {
+	t.Run("ShouldMailANewTokenEachTime", func(t *testing.T) {
+		repository := &fakeRepository{}
+		mail := &mailerSpy{}
+		sender := email_verification.NewSender(repository, mail, now, 24*time.Hour)
+
+		expiresAt, err := sender.Send(context.Background(), "user-123")
+		sender.Send(context.Background(), "user-123")
+
+		assert.NoError(t, err)
+		assert.Equal(t, timestamp.New(time.Date(2025, 1, 16, 12, 0, 0, 0, time.UTC)), expiresAt)
+		assert.Equal(t, expiresAt, repository.expiresAt)
+		assert.NotEqual(t, repository.tokens[0], repository.tokens[1], "Tokens should be random")
+		if assert.Len(t, mail.sent, 2) {
+			assert.Equal(t, "ana@example.com", mail.sent[0].To)
+			assert.Contains(t, mail.sent[0].Body, repository.tokens[0], "The token should be in the email")
+			assert.Contains(t, mail.sent[0].Body, "/u/user-123/verify-email")
+		}
+	})
+	t.Run("ShouldNotMailWhenTheTokenWasRefused", func(t *testing.T) {
+		repository := &fakeRepository{err: errors.New("email is already verified")}
+		mail := &mailerSpy{}
+		sender := email_verification.NewSender(repository, mail, now, 24*time.Hour)
+
+		_, err := sender.Send(context.Background(), "user-123")
+
+		assert.Error(t, err)
+		assert.Empty(t, mail.sent)
+	})
+}
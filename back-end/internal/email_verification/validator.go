@@ -0,0 +1,17 @@
+package email_verification
+
+import (
+	"sipub-test/pkg/apperror"
+)
+
+type VerifyValidator struct{}
+
+func (v *VerifyValidator) Validate(params VerifyParams) error {
+	if params.Token == nil {
+		return apperror.NewValidationError("Token", "is required")
+	}
+	if *params.Token == "" {
+		return apperror.NewValidationError("Token", "can't be empty")
+	}
+	return nil
+}
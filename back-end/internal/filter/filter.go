@@ -0,0 +1,297 @@
+package filter
+
+// Querystring filters shared by every GetAll/DeleteAll. Each resource declares
+// the fields it can be filtered by, and which operators each field accepts.
+// `?Price[gte]=10&Name=shirt` is parsed into a Filter, the AST, which the
+// MySQL repositories compile into the WHERE clause. Columns never come from
+// the request, only from the Schema, and every value is a placeholder
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sipub-test/internal/pagination"
+	"sipub-test/pkg/apperror"
+	"sipub-test/pkg/cpf"
+	"sipub-test/pkg/money"
+	"sipub-test/pkg/timestamp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Type int
+
+const (
+	String Type = iota
+	Bool
+	Number  // FLOAT columns
+	Integer // INT columns
+	Time    // DATETIME(6) columns, in UTC
+	Money   // DECIMAL(12,2) columns, compared exactly
+	Cpf     // CHAR(11) columns, formatted values are reduced to the digits
+)
+
+type Operator string
+
+const (
+	Eq     Operator = "eq"
+	Ne     Operator = "ne"
+	Gt     Operator = "gt"
+	Gte    Operator = "gte"
+	Lt     Operator = "lt"
+	Lte    Operator = "lte"
+	Like   Operator = "like" // Partial match
+	In     Operator = "in"   // Repeated keys or comma separated values
+	After  Operator = "after"
+	Before Operator = "before"
+)
+
+// Common operator sets for the schemas
+var (
+	NumberOperators  = []Operator{Eq, Gt, Gte, Lt, Lte}
+	IntegerOperators = []Operator{Eq, Ne, Gt, Gte, Lt, Lte}
+	TimeOperators    = []Operator{After, Before, Gte, Lte}
+)
+
+const (
+	// Schemas with this field hold soft deleted rows, which are hidden unless
+	// the request filters by it or sets IncludeDeletedKey
+	SoftDeleteField   = "IsDeleted"
+	IncludeDeletedKey = "includeDeleted"
+)
+
+type Field struct {
+	Column    string
+	Type      Type
+	Operators []Operator // The first one is used when the key has no operator
+	Required  bool
+}
+
+// Querystring name : field. The names are matched case insensitively
+type Schema map[string]Field
+
+// A single `column operator values` comparison
+type Condition struct {
+	Field    string // Name in the schema
+	Column   string
+	Type     Type
+	Operator Operator
+	Values   []interface{} // Already converted to the field type
+}
+
+// Conditions joined with AND. The zero value matches every row
+type Filter struct {
+	Conditions []Condition
+}
+
+// Matches `Price` and `Price[gte]`
+var keyPattern = regexp.MustCompile(`^(\w+)(?:\[(\w+)\])?$`)
+
+// Parses the querystring into a Filter. The pagination keys are ignored,
+// anything else the schema doesn't know about is a validation error
+func Parse(query url.Values, schema Schema) (Filter, error) {
+	var filter Filter
+	includeDeleted := false
+
+	// Sorted so the generated SQL is always the same for the same request
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if pagination.IsReserved(key) {
+			continue
+		}
+		if strings.EqualFold(key, IncludeDeletedKey) {
+			if _, _, found := schema.lookup(SoftDeleteField); !found {
+				return Filter{}, apperror.NewValidationError(key, "is not a valid filter")
+			}
+			value, err := strconv.ParseBool(query.Get(key))
+			if err != nil {
+				return Filter{}, apperror.NewValidationError(key, "must be true or false")
+			}
+			includeDeleted = value
+			continue
+		}
+		match := keyPattern.FindStringSubmatch(key)
+		if match == nil {
+			return Filter{}, apperror.NewValidationError(key, "is not a valid filter")
+		}
+		name, field, found := schema.lookup(match[1])
+		if !found {
+			return Filter{}, apperror.NewValidationError(key, "is not a valid filter")
+		}
+
+		operator := field.Operators[0]
+		if match[2] != "" {
+			operator = Operator(strings.ToLower(match[2]))
+			if !field.allows(operator) {
+				return Filter{}, apperror.NewValidationError(key, fmt.Sprintf("operator %q isn't supported, use one of %s", operator, field.operatorList()))
+			}
+		}
+
+		raw := query[key]
+		if operator == In {
+			raw = []string{}
+			for _, value := range query[key] {
+				raw = append(raw, strings.Split(value, ",")...)
+			}
+		} else if len(raw) > 1 {
+			return Filter{}, apperror.NewValidationError(key, "can only have one value")
+		}
+
+		condition := Condition{Field: name, Column: field.Column, Type: field.Type, Operator: operator}
+		for _, value := range raw {
+			converted, err := convert(field.Type, strings.TrimSpace(value))
+			if err != nil {
+				return Filter{}, apperror.NewValidationError(key, err.Error())
+			}
+			condition.Values = append(condition.Values, converted)
+		}
+		filter.Conditions = append(filter.Conditions, condition)
+	}
+
+	if name, field, found := schema.lookup(SoftDeleteField); found && !includeDeleted && !filter.Has(name) {
+		filter.Conditions = append(filter.Conditions, Condition{Field: name, Column: field.Column, Type: Bool, Operator: Eq, Values: []interface{}{false}})
+	}
+
+	for name, field := range schema {
+		if field.Required && !filter.Has(name) {
+			return Filter{}, apperror.NewValidationError(name, "is required")
+		}
+	}
+
+	return filter, nil
+}
+
+// Reports if there is a condition on the field
+func (f Filter) Has(field string) bool {
+	for _, condition := range f.Conditions {
+		if strings.EqualFold(condition.Field, field) {
+			return true
+		}
+	}
+	return false
+}
+
+// Compiles the conditions into " AND ..." clauses, to be appended after a
+// `WHERE 1=1`
+func (f Filter) SQL() (string, []interface{}) {
+	query := ""
+	args := []interface{}{}
+
+	for _, condition := range f.Conditions {
+		column := condition.Column
+		switch condition.Operator {
+		case Eq:
+			if condition.Type == Number {
+				// FLOAT columns rarely match an exact value
+				query += " AND ROUND(" + column + ", 2) = ROUND(?, 2)"
+			} else {
+				query += " AND " + column + " = ?"
+			}
+		case Ne:
+			query += " AND " + column + " <> ?"
+		case Gt, After:
+			query += " AND " + column + " > ?"
+		case Gte:
+			query += " AND " + column + " >= ?"
+		case Lt, Before:
+			query += " AND " + column + " < ?"
+		case Lte:
+			query += " AND " + column + " <= ?"
+		case Like:
+			query += " AND " + column + " LIKE ?"
+			args = append(args, "%"+escapeLike(condition.Values[0].(string))+"%")
+			continue
+		case In:
+			query += " AND " + column + " IN (?" + strings.Repeat(", ?", len(condition.Values)-1) + ")"
+		}
+		args = append(args, condition.Values...)
+	}
+
+	return query, args
+}
+
+func (s Schema) lookup(name string) (string, Field, bool) {
+	for fieldName, field := range s {
+		if strings.EqualFold(fieldName, name) {
+			return fieldName, field, true
+		}
+	}
+	return "", Field{}, false
+}
+
+func (f Field) allows(operator Operator) bool {
+	for _, allowed := range f.Operators {
+		if allowed == operator {
+			return true
+		}
+	}
+	return false
+}
+
+func (f Field) operatorList() string {
+	names := []string{}
+	for _, operator := range f.Operators {
+		names = append(names, string(operator))
+	}
+	return strings.Join(names, ", ")
+}
+
+func convert(fieldType Type, value string) (interface{}, error) {
+	switch fieldType {
+	case Bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("must be true or false")
+		}
+		return parsed, nil
+	case Number:
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("must be a number")
+		}
+		return parsed, nil
+	case Money:
+		parsed, err := money.Parse(value, money.HalfUp)
+		if err != nil {
+			return nil, fmt.Errorf("must be an amount, like 10.50")
+		}
+		return parsed, nil
+	case Integer:
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("must be an integer")
+		}
+		return parsed, nil
+	case Time:
+		if parsed, err := time.Parse(time.RFC3339Nano, value); err == nil {
+			return timestamp.New(parsed), nil
+		}
+		// A day alone is its midnight in UTC
+		if parsed, err := time.Parse("2006-01-02", value); err == nil {
+			return timestamp.New(parsed), nil
+		}
+		return nil, fmt.Errorf("must be a date (2006-01-02) or RFC3339 timestamp")
+	case Cpf:
+		digits, err := cpf.Normalize(value)
+		if err != nil {
+			return nil, fmt.Errorf("must be a valid CPF")
+		}
+		return digits, nil
+	}
+	if value == "" {
+		return nil, fmt.Errorf("can't be empty")
+	}
+	return value, nil
+}
+
+// The value is matched literally, % and _ aren't wildcards
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
@@ -0,0 +1,175 @@
+package filter_test
+
+import (
+	"errors"
+	"net/url"
+	"sipub-test/internal/filter"
+	"sipub-test/pkg/apperror"
+	"sipub-test/pkg/money"
+	"sipub-test/pkg/timestamp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var schema = filter.Schema{
+	"IsActive":  {Column: "isActive", Type: filter.Bool, Operators: []filter.Operator{filter.In}},
+	"Name":      {Column: "name", Type: filter.String, Operators: []filter.Operator{filter.Like, filter.Eq}},
+	"Price":     {Column: "price", Type: filter.Number, Operators: filter.NumberOperators},
+	"Amount":    {Column: "amount", Type: filter.Integer, Operators: filter.IntegerOperators},
+	"CreatedAt": {Column: "createdAt", Type: filter.Time, Operators: filter.TimeOperators},
+	"Total":     {Column: "total", Type: filter.Money, Operators: filter.NumberOperators},
+	"Cpf":       {Column: "cpf", Type: filter.Cpf, Operators: []filter.Operator{filter.Eq}},
+}
+
+func parse(t *testing.T, rawQuery string, schema filter.Schema) (filter.Filter, error) {
+	t.Helper()
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		t.Fatalf("failed to parse query: %v", err)
+	}
+	return filter.Parse(query, schema)
+}
+
+func TestParse(t *testing.T) {
+	t.Run("ShouldBuildTypedConditions", func(t *testing.T) {
+		where, err := parse(t, "price[GTE]=10&Price[lte]=50.5&amount[ne]=3&CreatedAt[after]=2025-01-01&limit=10&sort=-Price", schema)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []filter.Condition{
+			{Field: "CreatedAt", Column: "createdAt", Type: filter.Time, Operator: filter.After, Values: []interface{}{timestamp.New(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))}},
+			{Field: "Price", Column: "price", Type: filter.Number, Operator: filter.Lte, Values: []interface{}{50.5}},
+			{Field: "Amount", Column: "amount", Type: filter.Integer, Operator: filter.Ne, Values: []interface{}{int64(3)}},
+			{Field: "Price", Column: "price", Type: filter.Number, Operator: filter.Gte, Values: []interface{}{10.0}},
+		}, where.Conditions, "The keys should be sorted and the pagination ignored")
+	})
+	t.Run("ShouldUseTheFirstOperatorByDefault", func(t *testing.T) {
+		where, err := parse(t, "Name=shirt", schema)
+
+		assert.NoError(t, err)
+		assert.Equal(t, filter.Like, where.Conditions[0].Operator)
+	})
+	t.Run("ShouldCollectMultipleValues", func(t *testing.T) {
+		where, err := parse(t, "IsActive=true,false&IsActive=true", schema)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []interface{}{true, false, true}, where.Conditions[0].Values)
+	})
+	t.Run("ShouldConvertTimestampsToUTC", func(t *testing.T) {
+		where, err := parse(t, "CreatedAt[before]=2025-02-01T03:00:00-03:00", schema)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []interface{}{timestamp.New(time.Date(2025, 2, 1, 6, 0, 0, 0, time.UTC))}, where.Conditions[0].Values)
+	})
+	t.Run("ShouldRejectInvalidFilters", func(t *testing.T) {
+		for _, query := range []string{
+			"Color=red",            // Unknown field
+			"Price[between]=1",     // Unknown operator
+			"Name[gt]=a",           // Operator not allowed for the field
+			"Price=cheap",          // Wrong type
+			"Amount=1.5",           // Wrong type
+			"Total=1,50",           // Wrong type
+			"IsActive=maybe",       // Wrong type
+			"CreatedAt[after]=now", // Wrong type
+			"Cpf=12345678901",      // Invalid check digits
+			"Name=a&Name=b",        // Multiple values without IN
+			"Name=",                // Empty value
+			"Name[eq",              // Malformed key
+		} {
+			_, err := parse(t, query, schema)
+			assert.True(t, errors.Is(err, apperror.ErrValidation), "%q should be rejected", query)
+		}
+	})
+	t.Run("ShouldRequireFields", func(t *testing.T) {
+		required := filter.Schema{"UserID": {Column: "user_id", Type: filter.String, Operators: []filter.Operator{filter.Eq}, Required: true}}
+
+		_, err := parse(t, "", required)
+		var validationErr *apperror.ValidationError
+		if assert.True(t, errors.As(err, &validationErr)) {
+			assert.Equal(t, "UserID", validationErr.Field)
+		}
+
+		where, err := parse(t, "userid=abc", required)
+		assert.NoError(t, err)
+		assert.True(t, where.Has("UserID"))
+	})
+}
+
+func TestParseSoftDelete(t *testing.T) {
+	softDeleted := filter.Schema{
+		"Name":      {Column: "name", Type: filter.String, Operators: []filter.Operator{filter.Like}},
+		"IsDeleted": {Column: "isDeleted", Type: filter.Bool, Operators: []filter.Operator{filter.Eq}},
+	}
+	notDeleted := filter.Condition{Field: "IsDeleted", Column: "isDeleted", Type: filter.Bool, Operator: filter.Eq, Values: []interface{}{false}}
+
+	t.Run("ShouldHideDeletedRowsByDefault", func(t *testing.T) {
+		where, err := parse(t, "Name=shirt", softDeleted)
+
+		assert.NoError(t, err)
+		assert.Equal(t, notDeleted, where.Conditions[len(where.Conditions)-1])
+	})
+	t.Run("ShouldListEverythingWhenIncludingDeleted", func(t *testing.T) {
+		where, err := parse(t, "includeDeleted=true", softDeleted)
+
+		assert.NoError(t, err)
+		assert.Empty(t, where.Conditions)
+	})
+	t.Run("ShouldPreferAnExplicitFilter", func(t *testing.T) {
+		where, err := parse(t, "IsDeleted=true", softDeleted)
+
+		assert.NoError(t, err)
+		assert.Len(t, where.Conditions, 1)
+		assert.Equal(t, []interface{}{true}, where.Conditions[0].Values)
+	})
+	t.Run("ShouldRejectIncludeDeletedWithoutSoftDeletion", func(t *testing.T) {
+		_, err := parse(t, "includeDeleted=true", schema)
+		assert.True(t, errors.Is(err, apperror.ErrValidation), "The schema has no IsDeleted field")
+
+		_, err = parse(t, "includeDeleted=maybe", softDeleted)
+		assert.True(t, errors.Is(err, apperror.ErrValidation))
+	})
+}
+
+func TestSQL(t *testing.T) {
+	t.Run("ShouldCompileEveryOperator", func(t *testing.T) {
+		where, _ := parse(t, "Amount[gt]=1&Amount[lte]=5&IsActive=true,false&Name[eq]=a&Price=9.99&CreatedAt[before]=2025-01-01", schema)
+
+		query, args := where.SQL()
+
+		assert.Equal(t, " AND amount > ? AND amount <= ? AND createdAt < ? AND isActive IN (?, ?) AND name = ? AND ROUND(price, 2) = ROUND(?, 2)", query)
+		assert.Equal(t, []interface{}{int64(1), int64(5), timestamp.New(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)), true, false, "a", 9.99}, args)
+	})
+	t.Run("ShouldCompareMoneyExactly", func(t *testing.T) {
+		where, err := parse(t, "Total=10.5", schema)
+
+		query, args := where.SQL()
+
+		assert.NoError(t, err)
+		assert.Equal(t, " AND total = ?", query, "DECIMAL columns don't need the rounding")
+		assert.Equal(t, []interface{}{money.FromCents(1050)}, args)
+	})
+	t.Run("ShouldCompareTheCpfDigits", func(t *testing.T) {
+		where, err := parse(t, "Cpf=123.456.789-09", schema)
+
+		query, args := where.SQL()
+
+		assert.NoError(t, err)
+		assert.Equal(t, " AND cpf = ?", query)
+		assert.Equal(t, []interface{}{"12345678909"}, args)
+	})
+	t.Run("ShouldEscapeLikeWildcards", func(t *testing.T) {
+		where, _ := parse(t, "Name=50%25_off", schema)
+
+		query, args := where.SQL()
+
+		assert.Equal(t, " AND name LIKE ?", query)
+		assert.Equal(t, []interface{}{`%50\%\_off%`}, args)
+	})
+	t.Run("ShouldMatchEverythingWhenEmpty", func(t *testing.T) {
+		query, args := filter.Filter{}.SQL()
+
+		assert.Empty(t, query)
+		assert.Empty(t, args)
+	})
+}
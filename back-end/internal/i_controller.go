@@ -15,3 +15,14 @@ type IController interface {
 
 	Update(http.ResponseWriter, *http.Request)
 }
+
+// Controllers of the resources that are soft deleted, DeleteOne/DeleteAll only
+// flag the rows
+type ISoftDeleteController interface {
+	IController
+
+	Restore(http.ResponseWriter, *http.Request)
+
+	// Hard deletes the row
+	Purge(http.ResponseWriter, *http.Request)
+}
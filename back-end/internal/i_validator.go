@@ -0,0 +1,8 @@
+package internal
+
+// Validates the params received by a controller before they reach the
+// repository. Each entity has its own implementation in validator.go, the
+// controllers only know about this interface so tests can swap it.
+type IValidator[T any] interface {
+	Validate(params T) error
+}
@@ -0,0 +1,90 @@
+package inventory
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sipub-test/internal"
+	"sipub-test/internal/filter"
+	"sipub-test/internal/pagination"
+	"sipub-test/internal/response"
+)
+
+type InventoryController struct {
+	repository IInventoryRepository
+	validator  internal.IValidator[RestockParams]
+	logger     *slog.Logger
+}
+
+func NewInventoryController(repository IInventoryRepository, validator internal.IValidator[RestockParams], logger *slog.Logger) *InventoryController {
+	return &InventoryController{repository: repository, validator: validator, logger: logger}
+}
+
+func (c *InventoryController) Restock(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	var restockParams RestockParams
+	err := json.NewDecoder(r.Body).Decode(&restockParams)
+	if err != nil {
+		response.BadRequest(w, r, "invalid request body: "+err.Error())
+		return
+	}
+
+	if err := c.validator.Validate(restockParams); err != nil {
+		response.Error(w, r, c.logger, err)
+		return
+	}
+
+	movement, err := c.repository.Restock(r.Context(), id, *restockParams.Quantity)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, movement.ToDTO())
+}
+
+func (c *InventoryController) GetAll(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+
+	// The accepted fields and operators are declared in filterFields
+	where, err := filter.Parse(queryParams, filterFields)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
+	}
+
+	page, err := pagination.Parse(queryParams, sortFields)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
+	}
+
+	foundMovements, err := c.repository.GetAll(r.Context(), where, page)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
+	}
+	foundMovements, hasMore := pagination.Trim(page, foundMovements)
+
+	total, err := c.repository.Count(r.Context(), where)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
+	}
+
+	dtoFoundMovements := toDTOs(foundMovements)
+	var lastID string
+	if hasMore {
+		lastID = dtoFoundMovements[len(dtoFoundMovements)-1].Id
+	}
+	pagination.WriteHeaders(w, r, page, total, hasMore, lastID)
+	response.JSON(w, http.StatusOK, dtoFoundMovements)
+}
+
+func toDTOs(movements []StockMovementModel) []StockMovementDTO {
+	dtoMovements := []StockMovementDTO{}
+	for i := 0; i < len(movements); i++ {
+		dtoMovements = append(dtoMovements, movements[i].ToDTO())
+	}
+	return dtoMovements
+}
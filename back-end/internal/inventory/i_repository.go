@@ -0,0 +1,18 @@
+package inventory
+
+import (
+	"context"
+	"sipub-test/internal/filter"
+	"sipub-test/internal/pagination"
+)
+
+type IInventoryRepository interface {
+	// Adds to the stock of a product, returns the recorded movement
+	Restock(ctx context.Context, productID string, quantity uint) (StockMovementModel, error)
+
+	// Returns the found stock movements
+	GetAll(ctx context.Context, where filter.Filter, page pagination.Page) ([]StockMovementModel, error)
+
+	// Returns how many rows match the filter, ignoring the page
+	Count(ctx context.Context, where filter.Filter) (uint, error)
+}
@@ -0,0 +1,56 @@
+package inventory
+
+import "sipub-test/pkg/timestamp"
+
+// Why the stock of a product changed. Restocks, sales and cancellations change
+// products.stock, reservations and releases change products.reserved
+type MovementReason string
+
+const (
+	MovementRestock      MovementReason = "restock"
+	MovementReservation  MovementReason = "reservation"  // Checkout, the delivery holds the stock
+	MovementRelease      MovementReason = "release"      // Delivery cancelled before being dispatched
+	MovementSale         MovementReason = "sale"         // Delivery dispatched, the stock left the warehouse
+	MovementCancellation MovementReason = "cancellation" // Delivery cancelled after being dispatched
+)
+
+// What the client sends to restock a product
+type RestockParams struct {
+	Quantity *uint
+}
+
+// A product and how much of it a delivery takes
+type Item struct {
+	ProductID string
+	Quantity  uint
+}
+
+type StockMovementDTO struct {
+	Id         string  `json:"Id"`
+	ProductID  string  `json:"ProductID"`
+	DeliveryID *string `json:"DeliveryID,omitempty"`
+	Quantity   int     `json:"Quantity"`
+	Reason     string  `json:"Reason"`
+	CreatedAt  string  `json:"CreatedAt"`
+}
+
+type StockMovementModel struct {
+	id         string // ID will be a uuid
+	productID  string
+	deliveryID *string // Only restocks have no delivery
+	quantity   int     // Negative when the stock or the reservation shrinks
+	reason     MovementReason
+	createdAt  timestamp.Time
+}
+
+func (m *StockMovementModel) ToDTO() StockMovementDTO {
+	dtoMovement := StockMovementDTO{
+		Id:         m.id,
+		ProductID:  m.productID,
+		DeliveryID: m.deliveryID,
+		Quantity:   m.quantity,
+		Reason:     string(m.reason),
+		CreatedAt:  m.createdAt.String(),
+	}
+	return dtoMovement
+}
@@ -0,0 +1,102 @@
+package inventory
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sipub-test/db"
+	"sipub-test/internal/delivery_product"
+	"sipub-test/internal/unit_of_work"
+	"sipub-test/pkg/apperror"
+	"sipub-test/pkg/clock"
+	"sipub-test/pkg/timestamp"
+)
+
+// Delivery product repository behind POST /delivery_product. Lines added by
+// hand take their stock like the checkout does, the other methods go straight
+// to the wrapped repository. It lives here because delivery_product can't
+// import the unit of work
+type MySQLDeliveryProductRepository struct {
+	delivery_product.IDeliveryProductRepository
+	uow   *unit_of_work.UnitOfWork
+	clock clock.Clock
+}
+
+func NewMySQLDeliveryProductRepository(repository delivery_product.IDeliveryProductRepository, uow *unit_of_work.UnitOfWork, clock clock.Clock) *MySQLDeliveryProductRepository {
+	return &MySQLDeliveryProductRepository{IDeliveryProductRepository: repository, uow: uow, clock: clock}
+}
+
+func (r *MySQLDeliveryProductRepository) Create(ctx context.Context, params delivery_product.DeliveryProductParams) (delivery_product.DeliveryProductModel, error) {
+	var model delivery_product.DeliveryProductModel
+	err := r.uow.Do(ctx, func(ctx context.Context, repos unit_of_work.Repositories) error {
+		now := timestamp.New(r.clock.Now())
+
+		// Same lock as a status change, the delivery can't be dispatched or
+		// cancelled halfway through
+		var found int
+		query := `SELECT 1 FROM deliveries WHERE id = ? AND isDeleted = FALSE FOR UPDATE`
+		err := repos.Tx.QueryRowContext(ctx, query, *params.DeliveryID).Scan(&found)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("delivery %w", apperror.ErrNotFound)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to lock delivery: %w", err)
+		}
+
+		// A dispatched or cancelled delivery would never give the stock back
+		state, err := stockOf(ctx, repos.Tx, *params.DeliveryID)
+		if err != nil {
+			return err
+		}
+		if state.sold || state.closed {
+			return fmt.Errorf("delivery stock was already settled: %w", apperror.ErrConflict)
+		}
+
+		available, err := lockAvailable(ctx, repos.Tx, *params.ProductID, now)
+		if err != nil {
+			return err
+		}
+		if int64(*params.ProductAmount) > available {
+			return fmt.Errorf("not enough stock for product %s: %w", *params.ProductID, apperror.ErrConflict)
+		}
+
+		model, err = repos.DeliveryProduct.Create(ctx, params)
+		if err != nil {
+			return err
+		}
+		items := []Item{{ProductID: *params.ProductID, Quantity: *params.ProductAmount}}
+		return Reserve(ctx, repos.Tx, *params.DeliveryID, items, now)
+	})
+	if err != nil {
+		return delivery_product.DeliveryProductModel{}, err
+	}
+	return model, nil
+}
+
+// Locks the product and returns the stock that is neither reserved by a
+// delivery nor held by a cart
+func lockAvailable(ctx context.Context, tx db.Executor, productID string, now timestamp.Time) (int64, error) {
+	// The columns are unsigned, MySQL refuses a negative unsigned result
+	query := `
+		SELECT
+			CAST(p.stock AS SIGNED) - CAST(p.reserved AS SIGNED) - COALESCE((
+				SELECT SUM(c.product_amount) FROM shopping_cart c
+				WHERE c.product_id = p.id AND c.reservedUntil > ?
+			), 0)
+		FROM
+			products p
+		WHERE
+			p.id = ? AND p.isDeleted = FALSE
+		FOR UPDATE`
+
+	var available int64
+	err := tx.QueryRowContext(ctx, query, now, productID).Scan(&available)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("product %w", apperror.ErrNotFound)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get product stock: %w", err)
+	}
+	return available, nil
+}
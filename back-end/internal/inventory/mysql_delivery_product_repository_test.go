@@ -0,0 +1,128 @@
+package inventory_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sipub-test/internal/delivery_product"
+	"sipub-test/internal/inventory"
+	"sipub-test/internal/unit_of_work"
+	"sipub-test/pkg/apperror"
+	testhelper "sipub-test/pkg/test_helper"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateDeliveryProductReservesStock(t *testing.T) {
+	params := delivery_product.DeliveryProductParams{
+		DeliveryID:    testhelper.StringPointer("delivery-123"),
+		ProductID:     testhelper.StringPointer("product-1"),
+		ProductAmount: testhelper.UintPointer(3),
+	}
+
+	expectDelivery := func(mock sqlmock.Sqlmock) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT 1 FROM deliveries WHERE id = ? AND isDeleted = FALSE FOR UPDATE`)).
+			WithArgs("delivery-123").
+			WillReturnRows(sqlmock.NewRows([]string{"1"}).AddRow(1))
+	}
+	expectAvailable := func(mock sqlmock.Sqlmock, available int64) {
+		mock.ExpectQuery(`FROM\s+products p\s+WHERE\s+p.id = \? AND p.isDeleted = FALSE\s+FOR UPDATE`).
+			WithArgs(createdAt, "product-1").
+			WillReturnRows(sqlmock.NewRows([]string{"available"}).AddRow(available))
+	}
+
+	t.Run("ShouldReserveTheLine", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := inventory.NewMySQLDeliveryProductRepository(delivery_product.NewMySQLDeliveryProductRepository(db), unit_of_work.NewUnitOfWork(db, now), now)
+
+		mock.ExpectBegin()
+		expectDelivery(mock)
+		expectMovements(mock, sqlmock.NewRows(movementColumns()))
+		expectAvailable(mock, 3)
+		mock.ExpectExec(`INSERT INTO delivery_product`).
+			WithArgs(sqlmock.AnyArg(), "delivery-123", "product-1", uint(3), nil).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE products SET reserved = reserved + ? WHERE id = ?`)).
+			WithArgs(uint(3), "product-1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`INSERT INTO stock_movements`).
+			WithArgs(sqlmock.AnyArg(), "product-1", "delivery-123", 3, "reservation", createdAt).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		line, err := repo.Create(context.Background(), params)
+
+		assert.NoError(t, err, "Shouldn't contain any errors")
+		assert.Equal(t, uint(3), line.ToDTO().ProductAmount)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("ShouldRejectMoreThanTheAvailableStock", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := inventory.NewMySQLDeliveryProductRepository(delivery_product.NewMySQLDeliveryProductRepository(db), unit_of_work.NewUnitOfWork(db, now), now)
+
+		mock.ExpectBegin()
+		expectDelivery(mock)
+		expectMovements(mock, sqlmock.NewRows(movementColumns()))
+		expectAvailable(mock, 2)
+		mock.ExpectRollback()
+
+		_, err = repo.Create(context.Background(), params)
+
+		assert.True(t, errors.Is(err, apperror.ErrConflict), "Should be reported as a conflict")
+		assert.NoError(t, mock.ExpectationsWereMet(), "Nothing should be inserted")
+	})
+	t.Run("ShouldRejectSettledDeliveries", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := inventory.NewMySQLDeliveryProductRepository(delivery_product.NewMySQLDeliveryProductRepository(db), unit_of_work.NewUnitOfWork(db, now), now)
+
+		mock.ExpectBegin()
+		expectDelivery(mock)
+		expectMovements(mock, sqlmock.NewRows(movementColumns()).
+			AddRow("product-1", 3, "reservation").
+			AddRow("product-1", -3, "sale"))
+		mock.ExpectRollback()
+
+		_, err = repo.Create(context.Background(), params)
+
+		assert.True(t, errors.Is(err, apperror.ErrConflict), "A dispatched delivery can't take more stock")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("ShouldReportUnknownProducts", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := inventory.NewMySQLDeliveryProductRepository(delivery_product.NewMySQLDeliveryProductRepository(db), unit_of_work.NewUnitOfWork(db, now), now)
+
+		mock.ExpectBegin()
+		expectDelivery(mock)
+		expectMovements(mock, sqlmock.NewRows(movementColumns()))
+		mock.ExpectQuery(`FROM\s+products p`).
+			WillReturnRows(sqlmock.NewRows([]string{"available"}))
+		mock.ExpectRollback()
+
+		_, err = repo.Create(context.Background(), params)
+
+		assert.True(t, errors.Is(err, apperror.ErrNotFound))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
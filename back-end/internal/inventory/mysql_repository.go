@@ -0,0 +1,256 @@
+package inventory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sipub-test/db"
+	"sipub-test/internal/filter"
+	"sipub-test/internal/pagination"
+	"sipub-test/internal/unit_of_work"
+	"sipub-test/pkg/apperror"
+	"sipub-test/pkg/clock"
+	"sipub-test/pkg/timestamp"
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+type MySQLInventoryRepository struct {
+	db    *sql.DB
+	uow   *unit_of_work.UnitOfWork
+	clock clock.Clock
+}
+
+func NewMySQLInventoryRepository(db *sql.DB, uow *unit_of_work.UnitOfWork, clock clock.Clock) *MySQLInventoryRepository {
+	return &MySQLInventoryRepository{db: db, uow: uow, clock: clock}
+}
+
+// Fields the list can be filtered by
+var filterFields = filter.Schema{
+	"ProductID":  {Column: "product_id", Type: filter.String, Operators: []filter.Operator{filter.Eq, filter.In}},
+	"DeliveryID": {Column: "delivery_id", Type: filter.String, Operators: []filter.Operator{filter.Eq, filter.In}},
+	"Reason":     {Column: "reason", Type: filter.String, Operators: []filter.Operator{filter.Eq, filter.In}},
+	"CreatedAt":  {Column: "createdAt", Type: filter.Time, Operators: filter.TimeOperators},
+}
+
+// Columns the list can be sorted by
+var sortFields = pagination.Fields{
+	Table:    "stock_movements",
+	IDColumn: "id",
+	Columns: map[string]string{
+		"Quantity":  "quantity",
+		"CreatedAt": "createdAt",
+	},
+	Default: []pagination.Sort{{Field: "CreatedAt", Column: "createdAt", Desc: true}},
+}
+
+// Reserves the products of a checked out delivery. It must run in the
+// transaction that read the stock with FOR UPDATE, it doesn't check it again
+func Reserve(ctx context.Context, tx db.Executor, deliveryID string, items []Item, createdAt timestamp.Time) error {
+	for _, item := range items {
+		query := `UPDATE products SET reserved = reserved + ? WHERE id = ?`
+		if _, err := tx.ExecContext(ctx, query, item.Quantity, item.ProductID); err != nil {
+			return fmt.Errorf("failed to reserve stock: %w", db.TranslateError(err))
+		}
+		movement := newMovement(item.ProductID, &deliveryID, int(item.Quantity), MovementReservation, createdAt)
+		if err := insertMovement(ctx, tx, movement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Takes the products reserved by a delivery out of the stock once it is
+// dispatched. Like Reserve, it runs in the caller's transaction, which must
+// hold the delivery's row lock. Deliveries that didn't go through the checkout
+// reserved nothing and get no movements
+func Sell(ctx context.Context, tx db.Executor, deliveryID string, createdAt timestamp.Time) ([]StockMovementModel, error) {
+	state, err := stockOf(ctx, tx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case state.closed:
+		return nil, fmt.Errorf("delivery stock was given back: %w", apperror.ErrConflict)
+	case state.sold:
+		return nil, fmt.Errorf("delivery stock was already sold: %w", apperror.ErrConflict)
+	}
+
+	var movements []StockMovementModel
+	for _, item := range state.items {
+		query := `UPDATE products SET stock = stock - ?, reserved = reserved - ? WHERE id = ?`
+		if _, err := tx.ExecContext(ctx, query, item.Quantity, item.Quantity, item.ProductID); err != nil {
+			return nil, fmt.Errorf("failed to take stock: %w", db.TranslateError(err))
+		}
+		movement := newMovement(item.ProductID, &deliveryID, -int(item.Quantity), MovementSale, createdAt)
+		if err := insertMovement(ctx, tx, movement); err != nil {
+			return nil, err
+		}
+		movements = append(movements, movement)
+	}
+	return movements, nil
+}
+
+// Gives the products of a cancelled delivery back, under the same conditions
+// as Sell
+func Cancel(ctx context.Context, tx db.Executor, deliveryID string, createdAt timestamp.Time) ([]StockMovementModel, error) {
+	state, err := stockOf(ctx, tx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if state.closed {
+		return nil, fmt.Errorf("delivery stock was already given back: %w", apperror.ErrConflict)
+	}
+
+	// A sold delivery already left the reservation, its products go back to
+	// the stock instead
+	query, reason, sign := `UPDATE products SET reserved = reserved - ? WHERE id = ?`, MovementRelease, -1
+	if state.sold {
+		query, reason, sign = `UPDATE products SET stock = stock + ? WHERE id = ?`, MovementCancellation, 1
+	}
+
+	var movements []StockMovementModel
+	for _, item := range state.items {
+		if _, err := tx.ExecContext(ctx, query, item.Quantity, item.ProductID); err != nil {
+			return nil, fmt.Errorf("failed to give stock back: %w", db.TranslateError(err))
+		}
+		movement := newMovement(item.ProductID, &deliveryID, sign*int(item.Quantity), reason, createdAt)
+		if err := insertMovement(ctx, tx, movement); err != nil {
+			return nil, err
+		}
+		movements = append(movements, movement)
+	}
+	return movements, nil
+}
+
+func (r *MySQLInventoryRepository) Restock(ctx context.Context, productID string, quantity uint) (StockMovementModel, error) {
+	movement := newMovement(productID, nil, int(quantity), MovementRestock, r.now())
+	err := r.uow.Do(ctx, func(ctx context.Context, repos unit_of_work.Repositories) error {
+		query := `UPDATE products SET stock = stock + ? WHERE id = ? AND isDeleted = FALSE`
+		res, err := repos.Tx.ExecContext(ctx, query, quantity, productID)
+		if err != nil {
+			return fmt.Errorf("failed to restock product: %w", db.TranslateError(err))
+		}
+		if count, _ := res.RowsAffected(); count == 0 {
+			return fmt.Errorf("product %w", apperror.ErrNotFound)
+		}
+		return insertMovement(ctx, repos.Tx, movement)
+	})
+	if err != nil {
+		return StockMovementModel{}, err
+	}
+	return movement, nil
+}
+
+func (r *MySQLInventoryRepository) GetAll(ctx context.Context, where filter.Filter, page pagination.Page) ([]StockMovementModel, error) {
+	conditions, args := where.SQL()
+	query := `SELECT id, product_id, delivery_id, quantity, reason, createdAt FROM stock_movements WHERE 1=1` + conditions
+	query, args = sortFields.Apply(page, query, args)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stock movements: %w", err)
+	}
+	defer rows.Close()
+
+	var movements []StockMovementModel
+	for rows.Next() {
+		var movement StockMovementModel
+		err := rows.Scan(&movement.id,
+			&movement.productID,
+			&movement.deliveryID,
+			&movement.quantity,
+			&movement.reason,
+			&movement.createdAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan stock movement: %w", err)
+		}
+		movements = append(movements, movement)
+	}
+
+	return movements, nil
+}
+
+func (r *MySQLInventoryRepository) Count(ctx context.Context, where filter.Filter) (uint, error) {
+	conditions, args := where.SQL()
+	query := `SELECT COUNT(*) FROM stock_movements WHERE 1=1` + conditions
+
+	var count uint
+	if err := r.db.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count stock movements: %w", err)
+	}
+	return count, nil
+}
+
+func (r *MySQLInventoryRepository) now() timestamp.Time {
+	return timestamp.New(r.clock.Now())
+}
+
+// Where the stock of a delivery stands, built from its movements
+type deliveryStock struct {
+	items  []Item // What the checkout reserved
+	sold   bool   // Dispatched
+	closed bool   // Released or cancelled, nothing left to do
+}
+
+// Reads the movements of a delivery
+func stockOf(ctx context.Context, tx db.Executor, deliveryID string) (deliveryStock, error) {
+	query := `SELECT product_id, quantity, reason FROM stock_movements WHERE delivery_id = ?`
+	rows, err := tx.QueryContext(ctx, query, deliveryID)
+	if err != nil {
+		return deliveryStock{}, fmt.Errorf("failed to get stock movements: %w", err)
+	}
+	defer rows.Close()
+
+	var state deliveryStock
+	reserved := map[string]uint{}
+	for rows.Next() {
+		var productID string
+		var quantity int
+		var reason MovementReason
+		if err := rows.Scan(&productID, &quantity, &reason); err != nil {
+			return deliveryStock{}, fmt.Errorf("failed to scan stock movement: %w", err)
+		}
+		switch reason {
+		case MovementReservation:
+			reserved[productID] += uint(quantity)
+		case MovementSale:
+			state.sold = true
+		case MovementRelease, MovementCancellation:
+			state.closed = true
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return deliveryStock{}, fmt.Errorf("failed to iterate stock movements: %w", err)
+	}
+
+	for productID, quantity := range reserved {
+		state.items = append(state.items, Item{ProductID: productID, Quantity: quantity})
+	}
+	// Same order on every call, so two deliveries never lock products in
+	// opposite orders
+	sort.Slice(state.items, func(i, j int) bool { return state.items[i].ProductID < state.items[j].ProductID })
+	return state, nil
+}
+
+func newMovement(productID string, deliveryID *string, quantity int, reason MovementReason, createdAt timestamp.Time) StockMovementModel {
+	return StockMovementModel{
+		id:         uuid.NewString(),
+		productID:  productID,
+		deliveryID: deliveryID,
+		quantity:   quantity,
+		reason:     reason,
+		createdAt:  createdAt,
+	}
+}
+
+func insertMovement(ctx context.Context, tx db.Executor, movement StockMovementModel) error {
+	query := `INSERT INTO stock_movements (id, product_id, delivery_id, quantity, reason, createdAt) VALUES (?, ?, ?, ?, ?, ?)`
+	_, err := tx.ExecContext(ctx, query, movement.id, movement.productID, movement.deliveryID, movement.quantity, movement.reason, movement.createdAt)
+	if err != nil {
+		return fmt.Errorf("failed to record stock movement: %w", db.TranslateError(err))
+	}
+	return nil
+}
@@ -0,0 +1,255 @@
+package inventory_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sipub-test/internal/filter"
+	"sipub-test/internal/inventory"
+	"sipub-test/internal/pagination"
+	"sipub-test/internal/unit_of_work"
+	"sipub-test/pkg/apperror"
+	"sipub-test/pkg/clock"
+	testhelper "sipub-test/pkg/test_helper"
+	"sipub-test/pkg/timestamp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var now = clock.Fixed(time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC))
+
+var createdAt = timestamp.New(now.Now())
+
+func expectMovements(mock sqlmock.Sqlmock, movements *sqlmock.Rows) {
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT product_id, quantity, reason FROM stock_movements WHERE delivery_id = ?`)).
+		WithArgs("delivery-123").
+		WillReturnRows(movements)
+}
+
+func movementColumns() []string {
+	return []string{"product_id", "quantity", "reason"}
+}
+
+func TestRestock(t *testing.T) {
+	t.Run("ValidRestock", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := inventory.NewMySQLInventoryRepository(db, unit_of_work.NewUnitOfWork(db, now), now)
+
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE products SET stock = stock + ? WHERE id = ? AND isDeleted = FALSE`)).
+			WithArgs(uint(5), "product-123").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`INSERT INTO stock_movements`).
+			WithArgs(sqlmock.AnyArg(), "product-123", nil, 5, "restock", createdAt).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		movement, err := repo.Restock(context.Background(), "product-123", 5)
+
+		assert.NoError(t, err, "Shouldn't contain any errors")
+		assert.Equal(t, 5, movement.ToDTO().Quantity)
+		assert.Equal(t, "restock", movement.ToDTO().Reason)
+		assert.Nil(t, movement.ToDTO().DeliveryID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("ShouldReportUnknownProducts", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := inventory.NewMySQLInventoryRepository(db, unit_of_work.NewUnitOfWork(db, now), now)
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE products SET stock`).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		_, err = repo.Restock(context.Background(), "missing", 5)
+
+		assert.True(t, errors.Is(err, apperror.ErrNotFound))
+		assert.NoError(t, mock.ExpectationsWereMet(), "No movement should be recorded")
+	})
+}
+
+func TestSell(t *testing.T) {
+	t.Run("ShouldTakeTheReservedStock", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		expectMovements(mock, sqlmock.NewRows(movementColumns()).
+			AddRow("product-2", 1, "reservation").
+			AddRow("product-1", 3, "reservation"))
+		// Sorted by product
+		for _, item := range []struct {
+			product string
+			amount  uint
+		}{{"product-1", 3}, {"product-2", 1}} {
+			mock.ExpectExec(regexp.QuoteMeta(`UPDATE products SET stock = stock - ?, reserved = reserved - ? WHERE id = ?`)).
+				WithArgs(item.amount, item.amount, item.product).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectExec(`INSERT INTO stock_movements`).
+				WithArgs(sqlmock.AnyArg(), item.product, "delivery-123", -int(item.amount), "sale", createdAt).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+		}
+
+		movements, err := inventory.Sell(context.Background(), db, "delivery-123", createdAt)
+
+		assert.NoError(t, err, "Shouldn't contain any errors")
+		assert.Len(t, movements, 2)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("ShouldRejectSoldDeliveries", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		expectMovements(mock, sqlmock.NewRows(movementColumns()).
+			AddRow("product-1", 3, "reservation").
+			AddRow("product-1", -3, "sale"))
+
+		_, err = inventory.Sell(context.Background(), db, "delivery-123", createdAt)
+
+		assert.True(t, errors.Is(err, apperror.ErrConflict), "The stock can't be taken twice")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("ShouldIgnoreDeliveriesWithoutReservation", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		expectMovements(mock, sqlmock.NewRows(movementColumns()))
+
+		movements, err := inventory.Sell(context.Background(), db, "delivery-123", createdAt)
+
+		assert.NoError(t, err, "Deliveries created outside of the checkout reserve nothing")
+		assert.Empty(t, movements)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestCancel(t *testing.T) {
+	t.Run("ShouldReleaseTheReservation", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		expectMovements(mock, sqlmock.NewRows(movementColumns()).
+			AddRow("product-1", 3, "reservation"))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE products SET reserved = reserved - ? WHERE id = ?`)).
+			WithArgs(uint(3), "product-1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`INSERT INTO stock_movements`).
+			WithArgs(sqlmock.AnyArg(), "product-1", "delivery-123", -3, "release", createdAt).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		movements, err := inventory.Cancel(context.Background(), db, "delivery-123", createdAt)
+
+		assert.NoError(t, err, "Shouldn't contain any errors")
+		assert.Equal(t, "release", movements[0].ToDTO().Reason)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("ShouldRestockSoldDeliveries", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		expectMovements(mock, sqlmock.NewRows(movementColumns()).
+			AddRow("product-1", 3, "reservation").
+			AddRow("product-1", -3, "sale"))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE products SET stock = stock + ? WHERE id = ?`)).
+			WithArgs(uint(3), "product-1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`INSERT INTO stock_movements`).
+			WithArgs(sqlmock.AnyArg(), "product-1", "delivery-123", 3, "cancellation", createdAt).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		movements, err := inventory.Cancel(context.Background(), db, "delivery-123", createdAt)
+
+		assert.NoError(t, err, "Shouldn't contain any errors")
+		assert.Equal(t, "cancellation", movements[0].ToDTO().Reason)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("ShouldRejectCancelledDeliveries", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		expectMovements(mock, sqlmock.NewRows(movementColumns()).
+			AddRow("product-1", 3, "reservation").
+			AddRow("product-1", -3, "release"))
+
+		_, err = inventory.Cancel(context.Background(), db, "delivery-123", createdAt)
+
+		assert.True(t, errors.Is(err, apperror.ErrConflict))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestGetAllStockMovements(t *testing.T) {
+	t.Run("ValidGetAll", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := inventory.NewMySQLInventoryRepository(db, unit_of_work.NewUnitOfWork(db, now), now)
+
+		rows := sqlmock.NewRows([]string{"id", "product_id", "delivery_id", "quantity", "reason", "createdAt"}).
+			AddRow("movement-1", "product-123", nil, 5, "restock", "2025-01-15 12:00:00").
+			AddRow("movement-2", "product-123", "delivery-123", 2, "reservation", "2025-01-15 13:00:00")
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, product_id, delivery_id, quantity, reason, createdAt FROM stock_movements WHERE 1=1 AND product_id = ?`)).
+			WithArgs("product-123", 51, 0).
+			WillReturnRows(rows)
+
+		where := testhelper.FilterEqual("ProductID", "product_id", "product-123")
+		page := pagination.Page{Limit: 50, Sort: []pagination.Sort{{Field: "CreatedAt", Column: "createdAt", Desc: true}}}
+		movements, err := repo.GetAll(context.Background(), where, page)
+
+		assert.NoError(t, err, "Shouldn't contain any errors")
+		assert.Len(t, movements, 2)
+		assert.Nil(t, movements[0].ToDTO().DeliveryID)
+		assert.Equal(t, "delivery-123", *movements[1].ToDTO().DeliveryID)
+	})
+	t.Run("ValidCount", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := inventory.NewMySQLInventoryRepository(db, unit_of_work.NewUnitOfWork(db, now), now)
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT COUNT(*) FROM stock_movements WHERE 1=1`)).
+			WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(7))
+
+		count, err := repo.Count(context.Background(), filter.Filter{})
+
+		assert.NoError(t, err, "Shouldn't contain any errors")
+		assert.Equal(t, uint(7), count)
+	})
+}
@@ -0,0 +1,34 @@
+package inventory
+
+import (
+	"net/http"
+)
+
+// The inventory has no CRUD of its own. The deliveries move the stock through
+// their status transitions
+type IInventoryController interface {
+	Restock(http.ResponseWriter, *http.Request)
+
+	GetAll(http.ResponseWriter, *http.Request)
+}
+
+type InventoryRouter struct {
+	controller IInventoryController
+}
+
+func NewInventoryRouter(controller IInventoryController) InventoryRouter {
+	return InventoryRouter{controller: controller}
+}
+
+func (r InventoryRouter) Init(mux *http.ServeMux) {
+	r.restock(mux)
+	r.getAll(mux)
+}
+
+func (r InventoryRouter) restock(mux *http.ServeMux) {
+	mux.HandleFunc("POST /products/{id}/restock", r.controller.Restock)
+}
+
+func (r InventoryRouter) getAll(mux *http.ServeMux) {
+	mux.HandleFunc("GET /stock_movements", r.controller.GetAll)
+}
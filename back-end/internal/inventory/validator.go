@@ -0,0 +1,14 @@
+package inventory
+
+import (
+	"sipub-test/pkg/apperror"
+)
+
+type RestockValidator struct{}
+
+func (v *RestockValidator) Validate(restock RestockParams) error {
+	if restock.Quantity == nil || *restock.Quantity == 0 {
+		return apperror.NewValidationError("Quantity", "must be greater than zero")
+	}
+	return nil
+}
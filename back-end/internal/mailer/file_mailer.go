@@ -0,0 +1,51 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"sipub-test/pkg/clock"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Saves each message as an .eml file in directory, which any mail client
+// opens. Meant for local development and manual testing of the flows that
+// send emails
+type FileMailer struct {
+	from      string
+	directory string
+	clock     clock.Clock
+}
+
+func NewFileMailer(from string, directory string, clock clock.Clock) *FileMailer {
+	return &FileMailer{from: from, directory: directory, clock: clock}
+}
+
+func (m *FileMailer) Send(ctx context.Context, message Message) error {
+	if err := os.MkdirAll(m.directory, 0o755); err != nil {
+		return fmt.Errorf("failed to create mail directory: %w", err)
+	}
+
+	now := m.clock.Now().UTC()
+	// Sorted by the time they were sent, the uuid keeps two messages of the
+	// same instant apart
+	name := fmt.Sprintf("%s_%s.eml", now.Format("20060102T150405.000000Z"), uuid.NewString())
+
+	var content strings.Builder
+	fmt.Fprintf(&content, "From: %s\r\n", (&mail.Address{Address: m.from}).String())
+	fmt.Fprintf(&content, "To: %s\r\n", (&mail.Address{Address: message.To}).String())
+	fmt.Fprintf(&content, "Subject: %s\r\n", message.Subject)
+	fmt.Fprintf(&content, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	content.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	content.WriteString(strings.ReplaceAll(message.Body, "\n", "\r\n"))
+
+	if err := os.WriteFile(filepath.Join(m.directory, name), []byte(content.String()), 0o600); err != nil {
+		return fmt.Errorf("failed to write email: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package mailer_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sipub-test/internal/mailer"
+	"sipub-test/pkg/clock"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileMailerSend(t *testing.T) {
+	t.Run("ShouldWriteAnEmlFile", func(t *testing.T) {
+		directory := filepath.Join(t.TempDir(), "mail")
+		sender := mailer.NewFileMailer("no-reply@sipub.local", directory, clock.Fixed(time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)))
+
+		err := sender.Send(context.Background(), mailer.Message{To: "ana@example.com", Subject: "Verify your email", Body: "Line 1\nLine 2"})
+
+		assert.NoError(t, err, "The directory should be created")
+		files, _ := filepath.Glob(filepath.Join(directory, "*.eml"))
+		if assert.Len(t, files, 1) {
+			content, _ := os.ReadFile(files[0])
+			assert.Contains(t, string(content), "From: <no-reply@sipub.local>\r\n")
+			assert.Contains(t, string(content), "To: <ana@example.com>\r\n")
+			assert.Contains(t, string(content), "Subject: Verify your email\r\n")
+			assert.Contains(t, string(content), "Date: Wed, 15 Jan 2025 12:00:00 +0000\r\n")
+			assert.Contains(t, string(content), "\r\n\r\nLine 1\r\nLine 2")
+		}
+	})
+	t.Run("ShouldKeepEveryMessage", func(t *testing.T) {
+		directory := t.TempDir()
+		sender := mailer.NewFileMailer("no-reply@sipub.local", directory, clock.Fixed(time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)))
+
+		sender.Send(context.Background(), mailer.Message{To: "ana@example.com", Subject: "First"})
+		sender.Send(context.Background(), mailer.Message{To: "ana@example.com", Subject: "Second"})
+
+		files, _ := filepath.Glob(filepath.Join(directory, "*.eml"))
+		assert.Len(t, files, 2, "Messages sent at the same time shouldn't overwrite each other")
+	})
+}
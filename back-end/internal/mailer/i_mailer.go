@@ -0,0 +1,17 @@
+package mailer
+
+import "context"
+
+// A plain text email. The sender address comes from the mailer, it is the
+// same for every message
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Delivers the emails of the application. Only local implementations exist
+// for now, a SMTP or provider backed one plugs in here
+type IMailer interface {
+	Send(ctx context.Context, message Message) error
+}
@@ -0,0 +1,248 @@
+package pagination
+
+// Shared limit/offset and keyset pagination for the GetAll endpoints. The
+// controllers parse a Page out of the query string, the repositories turn it
+// into ORDER BY/LIMIT clauses and the controllers write the Link and
+// X-Total-Count headers, so the body stays a plain JSON array
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sipub-test/pkg/apperror"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultLimit = 50
+	MaxLimit     = 200
+
+	TotalCountHeader = "X-Total-Count"
+	LinkHeader       = "Link"
+)
+
+// Query string keys used by the pagination, the controllers should let them
+// through when validating the filters
+var reservedKeys = map[string]bool{"limit": true, "offset": true, "cursor": true, "sort": true}
+
+func IsReserved(key string) bool {
+	return reservedKeys[strings.ToLower(key)]
+}
+
+type Sort struct {
+	Field  string // Name used in the query string
+	Column string
+	Desc   bool
+}
+
+// Describes how a resource can be sorted and where it is stored
+type Fields struct {
+	Table    string            // Table used to find the cursor row, may carry an alias
+	IDColumn string            // Unique column used as the tie-breaker
+	Columns  map[string]string // Query string name : column
+	Default  []Sort            // Used when the request has no sort
+}
+
+type Page struct {
+	Limit  uint // 0 means everything
+	Offset uint
+	After  string // Id of the last row of the previous page
+	Sort   []Sort
+
+	usesCursor bool
+}
+
+// Parses limit, offset, cursor and sort. Offset and cursor can't be used
+// together, and the cursor must have been created with the same sort
+func Parse(query url.Values, fields Fields) (Page, error) {
+	page := Page{Limit: DefaultLimit, Sort: fields.Default}
+
+	if limit := query.Get("limit"); limit != "" {
+		value, err := strconv.ParseUint(limit, 10, 32)
+		if err != nil || value == 0 || value > MaxLimit {
+			return Page{}, apperror.NewValidationError("limit", fmt.Sprintf("must be between 1 and %d", MaxLimit))
+		}
+		page.Limit = uint(value)
+	}
+
+	if sort := query.Get("sort"); sort != "" {
+		page.Sort = nil
+		for _, field := range strings.Split(sort, ",") {
+			desc := strings.HasPrefix(field, "-")
+			name := strings.TrimPrefix(field, "-")
+			column, found := lookupColumn(fields, name)
+			if !found {
+				return Page{}, apperror.NewValidationError("sort", fmt.Sprintf("can't sort by %q", name))
+			}
+			page.Sort = append(page.Sort, Sort{Field: name, Column: column, Desc: desc})
+		}
+	}
+
+	offset, cursor := query.Get("offset"), query.Get("cursor")
+	if offset != "" && cursor != "" {
+		return Page{}, apperror.NewValidationError("cursor", "can't be used with offset")
+	}
+	if offset != "" {
+		value, err := strconv.ParseUint(offset, 10, 32)
+		if err != nil {
+			return Page{}, apperror.NewValidationError("offset", "must be a positive number")
+		}
+		page.Offset = uint(value)
+	} else {
+		page.usesCursor = true
+	}
+	if cursor != "" {
+		decoded, err := decodeCursor(cursor)
+		if err != nil || decoded.ID == "" {
+			return Page{}, apperror.NewValidationError("cursor", "is invalid")
+		}
+		if decoded.Sort != page.sortKey() {
+			return Page{}, apperror.NewValidationError("cursor", "was created with a different sort")
+		}
+		page.After = decoded.ID
+	}
+
+	return page, nil
+}
+
+func lookupColumn(fields Fields, name string) (string, bool) {
+	for field, column := range fields.Columns {
+		if strings.EqualFold(field, name) {
+			return column, true
+		}
+	}
+	return "", false
+}
+
+// Appends the keyset condition, ORDER BY and LIMIT/OFFSET to a query that
+// already has a WHERE clause. One extra row is requested so Trim can tell if
+// there is a next page
+func (f Fields) Apply(page Page, query string, args []interface{}) (string, []interface{}) {
+	sorts := append(append([]Sort{}, page.Sort...), Sort{Column: f.IDColumn})
+
+	if page.After != "" {
+		// The cursor only stores the id, the values to compare against are
+		// read from that row. If it was deleted in the meantime the page is
+		// empty
+		anchor := func(column string) string {
+			if column == f.IDColumn {
+				return "?"
+			}
+			return fmt.Sprintf("(SELECT %s FROM %s WHERE %s = ?)", column, f.Table, f.IDColumn)
+		}
+		conditions := []string{}
+		for i, sort := range sorts {
+			parts := []string{}
+			for _, previous := range sorts[:i] {
+				parts = append(parts, previous.Column+" = "+anchor(previous.Column))
+				args = append(args, page.After)
+			}
+			operator := ">"
+			if sort.Desc {
+				operator = "<"
+			}
+			parts = append(parts, sort.Column+" "+operator+" "+anchor(sort.Column))
+			args = append(args, page.After)
+			conditions = append(conditions, "("+strings.Join(parts, " AND ")+")")
+		}
+		query += " AND (" + strings.Join(conditions, " OR ") + ")"
+	}
+
+	order := []string{}
+	for _, sort := range sorts {
+		if sort.Desc {
+			order = append(order, sort.Column+" DESC")
+		} else {
+			order = append(order, sort.Column+" ASC")
+		}
+	}
+	query += " ORDER BY " + strings.Join(order, ", ")
+
+	if page.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, page.Limit+1, page.Offset)
+	}
+	return query, args
+}
+
+// Drops the extra row requested by Apply, and reports if there was one
+func Trim[T any](page Page, items []T) ([]T, bool) {
+	if page.Limit > 0 && uint(len(items)) > page.Limit {
+		return items[:page.Limit], true
+	}
+	return items, false
+}
+
+// Writes X-Total-Count and the Link header. lastID is the id of the last
+// returned item, used for the next cursor
+func WriteHeaders(w http.ResponseWriter, r *http.Request, page Page, total uint, hasMore bool, lastID string) {
+	w.Header().Set(TotalCountHeader, strconv.FormatUint(uint64(total), 10))
+
+	links := []string{}
+	link := func(rel string, set map[string]string) {
+		query := r.URL.Query()
+		for key, value := range set {
+			query.Set(key, value)
+		}
+		target := url.URL{Path: r.URL.Path, RawQuery: query.Encode()}
+		links = append(links, fmt.Sprintf("<%s>; rel=%q", target.String(), rel))
+	}
+
+	limit := strconv.FormatUint(uint64(page.Limit), 10)
+	if page.usesCursor {
+		if hasMore {
+			link("next", map[string]string{"limit": limit, "cursor": encodeCursor(cursor{ID: lastID, Sort: page.sortKey()})})
+		}
+	} else {
+		if hasMore {
+			link("next", map[string]string{"limit": limit, "offset": strconv.FormatUint(uint64(page.Offset+page.Limit), 10)})
+		}
+		if page.Offset > 0 {
+			previous := uint(0)
+			if page.Offset > page.Limit {
+				previous = page.Offset - page.Limit
+			}
+			link("prev", map[string]string{"limit": limit, "offset": strconv.FormatUint(uint64(previous), 10)})
+		}
+	}
+
+	if len(links) > 0 {
+		w.Header().Set(LinkHeader, strings.Join(links, ", "))
+	}
+}
+
+// The cursor is opaque to the clients, it only needs to survive a round trip
+type cursor struct {
+	ID   string `json:"id"`
+	Sort string `json:"sort"`
+}
+
+func (p Page) sortKey() string {
+	keys := []string{}
+	for _, sort := range p.Sort {
+		if sort.Desc {
+			keys = append(keys, "-"+sort.Column)
+		} else {
+			keys = append(keys, sort.Column)
+		}
+	}
+	return strings.Join(keys, ",")
+}
+
+func encodeCursor(c cursor) string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(value string) (cursor, error) {
+	var c cursor
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(raw, &c)
+	return c, err
+}
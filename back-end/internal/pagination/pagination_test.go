@@ -0,0 +1,138 @@
+package pagination_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sipub-test/internal/pagination"
+	"sipub-test/pkg/apperror"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var fields = pagination.Fields{
+	Table:    "products",
+	IDColumn: "id",
+	Columns:  map[string]string{"Name": "name", "Price": "price"},
+	Default:  []pagination.Sort{{Field: "Name", Column: "name"}},
+}
+
+func parse(t *testing.T, rawQuery string) (pagination.Page, error) {
+	t.Helper()
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		t.Fatalf("failed to parse query: %v", err)
+	}
+	return pagination.Parse(query, fields)
+}
+
+func TestParse(t *testing.T) {
+	t.Run("ShouldUseDefaults", func(t *testing.T) {
+		page, err := parse(t, "")
+
+		assert.NoError(t, err)
+		assert.EqualValues(t, pagination.DefaultLimit, page.Limit)
+		assert.Equal(t, fields.Default, page.Sort)
+	})
+	t.Run("ShouldParseSortCaseInsensitive", func(t *testing.T) {
+		page, err := parse(t, "sort=-price,NAME&limit=10&offset=20")
+
+		assert.NoError(t, err)
+		assert.EqualValues(t, 10, page.Limit)
+		assert.EqualValues(t, 20, page.Offset)
+		assert.Equal(t, []pagination.Sort{{Field: "price", Column: "price", Desc: true}, {Field: "NAME", Column: "name"}}, page.Sort)
+	})
+	t.Run("ShouldRejectInvalidValues", func(t *testing.T) {
+		for _, query := range []string{"limit=0", "limit=1000", "limit=abc", "offset=-1", "sort=password", "offset=1&cursor=abc", "cursor=not-base64!"} {
+			_, err := parse(t, query)
+			assert.True(t, errors.Is(err, apperror.ErrValidation), "%q should be rejected", query)
+		}
+	})
+}
+
+func TestCursor(t *testing.T) {
+	t.Run("ShouldRoundTripThroughTheLinkHeader", func(t *testing.T) {
+		page, _ := parse(t, "limit=2&sort=-price")
+		w := httptest.NewRecorder()
+
+		pagination.WriteHeaders(w, httptest.NewRequest(http.MethodGet, "/products?limit=2&sort=-price", nil), page, 5, true, "abc")
+
+		assert.Equal(t, "5", w.Header().Get(pagination.TotalCountHeader))
+		next := regexp.MustCompile(`<([^>]+)>; rel="next"`).FindStringSubmatch(w.Header().Get(pagination.LinkHeader))
+		if assert.Len(t, next, 2) {
+			link, _ := url.Parse(next[1])
+			nextPage, err := pagination.Parse(link.Query(), fields)
+			assert.NoError(t, err)
+			assert.Equal(t, "abc", nextPage.After)
+		}
+	})
+	t.Run("ShouldRejectCursorFromAnotherSort", func(t *testing.T) {
+		page, _ := parse(t, "sort=price")
+		w := httptest.NewRecorder()
+		pagination.WriteHeaders(w, httptest.NewRequest(http.MethodGet, "/products?sort=price", nil), page, 5, true, "abc")
+		link, _ := url.Parse(regexp.MustCompile(`<([^>]+)>`).FindStringSubmatch(w.Header().Get(pagination.LinkHeader))[1])
+
+		query := link.Query()
+		query.Set("sort", "name")
+		_, err := pagination.Parse(query, fields)
+
+		assert.True(t, errors.Is(err, apperror.ErrValidation))
+	})
+}
+
+func TestWriteHeaders(t *testing.T) {
+	t.Run("ShouldLinkOffsetPages", func(t *testing.T) {
+		page, _ := parse(t, "limit=10&offset=15")
+		w := httptest.NewRecorder()
+
+		pagination.WriteHeaders(w, httptest.NewRequest(http.MethodGet, "/products?limit=10&offset=15&Name=a", nil), page, 40, true, "")
+
+		link := w.Header().Get(pagination.LinkHeader)
+		assert.Contains(t, link, `</products?Name=a&limit=10&offset=25>; rel="next"`)
+		assert.Contains(t, link, `</products?Name=a&limit=10&offset=5>; rel="prev"`)
+	})
+	t.Run("ShouldNotLinkPastTheLastPage", func(t *testing.T) {
+		page, _ := parse(t, "")
+		w := httptest.NewRecorder()
+
+		pagination.WriteHeaders(w, httptest.NewRequest(http.MethodGet, "/products", nil), page, 3, false, "")
+
+		assert.Equal(t, "3", w.Header().Get(pagination.TotalCountHeader))
+		assert.Empty(t, w.Header().Get(pagination.LinkHeader))
+	})
+}
+
+func TestApply(t *testing.T) {
+	t.Run("ShouldOrderAndLimit", func(t *testing.T) {
+		page := pagination.Page{Limit: 10, Offset: 20, Sort: []pagination.Sort{{Column: "price", Desc: true}}}
+
+		query, args := fields.Apply(page, "SELECT id FROM products WHERE 1=1", []interface{}{true})
+
+		assert.Equal(t, "SELECT id FROM products WHERE 1=1 ORDER BY price DESC, id ASC LIMIT ? OFFSET ?", query)
+		assert.Equal(t, []interface{}{true, uint(11), uint(20)}, args, "One extra row should be requested")
+	})
+	t.Run("ShouldSeekAfterTheCursor", func(t *testing.T) {
+		page := pagination.Page{After: "abc", Sort: []pagination.Sort{{Column: "price", Desc: true}}}
+
+		query, args := fields.Apply(page, "SELECT id FROM products WHERE 1=1", nil)
+
+		assert.Equal(t, "SELECT id FROM products WHERE 1=1 AND ("+
+			"(price < (SELECT price FROM products WHERE id = ?)) OR "+
+			"(price = (SELECT price FROM products WHERE id = ?) AND id > ?)"+
+			") ORDER BY price DESC, id ASC", query)
+		assert.Equal(t, []interface{}{"abc", "abc", "abc"}, args)
+	})
+}
+
+func TestTrim(t *testing.T) {
+	items, hasMore := pagination.Trim(pagination.Page{Limit: 2}, []int{1, 2, 3})
+	assert.Equal(t, []int{1, 2}, items)
+	assert.True(t, hasMore)
+
+	items, hasMore = pagination.Trim(pagination.Page{}, []int{1, 2, 3})
+	assert.Len(t, items, 3, "A page without limit should keep everything")
+	assert.False(t, hasMore)
+}
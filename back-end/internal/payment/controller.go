@@ -1,187 +1,239 @@
 package payment
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
-	"strconv"
-	"strings"
+	"sipub-test/internal"
+	"sipub-test/internal/filter"
+	"sipub-test/internal/pagination"
+	"sipub-test/internal/payment_gateway"
+	"sipub-test/internal/response"
+	"sipub-test/pkg/apperror"
 )
 
 type PaymentController struct {
-	// TODO
-	repository IPaymentRepository
+	repository       IPaymentRepository
+	validator        internal.IValidator[PaymentParams]
+	gateway          payment_gateway.IPaymentGateway
+	captureValidator internal.IValidator[CaptureParams]
+	logger           *slog.Logger
 }
 
-// Used for testing
-func (c *PaymentController) SetRepository(repo IPaymentRepository) {
-	c.repository = repo
-}
-
-func NewPaymentController() *PaymentController {
-	return &PaymentController{repository: NewMySQLPaymentRepository()}
+func NewPaymentController(repository IPaymentRepository, validator internal.IValidator[PaymentParams], gateway payment_gateway.IPaymentGateway, captureValidator internal.IValidator[CaptureParams], logger *slog.Logger) *PaymentController {
+	return &PaymentController{repository: repository, validator: validator, gateway: gateway, captureValidator: captureValidator, logger: logger}
 }
 
 func (c *PaymentController) Create(w http.ResponseWriter, r *http.Request) {
 	var paymentParam PaymentParams
 	err := json.NewDecoder(r.Body).Decode(&paymentParam)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		response.BadRequest(w, r, "invalid request body: "+err.Error())
 		return
 	}
 
-	createdPayment, err := c.repository.Create(paymentParam)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := c.validator.Validate(paymentParam); err != nil {
+		response.Error(w, r, c.logger, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-
-	if err := json.NewEncoder(w).Encode(createdPayment.ToDTO()); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	createdPayment, err := c.repository.Create(r.Context(), paymentParam)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
 		return
 	}
+
+	response.JSON(w, http.StatusCreated, createdPayment.ToDTO())
 }
 
 func (c *PaymentController) GetAll(w http.ResponseWriter, r *http.Request) {
-	var paymentParams PaymentParams
 	queryParams := r.URL.Query()
 
-	// First it checks to see if the values in the querystring are valid
-	for key := range queryParams {
-		if strings.ToLower(key) != "userID" {
-			http.Error(w, fmt.Sprintf("Invalid query parameter: %s", key), http.StatusBadRequest)
-			return
-		}
+	// The accepted fields and operators are declared in filterFields
+	where, err := filter.Parse(queryParams, filterFields)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
 	}
 
-	// If the values are valid it will check what each value is
-	if isDeleted := queryParams.Get("IsDeleted"); isDeleted != "" {
-		value := isDeleted == "true"
-		*paymentParams.IsDeleted = value
+	page, err := pagination.Parse(queryParams, sortFields)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
 	}
 
-	if createdAt := queryParams.Get("CreatedAt"); createdAt != "" {
-		*paymentParams.CreatedAt = createdAt
+	foundPaymentes, err := c.repository.GetAll(r.Context(), where, page)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
 	}
+	foundPaymentes, hasMore := pagination.Trim(page, foundPaymentes)
 
-	if valQuery := queryParams.Get("Value"); valQuery != "" {
-		value, err := strconv.ParseFloat(valQuery, 32)
-		if err == nil {
-			val := float32(value)
-			*paymentParams.Value = val
-		}
+	total, err := c.repository.Count(r.Context(), where)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
 	}
 
-	if deliveryID := queryParams.Get("DeliveryID"); deliveryID != "" {
-		*paymentParams.DeliveryID = deliveryID
+	dtoFoundPaymentes := []PaymentDTO{}
+	for i := 0; i < len(foundPaymentes); i++ {
+		dtoFoundPaymentes = append(dtoFoundPaymentes, foundPaymentes[i].ToDTO())
 	}
+	var lastID string
+	if hasMore {
+		lastID = dtoFoundPaymentes[len(dtoFoundPaymentes)-1].Id
+	}
+	pagination.WriteHeaders(w, r, page, total, hasMore, lastID)
+	// Returns the DTO payments
+	response.JSON(w, http.StatusOK, dtoFoundPaymentes)
+}
 
-	if userID := queryParams.Get("UserID"); userID != "" {
-		*paymentParams.UserID = userID
+func (c *PaymentController) GetOne(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	payment, err := c.repository.GetOne(r.Context(), id)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
 	}
+	response.JSON(w, http.StatusOK, payment.ToDTO())
+}
 
-	// It now passes the payment param as a "filter" and gets the found payment
-	foundPaymentes, err := c.repository.GetAll(paymentParams)
+func (c *PaymentController) DeleteAll(w http.ResponseWriter, r *http.Request) {
+	where, err := filter.Parse(r.URL.Query(), filterFields)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		response.Error(w, r, c.logger, err)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
 
-	dtoFoundPaymentes := []PaymentDTO{}
-	for i := 0; i < len(foundPaymentes); i++ {
-		dtoFoundPaymentes = append(dtoFoundPaymentes, foundPaymentes[i].ToDTO())
+	count, err := c.repository.DeleteAll(r.Context(), where)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
 	}
-	// Returns the DTO payments
-	if err := json.NewEncoder(w).Encode(dtoFoundPaymentes); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+	response.JSON(w, http.StatusOK, count)
+}
+
+func (c *PaymentController) DeleteOne(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	count, err := c.repository.DeleteOne(r.Context(), id)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
 		return
 	}
+
+	response.JSON(w, http.StatusOK, count)
 }
 
-func (c *PaymentController) GetOne(w http.ResponseWriter, r *http.Request) {
+func (c *PaymentController) Update(w http.ResponseWriter, r *http.Request) {
+	// There is no update method, but this needs to be included since the controller is implementing an interface (IController)
+}
+
+func (c *PaymentController) Restore(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
-	payment, err := c.repository.GetOne(id)
+	payment, err := c.repository.Restore(r.Context(), id)
 	if err != nil {
-		fmt.Println(err)
-		http.Error(w, err.Error(), http.StatusNotFound) // The Id was not found but the request did go though
+		response.Error(w, r, c.logger, err)
 		return
 	}
-	if err := json.NewEncoder(w).Encode(payment.ToDTO()); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+	response.JSON(w, http.StatusOK, payment.ToDTO())
+}
+
+func (c *PaymentController) Purge(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	count, err := c.repository.Purge(r.Context(), id)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
 		return
 	}
+
+	response.JSON(w, http.StatusOK, count)
 }
 
-func (c *PaymentController) DeleteAll(w http.ResponseWriter, r *http.Request) {
-	var paymentParams PaymentParams
-	queryParams := r.URL.Query()
+func (c *PaymentController) Balance(w http.ResponseWriter, r *http.Request) {
+	deliveryID := r.PathValue("id")
+	balance, err := c.repository.Balance(r.Context(), deliveryID)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, balance.ToDTO())
+}
 
-	for key := range queryParams {
-		if strings.ToLower(key) != "userID" {
-			http.Error(w, fmt.Sprintf("Invalid query parameter: %s", key), http.StatusBadRequest)
-			return
-		}
+// Authorizes the payment first if needed, then charges it. A failure after the
+// authorization leaves the payment authorized, the capture can be retried
+// without the card
+func (c *PaymentController) Capture(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	var captureParams CaptureParams
+	// The body can be left out when the payment is already authorized
+	err := json.NewDecoder(r.Body).Decode(&captureParams)
+	if err != nil && !errors.Is(err, io.EOF) {
+		response.BadRequest(w, r, "invalid request body: "+err.Error())
+		return
 	}
 
-	// If the values are valid it will check what each value is
-	if isDeleted := queryParams.Get("IsDeleted"); isDeleted != "" {
-		value := isDeleted == "true"
-		*paymentParams.IsDeleted = value
+	if err := c.captureValidator.Validate(captureParams); err != nil {
+		response.Error(w, r, c.logger, err)
+		return
 	}
 
-	if createdAt := queryParams.Get("CreatedAt"); createdAt != "" {
-		*paymentParams.CreatedAt = createdAt
+	payment, err := c.repository.GetOne(r.Context(), id)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
 	}
 
-	if valQuery := queryParams.Get("Value"); valQuery != "" {
-		value, err := strconv.ParseFloat(valQuery, 32)
-		if err == nil {
-			val := float32(value)
-			*paymentParams.Value = val
+	if payment.status == StatusPending || payment.status == StatusFailed {
+		payment, err = c.authorize(r.Context(), payment, captureParams)
+		if err != nil {
+			response.Error(w, r, c.logger, err)
+			return
 		}
 	}
-
-	if deliveryID := queryParams.Get("DeliveryID"); deliveryID != "" {
-		*paymentParams.DeliveryID = deliveryID
+	if payment.status != StatusAuthorized {
+		response.Error(w, r, c.logger, fmt.Errorf("payment is %s: %w", payment.status, apperror.ErrConflict))
+		return
 	}
 
-	if userID := queryParams.Get("UserID"); userID != "" {
-		*paymentParams.UserID = userID
+	if err := c.gateway.Capture(r.Context(), *payment.gatewayReference, payment.value); err != nil {
+		response.Error(w, r, c.logger, fmt.Errorf("failed to capture payment: %w", err))
+		return
 	}
-
-	// Make the request on the repo
-	count, err := c.repository.DeleteAll(paymentParams)
+	payment, err = c.repository.UpdateStatus(r.Context(), id, StatusAuthorized, PaymentStatusParams{Status: StatusCaptured})
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		response.Error(w, r, c.logger, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(count); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
+	response.JSON(w, http.StatusOK, payment.ToDTO())
 }
 
-func (c *PaymentController) DeleteOne(w http.ResponseWriter, r *http.Request) {
-	id := r.PathValue("id")
-	count, err := c.repository.DeleteOne(id)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound) // Did go through, none found
+// A declined card is recorded as failed before the error is returned, so the
+// client can see why and try another one
+func (c *PaymentController) authorize(ctx context.Context, payment PaymentModel, params CaptureParams) (PaymentModel, error) {
+	if params.CardNumber == nil {
+		return PaymentModel{}, apperror.NewValidationError("CardNumber", "is required to authorize the payment")
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(count); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	reference, err := c.gateway.Authorize(ctx, payment_gateway.AuthorizeRequest{
+		PaymentID:  payment.id,
+		CardNumber: *params.CardNumber,
+		Amount:     payment.value,
+	})
+	if errors.Is(err, apperror.ErrPaymentDeclined) {
+		reason := err.Error()
+		_, updateErr := c.repository.UpdateStatus(ctx, payment.id, payment.status, PaymentStatusParams{Status: StatusFailed, FailureReason: &reason})
+		return PaymentModel{}, errors.Join(err, updateErr)
+	}
+	if err != nil {
+		return PaymentModel{}, fmt.Errorf("failed to authorize payment: %w", err)
 	}
-}
 
-func (c *PaymentController) Update(w http.ResponseWriter, r *http.Request) {
-	// There is no update method, but this needs to be included since the controller is implementing an interface (IController)
+	return c.repository.UpdateStatus(ctx, payment.id, payment.status, PaymentStatusParams{Status: StatusAuthorized, GatewayReference: &reference})
 }
@@ -1,23 +1,46 @@
 package payment
 
+import (
+	"context"
+	"sipub-test/internal/filter"
+	"sipub-test/internal/pagination"
+)
+
 type IPaymentRepository interface {
-	// Returns the created payment
-	Create(PaymentParams) (PaymentModel, error)
+	// Returns the created payment. The value can't go over what the other
+	// payments leave of the delivery total
+	Create(ctx context.Context, params PaymentParams) (PaymentModel, error)
 
 	// Returns the found payments
 	// NOTE: reusing the same type for a filter and a "constructor" is not
 	// ideal at all, but it will save on code repetition
-	GetAll(filter PaymentParams) ([]PaymentModel, error)
+	GetAll(ctx context.Context, where filter.Filter, page pagination.Page) ([]PaymentModel, error)
+
+	// Returns how many rows match the filter, ignoring the page
+	Count(ctx context.Context, where filter.Filter) (uint, error)
 
 	// Returns the found payment
-	GetOne(id string) (PaymentModel, error)
+	GetOne(ctx context.Context, id string) (PaymentModel, error)
 
-	// Returns amount of deleted payments
-	DeleteOne(id string) (uint, error)
+	// Soft deletes, returns amount of deleted payments
+	DeleteOne(ctx context.Context, id string) (uint, error)
 
-	// Returns amount of deleted payments
-	DeleteAll(filter PaymentParams) (uint, error)
+	// Soft deletes, returns amount of deleted payments
+	DeleteAll(ctx context.Context, where filter.Filter) (uint, error)
 
 	// Cannot be updated after being created
 	// Update(id string, newPayment PaymentParams) (PaymentModel, error)
+
+	// Only changes the payment if it is still in the from status, a concurrent
+	// capture or refund is reported as a conflict. Returns the updated payment
+	UpdateStatus(ctx context.Context, id string, from PaymentStatus, params PaymentStatusParams) (PaymentModel, error)
+
+	// Returns the delivery total and how much of it is paid
+	Balance(ctx context.Context, deliveryID string) (BalanceModel, error)
+
+	// Undoes a soft delete, returns the restored payment
+	Restore(ctx context.Context, id string) (PaymentModel, error)
+
+	// Removes the row for good, whether it was soft deleted or not
+	Purge(ctx context.Context, id string) (uint, error)
 }
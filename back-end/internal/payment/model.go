@@ -1,39 +1,201 @@
 package payment
 
+import (
+	"sipub-test/pkg/money"
+	"sipub-test/pkg/timestamp"
+)
+
+// Where the money of a payment stands
+type PaymentStatus string
+
+const (
+	StatusPending    PaymentStatus = "pending"    // Nothing was asked to the gateway yet
+	StatusAuthorized PaymentStatus = "authorized" // The amount is held on the card
+	StatusCaptured   PaymentStatus = "captured"
+	StatusFailed     PaymentStatus = "failed"   // Declined, another card can be tried
+	StatusRefunded   PaymentStatus = "refunded" // Every captured cent was given back
+)
+
+// Where a refund stands with the gateway. Only completed refunds are shown
+// and count as given back
+type RefundStatus string
+
+const (
+	RefundPending   RefundStatus = "pending" // Reserved, the gateway didn't answer yet
+	RefundCompleted RefundStatus = "completed"
+	RefundFailed    RefundStatus = "failed" // Refused by the gateway, the money stays captured
+)
+
 // This is what will be used to create/find/update the payment model. The
 // fields are used as pointers so they can be nullified
 type PaymentParams struct {
 	IsDeleted  *bool
 	CreatedAt  *string
 	DeliveryID *string
-	Value      *float32
+	Value      *money.Money
 	UserID     *string // This is what will be used for getAll/deleteAll
 }
 
+// What the client sends to capture a payment. The card is only needed while
+// the payment isn't authorized
+type CaptureParams struct {
+	CardNumber *string
+}
+
+// Written after each call to the gateway
+type PaymentStatusParams struct {
+	Status           PaymentStatus
+	GatewayReference *string // Kept when nil
+	FailureReason    *string // Cleared when nil
+}
+
 type PaymentDTO struct {
-	Id         string  `json:"Id"`
-	IsDeleted  bool    `json:"IsDeleted"`
-	CreatedAt  string  `json:"CreatedAt"`
-	DeliveryID string  `json:"DeliveryID"`
-	Value      float32 `json:"Value"`
+	Id         string      `json:"Id"`
+	IsDeleted  bool        `json:"IsDeleted"`
+	CreatedAt  string      `json:"CreatedAt"`
+	DeletedAt  *string     `json:"DeletedAt,omitempty"`
+	DeliveryID string      `json:"DeliveryID"`
+	Value      money.Money `json:"Value"`
+
+	Status           string  `json:"Status"`
+	GatewayReference *string `json:"GatewayReference,omitempty"`
+	FailureReason    *string `json:"FailureReason,omitempty"`
+
+	RefundedValue money.Money `json:"RefundedValue"`
+	Refunds       []RefundDTO `json:"Refunds"`
 }
 
 type PaymentModel struct {
 	// Base of db models, included here because go doesn't allow for
 	// inheritance. Explained in COMMENTS.md
-	id         string // ID will be a uuid
-	isDeleted  bool   // Soft deletion
-	createdAt  string
+	id         string          // ID will be a uuid
+	isDeleted  bool            // Soft deletion
+	deletedAt  *timestamp.Time // Set while soft deleted
+	createdAt  timestamp.Time
 	deliveryID string
-	value      float32
+	value      money.Money
+
+	status           PaymentStatus
+	gatewayReference *string // Set once authorized
+	failureReason    *string // Why the last authorization was declined
+
+	refunds []RefundModel // Oldest first
+}
+
+// Captured, minus what was already given back
+func (a *PaymentModel) Refundable() money.Money {
+	return a.value.Sub(a.refunded())
+}
+
+func (a *PaymentModel) refunded() money.Money {
+	total := money.Zero
+	for _, refund := range a.refunds {
+		total = total.Add(refund.amount)
+	}
+	return total
 }
 
 func (a *PaymentModel) ToDTO() PaymentDTO {
 	dtoPayment := PaymentDTO{
 		Id:         a.id,
-		CreatedAt:  a.createdAt,
+		IsDeleted:  a.isDeleted,
+		CreatedAt:  a.createdAt.String(),
+		DeletedAt:  timestamp.Format(a.deletedAt),
 		DeliveryID: a.deliveryID,
 		Value:      a.value,
+
+		Status:           string(a.status),
+		GatewayReference: a.gatewayReference,
+		FailureReason:    a.failureReason,
+
+		RefundedValue: a.refunded(),
+		Refunds:       []RefundDTO{},
+	}
+	for i := 0; i < len(a.refunds); i++ {
+		dtoPayment.Refunds = append(dtoPayment.Refunds, a.refunds[i].ToDTO())
 	}
 	return dtoPayment
 }
+
+// Money given back from a captured payment, written by the refund package
+type RefundDTO struct {
+	Id        string      `json:"Id"`
+	PaymentID string      `json:"PaymentID"`
+	Amount    money.Money `json:"Amount"`
+	Reason    string      `json:"Reason"`
+	Note      *string     `json:"Note,omitempty"`
+	CreatedAt string      `json:"CreatedAt"`
+}
+
+type RefundModel struct {
+	id        string
+	paymentID string
+	amount    money.Money
+	reason    string
+	note      *string
+	createdAt timestamp.Time
+}
+
+func (r *RefundModel) ToDTO() RefundDTO {
+	dtoRefund := RefundDTO{
+		Id:        r.id,
+		PaymentID: r.paymentID,
+		Amount:    r.amount,
+		Reason:    r.reason,
+		Note:      r.note,
+		CreatedAt: r.createdAt.String(),
+	}
+	return dtoRefund
+}
+
+// What a delivery costs and how much of it its payments cover
+type BalanceDTO struct {
+	DeliveryID  string      `json:"DeliveryID"`
+	ItemsTotal  money.Money `json:"ItemsTotal"`
+	ShippingFee money.Money `json:"ShippingFee"`
+	Total       money.Money `json:"Total"`
+	Paid        money.Money `json:"Paid"`
+	Pending     money.Money `json:"Pending"`
+	Outstanding money.Money `json:"Outstanding"`
+	Remaining   money.Money `json:"Remaining"`
+}
+
+type BalanceModel struct {
+	deliveryID  string
+	itemsTotal  money.Money // Each product at its checkout price, or the current one
+	shippingFee money.Money
+	paid        money.Money // Captured, minus the refunds
+	pending     money.Money // Pending, authorized or failed, still expected to be captured. A failed one is retried with another card, see PaymentController.Capture
+}
+
+func (b *BalanceModel) total() money.Money {
+	return b.itemsTotal.Add(b.shippingFee)
+}
+
+// Not captured yet
+func (b *BalanceModel) outstanding() money.Money {
+	return b.total().Sub(b.paid)
+}
+
+// Not covered by any payment, the most a new payment can have
+func (b *BalanceModel) remaining() money.Money {
+	remaining := b.outstanding().Sub(b.pending)
+	if remaining.IsNegative() {
+		return money.Zero
+	}
+	return remaining
+}
+
+func (b *BalanceModel) ToDTO() BalanceDTO {
+	dtoBalance := BalanceDTO{
+		DeliveryID:  b.deliveryID,
+		ItemsTotal:  b.itemsTotal,
+		ShippingFee: b.shippingFee,
+		Total:       b.total(),
+		Paid:        b.paid,
+		Pending:     b.pending,
+		Outstanding: b.outstanding(),
+		Remaining:   b.remaining(),
+	}
+	return dtoBalance
+}
@@ -1,93 +1,164 @@
 package payment
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
-	"log"
 	"sipub-test/db"
-	"time"
+	"sipub-test/internal/filter"
+	"sipub-test/internal/pagination"
+	"sipub-test/pkg/apperror"
+	"sipub-test/pkg/clock"
+	"sipub-test/pkg/money"
+	"sipub-test/pkg/nilcheck"
+	"sipub-test/pkg/timestamp"
+	"strings"
 
 	"github.com/google/uuid"
 )
 
 type MySQLPaymentRepository struct {
-	db *sql.DB
+	db    db.Executor
+	clock clock.Clock
 }
 
-// Mainly used for testing, but could be used elsewhere
-func (r *MySQLPaymentRepository) SetDB(db *sql.DB) { r.db = db }
-
-func (r *MySQLPaymentRepository) createNewPaymentTableIfNoneExists() {
-	r.db = db.GetDB()
-
-	createTableQuery := `
-	CREATE TABLE IF NOT EXISTS payments (
-		id CHAR(36) NOT NULL,
-		isDeleted BOOLEAN NOT NULL DEFAULT FALSE,
-        createdAt CHAR(19) NOT NULL,
-        delivery_id CHAR(16) NOT NULL,
-        value FLOAT NOT NULL,
-        FOREIGN KEY (delivery_id) REFERENCES deliveries(id) ON DELETE CASCADE,
-        PRIMARY KEY (id)
-	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`
-	// https://dev.mysql.com/doc/refman/8.4/en/innodb-benefits.html
-	// Mainly using InnoDB because it supports foreing keys
-	// createdAt is a string because it is simpler to handle. It uses this
-	// format 2006-01-02 15:04:05 (19 chars)
+func NewMySQLPaymentRepository(db db.Executor, clock clock.Clock) *MySQLPaymentRepository {
+	return &MySQLPaymentRepository{db: db, clock: clock}
+}
 
-	if _, err := r.db.Exec(createTableQuery); err != nil {
-		log.Fatalf("Failed to create table: %v", err)
-	}
+// Fields the list can be filtered and deleted by
+var filterFields = filter.Schema{
+	"IsDeleted":  {Column: "p.isDeleted", Type: filter.Bool, Operators: []filter.Operator{filter.Eq}},
+	"CreatedAt":  {Column: "p.createdAt", Type: filter.Time, Operators: filter.TimeOperators},
+	"DeliveryID": {Column: "p.delivery_id", Type: filter.String, Operators: []filter.Operator{filter.Eq, filter.In}},
+	"UserID":     {Column: "d.user_id", Type: filter.String, Operators: []filter.Operator{filter.Eq, filter.In}},
+	"Value":      {Column: "p.value", Type: filter.Money, Operators: filter.NumberOperators},
+	"Status":     {Column: "p.status", Type: filter.String, Operators: []filter.Operator{filter.Eq, filter.In}},
 }
 
-func NewMySQLPaymentRepository() *MySQLPaymentRepository {
-	repo := &MySQLPaymentRepository{db: db.GetDB()}
-	repo.createNewPaymentTableIfNoneExists()
-	return repo
+// Columns the list can be sorted by
+var sortFields = pagination.Fields{
+	Table:    "payments p",
+	IDColumn: "p.id",
+	Columns: map[string]string{
+		"Value":     "p.value",
+		"CreatedAt": "p.createdAt",
+	},
+	Default: []pagination.Sort{{Field: "CreatedAt", Column: "p.createdAt", Desc: true}},
 }
 
-func (r *MySQLPaymentRepository) Create(params PaymentParams) (PaymentModel, error) {
+func (r *MySQLPaymentRepository) Create(ctx context.Context, params PaymentParams) (PaymentModel, error) {
 	id := uuid.NewString()
 
-	timeCreated := time.Now().Format("2006-01-02 15:04:05")
+	timeCreated := timestamp.New(r.clock.Now())
 
 	// Fields might be nil, but they need to be passed empty/defaulted non nil fields
 	model := PaymentModel{
 		id:         id,
-		isDeleted:  *params.IsDeleted,
+		isDeleted:  nilcheck.NotNilBool(params.IsDeleted, false),
 		createdAt:  timeCreated,
 		deliveryID: *params.DeliveryID,
 		value:      *params.Value,
+		status:     StatusPending, // Column default
 	}
 
-	query := `INSERT INTO payments (id, isDeleted, createdAt, delivery_id, value) VALUES (?, ?, ?, ?, ?)`
+	// The delivery stays locked until the payment is inserted, two payments
+	// can't both take what is left of it
+	err := db.WithTx(ctx, r.db, func(tx db.Executor) error {
+		balance, err := balanceOf(ctx, tx, model.deliveryID, true)
+		if err != nil {
+			return err
+		}
+		if remaining := balance.remaining(); model.value.GreaterThan(remaining) {
+			return apperror.NewValidationError("Value", fmt.Sprintf("exceeds what is left to pay on the delivery (%s)", remaining))
+		}
 
-	_, err := r.db.Exec(query, id, model.isDeleted, timeCreated, model.deliveryID, model.value)
+		query := `INSERT INTO payments (id, isDeleted, createdAt, delivery_id, value) VALUES (?, ?, ?, ?, ?)`
+		_, err = tx.ExecContext(ctx, query, id, model.isDeleted, timeCreated, model.deliveryID, model.value)
+		if err != nil {
+			return fmt.Errorf("failed to create payment: %w", db.TranslateError(err))
+		}
+		return nil
+	})
 	if err != nil {
-		return PaymentModel{}, fmt.Errorf("failed to create payment: %w", err)
+		return PaymentModel{}, err
 	}
 
 	return model, nil
 }
 
-func (r *MySQLPaymentRepository) GetAll(filter PaymentParams) ([]PaymentModel, error) {
+func (r *MySQLPaymentRepository) Balance(ctx context.Context, deliveryID string) (BalanceModel, error) {
+	return balanceOf(ctx, r.db, deliveryID, false)
+}
+
+// Adds up the products, the shipping and the payments of a delivery. Soft
+// deleted and refunded payments don't count
+func balanceOf(ctx context.Context, executor db.Executor, deliveryID string, lock bool) (BalanceModel, error) {
+	balance := BalanceModel{deliveryID: deliveryID}
+
+	query := `SELECT shippingFee FROM deliveries WHERE id = ? AND isDeleted = FALSE`
+	if lock {
+		query += ` FOR UPDATE`
+	}
+	err := executor.QueryRowContext(ctx, query, deliveryID).Scan(&balance.shippingFee)
+	if errors.Is(err, sql.ErrNoRows) {
+		return BalanceModel{}, fmt.Errorf("delivery %w", apperror.ErrNotFound)
+	}
+	if err != nil {
+		return BalanceModel{}, fmt.Errorf("failed to get delivery: %w", err)
+	}
+
+	// Rows added without going through the checkout have no price snapshot
+	query = `
+		SELECT
+			COALESCE(SUM(dp.product_amount * COALESCE(dp.unit_price, p.price)), 0)
+		FROM
+			delivery_product dp
+		JOIN
+			products p ON p.id = dp.product_id
+		WHERE
+			dp.delivery_id = ?`
+	if err := executor.QueryRowContext(ctx, query, deliveryID).Scan(&balance.itemsTotal); err != nil {
+		return BalanceModel{}, fmt.Errorf("failed to get delivery total: %w", err)
+	}
+
+	// What was given back of a captured payment can be paid again
+	query = `
+		SELECT
+			COALESCE(SUM(CASE WHEN p.status = ? THEN p.value - COALESCE(r.amount, 0) END), 0),
+			COALESCE(SUM(CASE WHEN p.status IN (?, ?, ?) THEN p.value END), 0)
+		FROM
+			payments p
+		LEFT JOIN
+			(SELECT payment_id, SUM(amount) AS amount FROM refunds WHERE status = ? GROUP BY payment_id) r ON r.payment_id = p.id
+		WHERE
+			p.delivery_id = ? AND p.isDeleted = FALSE`
+	err = executor.QueryRowContext(ctx, query, StatusCaptured, StatusPending, StatusAuthorized, StatusFailed, RefundCompleted, deliveryID).
+		Scan(&balance.paid, &balance.pending)
+	if err != nil {
+		return BalanceModel{}, fmt.Errorf("failed to get delivery payments: %w", err)
+	}
+
+	return balance, nil
+}
+
+func (r *MySQLPaymentRepository) GetAll(ctx context.Context, where filter.Filter, page pagination.Page) ([]PaymentModel, error) {
+	conditions, args := where.SQL()
+	// The deliveries are joined so the payments can be filtered by user
 	query := `
-		SELECT 
-			p.id, p.isDeleted, p.createdAt, p.deliveryID, p.value
-		FROM 
-			userDelivery ud
-		JOIN 
-			deliveries d ON ud.delivery_id = d.delivery_id
-		JOIN 
-			payments p ON p.delivery_id = d.delivery_id
-		WHERE 
-			1=1 AND ud.user_id = ?`
-	args := []interface{}{*filter.UserID}
-
-	// Add filtering for user_id if provided
-
-	rows, err := r.db.Query(query, args...)
+		SELECT
+			p.id, p.isDeleted, p.createdAt, p.delivery_id, p.value, p.deletedAt,
+			p.status, p.gatewayReference, p.failureReason
+		FROM
+			payments p
+		JOIN
+			deliveries d ON d.id = p.delivery_id
+		WHERE
+			1=1` + conditions
+	query, args = sortFields.Apply(page, query, args)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get payments: %w", err)
 	}
@@ -102,6 +173,10 @@ func (r *MySQLPaymentRepository) GetAll(filter PaymentParams) ([]PaymentModel, e
 			&payment.createdAt,
 			&payment.deliveryID,
 			&payment.value,
+			&payment.deletedAt,
+			&payment.status,
+			&payment.gatewayReference,
+			&payment.failureReason,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan payment: %w", err)
@@ -109,53 +184,302 @@ func (r *MySQLPaymentRepository) GetAll(filter PaymentParams) ([]PaymentModel, e
 		payments = append(payments, payment)
 	}
 
+	ids := make([]string, 0, len(payments))
+	for _, payment := range payments {
+		ids = append(ids, payment.id)
+	}
+	refunds, err := r.refundsOf(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range payments {
+		payments[i].refunds = refunds[payments[i].id]
+	}
+
 	return payments, nil
 }
 
-func (r *MySQLPaymentRepository) GetOne(id string) (PaymentModel, error) {
-	query := `SELECT id, isDeleted, createdAt, delivery_id, value FROM payments WHERE id = ?`
+// Returns the completed refunds of each payment, in a single query
+func (r *MySQLPaymentRepository) refundsOf(ctx context.Context, paymentIDs []string) (map[string][]RefundModel, error) {
+	refunds := map[string][]RefundModel{}
+	if len(paymentIDs) == 0 {
+		return refunds, nil
+	}
+
+	placeholders := make([]string, len(paymentIDs))
+	args := make([]interface{}, len(paymentIDs))
+	for i, id := range paymentIDs {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+	args = append(args, RefundCompleted)
+	query := `SELECT id, payment_id, amount, reason, note, createdAt FROM refunds WHERE payment_id IN (` + strings.Join(placeholders, ", ") + `) AND status = ? ORDER BY createdAt ASC`
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get refunds: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var refund RefundModel
+		err := rows.Scan(&refund.id,
+			&refund.paymentID,
+			&refund.amount,
+			&refund.reason,
+			&refund.note,
+			&refund.createdAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan refund: %w", err)
+		}
+		refunds[refund.paymentID] = append(refunds[refund.paymentID], refund)
+	}
+	return refunds, nil
+}
+
+func (r *MySQLPaymentRepository) Count(ctx context.Context, where filter.Filter) (uint, error) {
+	conditions, args := where.SQL()
+	query := `
+		SELECT
+			COUNT(*)
+		FROM
+			payments p
+		JOIN
+			deliveries d ON d.id = p.delivery_id
+		WHERE
+			1=1` + conditions
+
+	var count uint
+	if err := r.db.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count payments: %w", err)
+	}
+	return count, nil
+}
+
+func (r *MySQLPaymentRepository) GetOne(ctx context.Context, id string) (PaymentModel, error) {
+	query := `SELECT id, isDeleted, createdAt, delivery_id, value, status, gatewayReference, failureReason FROM payments WHERE id = ? AND isDeleted = FALSE`
 
 	var payment PaymentModel
-	row := r.db.QueryRow(query, id)
-	err := row.Scan(&payment.id, &payment.isDeleted, &payment.createdAt, &payment.deliveryID, &payment.value)
+	row := r.db.QueryRowContext(ctx, query, id)
+	err := row.Scan(&payment.id,
+		&payment.isDeleted,
+		&payment.createdAt,
+		&payment.deliveryID,
+		&payment.value,
+		&payment.status,
+		&payment.gatewayReference,
+		&payment.failureReason)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return PaymentModel{}, fmt.Errorf("payment not found")
+			return PaymentModel{}, fmt.Errorf("payment %w", apperror.ErrNotFound)
 		}
 		return PaymentModel{}, fmt.Errorf("failed to get payment: %w", err)
 	}
+
+	refunds, err := r.refundsOf(ctx, []string{id})
+	if err != nil {
+		return PaymentModel{}, err
+	}
+	payment.refunds = refunds[id]
 	return payment, nil
 }
 
-func (r *MySQLPaymentRepository) DeleteOne(id string) (uint, error) {
-	query := `DELETE FROM payments WHERE id = ?`
-	res, err := r.db.Exec(query, id)
+func (r *MySQLPaymentRepository) UpdateStatus(ctx context.Context, id string, from PaymentStatus, params PaymentStatusParams) (PaymentModel, error) {
+	query := `UPDATE payments SET status = ?, gatewayReference = COALESCE(?, gatewayReference), failureReason = ? WHERE id = ? AND status = ? AND isDeleted = FALSE`
+	res, err := r.db.ExecContext(ctx, query, params.Status, params.GatewayReference, params.FailureReason, id, from)
 	if err != nil {
-		return 0, fmt.Errorf("failed to delete payment: %w", err)
+		return PaymentModel{}, fmt.Errorf("failed to update payment status: %w", db.TranslateError(err))
 	}
 	count, _ := res.RowsAffected()
 	if count == 0 {
-		return 0, fmt.Errorf("no payment found with the given ID")
+		return PaymentModel{}, fmt.Errorf("payment is no longer %s: %w", from, apperror.ErrConflict)
+	}
+	return r.GetOne(ctx, id)
+}
+
+// Captured payments and the ones with refunds can't be deleted, see
+// checkRemovable
+func (r *MySQLPaymentRepository) DeleteOne(ctx context.Context, id string) (uint, error) {
+	deletedAt := timestamp.New(r.clock.Now())
+	var count int64
+	err := db.WithTx(ctx, r.db, func(tx db.Executor) error {
+		var status PaymentStatus
+		var refunds uint
+		query := `SELECT ` + removableColumns + ` FROM payments p WHERE p.id = ? AND p.isDeleted = FALSE FOR UPDATE`
+		err := tx.QueryRowContext(ctx, query, id).Scan(&status, &refunds)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("payment %w", apperror.ErrNotFound)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get payment: %w", err)
+		}
+		if err := checkRemovable(id, status, refunds); err != nil {
+			return err
+		}
+
+		query = `UPDATE payments SET isDeleted = TRUE, deletedAt = ? WHERE id = ? AND isDeleted = FALSE`
+		res, err := tx.ExecContext(ctx, query, deletedAt, id)
+		if err != nil {
+			return fmt.Errorf("failed to delete payment: %w", db.TranslateError(err))
+		}
+		count, _ = res.RowsAffected()
+		return nil
+	})
+	if err != nil {
+		return 0, err
 	}
 	return uint(count), nil
 }
 
-func (r *MySQLPaymentRepository) DeleteAll(filter PaymentParams) (uint, error) {
-	// SQL query to delete payments associated with a specific user
-	query := `
-		DELETE p
-		FROM payments p
-		JOIN deliveries d ON p.deliveryID = d.deliveryID
-		JOIN userDelivery ud ON ud.deliveryID = d.deliveryID
-		WHERE 1=1 AND ud.user_id = ?`
-	args := []interface{}{*filter.UserID}
-
-	res, err := r.db.Exec(query, args...)
+// Nothing is deleted when one of the payments can't be, see checkRemovable
+func (r *MySQLPaymentRepository) DeleteAll(ctx context.Context, where filter.Filter) (uint, error) {
+	conditions, args := where.SQL()
+	var count int64
+	err := db.WithTx(ctx, r.db, func(tx db.Executor) error {
+		query := `
+			SELECT
+				p.id, ` + removableColumns + `
+			FROM
+				payments p
+			JOIN
+				deliveries d ON d.id = p.delivery_id
+			WHERE
+				1=1` + conditions + `
+			FOR UPDATE`
+		rows, err := tx.QueryContext(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("failed to get payments: %w", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var id string
+			var status PaymentStatus
+			var refunds uint
+			if err := rows.Scan(&id, &status, &refunds); err != nil {
+				return fmt.Errorf("failed to scan payment: %w", err)
+			}
+			if err := checkRemovable(id, status, refunds); err != nil {
+				return err
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to get payments: %w", err)
+		}
+
+		query = `
+			UPDATE
+				payments p
+			JOIN
+				deliveries d ON d.id = p.delivery_id
+			SET
+				p.isDeleted = TRUE, p.deletedAt = ?
+			WHERE
+				1=1` + conditions
+		res, err := tx.ExecContext(ctx, query, append([]interface{}{timestamp.New(r.clock.Now())}, args...)...)
+		if err != nil {
+			return fmt.Errorf("failed to delete payments: %w", db.TranslateError(err))
+		}
+
+		// Get the number of rows affected
+		count, _ = res.RowsAffected()
+		return nil
+	})
 	if err != nil {
-		return 0, fmt.Errorf("failed to delete payments: %w", err)
+		return 0, err
 	}
+	return uint(count), nil
+}
 
-	// Get the number of rows affected
-	count, _ := res.RowsAffected()
+// The payment counts again in the balance of its delivery once restored, it
+// can't go over what is left to pay, like in Create
+func (r *MySQLPaymentRepository) Restore(ctx context.Context, id string) (PaymentModel, error) {
+	err := db.WithTx(ctx, r.db, func(tx db.Executor) error {
+		// What the payment adds to the balance, a captured one only counts
+		// what wasn't given back
+		var deliveryID string
+		var status PaymentStatus
+		var value money.Money
+		query := `
+			SELECT
+				p.delivery_id, p.status, p.value - COALESCE((SELECT SUM(amount) FROM refunds WHERE payment_id = p.id AND status = ?), 0)
+			FROM
+				payments p
+			WHERE
+				p.id = ? AND p.isDeleted = TRUE
+			FOR UPDATE`
+		err := tx.QueryRowContext(ctx, query, RefundCompleted, id).Scan(&deliveryID, &status, &value)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("deleted payment %w", apperror.ErrNotFound)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get payment: %w", err)
+		}
+
+		if status != StatusRefunded {
+			balance, err := balanceOf(ctx, tx, deliveryID, true)
+			if err != nil {
+				return err
+			}
+			if remaining := balance.remaining(); value.GreaterThan(remaining) {
+				return apperror.NewValidationError("Value", fmt.Sprintf("exceeds what is left to pay on the delivery (%s)", remaining))
+			}
+		}
+
+		query = `UPDATE payments SET isDeleted = FALSE, deletedAt = NULL WHERE id = ? AND isDeleted = TRUE`
+		if _, err := tx.ExecContext(ctx, query, id); err != nil {
+			return fmt.Errorf("failed to restore payment: %w", db.TranslateError(err))
+		}
+		return nil
+	})
+	if err != nil {
+		return PaymentModel{}, err
+	}
+	return r.GetOne(ctx, id)
+}
+
+// Captured payments and the ones with refunds can't be purged, see
+// checkRemovable
+func (r *MySQLPaymentRepository) Purge(ctx context.Context, id string) (uint, error) {
+	var count int64
+	err := db.WithTx(ctx, r.db, func(tx db.Executor) error {
+		var status PaymentStatus
+		var refunds uint
+		query := `SELECT ` + removableColumns + ` FROM payments p WHERE p.id = ? FOR UPDATE`
+		err := tx.QueryRowContext(ctx, query, id).Scan(&status, &refunds)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("payment %w", apperror.ErrNotFound)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get payment: %w", err)
+		}
+		if err := checkRemovable(id, status, refunds); err != nil {
+			return err
+		}
+
+		query = `DELETE FROM payments WHERE id = ?`
+		res, err := tx.ExecContext(ctx, query, id)
+		if err != nil {
+			return fmt.Errorf("failed to purge payment: %w", db.TranslateError(err))
+		}
+		count, _ = res.RowsAffected()
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
 	return uint(count), nil
 }
+
+// What checkRemovable needs of a payment p
+const removableColumns = `p.status, (SELECT COUNT(*) FROM refunds WHERE payment_id = p.id)`
+
+// Captured payments and the ones with refunds are the record of money that
+// went through the gateway. Deleting a captured one would also drop it out of
+// the balance, and the delivery could be charged twice
+func checkRemovable(id string, status PaymentStatus, refunds uint) error {
+	if status == StatusCaptured || refunds > 0 {
+		return fmt.Errorf("payment %s is %s with %d refunds, it has to be kept: %w", id, status, refunds, apperror.ErrConflict)
+	}
+	return nil
+}
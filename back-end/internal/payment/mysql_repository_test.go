@@ -1,15 +1,44 @@
 package payment_test
 
 import (
+	"context"
+	"errors"
 	"regexp"
+	"sipub-test/internal/pagination"
 	"sipub-test/internal/payment"
+	"sipub-test/pkg/apperror"
+	"sipub-test/pkg/clock"
+	"sipub-test/pkg/money"
 	testhelper "sipub-test/pkg/test_helper"
+	"sipub-test/pkg/timestamp"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
 )
 
+// What the delivery costs and what its payments already cover
+func expectBalance(mock sqlmock.Sqlmock, lock bool, shippingFee float64, itemsTotal float64, paid float64, pending float64) {
+	query := `SELECT shippingFee FROM deliveries WHERE id = ? AND isDeleted = FALSE`
+	if lock {
+		query += ` FOR UPDATE`
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(query)).
+		WithArgs("delivery-123").
+		WillReturnRows(sqlmock.NewRows([]string{"shippingFee"}).AddRow(shippingFee))
+	mock.ExpectQuery(`SUM\(dp.product_amount \* COALESCE\(dp.unit_price, p.price\)\)`).
+		WithArgs("delivery-123").
+		WillReturnRows(sqlmock.NewRows([]string{"total"}).AddRow(itemsTotal))
+	mock.ExpectQuery(`FROM\s+payments`).
+		WithArgs(payment.StatusCaptured, payment.StatusPending, payment.StatusAuthorized, payment.StatusFailed, payment.RefundCompleted, "delivery-123").
+		WillReturnRows(sqlmock.NewRows([]string{"paid", "pending"}).AddRow(paid, pending))
+}
+
+func refundColumns() []string {
+	return []string{"id", "payment_id", "amount", "reason", "note", "createdAt"}
+}
+
 func TestCreatePayment(t *testing.T) {
 	t.Run("ValidCreate", func(t *testing.T) {
 		db, mock, err := sqlmock.New()
@@ -18,25 +47,132 @@ func TestCreatePayment(t *testing.T) {
 		}
 		defer db.Close()
 
-		repo := &payment.MySQLPaymentRepository{}
-		repo.SetDB(db)
+		repo := payment.NewMySQLPaymentRepository(db, clock.System{})
 
 		params := payment.PaymentParams{
 			IsDeleted:  testhelper.BoolPointer(false),
 			DeliveryID: testhelper.StringPointer("delivery-123"),
-			Value:      testhelper.FloatPointer(150.50),
+			Value:      testhelper.MoneyPointer(money.FromCents(15050)),
 		}
 
+		mock.ExpectBegin()
+		expectBalance(mock, true, 10, 190.50, 50, 0)
 		mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO payments (id, isDeleted, createdAt, delivery_id, value) VALUES (?, ?, ?, ?, ?)`)).
-			WithArgs(sqlmock.AnyArg(), false, sqlmock.AnyArg(), "delivery-123", 150.50).
+			WithArgs(sqlmock.AnyArg(), false, sqlmock.AnyArg(), "delivery-123", money.FromCents(15050)).
 			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
 
-		result, err := repo.Create(params)
+		result, err := repo.Create(context.Background(), params)
 
 		assert.NoError(t, err, "Shouldn't contain any errors")
 		assert.Equal(t, "delivery-123", result.ToDTO().DeliveryID, "DeliveryID should match")
-		assert.Equal(t, float32(150.50), result.ToDTO().Value, "Payment value should match")
+		assert.Equal(t, money.FromCents(15050), result.ToDTO().Value, "Payment value should match")
 		assert.Equal(t, false, result.ToDTO().IsDeleted, "IsDeleted should match")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("ShouldRejectValuesOverTheRemaining", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := payment.NewMySQLPaymentRepository(db, clock.System{})
+
+		params := payment.PaymentParams{
+			DeliveryID: testhelper.StringPointer("delivery-123"),
+			Value:      testhelper.MoneyPointer(money.FromCents(15051)),
+		}
+
+		// 200.50 in total, 50 already captured
+		mock.ExpectBegin()
+		expectBalance(mock, true, 10, 190.50, 50, 0)
+		mock.ExpectRollback()
+
+		_, err = repo.Create(context.Background(), params)
+
+		assert.True(t, errors.Is(err, apperror.ErrValidation), "The payments can't go over the delivery total")
+		assert.NoError(t, mock.ExpectationsWereMet(), "Nothing should be inserted")
+	})
+	t.Run("ShouldCountPendingPayments", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := payment.NewMySQLPaymentRepository(db, clock.System{})
+
+		params := payment.PaymentParams{
+			DeliveryID: testhelper.StringPointer("delivery-123"),
+			Value:      testhelper.MoneyPointer(money.FromCents(100)),
+		}
+
+		mock.ExpectBegin()
+		expectBalance(mock, true, 0, 100, 0, 100)
+		mock.ExpectRollback()
+
+		_, err = repo.Create(context.Background(), params)
+
+		assert.True(t, errors.Is(err, apperror.ErrValidation), "A pending payment already covers the delivery")
+	})
+}
+
+func TestPaymentBalance(t *testing.T) {
+	t.Run("ValidBalance", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := payment.NewMySQLPaymentRepository(db, clock.System{})
+
+		expectBalance(mock, false, 10, 90.10, 40, 20)
+
+		balance, err := repo.Balance(context.Background(), "delivery-123")
+
+		assert.NoError(t, err, "Shouldn't contain any errors")
+		dto := balance.ToDTO()
+		assert.Equal(t, money.FromCents(10010), dto.Total)
+		assert.Equal(t, money.FromCents(4000), dto.Paid)
+		assert.Equal(t, money.FromCents(2000), dto.Pending)
+		assert.Equal(t, money.FromCents(6010), dto.Outstanding)
+		assert.Equal(t, money.FromCents(4010), dto.Remaining)
+	})
+	t.Run("ShouldNotGoBelowZero", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := payment.NewMySQLPaymentRepository(db, clock.System{})
+
+		// The products got cheaper after the payment was made
+		expectBalance(mock, false, 0, 80, 0, 100)
+
+		balance, err := repo.Balance(context.Background(), "delivery-123")
+
+		assert.NoError(t, err, "Shouldn't contain any errors")
+		assert.Equal(t, money.Zero, balance.ToDTO().Remaining)
+	})
+	t.Run("ShouldReportUnknownDeliveries", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := payment.NewMySQLPaymentRepository(db, clock.System{})
+
+		mock.ExpectQuery(`SELECT shippingFee FROM deliveries`).
+			WithArgs("missing").
+			WillReturnRows(sqlmock.NewRows([]string{"shippingFee"}))
+
+		_, err = repo.Balance(context.Background(), "missing")
+
+		assert.True(t, errors.Is(err, apperror.ErrNotFound))
 	})
 }
 
@@ -48,33 +184,59 @@ func TestGetAllPayments(t *testing.T) {
 		}
 		defer db.Close()
 
-		repo := &payment.MySQLPaymentRepository{}
-		repo.SetDB(db)
+		repo := payment.NewMySQLPaymentRepository(db, clock.System{})
 
-		rows := sqlmock.NewRows([]string{"id", "isDeleted", "createdAt", "deliveryID", "value"}).
-			AddRow("payment-123", false, "2025-01-15 12:00:00", "delivery-123", 150.50)
+		rows := sqlmock.NewRows([]string{"id", "isDeleted", "createdAt", "deliveryID", "value", "deletedAt", "status", "gatewayReference", "failureReason"}).
+			AddRow("payment-123", false, "2025-01-15 12:00:00", "delivery-123", 150.50, nil, "captured", "fake_payment-123", nil)
 
 		mock.ExpectQuery(regexp.QuoteMeta(`
-			SELECT 
-				p.id, p.isDeleted, p.createdAt, p.deliveryID, p.value
-			FROM 
-				userDelivery ud
-			JOIN 
-				deliveries d ON ud.delivery_id = d.delivery_id
-			JOIN 
-				payments p ON p.delivery_id = d.delivery_id
-			WHERE 
-				1=1 AND ud.user_id = ?`)).
+			SELECT
+				p.id, p.isDeleted, p.createdAt, p.delivery_id, p.value, p.deletedAt,
+				p.status, p.gatewayReference, p.failureReason
+			FROM
+				payments p
+			JOIN
+				deliveries d ON d.id = p.delivery_id
+			WHERE
+				1=1 AND d.user_id = ?`)).
 			WithArgs("user-123").
 			WillReturnRows(rows)
+		// The refunds of the whole page come in one query
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, payment_id, amount, reason, note, createdAt FROM refunds WHERE payment_id IN (?) AND status = ? ORDER BY createdAt ASC`)).
+			WithArgs("payment-123", payment.RefundCompleted).
+			WillReturnRows(sqlmock.NewRows(refundColumns()).
+				AddRow("refund-1", "payment-123", 20, "damaged", nil, "2025-01-15 13:00:00").
+				AddRow("refund-2", "payment-123", 10.25, "other", "Late delivery", "2025-01-15 14:00:00"))
 
-		filter := payment.PaymentParams{UserID: testhelper.StringPointer("user-123")}
-		results, err := repo.GetAll(filter)
+		where := testhelper.FilterEqual("UserID", "d.user_id", "user-123")
+		results, err := repo.GetAll(context.Background(), where, pagination.Page{})
 
 		assert.NoError(t, err, "Shouldn't contain any errors")
 		assert.Len(t, results, 1, "Result length should be 1")
 		assert.Equal(t, "delivery-123", results[0].ToDTO().DeliveryID, "DeliveryID should match")
-		assert.Equal(t, float32(150.50), results[0].ToDTO().Value, "Payment value should match")
+		assert.Equal(t, money.FromCents(15050), results[0].ToDTO().Value, "Payment value should match")
+		assert.Equal(t, "captured", results[0].ToDTO().Status, "Status should match")
+		assert.Equal(t, money.FromCents(3025), results[0].ToDTO().RefundedValue)
+		assert.Len(t, results[0].ToDTO().Refunds, 2)
+		assert.Equal(t, money.FromCents(12025), results[0].Refundable())
+	})
+	t.Run("ShouldNotLookForRefundsOfAnEmptyPage", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := payment.NewMySQLPaymentRepository(db, clock.System{})
+
+		mock.ExpectQuery(`FROM\s+payments p`).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "isDeleted", "createdAt", "deliveryID", "value", "deletedAt", "status", "gatewayReference", "failureReason"}))
+
+		results, err := repo.GetAll(context.Background(), testhelper.FilterEqual("UserID", "d.user_id", "user-123"), pagination.Page{})
+
+		assert.NoError(t, err, "Shouldn't contain any errors")
+		assert.Empty(t, results)
+		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 }
 
@@ -85,66 +247,382 @@ func TestGetPaymentByID(t *testing.T) {
 	}
 	defer db.Close()
 
-	repo := &payment.MySQLPaymentRepository{}
-	repo.SetDB(db)
+	repo := payment.NewMySQLPaymentRepository(db, clock.System{})
 
-	rows := sqlmock.NewRows([]string{"id", "isDeleted", "createdAt", "deliveryID", "value"}).
-		AddRow("payment-123", false, "2025-01-15 12:00:00", "delivery-123", 150.50)
+	rows := sqlmock.NewRows([]string{"id", "isDeleted", "createdAt", "deliveryID", "value", "status", "gatewayReference", "failureReason"}).
+		AddRow("payment-123", false, "2025-01-15 12:00:00", "delivery-123", 150.50, "pending", nil, nil)
 
-	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, isDeleted, createdAt, delivery_id, value FROM payments WHERE id = ?`)).
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, isDeleted, createdAt, delivery_id, value, status, gatewayReference, failureReason FROM payments WHERE id = ?`)).
 		WithArgs("payment-123").
 		WillReturnRows(rows)
+	mock.ExpectQuery(`FROM refunds`).
+		WithArgs("payment-123", payment.RefundCompleted).
+		WillReturnRows(sqlmock.NewRows(refundColumns()))
 
-	result, err := repo.GetOne("payment-123")
+	result, err := repo.GetOne(context.Background(), "payment-123")
 
 	assert.NoError(t, err, "Shouldn't contain any errors")
+	assert.Empty(t, result.ToDTO().Refunds)
+	assert.NotNil(t, result.ToDTO().Refunds, "Should be an empty list, not null")
 	assert.Equal(t, "payment-123", result.ToDTO().Id, "Payment ID should match")
 	assert.Equal(t, "delivery-123", result.ToDTO().DeliveryID, "DeliveryID should match")
-	assert.Equal(t, float32(150.50), result.ToDTO().Value, "Payment value should match")
+	assert.Equal(t, money.FromCents(15050), result.ToDTO().Value, "Payment value should match")
+}
+
+func TestUpdatePaymentStatus(t *testing.T) {
+	t.Run("ValidUpdate", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := payment.NewMySQLPaymentRepository(db, clock.System{})
+
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE payments SET status = ?, gatewayReference = COALESCE(?, gatewayReference), failureReason = ? WHERE id = ? AND status = ? AND isDeleted = FALSE`)).
+			WithArgs(payment.StatusAuthorized, "fake_payment-123", nil, "payment-123", payment.StatusPending).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(`SELECT id, isDeleted, createdAt, delivery_id, value, status, gatewayReference, failureReason FROM payments`).
+			WithArgs("payment-123").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "isDeleted", "createdAt", "deliveryID", "value", "status", "gatewayReference", "failureReason"}).
+				AddRow("payment-123", false, "2025-01-15 12:00:00", "delivery-123", 150.50, "authorized", "fake_payment-123", nil))
+		mock.ExpectQuery(`FROM refunds`).
+			WithArgs("payment-123", payment.RefundCompleted).
+			WillReturnRows(sqlmock.NewRows(refundColumns()))
+
+		result, err := repo.UpdateStatus(context.Background(), "payment-123", payment.StatusPending, payment.PaymentStatusParams{
+			Status:           payment.StatusAuthorized,
+			GatewayReference: testhelper.StringPointer("fake_payment-123"),
+		})
+
+		assert.NoError(t, err, "Shouldn't contain any errors")
+		assert.Equal(t, "authorized", result.ToDTO().Status)
+		assert.Equal(t, "fake_payment-123", *result.ToDTO().GatewayReference)
+	})
+	t.Run("ShouldRejectStaleStatus", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := payment.NewMySQLPaymentRepository(db, clock.System{})
+
+		mock.ExpectExec(`UPDATE payments SET status`).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		_, err = repo.UpdateStatus(context.Background(), "payment-123", payment.StatusAuthorized, payment.PaymentStatusParams{Status: payment.StatusCaptured})
+
+		assert.True(t, errors.Is(err, apperror.ErrConflict), "Another request already moved the payment")
+	})
 }
 
 func TestDeletePayment(t *testing.T) {
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatalf("failed to create mock DB: %v", err)
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	query := regexp.QuoteMeta(`SELECT p.status, (SELECT COUNT(*) FROM refunds WHERE payment_id = p.id) FROM payments p WHERE p.id = ? AND p.isDeleted = FALSE FOR UPDATE`)
+	columns := []string{"status", "refunds"}
+
+	t.Run("ValidDelete", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := payment.NewMySQLPaymentRepository(db, clock.Fixed(now))
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(query).
+			WithArgs("payment-123").
+			WillReturnRows(sqlmock.NewRows(columns).AddRow("pending", 0))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE payments SET isDeleted = TRUE, deletedAt = ? WHERE id = ? AND isDeleted = FALSE`)).
+			WithArgs(timestamp.New(now), "payment-123").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		count, err := repo.DeleteOne(context.Background(), "payment-123")
+
+		assert.NoError(t, err, "Shouldn't contain any errors")
+		assert.Equal(t, uint(1), count, "Affected row count should be 1")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	for _, tt := range []struct {
+		name    string
+		status  string
+		refunds int
+	}{
+		// It would drop out of the balance and the delivery could be paid again
+		{"ShouldKeepCapturedPayments", "captured", 0},
+		{"ShouldKeepRefundedPayments", "refunded", 1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("failed to create mock DB: %v", err)
+			}
+			defer db.Close()
+
+			repo := payment.NewMySQLPaymentRepository(db, clock.Fixed(now))
+
+			mock.ExpectBegin()
+			mock.ExpectQuery(query).
+				WithArgs("payment-123").
+				WillReturnRows(sqlmock.NewRows(columns).AddRow(tt.status, tt.refunds))
+			mock.ExpectRollback()
+
+			_, err = repo.DeleteOne(context.Background(), "payment-123")
+
+			assert.True(t, errors.Is(err, apperror.ErrConflict))
+			assert.NoError(t, mock.ExpectationsWereMet(), "Nothing should be deleted")
+		})
 	}
-	defer db.Close()
+	t.Run("ShouldNotFindDeletedPayments", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
 
-	repo := &payment.MySQLPaymentRepository{}
-	repo.SetDB(db)
+		repo := payment.NewMySQLPaymentRepository(db, clock.Fixed(now))
 
-	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM payments WHERE id = ?`)).
-		WithArgs("payment-123").
-		WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectBegin()
+		mock.ExpectQuery(query).
+			WithArgs("payment-123").
+			WillReturnRows(sqlmock.NewRows(columns))
+		mock.ExpectRollback()
 
-	count, err := repo.DeleteOne("payment-123")
+		_, err = repo.DeleteOne(context.Background(), "payment-123")
 
-	assert.NoError(t, err, "Shouldn't contain any errors")
-	assert.Equal(t, uint(1), count, "Affected row count should be 1")
+		assert.True(t, errors.Is(err, apperror.ErrNotFound))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
 }
 
 func TestDeleteAllPayments(t *testing.T) {
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatalf("failed to create mock DB: %v", err)
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	query := regexp.QuoteMeta(`
+		SELECT p.id, p.status, (SELECT COUNT(*) FROM refunds WHERE payment_id = p.id)
+		FROM payments p
+		JOIN deliveries d ON d.id = p.delivery_id
+		WHERE 1=1 AND d.user_id = ?
+		FOR UPDATE`)
+	columns := []string{"id", "status", "refunds"}
+	where := testhelper.FilterEqual("UserID", "d.user_id", "user-123")
+
+	t.Run("ValidDeleteAll", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := payment.NewMySQLPaymentRepository(db, clock.Fixed(now))
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(query).
+			WithArgs("user-123").
+			WillReturnRows(sqlmock.NewRows(columns).AddRow("payment-123", "pending", 0).AddRow("payment-456", "failed", 0))
+		mock.ExpectExec(regexp.QuoteMeta(`
+			UPDATE payments p
+			JOIN deliveries d ON d.id = p.delivery_id
+			SET p.isDeleted = TRUE, p.deletedAt = ?
+			WHERE 1=1 AND d.user_id = ?`)).
+			WithArgs(timestamp.New(now), "user-123").
+			WillReturnResult(sqlmock.NewResult(1, 2))
+		mock.ExpectCommit()
+
+		count, err := repo.DeleteAll(context.Background(), where)
+
+		assert.NoError(t, err, "Shouldn't contain any errors")
+		assert.Equal(t, uint(2), count, "Affected row count should be 2")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("ShouldKeepEveryPaymentWhenOneIsCaptured", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := payment.NewMySQLPaymentRepository(db, clock.Fixed(now))
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(query).
+			WithArgs("user-123").
+			WillReturnRows(sqlmock.NewRows(columns).AddRow("payment-123", "pending", 0).AddRow("payment-456", "captured", 0))
+		mock.ExpectRollback()
+
+		_, err = repo.DeleteAll(context.Background(), where)
+
+		assert.True(t, errors.Is(err, apperror.ErrConflict))
+		assert.ErrorContains(t, err, "payment-456")
+		assert.NoError(t, mock.ExpectationsWereMet(), "Nothing should be deleted")
+	})
+}
+
+func TestPurgePayment(t *testing.T) {
+	query := regexp.QuoteMeta(`SELECT p.status, (SELECT COUNT(*) FROM refunds WHERE payment_id = p.id) FROM payments p WHERE p.id = ? FOR UPDATE`)
+	columns := []string{"status", "refunds"}
+
+	t.Run("ShouldPurgePaymentsThatNeverMovedMoney", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := payment.NewMySQLPaymentRepository(db, clock.System{})
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(query).
+			WithArgs("payment-123").
+			WillReturnRows(sqlmock.NewRows(columns).AddRow("failed", 0))
+		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM payments WHERE id = ?`)).
+			WithArgs("payment-123").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		count, err := repo.Purge(context.Background(), "payment-123")
+
+		assert.NoError(t, err, "Shouldn't contain any errors")
+		assert.Equal(t, uint(1), count)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	for _, tt := range []struct {
+		name    string
+		status  string
+		refunds int
+	}{
+		{"ShouldKeepCapturedPayments", "captured", 0},
+		{"ShouldKeepRefundedPayments", "refunded", 2},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("failed to create mock DB: %v", err)
+			}
+			defer db.Close()
+
+			repo := payment.NewMySQLPaymentRepository(db, clock.System{})
+
+			mock.ExpectBegin()
+			mock.ExpectQuery(query).
+				WithArgs("payment-123").
+				WillReturnRows(sqlmock.NewRows(columns).AddRow(tt.status, tt.refunds))
+			mock.ExpectRollback()
+
+			_, err = repo.Purge(context.Background(), "payment-123")
+
+			assert.True(t, errors.Is(err, apperror.ErrConflict))
+			assert.NoError(t, mock.ExpectationsWereMet(), "Nothing should be deleted")
+		})
 	}
-	defer db.Close()
+	t.Run("ShouldNotFindMissingPayments", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
 
-	repo := &payment.MySQLPaymentRepository{}
-	repo.SetDB(db)
+		repo := payment.NewMySQLPaymentRepository(db, clock.System{})
 
-	mock.ExpectExec(regexp.QuoteMeta(`
-		DELETE p
-		FROM payments p
-		JOIN deliveries d ON p.deliveryID = d.deliveryID
-		JOIN userDelivery ud ON ud.deliveryID = d.deliveryID
-		WHERE 1=1 AND ud.user_id = ?`)).
-		WithArgs("user-123").
-		WillReturnResult(sqlmock.NewResult(1, 5))
+		mock.ExpectBegin()
+		mock.ExpectQuery(query).
+			WithArgs("payment-123").
+			WillReturnRows(sqlmock.NewRows(columns))
+		mock.ExpectRollback()
 
-	filter := payment.PaymentParams{UserID: testhelper.StringPointer("user-123")}
-	count, err := repo.DeleteAll(filter)
+		_, err = repo.Purge(context.Background(), "payment-123")
 
-	assert.NoError(t, err, "Shouldn't contain any errors")
-	assert.Equal(t, uint(5), count, "Affected row count should be 5")
+		assert.True(t, errors.Is(err, apperror.ErrNotFound))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestRestorePayment(t *testing.T) {
+	query := regexp.QuoteMeta(`SELECT
+				p.delivery_id, p.status, p.value - COALESCE((SELECT SUM(amount) FROM refunds WHERE payment_id = p.id AND status = ?), 0)
+			FROM
+				payments p
+			WHERE
+				p.id = ? AND p.isDeleted = TRUE
+			FOR UPDATE`)
+	columns := []string{"delivery_id", "status", "value"}
+
+	t.Run("ShouldRestoreWhatStillFits", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := payment.NewMySQLPaymentRepository(db, clock.System{})
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(query).
+			WithArgs(payment.RefundCompleted, "payment-123").
+			WillReturnRows(sqlmock.NewRows(columns).AddRow("delivery-123", "pending", 50))
+		expectBalance(mock, true, 10, 90, 0, 50)
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE payments SET isDeleted = FALSE, deletedAt = NULL WHERE id = ? AND isDeleted = TRUE`)).
+			WithArgs("payment-123").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		mock.ExpectQuery(`SELECT id, isDeleted, createdAt, delivery_id, value, status, gatewayReference, failureReason FROM payments`).
+			WithArgs("payment-123").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "isDeleted", "createdAt", "deliveryID", "value", "status", "gatewayReference", "failureReason"}).
+				AddRow("payment-123", false, "2025-01-15 12:00:00", "delivery-123", 50, "pending", nil, nil))
+		mock.ExpectQuery(`FROM refunds`).
+			WithArgs("payment-123", payment.RefundCompleted).
+			WillReturnRows(sqlmock.NewRows(refundColumns()))
+
+		result, err := repo.Restore(context.Background(), "payment-123")
+
+		assert.NoError(t, err, "Shouldn't contain any errors")
+		assert.False(t, result.ToDTO().IsDeleted)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("ShouldRejectPaymentsOverWhatIsLeftToPay", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := payment.NewMySQLPaymentRepository(db, clock.System{})
+
+		// Another payment took the delivery while this one was deleted
+		mock.ExpectBegin()
+		mock.ExpectQuery(query).
+			WithArgs(payment.RefundCompleted, "payment-123").
+			WillReturnRows(sqlmock.NewRows(columns).AddRow("delivery-123", "captured", 50))
+		expectBalance(mock, true, 10, 90, 80, 0)
+		mock.ExpectRollback()
+
+		_, err = repo.Restore(context.Background(), "payment-123")
+
+		var validationErr *apperror.ValidationError
+		assert.True(t, errors.As(err, &validationErr))
+		assert.Equal(t, "Value", validationErr.Field)
+		assert.NoError(t, mock.ExpectationsWereMet(), "The payment should stay deleted")
+	})
+	t.Run("ShouldNotFindPaymentsThatArentDeleted", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := payment.NewMySQLPaymentRepository(db, clock.System{})
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(query).
+			WithArgs(payment.RefundCompleted, "payment-123").
+			WillReturnRows(sqlmock.NewRows(columns))
+		mock.ExpectRollback()
+
+		_, err = repo.Restore(context.Background(), "payment-123")
+
+		assert.True(t, errors.Is(err, apperror.ErrNotFound))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
 }
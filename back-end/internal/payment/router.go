@@ -5,16 +5,24 @@ import (
 	"sipub-test/internal"
 )
 
+// Besides the CRUD, payments are charged through the gateway. Refunds have
+// their own package, see internal/refund
+type IPaymentController interface {
+	internal.ISoftDeleteController
+
+	Capture(http.ResponseWriter, *http.Request)
+
+	// How much of a delivery is paid
+	Balance(http.ResponseWriter, *http.Request)
+}
+
 type PaymentRouter struct {
 	baseEndPoint string
-	controller   internal.IController
+	controller   IPaymentController
 }
 
-func NewPaymentRouter() PaymentRouter {
-	router := PaymentRouter{
-		controller: NewPaymentController(),
-	}
-	return router
+func NewPaymentRouter(controller IPaymentController) PaymentRouter {
+	return PaymentRouter{controller: controller}
 }
 
 func (r PaymentRouter) Init(mux *http.ServeMux) {
@@ -26,6 +34,10 @@ func (r PaymentRouter) Init(mux *http.ServeMux) {
 	r.deleteAll(mux)
 	r.deleteOne(mux)
 	r.update(mux)
+	r.restore(mux)
+	r.purge(mux)
+	r.capture(mux)
+	r.balance(mux)
 }
 
 func (r PaymentRouter) create(mux *http.ServeMux) {
@@ -51,3 +63,20 @@ func (r PaymentRouter) deleteOne(mux *http.ServeMux) {
 func (r PaymentRouter) update(mux *http.ServeMux) {
 	mux.HandleFunc("PUT "+r.baseEndPoint+"/{id}", r.controller.Update)
 }
+
+func (r PaymentRouter) restore(mux *http.ServeMux) {
+	mux.HandleFunc("POST "+r.baseEndPoint+"/{id}/restore", r.controller.Restore)
+}
+
+func (r PaymentRouter) purge(mux *http.ServeMux) {
+	mux.HandleFunc("DELETE "+r.baseEndPoint+"/{id}/purge", r.controller.Purge)
+}
+
+func (r PaymentRouter) capture(mux *http.ServeMux) {
+	mux.HandleFunc("POST "+r.baseEndPoint+"/{id}/capture", r.controller.Capture)
+}
+
+// Lives under the delivery, it is about all of its payments
+func (r PaymentRouter) balance(mux *http.ServeMux) {
+	mux.HandleFunc("GET /deliveries/{id}/balance", r.controller.Balance)
+}
@@ -0,0 +1,42 @@
+package payment
+
+import (
+	"sipub-test/pkg/apperror"
+)
+
+type PaymentValidator struct{}
+
+// Whether the value fits in the delivery is checked by the repository, it
+// depends on the other payments
+func (v *PaymentValidator) Validate(payment PaymentParams) error {
+	if payment.DeliveryID == nil || *payment.DeliveryID == "" {
+		return apperror.NewValidationError("DeliveryID", "is required")
+	}
+	if payment.Value == nil {
+		return apperror.NewValidationError("Value", "is required")
+	}
+	if !payment.Value.IsPositive() {
+		return apperror.NewValidationError("Value", "must be greater than zero")
+	}
+	return nil
+}
+
+type CaptureValidator struct{}
+
+// The card number is only checked for its format, the gateway decides whether
+// it can be charged
+func (v *CaptureValidator) Validate(capture CaptureParams) error {
+	if capture.CardNumber == nil {
+		return nil
+	}
+	card := *capture.CardNumber
+	if len(card) < 12 || len(card) > 19 {
+		return apperror.NewValidationError("CardNumber", "must have between 12 and 19 digits")
+	}
+	for _, digit := range card {
+		if digit < '0' || digit > '9' {
+			return apperror.NewValidationError("CardNumber", "must contain only digits")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package payment_gateway
+
+import (
+	"context"
+	"fmt"
+	"sipub-test/pkg/apperror"
+	"sipub-test/pkg/money"
+	"strings"
+)
+
+const fakeReferencePrefix = "fake_"
+
+// In-process gateway for tests and local development. Nothing leaves the
+// process and the same request always gets the same answer: the cards in
+// declinedCards and the amounts above declineAbove are refused, everything
+// else is accepted
+type FakeGateway struct {
+	declinedCards map[string]bool
+	declineAbove  money.Money // Zero accepts any amount
+}
+
+func NewFakeGateway(declinedCards []string, declineAbove money.Money) *FakeGateway {
+	cards := map[string]bool{}
+	for _, card := range declinedCards {
+		cards[card] = true
+	}
+	return &FakeGateway{declinedCards: cards, declineAbove: declineAbove}
+}
+
+func (g *FakeGateway) Authorize(ctx context.Context, request AuthorizeRequest) (string, error) {
+	if g.declinedCards[request.CardNumber] {
+		return "", fmt.Errorf("card declined: %w", apperror.ErrPaymentDeclined)
+	}
+	if g.declineAbove.IsPositive() && request.Amount.GreaterThan(g.declineAbove) {
+		return "", fmt.Errorf("amount above %s declined: %w", g.declineAbove, apperror.ErrPaymentDeclined)
+	}
+	return fakeReferencePrefix + request.PaymentID, nil
+}
+
+func (g *FakeGateway) Capture(ctx context.Context, reference string, amount money.Money) error {
+	return g.checkReference(reference)
+}
+
+func (g *FakeGateway) Refund(ctx context.Context, reference string, amount money.Money) error {
+	return g.checkReference(reference)
+}
+
+// Only the references handed out by Authorize are known, a payment authorized
+// by another gateway can't be captured here
+func (g *FakeGateway) checkReference(reference string) error {
+	if !strings.HasPrefix(reference, fakeReferencePrefix) {
+		return fmt.Errorf("unknown payment reference %q", reference)
+	}
+	return nil
+}
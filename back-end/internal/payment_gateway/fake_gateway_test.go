@@ -0,0 +1,46 @@
+package payment_gateway_test
+
+import (
+	"context"
+	"errors"
+	"sipub-test/internal/payment_gateway"
+	"sipub-test/pkg/apperror"
+	"sipub-test/pkg/money"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeGatewayAuthorize(t *testing.T) {
+	gateway := payment_gateway.NewFakeGateway([]string{"4000000000000002"}, money.FromCents(100000))
+
+	t.Run("ShouldAcceptValidPayments", func(t *testing.T) {
+		request := payment_gateway.AuthorizeRequest{PaymentID: "payment-123", CardNumber: "4242424242424242", Amount: money.FromCents(15050)}
+
+		first, err := gateway.Authorize(context.Background(), request)
+		assert.NoError(t, err)
+		second, _ := gateway.Authorize(context.Background(), request)
+
+		assert.Equal(t, first, second, "The same request should get the same reference")
+	})
+	t.Run("ShouldDeclineConfiguredCards", func(t *testing.T) {
+		_, err := gateway.Authorize(context.Background(), payment_gateway.AuthorizeRequest{PaymentID: "payment-123", CardNumber: "4000000000000002", Amount: money.FromCents(1000)})
+
+		assert.True(t, errors.Is(err, apperror.ErrPaymentDeclined))
+	})
+	t.Run("ShouldDeclineLargeAmounts", func(t *testing.T) {
+		_, err := gateway.Authorize(context.Background(), payment_gateway.AuthorizeRequest{PaymentID: "payment-123", CardNumber: "4242424242424242", Amount: money.FromCents(100001)})
+
+		assert.True(t, errors.Is(err, apperror.ErrPaymentDeclined))
+	})
+}
+
+func TestFakeGatewayCapture(t *testing.T) {
+	gateway := payment_gateway.NewFakeGateway(nil, money.Zero)
+
+	reference, _ := gateway.Authorize(context.Background(), payment_gateway.AuthorizeRequest{PaymentID: "payment-123", CardNumber: "4242424242424242", Amount: money.FromCents(1e8)})
+
+	assert.NoError(t, gateway.Capture(context.Background(), reference, money.FromCents(1e8)), "Any amount is accepted when declineAbove is zero")
+	assert.NoError(t, gateway.Refund(context.Background(), reference, money.FromCents(1000)))
+	assert.Error(t, gateway.Capture(context.Background(), "ch_from_another_gateway", money.FromCents(1000)))
+}
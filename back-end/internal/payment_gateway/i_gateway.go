@@ -0,0 +1,30 @@
+package payment_gateway
+
+import (
+	"context"
+	"sipub-test/pkg/money"
+)
+
+// What the gateway needs to hold an amount on a card. PaymentID is sent to
+// the provider as the idempotency key, retrying after a timeout doesn't hold
+// the amount twice
+type AuthorizeRequest struct {
+	PaymentID  string
+	CardNumber string
+	Amount     money.Money
+}
+
+// The provider behind the payments. Refusals are returned wrapping
+// apperror.ErrPaymentDeclined, any other error means the provider couldn't be
+// reached or didn't understand the request
+type IPaymentGateway interface {
+	// Holds the amount, returns the provider's reference used by the other
+	// calls
+	Authorize(ctx context.Context, request AuthorizeRequest) (string, error)
+
+	// Charges an authorized amount
+	Capture(ctx context.Context, reference string, amount money.Money) error
+
+	// Gives back part or all of a captured amount
+	Refund(ctx context.Context, reference string, amount money.Money) error
+}
@@ -2,212 +2,123 @@ package product
 
 import (
 	"encoding/json"
-	"fmt"
+	"log/slog"
 	"net/http"
-	"strconv"
-	"strings"
+	"sipub-test/internal"
+	"sipub-test/internal/filter"
+	"sipub-test/internal/pagination"
+	"sipub-test/internal/response"
 )
 
 type ProductController struct {
-	// TODO
-	validator  ProductValidator
 	repository IProductRepository
+	validator  internal.IValidator[ProductParams]
+	logger     *slog.Logger
 }
 
-// Used for testing
-func (c *ProductController) SetRepository(repo IProductRepository) {
-	c.repository = repo
-}
-
-func NewProductController() *ProductController {
-	return &ProductController{repository: NewMySQLproductRepository()}
+func NewProductController(repository IProductRepository, validator internal.IValidator[ProductParams], logger *slog.Logger) *ProductController {
+	return &ProductController{repository: repository, validator: validator, logger: logger}
 }
 
 func (c *ProductController) Create(w http.ResponseWriter, r *http.Request) {
 	var productParam ProductParams
 	err := json.NewDecoder(r.Body).Decode(&productParam)
 	if err != nil {
-		fmt.Println(err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		response.BadRequest(w, r, "invalid request body: "+err.Error())
 		return
 	}
 
 	if err := c.validator.Validate(productParam); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		response.Error(w, r, c.logger, err)
 		return
 	}
 
-	createdProduct, err := c.repository.Create(productParam)
+	createdProduct, err := c.repository.Create(r.Context(), productParam)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		response.Error(w, r, c.logger, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-
-	if err := json.NewEncoder(w).Encode(createdProduct.ToDTO()); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
+	response.JSON(w, http.StatusCreated, createdProduct.ToDTO())
 }
 
 func (c *ProductController) GetAll(w http.ResponseWriter, r *http.Request) {
-	var productParams ProductParams
 	queryParams := r.URL.Query()
 
-	// First it checks to see if the values in the querystring are valid
-	for key := range queryParams {
-		if strings.ToLower(key) != "isactive" &&
-			strings.ToLower(key) != "isdeleted" &&
-			strings.ToLower(key) != "createdAt" &&
-			strings.ToLower(key) != "weightgrams" &&
-			strings.ToLower(key) != "price" &&
-			strings.ToLower(key) != "name" {
-			http.Error(w, fmt.Sprintf("Invalid query parameter: %s", key), http.StatusBadRequest)
-			return
-		}
-	}
-
-	// If the values are valid it will check what each value is
-	if isActive := queryParams.Get("IsActive"); isActive != "" {
-		isActiveValue, err := strconv.ParseBool(isActive)
-		if err == nil {
-			*productParams.IsActive = isActiveValue
-		}
-	}
-
-	if isDeleted := queryParams.Get("IsDeleted"); isDeleted != "" {
-		isDeletedValue, err := strconv.ParseBool(isDeleted)
-		if err == nil {
-			*productParams.IsDeleted = isDeletedValue
-		}
-	}
-
-	if createdAt := queryParams.Get("CreatedAt"); createdAt != "" {
-		*productParams.CreatedAt = createdAt
-	}
-
-	if weight := queryParams.Get("WeightGrams"); weight != "" {
-		weightValue, err := strconv.ParseFloat(weight, 32)
-		if err == nil {
-			*productParams.WeightGrams = float32(weightValue)
-		}
+	// The accepted fields and operators are declared in filterFields
+	where, err := filter.Parse(queryParams, filterFields)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
 	}
 
-	if price := queryParams.Get("Price"); price != "" {
-		priceValue, err := strconv.ParseFloat(price, 32)
-		if err == nil {
-			*productParams.Price = float32(priceValue)
-		}
+	page, err := pagination.Parse(queryParams, sortFields)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
 	}
 
-	if name := queryParams.Get("Name"); name != "" {
-		*productParams.Name = name
+	foundProducts, err := c.repository.GetAll(r.Context(), where, page)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
 	}
+	foundProducts, hasMore := pagination.Trim(page, foundProducts)
 
-	// NOTE: Why don't I just parse it from the json? Well, if the json is nil,
-	// then it will generate an error and if the json isn't but a field is,
-	// that is another possible error. This way, although repetitive, will make
-	// it simple to understand. Where as having multiple nested `if`s might not
-
-	// It now passes the product param as a "filter" and gets the found products
-	foundProducts, err := c.repository.GetAll(productParams)
+	total, err := c.repository.Count(r.Context(), where)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		response.Error(w, r, c.logger, err)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
 
 	dtoFoundProducts := []ProductDTO{}
 	for i := 0; i < len(foundProducts); i++ {
 		dtoFoundProducts = append(dtoFoundProducts, foundProducts[i].ToDTO())
 	}
-	// Returns the DTO products
-	if err := json.NewEncoder(w).Encode(dtoFoundProducts); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	var lastID string
+	if hasMore {
+		lastID = dtoFoundProducts[len(dtoFoundProducts)-1].Id
 	}
+	pagination.WriteHeaders(w, r, page, total, hasMore, lastID)
+	// Returns the DTO products
+	response.JSON(w, http.StatusOK, dtoFoundProducts)
 }
 
 func (c *ProductController) GetOne(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
-	product, err := c.repository.GetOne(id)
+	product, err := c.repository.GetOne(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound) // The Id was not found but the request did go though
-		return
-	}
-	if err := json.NewEncoder(w).Encode(product.ToDTO()); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		response.Error(w, r, c.logger, err)
 		return
 	}
+	response.JSON(w, http.StatusOK, product.ToDTO())
 }
 
 func (c *ProductController) DeleteAll(w http.ResponseWriter, r *http.Request) {
-	var productParams ProductParams
-	queryParams := r.URL.Query()
-
-	if isActive := queryParams.Get("IsActive"); isActive != "" {
-		isActiveValue, err := strconv.ParseBool(isActive)
-		if err == nil {
-			*productParams.IsActive = isActiveValue
-		}
-	}
-
-	if isDeleted := queryParams.Get("IsDeleted"); isDeleted != "" {
-		isDeletedValue, err := strconv.ParseBool(isDeleted)
-		if err == nil {
-			*productParams.IsDeleted = isDeletedValue
-		}
-	}
-
-	if weight := queryParams.Get("WeightGrams"); weight != "" {
-		weightValue, err := strconv.ParseFloat(weight, 32)
-		if err == nil {
-			*productParams.WeightGrams = float32(weightValue)
-		}
-	}
-
-	if price := queryParams.Get("Price"); price != "" {
-		priceValue, err := strconv.ParseFloat(price, 32)
-		if err == nil {
-			*productParams.Price = float32(priceValue)
-		}
-	}
-
-	if name := queryParams.Get("Name"); name != "" {
-		if productParams.Name == nil {
-			productParams.Name = new(string)
-		}
-		*productParams.Name = name
+	where, err := filter.Parse(r.URL.Query(), filterFields)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
 	}
 
-	count, err := c.repository.DeleteAll(productParams)
+	count, err := c.repository.DeleteAll(r.Context(), where)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		response.Error(w, r, c.logger, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(count); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
+	response.JSON(w, http.StatusOK, count)
 }
 
 func (c *ProductController) DeleteOne(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
-	count, err := c.repository.DeleteOne(id)
+	count, err := c.repository.DeleteOne(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound) // Did go through, none found
+		response.Error(w, r, c.logger, err)
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(count); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
+	response.JSON(w, http.StatusOK, count)
 }
 
 func (c *ProductController) Update(w http.ResponseWriter, r *http.Request) {
@@ -215,17 +126,36 @@ func (c *ProductController) Update(w http.ResponseWriter, r *http.Request) {
 	var productParams ProductParams
 	err := json.NewDecoder(r.Body).Decode(&productParams)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		response.BadRequest(w, r, "invalid request body: "+err.Error())
 		return
 	}
-	product, err := c.repository.Update(id, productParams)
+	product, err := c.repository.Update(r.Context(), id, productParams)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound) // Did go through, none found
+		response.Error(w, r, c.logger, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, product.ToDTO())
+}
+
+func (c *ProductController) Restore(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	product, err := c.repository.Restore(r.Context(), id)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(product.ToDTO()); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	response.JSON(w, http.StatusOK, product.ToDTO())
+}
+
+func (c *ProductController) Purge(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	count, err := c.repository.Purge(r.Context(), id)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
 	}
+
+	response.JSON(w, http.StatusOK, count)
 }
@@ -1,23 +1,36 @@
 package product
 
+import (
+	"context"
+	"sipub-test/internal/filter"
+	"sipub-test/internal/pagination"
+)
+
 type IProductRepository interface {
 	// Returns the created product
-	Create(ProductParams) (ProductModel, error)
+	Create(ctx context.Context, params ProductParams) (ProductModel, error)
 
 	// Returns the found products
-	// NOTE: reusing the same type for a filter and a "constructor" is not
-	// ideal at all, but it will save on code repetition
-	GetAll(filter ProductParams) ([]ProductModel, error)
+	GetAll(ctx context.Context, where filter.Filter, page pagination.Page) ([]ProductModel, error)
+
+	// Returns how many rows match the filter, ignoring the page
+	Count(ctx context.Context, where filter.Filter) (uint, error)
 
 	// Returns the found product
-	GetOne(id string) (ProductModel, error)
+	GetOne(ctx context.Context, id string) (ProductModel, error)
 
-	// Returns amount of deleted products
-	DeleteOne(id string) (uint, error)
+	// Soft deletes, returns amount of deleted products
+	DeleteOne(ctx context.Context, id string) (uint, error)
 
-	// Returns amount of deleted products
-	DeleteAll(filter ProductParams) (uint, error)
+	// Soft deletes, returns amount of deleted products
+	DeleteAll(ctx context.Context, where filter.Filter) (uint, error)
 
 	// Returns the updated product
-	Update(id string, newProduct ProductParams) (ProductModel, error)
+	Update(ctx context.Context, id string, newProduct ProductParams) (ProductModel, error)
+
+	// Undoes a soft delete, returns the restored product
+	Restore(ctx context.Context, id string) (ProductModel, error)
+
+	// Removes the row for good, whether it was soft deleted or not
+	Purge(ctx context.Context, id string) (uint, error)
 }
@@ -1,5 +1,10 @@
 package product
 
+import (
+	"sipub-test/pkg/money"
+	"sipub-test/pkg/timestamp"
+)
+
 // I know that there is a lot of code repetition, and there is a possibility of
 // just letting the main model to have all of it's fields public. This code
 // repeats itself often because of the no inheritance that golang provides, not
@@ -13,18 +18,24 @@ type ProductParams struct {
 	IsDeleted   *bool
 	CreatedAt   *string
 	WeightGrams *float32
-	Price       *float32
+	Price       *money.Money
 	Name        *string
 }
 
 // This is what will be passed to user about the product model. Perhaps DTO
 // isn't the best name
 type ProductDTO struct {
-	Id          string  `json:"Id"`
-	CreatedAt   string  `json:"CreatedAt"`
-	WeightGrams float32 `json:"WeightGrams"`
-	Price       float32 `json:"Price"`
-	Name        string  `json:"Name"`
+	Id          string      `json:"Id"`
+	CreatedAt   string      `json:"CreatedAt"`
+	UpdatedAt   string      `json:"UpdatedAt"`
+	DeletedAt   *string     `json:"DeletedAt,omitempty"`
+	WeightGrams float32     `json:"WeightGrams"`
+	Price       money.Money `json:"Price"`
+	Name        string      `json:"Name"`
+
+	// Changed through restocks and confirmed deliveries, see internal/inventory
+	Stock          uint `json:"Stock"`
+	AvailableStock uint `json:"AvailableStock"` // Stock not reserved by a delivery
 }
 
 type ProductModel struct {
@@ -32,17 +43,25 @@ type ProductModel struct {
 	// inheritance. Explained in COMMENTS.md
 	id        string // ID will be a uuid
 	isActive  bool
-	isDeleted bool // Soft deletion
-	createdAt string
+	isDeleted bool            // Soft deletion
+	deletedAt *timestamp.Time // Set while soft deleted
+	createdAt timestamp.Time
+	updatedAt timestamp.Time // Changed by every update
 
 	// Weight and price per product
 	weightGrams float32
-	price       float32
+	price       money.Money
 	name        string
+
+	stock    uint
+	reserved uint // Reserved by checked out deliveries, not confirmed yet
 }
 
 func (p *ProductModel) ToDTO() ProductDTO {
-	dtoProduct := ProductDTO{Id: p.id, CreatedAt: p.createdAt, WeightGrams: p.weightGrams, Price: p.price, Name: p.name}
+	dtoProduct := ProductDTO{Id: p.id, CreatedAt: p.createdAt.String(), UpdatedAt: p.updatedAt.String(), DeletedAt: timestamp.Format(p.deletedAt), WeightGrams: p.weightGrams, Price: p.price, Name: p.name, Stock: p.stock}
+	if p.stock > p.reserved {
+		dtoProduct.AvailableStock = p.stock - p.reserved
+	}
 	return dtoProduct
 }
 
@@ -50,7 +69,7 @@ func (p *ProductModel) GetWeight() float32 {
 	return p.weightGrams
 }
 
-func (p *ProductModel) GetPrice() float32 {
+func (p *ProductModel) GetPrice() money.Money {
 	return p.price
 }
 
@@ -67,10 +86,10 @@ func (p *ProductModel) GetIsActive() bool {
 }
 
 func (p *ProductModel) SetWeight(newWeight float32) {
-	p.price = newWeight
+	p.weightGrams = newWeight
 }
 
-func (p *ProductModel) SetPrice(newPrice float32) {
+func (p *ProductModel) SetPrice(newPrice money.Money) {
 	p.price = newPrice
 }
 
@@ -1,66 +1,64 @@
 package product
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
-	"log"
 	"math"
 	"sipub-test/db"
+	"sipub-test/internal/filter"
+	"sipub-test/internal/pagination"
+	"sipub-test/pkg/apperror"
+	"sipub-test/pkg/clock"
 	"sipub-test/pkg/nilcheck"
-	"time"
+	"sipub-test/pkg/timestamp"
 
 	"github.com/google/uuid"
 )
 
 type MySQLProductRepository struct {
-	db *sql.DB
+	db    db.Executor
+	clock clock.Clock
 }
 
-// Mainly used for testing, but could be used elsewhere
-func (r *MySQLProductRepository) SetDB(db *sql.DB) { r.db = db }
-
-func (r *MySQLProductRepository) createNewProductTableIfNoneExists() {
-	r.db = db.GetDB()
-
-	createTableQuery := `
-	CREATE TABLE IF NOT EXISTS products (
-		id CHAR(36) NOT NULL,
-		isActive BOOLEAN NOT NULL DEFAULT TRUE,
-		isDeleted BOOLEAN NOT NULL DEFAULT FALSE,
-        createdAt CHAR(19) NOT NULL,
-		weightGrams FLOAT NOT NULL,
-		price FLOAT NOT NULL,
-		name VARCHAR(255) NOT NULL,
-		PRIMARY KEY (id)
-	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`
-	// https://dev.mysql.com/doc/refman/8.4/en/innodb-benefits.html
-	// Mainly using InnoDB because it supports foreing keys
-	// createdAt is a string because it is simpler to handle. It uses this format 2006-01-02 15:04:05 (19 chars)
-
-	if _, err := r.db.Exec(createTableQuery); err != nil {
-		log.Fatalf("Failed to create table: %v", err)
-	}
+func NewMySQLProductRepository(db db.Executor, clock clock.Clock) *MySQLProductRepository {
+	return &MySQLProductRepository{db: db, clock: clock}
+}
+
+// Fields the list can be filtered and deleted by
+var filterFields = filter.Schema{
+	"IsActive":    {Column: "isActive", Type: filter.Bool, Operators: []filter.Operator{filter.In}},
+	"IsDeleted":   {Column: "isDeleted", Type: filter.Bool, Operators: []filter.Operator{filter.Eq}},
+	"CreatedAt":   {Column: "createdAt", Type: filter.Time, Operators: filter.TimeOperators},
+	"Name":        {Column: "name", Type: filter.String, Operators: []filter.Operator{filter.Like, filter.Eq}},
+	"Price":       {Column: "price", Type: filter.Money, Operators: filter.NumberOperators},
+	"WeightGrams": {Column: "weightGrams", Type: filter.Number, Operators: filter.NumberOperators},
+	"Stock":       {Column: "stock", Type: filter.Integer, Operators: filter.IntegerOperators},
 }
 
-func NewMySQLproductRepository() *MySQLProductRepository {
-	repo := &MySQLProductRepository{db: db.GetDB()}
-	repo.createNewProductTableIfNoneExists()
-	return repo
+// Columns the list can be sorted by
+var sortFields = pagination.Fields{
+	Table:    "products",
+	IDColumn: "id",
+	Columns: map[string]string{
+		"Name":        "name",
+		"Price":       "price",
+		"WeightGrams": "weightGrams",
+		"CreatedAt":   "createdAt",
+	},
+	Default: []pagination.Sort{{Field: "CreatedAt", Column: "createdAt", Desc: true}},
 }
 
-func (r *MySQLProductRepository) Create(params ProductParams) (ProductModel, error) {
+func (r *MySQLProductRepository) Create(ctx context.Context, params ProductParams) (ProductModel, error) {
 	id := uuid.NewString()
 
-	// Round price to 2 decimal places, if not, there will be floating number
-	// innacuracy
-	price := math.Round(float64(*params.Price)*100) / 100.0
-	timeCreated := time.Now().Format("2006-01-02 15:04:05")
+	timeCreated := timestamp.New(r.clock.Now())
 
-	query := `INSERT INTO products (id, isActive, isDeleted, createdAt, weightGrams, price, name) VALUES (?, ?, ?, ?, ?, ?, ?)`
-	_, err := r.db.Exec(query, id, *params.IsActive, *params.IsDeleted, timeCreated, *params.WeightGrams, price, *params.Name)
+	query := `INSERT INTO products (id, isActive, isDeleted, createdAt, updatedAt, weightGrams, price, name) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := r.db.ExecContext(ctx, query, id, *params.IsActive, *params.IsDeleted, timeCreated, timeCreated, *params.WeightGrams, *params.Price, *params.Name)
 	if err != nil {
-		return ProductModel{}, fmt.Errorf("failed to create product: %w", err)
+		return ProductModel{}, fmt.Errorf("failed to create product: %w", db.TranslateError(err))
 	}
 
 	return ProductModel{
@@ -68,38 +66,19 @@ func (r *MySQLProductRepository) Create(params ProductParams) (ProductModel, err
 		isActive:    *params.IsActive,
 		isDeleted:   *params.IsDeleted,
 		createdAt:   timeCreated,
+		updatedAt:   timeCreated,
 		weightGrams: *params.WeightGrams,
-		price:       float32(price),
+		price:       *params.Price,
 		name:        *params.Name,
 	}, nil
 }
 
-func (r *MySQLProductRepository) GetAll(filter ProductParams) ([]ProductModel, error) {
-	query := `SELECT id, isActive, isDeleted, createdAt, weightGrams, price, name FROM products WHERE 1=1`
-	args := []interface{}{}
-
-	if filter.IsActive != nil {
-		query += " AND isActive = ?"
-		args = append(args, *filter.IsActive)
-	}
-	if filter.IsDeleted != nil {
-		query += " AND isDeleted = ?"
-		args = append(args, *filter.IsDeleted)
-	}
-	if filter.WeightGrams != nil {
-		query += " AND weightGrams = ?"
-		args = append(args, *filter.WeightGrams)
-	}
-	if filter.Price != nil {
-		query += " AND price = ?"
-		args = append(args, *filter.Price)
-	}
-	if filter.Name != nil {
-		query += " AND name LIKE ?"
-		args = append(args, "%"+*filter.Name+"%")
-	}
+func (r *MySQLProductRepository) GetAll(ctx context.Context, where filter.Filter, page pagination.Page) ([]ProductModel, error) {
+	conditions, args := where.SQL()
+	query := `SELECT id, isActive, isDeleted, createdAt, updatedAt, weightGrams, price, name, deletedAt, stock, reserved FROM products WHERE 1=1` + conditions
+	query, args = sortFields.Apply(page, query, args)
 
-	rows, err := r.db.Query(query, args...)
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get products: %w", err)
 	}
@@ -108,7 +87,7 @@ func (r *MySQLProductRepository) GetAll(filter ProductParams) ([]ProductModel, e
 	var products []ProductModel
 	for rows.Next() {
 		var product ProductModel
-		if err := rows.Scan(&product.id, &product.isActive, &product.isDeleted, &product.createdAt, &product.weightGrams, &product.price, &product.name); err != nil {
+		if err := rows.Scan(&product.id, &product.isActive, &product.isDeleted, &product.createdAt, &product.updatedAt, &product.weightGrams, &product.price, &product.name, &product.deletedAt, &product.stock, &product.reserved); err != nil {
 			return nil, fmt.Errorf("failed to scan product: %w", err)
 		}
 		products = append(products, product)
@@ -117,79 +96,103 @@ func (r *MySQLProductRepository) GetAll(filter ProductParams) ([]ProductModel, e
 	return products, nil
 }
 
-func (r *MySQLProductRepository) GetOne(id string) (ProductModel, error) {
-	query := `SELECT id, isActive, isDeleted, createdAt, weightGrams, price, name FROM products WHERE id = ?`
+func (r *MySQLProductRepository) Count(ctx context.Context, where filter.Filter) (uint, error) {
+	conditions, args := where.SQL()
+	query := `SELECT COUNT(*) FROM products WHERE 1=1` + conditions
+
+	var count uint
+	if err := r.db.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count products: %w", err)
+	}
+	return count, nil
+}
+
+func (r *MySQLProductRepository) GetOne(ctx context.Context, id string) (ProductModel, error) {
+	query := `SELECT id, isActive, isDeleted, createdAt, updatedAt, weightGrams, price, name, stock, reserved FROM products WHERE id = ? AND isDeleted = FALSE`
 	var product ProductModel
-	row := r.db.QueryRow(query, id)
-	if err := row.Scan(&product.id, &product.isActive, &product.isDeleted, &product.createdAt, &product.weightGrams, &product.price, &product.name); err != nil {
+	row := r.db.QueryRowContext(ctx, query, id)
+	if err := row.Scan(&product.id, &product.isActive, &product.isDeleted, &product.createdAt, &product.updatedAt, &product.weightGrams, &product.price, &product.name, &product.stock, &product.reserved); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return ProductModel{}, fmt.Errorf("product not found")
+			return ProductModel{}, fmt.Errorf("product %w", apperror.ErrNotFound)
 		}
 		return ProductModel{}, fmt.Errorf("failed to get product: %w", err)
 	}
 	return product, nil
 }
 
-func (r *MySQLProductRepository) DeleteOne(id string) (uint, error) {
-	query := `DELETE FROM products WHERE id = ?`
-	res, err := r.db.Exec(query, id)
+func (r *MySQLProductRepository) DeleteOne(ctx context.Context, id string) (uint, error) {
+	deletedAt := timestamp.New(r.clock.Now())
+	query := `UPDATE products SET isDeleted = TRUE, deletedAt = ? WHERE id = ? AND isDeleted = FALSE`
+	res, err := r.db.ExecContext(ctx, query, deletedAt, id)
 	if err != nil {
-		return 0, fmt.Errorf("failed to delete product: %w", err)
+		return 0, fmt.Errorf("failed to delete product: %w", db.TranslateError(err))
 	}
 	count, _ := res.RowsAffected()
 	if count == 0 {
-		return 0, fmt.Errorf("failed to delete product: %w", err)
+		return 0, fmt.Errorf("product %w", apperror.ErrNotFound)
 	}
 	return uint(count), nil
 }
 
-func (r *MySQLProductRepository) DeleteAll(filter ProductParams) (uint, error) {
-	query := `DELETE FROM products WHERE 1=1`
-	args := []interface{}{}
-
-	if filter.IsActive != nil {
-		query += " AND isActive = ?"
-		args = append(args, filter.IsActive)
-	}
-	if filter.IsDeleted != nil {
-		query += " AND isDeleted = ?"
-		args = append(args, filter.IsDeleted)
-	}
-	if filter.Name != nil {
-		query += " AND name LIKE ?"
-		args = append(args, "%"+*filter.Name+"%")
-	}
+func (r *MySQLProductRepository) DeleteAll(ctx context.Context, where filter.Filter) (uint, error) {
+	conditions, args := where.SQL()
+	query := `UPDATE products SET isDeleted = TRUE, deletedAt = ? WHERE 1=1` + conditions
+	args = append([]interface{}{timestamp.New(r.clock.Now())}, args...)
 
-	res, err := r.db.Exec(query, args...)
+	res, err := r.db.ExecContext(ctx, query, args...)
 	if err != nil {
-		return 0, fmt.Errorf("failed to delete products: %w", err)
+		return 0, fmt.Errorf("failed to delete products: %w", db.TranslateError(err))
 	}
 	count, _ := res.RowsAffected()
 	return uint(count), nil
 }
 
-func (r *MySQLProductRepository) Update(id string, newProduct ProductParams) (ProductModel, error) {
-	previousProduct, err := r.GetOne(id)
+func (r *MySQLProductRepository) Update(ctx context.Context, id string, newProduct ProductParams) (ProductModel, error) {
+	previousProduct, err := r.GetOne(ctx, id)
 	if err != nil {
 		return ProductModel{}, err
 	}
 	// This will check nil arguments and change only the non-nil ones
 	updatedProduct := ProductModel{
 		isActive:    nilcheck.NotNilBool(newProduct.IsActive, previousProduct.isActive),
-		isDeleted:   nilcheck.NotNilBool(newProduct.IsDeleted, previousProduct.isDeleted),
 		weightGrams: nilcheck.NotNilFloat32(newProduct.WeightGrams, previousProduct.weightGrams),
-		price:       nilcheck.NotNilFloat32(newProduct.Price, previousProduct.price),
+		price:       nilcheck.NotNilMoney(newProduct.Price, previousProduct.price),
 		name:        nilcheck.NotNilString(newProduct.Name, previousProduct.name),
 	}
-	// Remove floating point innacuracy
+	// Remove floating point innacuracy, the price already is in cents
 	roundedWeight := math.Round(float64(updatedProduct.weightGrams)*100) / 100
-	roundedPrice := math.Round(float64(updatedProduct.price)*100) / 100
 
-	query := `UPDATE products SET isActive = ?, isDeleted = ?, weightGrams = ?, price = ?, name = ? WHERE id = ?`
+	query := `UPDATE products SET isActive = ?, weightGrams = ?, price = ?, name = ?, updatedAt = ? WHERE id = ? AND isDeleted = FALSE`
+
+	_, err = r.db.ExecContext(ctx, query, updatedProduct.isActive, roundedWeight, updatedProduct.price, updatedProduct.name, timestamp.New(r.clock.Now()), id)
+	if err != nil {
+		return ProductModel{}, fmt.Errorf("failed to update product: %w", db.TranslateError(err))
+	}
+	return r.GetOne(ctx, id)
+}
 
-	_, err = r.db.Exec(query, updatedProduct.isActive, updatedProduct.isDeleted, roundedWeight, roundedPrice, updatedProduct.name, id)
+func (r *MySQLProductRepository) Restore(ctx context.Context, id string) (ProductModel, error) {
+	query := `UPDATE products SET isDeleted = FALSE, deletedAt = NULL WHERE id = ? AND isDeleted = TRUE`
+	res, err := r.db.ExecContext(ctx, query, id)
 	if err != nil {
-		return ProductModel{}, fmt.Errorf("failed to update product: %w", err)
+		return ProductModel{}, fmt.Errorf("failed to restore product: %w", db.TranslateError(err))
 	}
-	return r.GetOne(id)
+	count, _ := res.RowsAffected()
+	if count == 0 {
+		return ProductModel{}, fmt.Errorf("deleted product %w", apperror.ErrNotFound)
+	}
+	return r.GetOne(ctx, id)
+}
+
+func (r *MySQLProductRepository) Purge(ctx context.Context, id string) (uint, error) {
+	query := `DELETE FROM products WHERE id = ?`
+	res, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge product: %w", db.TranslateError(err))
+	}
+	count, _ := res.RowsAffected()
+	if count == 0 {
+		return 0, fmt.Errorf("product %w", apperror.ErrNotFound)
+	}
+	return uint(count), nil
 }
@@ -1,14 +1,25 @@
 package product_test
 
 import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
 	"fmt"
 	"math"
 	"regexp"
+	"sipub-test/internal/filter"
+	"sipub-test/internal/pagination"
 	"sipub-test/internal/product"
+	"sipub-test/pkg/apperror"
+	"sipub-test/pkg/clock"
+	"sipub-test/pkg/money"
 	testhelper "sipub-test/pkg/test_helper"
+	"sipub-test/pkg/timestamp"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -20,27 +31,30 @@ func TestCreateProduct(t *testing.T) {
 		}
 		defer db.Close()
 
-		repo := &product.MySQLProductRepository{}
-		repo.SetDB(db)
+		// The clock is frozen so the creation time can be checked
+		now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+		repo := product.NewMySQLProductRepository(db, clock.Fixed(now))
 
 		params := product.ProductParams{
 			IsActive:    testhelper.BoolPointer(true),
 			IsDeleted:   testhelper.BoolPointer(false),
 			WeightGrams: testhelper.FloatPointer(100),
-			Price:       testhelper.FloatPointer(19.99),
+			Price:       testhelper.MoneyPointer(money.FromCents(1999)),
 			Name:        testhelper.StringPointer("Test Product"),
 		}
 
 		mock.ExpectExec(`INSERT INTO products`).
-			WithArgs(sqlmock.AnyArg() /* id determined at function */, true, false, sqlmock.AnyArg() /*time determined at function*/, 100.0, 19.99, "Test Product").
+			WithArgs(sqlmock.AnyArg() /* id determined at function */, true, false, timestamp.New(now), timestamp.New(now), 100.0, money.FromCents(1999), "Test Product").
 			WillReturnResult(sqlmock.NewResult(1, 1))
 
-		product, err := repo.Create(params)
+		product, err := repo.Create(context.Background(), params)
 
 		assert.NoError(t, err, "Shouldn't contain any errors")
 		// Won't check for id since it is created in the repository
 		assert.Equal(t, *params.IsActive, product.GetIsActive())
 		assert.Equal(t, *params.Price, product.GetPrice())
+		assert.Equal(t, "2025-01-15T12:00:00Z", product.ToDTO().CreatedAt)
+		assert.Equal(t, "2025-01-15T12:00:00Z", product.ToDTO().UpdatedAt, "UpdatedAt should start at CreatedAt")
 	})
 }
 
@@ -52,18 +66,17 @@ func TestGetAllProducts(t *testing.T) {
 		}
 		defer db.Close()
 
-		repo := &product.MySQLProductRepository{}
-		repo.SetDB(db)
+		repo := product.NewMySQLProductRepository(db, clock.System{})
 
 		// Setting the id to 123 is unreallistic but it works for a testing environment
-		rows := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "weightGrams", "price", "name"}).
-			AddRow("123", true, false, "2025-01-15 12:00:00", 100.0, 19.99, "Test Product")
+		rows := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "updatedAt", "weightGrams", "price", "name", "deletedAt", "stock", "reserved"}).
+			AddRow("123", true, false, "2025-01-15 12:00:00", "2025-01-15 12:00:00", 100.0, 19.99, "Test Product", nil, 10, 0)
 
-		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, weightGrams, price, name FROM products`).
+		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, updatedAt, weightGrams, price, name, deletedAt, stock, reserved FROM products`).
 			WillReturnRows(rows)
 
-		filter := product.ProductParams{}
-		products, err := repo.GetAll(filter)
+		where := filter.Filter{}
+		products, err := repo.GetAll(context.Background(), where, pagination.Page{})
 
 		assert.NoError(t, err, "Should have no errors")
 		assert.Len(t, products, 1, "Lenght should be 1")
@@ -76,23 +89,118 @@ func TestGetAllProducts(t *testing.T) {
 		}
 		defer db.Close()
 
-		repo := &product.MySQLProductRepository{}
-		repo.SetDB(db)
+		repo := product.NewMySQLProductRepository(db, clock.System{})
 		// Create one with weight 100
-		sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "weightGrams", "price", "name"}).
-			AddRow("123", true, false, "2025-01-15 12:00:00", 100.0, 19.99, "Test Product")
+		sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "updatedAt", "weightGrams", "price", "name", "deletedAt", "stock", "reserved"}).
+			AddRow("123", true, false, "2025-01-15 12:00:00", "2025-01-15 12:00:00", 100.0, 19.99, "Test Product", nil, 10, 0)
 
 			// Should return "failed to get products"
-		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, weightGrams, price, name FROM products`).
+		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, updatedAt, weightGrams, price, name, deletedAt, stock, reserved FROM products`).
 			WillReturnError(fmt.Errorf("failed to get products"))
 
 		// Will search for one with weight 10 and should return 0 found
-		filter := product.ProductParams{WeightGrams: testhelper.FloatPointer(10)}
-		products, err := repo.GetAll(filter)
+		where := filter.Filter{Conditions: []filter.Condition{
+			{Field: "WeightGrams", Column: "weightGrams", Type: filter.Number, Operator: filter.Eq, Values: []interface{}{10.0}},
+		}}
+		products, err := repo.GetAll(context.Background(), where, pagination.Page{})
 
 		assert.Error(t, err, "Should have no errors")
 		assert.Len(t, products, 0, "Lenght should be 0")
 	})
+	t.Run("ShouldStopWhenContextIsCancelled", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := product.NewMySQLProductRepository(db, clock.System{})
+
+		// The query takes longer than the request is willing to wait
+		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, updatedAt, weightGrams, price, name, deletedAt, stock, reserved FROM products`).
+			WillDelayFor(time.Second).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "updatedAt", "weightGrams", "price", "name", "deletedAt", "stock", "reserved"}))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		products, err := repo.GetAll(ctx, filter.Filter{}, pagination.Page{})
+
+		assert.Error(t, err, "The deadline should cancel the query")
+		assert.Len(t, products, 0, "Lenght should be 0")
+	})
+	t.Run("ShouldApplyThePage", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := product.NewMySQLProductRepository(db, clock.System{})
+
+		mock.ExpectQuery(regexp.QuoteMeta(`FROM products WHERE 1=1 AND isActive IN (?) ORDER BY price DESC, id ASC LIMIT ? OFFSET ?`)).
+			WithArgs(true, 11, 20).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "updatedAt", "weightGrams", "price", "name", "deletedAt", "stock", "reserved"}))
+
+		page := pagination.Page{Limit: 10, Offset: 20, Sort: []pagination.Sort{{Field: "Price", Column: "price", Desc: true}}}
+		where := filter.Filter{Conditions: []filter.Condition{
+			{Field: "IsActive", Column: "isActive", Type: filter.Bool, Operator: filter.In, Values: []interface{}{true}},
+		}}
+		_, err = repo.GetAll(context.Background(), where, page)
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("ShouldFilterByRanges", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := product.NewMySQLProductRepository(db, clock.System{})
+		january := timestamp.New(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+		february := timestamp.New(time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC))
+
+		mock.ExpectQuery(regexp.QuoteMeta(`FROM products WHERE 1=1 AND isActive IN (?, ?) AND weightGrams > ? AND price >= ? AND price <= ? AND createdAt > ? AND createdAt < ?`)).
+			WithArgs(true, false, 100.0, money.FromCents(1000), money.FromCents(5000), january, february).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "updatedAt", "weightGrams", "price", "name", "deletedAt", "stock", "reserved"}))
+
+		where := filter.Filter{Conditions: []filter.Condition{
+			{Field: "IsActive", Column: "isActive", Type: filter.Bool, Operator: filter.In, Values: []interface{}{true, false}},
+			{Field: "WeightGrams", Column: "weightGrams", Type: filter.Number, Operator: filter.Gt, Values: []interface{}{100.0}},
+			{Field: "Price", Column: "price", Type: filter.Money, Operator: filter.Gte, Values: []interface{}{money.FromCents(1000)}},
+			{Field: "Price", Column: "price", Type: filter.Money, Operator: filter.Lte, Values: []interface{}{money.FromCents(5000)}},
+			{Field: "CreatedAt", Column: "createdAt", Type: filter.Time, Operator: filter.After, Values: []interface{}{january}},
+			{Field: "CreatedAt", Column: "createdAt", Type: filter.Time, Operator: filter.Before, Values: []interface{}{february}},
+		}}
+		_, err = repo.GetAll(context.Background(), where, pagination.Page{})
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestCountProducts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock DB: %v", err)
+	}
+	defer db.Close()
+
+	repo := product.NewMySQLProductRepository(db, clock.System{})
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT COUNT(*) FROM products WHERE 1=1 AND name LIKE ?`)).
+		WithArgs("%Test%").
+		WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(42))
+
+	where := filter.Filter{Conditions: []filter.Condition{
+		{Field: "Name", Column: "name", Type: filter.String, Operator: filter.Like, Values: []interface{}{"Test"}},
+	}}
+	count, err := repo.Count(context.Background(), where)
+
+	assert.NoError(t, err)
+	assert.EqualValues(t, 42, count)
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 func TestGetProductByID(t *testing.T) {
@@ -102,29 +210,27 @@ func TestGetProductByID(t *testing.T) {
 	}
 	defer db.Close()
 
-	repo := &product.MySQLProductRepository{}
-	repo.SetDB(db)
+	repo := product.NewMySQLProductRepository(db, clock.System{})
 
 	// Setting the id to 123 is unreallistic but it works for a testing environment
-	rows := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "weightGrams", "price", "name"}).
-		AddRow("123", true, false, "2025-01-15 12:00:00", 100.0, 19.99, "Test Product")
+	rows := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "updatedAt", "weightGrams", "price", "name", "stock", "reserved"}).
+		AddRow("123", true, false, "2025-01-15 12:00:00", "2025-01-15 12:00:00", 100.0, []byte("19.99"), "Test Product", 10, 0) // DECIMAL comes as bytes
 
-	mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, weightGrams, price, name FROM products WHERE id = ?`).
+	mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, updatedAt, weightGrams, price, name, stock, reserved FROM products WHERE id = ?`).
 		WithArgs("123").
 		WillReturnRows(rows)
 
-	product, err := repo.GetOne("123")
+	product, err := repo.GetOne(context.Background(), "123")
 
 	// Rounded to fix floating point innacuracy
 	roundedWeight := math.Round(float64(product.ToDTO().WeightGrams)*100) / 100
-	roundedPrice := math.Round(float64(product.ToDTO().Price)*100) / 100
 
 	assert.NoError(t, err, "Should have no errors")
 	assert.Equal(t, "123", product.ToDTO().Id, "Id should be the same")
 	assert.Equal(t, true, product.GetIsActive(), "IsActive should be the same")
-	assert.Equal(t, "2025-01-15 12:00:00", product.ToDTO().CreatedAt, "Date created should be the same")
+	assert.Equal(t, "2025-01-15T12:00:00Z", product.ToDTO().CreatedAt, "Date created should be RFC 3339")
 	assert.Equal(t, 100.0, roundedWeight, "Weigth should be the same")
-	assert.Equal(t, 19.99, roundedPrice, "Price should be the same")
+	assert.Equal(t, "19.99", product.ToDTO().Price.String(), "Price should be exact")
 }
 
 func TestDeleteProduct(t *testing.T) {
@@ -134,14 +240,105 @@ func TestDeleteProduct(t *testing.T) {
 	}
 	defer db.Close()
 
-	repo := &product.MySQLProductRepository{}
-	repo.SetDB(db)
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	repo := product.NewMySQLProductRepository(db, clock.Fixed(now))
 
-	mock.ExpectExec(`DELETE FROM products WHERE id = ?`).
-		WithArgs("123").
+	// The row is only flagged, Purge is what removes it
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE products SET isDeleted = TRUE, deletedAt = ? WHERE id = ? AND isDeleted = FALSE`)).
+		WithArgs(timestamp.New(now), "123").
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	count, err := repo.DeleteOne("123")
+	count, err := repo.DeleteOne(context.Background(), "123")
+
+	assert.NoError(t, err)
+	assert.Equal(t, uint(1), count)
+}
+
+func TestDeleteAllProducts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock DB: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	repo := product.NewMySQLProductRepository(db, clock.Fixed(now))
+
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE products SET isDeleted = TRUE, deletedAt = ? WHERE 1=1 AND name LIKE ? AND isDeleted = ?`)).
+		WithArgs(timestamp.New(now), "%Test%", false).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	// The same conditions filter.Parse builds for ?Name=Test
+	where := filter.Filter{Conditions: []filter.Condition{
+		{Field: "Name", Column: "name", Type: filter.String, Operator: filter.Like, Values: []interface{}{"Test"}},
+		{Field: "IsDeleted", Column: "isDeleted", Type: filter.Bool, Operator: filter.Eq, Values: []interface{}{false}},
+	}}
+	count, err := repo.DeleteAll(context.Background(), where)
+
+	assert.NoError(t, err)
+	assert.Equal(t, uint(3), count)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRestoreProduct(t *testing.T) {
+	t.Run("ShouldClearTheDeletion", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := product.NewMySQLProductRepository(db, clock.System{})
+
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE products SET isDeleted = FALSE, deletedAt = NULL WHERE id = ? AND isDeleted = TRUE`)).
+			WithArgs("123").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(regexp.QuoteMeta(`FROM products WHERE id = ? AND isDeleted = FALSE`)).
+			WithArgs("123").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "updatedAt", "weightGrams", "price", "name", "stock", "reserved"}).
+				AddRow("123", true, false, "2025-01-15 12:00:00", "2025-01-15 12:00:00", 100.0, 19.99, "Test Product", 10, 0))
+
+		restored, err := repo.Restore(context.Background(), "123")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "123", restored.ToDTO().Id)
+		assert.Nil(t, restored.ToDTO().DeletedAt)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("ShouldReturnNotFoundIfItWasntDeleted", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := product.NewMySQLProductRepository(db, clock.System{})
+
+		mock.ExpectExec(`UPDATE products SET isDeleted = FALSE`).
+			WithArgs("123").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		_, err = repo.Restore(context.Background(), "123")
+
+		assert.ErrorIs(t, err, apperror.ErrNotFound)
+	})
+}
+
+func TestPurgeProduct(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock DB: %v", err)
+	}
+	defer db.Close()
+
+	repo := product.NewMySQLProductRepository(db, clock.System{})
+
+	// Deleted or not, the row is removed
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM products WHERE id = ?`)).
+		WithArgs("123").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	count, err := repo.Purge(context.Background(), "123")
 
 	assert.NoError(t, err)
 	assert.Equal(t, uint(1), count)
@@ -155,44 +352,43 @@ func TestUpdateProduct(t *testing.T) {
 		}
 		defer db.Close()
 
-		repo := &product.MySQLProductRepository{}
-		repo.SetDB(db)
+		repo := product.NewMySQLProductRepository(db, clock.System{})
 
 		newParams := product.ProductParams{
 			IsActive:    testhelper.BoolPointer(false),
 			IsDeleted:   testhelper.BoolPointer(true),
 			WeightGrams: testhelper.FloatPointer(200),
-			Price:       testhelper.FloatPointer(29.99),
+			Price:       testhelper.MoneyPointer(money.FromCents(2999)),
 			Name:        testhelper.StringPointer("Updated Product"),
 		}
 
 		// Create a new row
-		rows := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "weightGrams", "price", "name"}).
-			AddRow("123", true, false, "2025-01-15 12:00:00", 150.0, 19.99, "Original Product")
+		rows := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "updatedAt", "weightGrams", "price", "name", "stock", "reserved"}).
+			AddRow("123", true, false, "2025-01-15 12:00:00", "2025-01-15 12:00:00", 150.0, 19.99, "Original Product", 10, 0)
 
 		// Initial SELECT for GetOne
-		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, weightGrams, price, name FROM products WHERE id = ?`).
+		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, updatedAt, weightGrams, price, name, stock, reserved FROM products WHERE id = ?`).
 			WithArgs("123").
 			WillReturnRows(rows)
 
-		// UPDATE query
-		mock.ExpectExec(regexp.QuoteMeta(`UPDATE products SET isActive = ?, isDeleted = ?, weightGrams = ?, price = ?, name = ? WHERE id = ?`)).
-			WithArgs(false, true, 200.0, 29.99, "Updated Product", "123").
+		// UPDATE query, IsDeleted is left to DeleteOne and Restore
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE products SET isActive = ?, weightGrams = ?, price = ?, name = ?, updatedAt = ? WHERE id = ? AND isDeleted = FALSE`)).
+			WithArgs(false, 200.0, money.FromCents(2999), "Updated Product", sqlmock.AnyArg(), "123").
 			WillReturnResult(sqlmock.NewResult(1, 1))
 
 		// Final SELECT for updated product
-		updatedRows := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "weightGrams", "price", "name"}).
-			AddRow("123", false, true, "2025-01-15 12:00:00", 200.0, 29.99, "Updated Product")
+		updatedRows := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "updatedAt", "weightGrams", "price", "name", "stock", "reserved"}).
+			AddRow("123", false, false, "2025-01-15 12:00:00", "2025-01-15 12:00:00", 200.0, 29.99, "Updated Product", 10, 0)
 
-		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, weightGrams, price, name FROM products WHERE id = ?`).
+		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, updatedAt, weightGrams, price, name, stock, reserved FROM products WHERE id = ?`).
 			WithArgs("123").
 			WillReturnRows(updatedRows)
 
-		product, err := repo.Update("123", newParams)
+		product, err := repo.Update(context.Background(), "123", newParams)
 
 		assert.NoError(t, err, "Should contain no errors")
 		assert.Equal(t, "123", product.ToDTO().Id, "Id should remain the same")
-		assert.Equal(t, true, product.GetIsDeleted(), "IsDeleted should be set to true")
+		assert.Equal(t, false, product.GetIsDeleted(), "Update shouldn't soft delete the product")
 	})
 	t.Run("Update with Null Params", func(t *testing.T) {
 		db, mock, err := sqlmock.New()
@@ -201,15 +397,14 @@ func TestUpdateProduct(t *testing.T) {
 		}
 		defer db.Close()
 
-		repo := &product.MySQLProductRepository{}
-		repo.SetDB(db)
+		repo := product.NewMySQLProductRepository(db, clock.System{})
 
 		// Creating an existing product that will be retrieved from the database
-		existingProduct := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "weightGrams", "price", "name"}).
-			AddRow("123", true, false, "2025-01-15 12:00:00", 100.0, 19.99, "Original Product")
+		existingProduct := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "updatedAt", "weightGrams", "price", "name", "stock", "reserved"}).
+			AddRow("123", true, false, "2025-01-15 12:00:00", "2025-01-15 12:00:00", 100.0, 19.99, "Original Product", 10, 0)
 
 		// Expect the `GetOne` call to return the existing product
-		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, weightGrams, price, name FROM products WHERE id = ?`).
+		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, updatedAt, weightGrams, price, name, stock, reserved FROM products WHERE id = ?`).
 			WithArgs("123").
 			WillReturnRows(existingProduct)
 
@@ -220,25 +415,119 @@ func TestUpdateProduct(t *testing.T) {
 		}
 
 		// Expect the `UPDATE` query with values including the updated fields and the unchanged fields
-		mock.ExpectExec(regexp.QuoteMeta(`UPDATE products SET isActive = ?, isDeleted = ?, weightGrams = ?, price = ?, name = ? WHERE id = ?`)).
-			WithArgs(false, false, 100.0, 19.99, "Partially Updated Product", "123").
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE products SET isActive = ?, weightGrams = ?, price = ?, name = ?, updatedAt = ? WHERE id = ? AND isDeleted = FALSE`)).
+			WithArgs(false, 100.0, money.FromCents(1999), "Partially Updated Product", sqlmock.AnyArg(), "123").
 			WillReturnResult(sqlmock.NewResult(1, 1))
 
 		// Expect the `GetOne` call after the update to return the updated product
-		updatedProduct := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "weightGrams", "price", "name"}).
-			AddRow("123", false, false, "2025-01-15 12:00:00", 100.0, 19.99, "Partially Updated Product")
+		updatedProduct := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "updatedAt", "weightGrams", "price", "name", "stock", "reserved"}).
+			AddRow("123", false, false, "2025-01-15 12:00:00", "2025-01-15 12:00:00", 100.0, 19.99, "Partially Updated Product", 10, 0)
 
-		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, weightGrams, price, name FROM products WHERE id = ?`).
+		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, updatedAt, weightGrams, price, name, stock, reserved FROM products WHERE id = ?`).
 			WithArgs("123").
 			WillReturnRows(updatedProduct)
 
-		product, err := repo.Update("123", newParams)
+		product, err := repo.Update(context.Background(), "123", newParams)
 
 		assert.NoError(t, err, "Should not fail when updating with partial fields")
 		assert.Equal(t, "123", product.ToDTO().Id, "Id should remain the same")
 		assert.Equal(t, false, product.GetIsActive(), "IsActive should match the updated value")
 		assert.Equal(t, "Partially Updated Product", product.ToDTO().Name, "Name should be updated")
-		assert.Equal(t, float32(19.99), product.ToDTO().Price, "Price should remain unchanged")
+		assert.Equal(t, money.FromCents(1999), product.ToDTO().Price, "Price should remain unchanged")
 		assert.Equal(t, float32(100.0), product.ToDTO().WeightGrams, "Weight should remain unchanged")
 	})
 }
+
+// The repository should return the apperror sentinels, so the controller can
+// pick the status without looking at the message
+func TestProductRepositoryErrors(t *testing.T) {
+	t.Run("ShouldReturnNotFoundOnMissingRow", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := product.NewMySQLProductRepository(db, clock.System{})
+		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, updatedAt, weightGrams, price, name, stock, reserved FROM products WHERE id = ?`).
+			WithArgs("123").
+			WillReturnError(sql.ErrNoRows)
+
+		_, err = repo.GetOne(context.Background(), "123")
+
+		assert.ErrorIs(t, err, apperror.ErrNotFound)
+	})
+	t.Run("ShouldNotReturnNotFoundOnOutage", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := product.NewMySQLProductRepository(db, clock.System{})
+		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, updatedAt, weightGrams, price, name, stock, reserved FROM products WHERE id = ?`).
+			WithArgs("123").
+			WillReturnError(driver.ErrBadConn)
+
+		_, err = repo.GetOne(context.Background(), "123")
+
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, apperror.ErrNotFound, "A broken connection isn't a missing product")
+	})
+	t.Run("ShouldReturnNotFoundWhenNothingWasDeleted", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := product.NewMySQLProductRepository(db, clock.System{})
+		// Missing or already deleted
+		mock.ExpectExec(`UPDATE products SET isDeleted = TRUE`).
+			WithArgs(sqlmock.AnyArg(), "123").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		count, err := repo.DeleteOne(context.Background(), "123")
+
+		assert.ErrorIs(t, err, apperror.ErrNotFound)
+		assert.Equal(t, uint(0), count)
+	})
+	t.Run("ShouldReturnForeignKeyWhenProductIsReferenced", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := product.NewMySQLProductRepository(db, clock.System{})
+		// Only a purge removes the row, so it is the one that can hit a foreign key
+		mock.ExpectExec(`DELETE FROM products WHERE id = ?`).
+			WithArgs("123").
+			WillReturnError(&mysql.MySQLError{Number: 1451, Message: "Cannot delete or update a parent row"})
+
+		_, err = repo.Purge(context.Background(), "123")
+
+		assert.ErrorIs(t, err, apperror.ErrForeignKey)
+	})
+	t.Run("ShouldReturnDuplicateOnCreate", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := product.NewMySQLProductRepository(db, clock.System{})
+		mock.ExpectExec(`INSERT INTO products`).
+			WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"})
+
+		_, err = repo.Create(context.Background(), product.ProductParams{
+			IsActive:    testhelper.BoolPointer(true),
+			IsDeleted:   testhelper.BoolPointer(false),
+			WeightGrams: testhelper.FloatPointer(100),
+			Price:       testhelper.MoneyPointer(money.FromCents(1999)),
+			Name:        testhelper.StringPointer("Test Product"),
+		})
+
+		assert.ErrorIs(t, err, apperror.ErrDuplicate)
+	})
+}
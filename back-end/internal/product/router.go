@@ -7,17 +7,13 @@ import (
 
 type ProductRouter struct {
 	baseEndPoint string
-	controller   internal.IController
+	controller   internal.ISoftDeleteController
 }
 
-// Ideally there should be a param to change the controller when needed, but
-// this will make the code bigger and I will not change the controller nor the
-// repository
-func NewProductRouter() ProductRouter {
-	router := ProductRouter{
-		controller: NewProductController(),
-	}
-	return router
+// The controller is built by main, so it can be swapped (by a fake in tests for
+// example) without touching the router
+func NewProductRouter(controller internal.ISoftDeleteController) ProductRouter {
+	return ProductRouter{controller: controller}
 }
 
 func (r ProductRouter) Init(mux *http.ServeMux) {
@@ -29,6 +25,8 @@ func (r ProductRouter) Init(mux *http.ServeMux) {
 	r.deleteAll(mux)
 	r.deleteOne(mux)
 	r.update(mux)
+	r.restore(mux)
+	r.purge(mux)
 }
 
 func (r ProductRouter) create(mux *http.ServeMux) {
@@ -55,3 +53,11 @@ func (r ProductRouter) deleteOne(mux *http.ServeMux) {
 func (r ProductRouter) update(mux *http.ServeMux) {
 	mux.HandleFunc("PUT "+r.baseEndPoint+"/{id}", r.controller.Update)
 }
+
+func (r ProductRouter) restore(mux *http.ServeMux) {
+	mux.HandleFunc("POST "+r.baseEndPoint+"/{id}/restore", r.controller.Restore)
+}
+
+func (r ProductRouter) purge(mux *http.ServeMux) {
+	mux.HandleFunc("DELETE "+r.baseEndPoint+"/{id}/purge", r.controller.Purge)
+}
@@ -1,44 +1,36 @@
 package product
 
 import (
-	"errors"
+	"sipub-test/pkg/apperror"
 	"strings"
 )
 
 type ProductValidator struct{}
 
 func (v *ProductValidator) Validate(params ProductParams) error {
-	/*
-		IsActive    *bool
-		IsDeleted   *bool // Soft deletion
-		WeightGrams *float32
-		CreatedAt   *string // TODO
-		Price       *float32
-		Name        *string
-	*/
 	if params.IsActive == nil {
-		return errors.New("IsActive is empty")
+		return apperror.NewValidationError("IsActive", "is required")
 	}
 	if params.IsDeleted == nil {
-		return errors.New("IsDeleted is empty")
+		return apperror.NewValidationError("IsDeleted", "is required")
 	}
 	if params.Name == nil {
-		return errors.New("Name is empty")
+		return apperror.NewValidationError("Name", "is required")
 	}
 	if strings.TrimSpace(*params.Name) == "" {
-		return errors.New("Name is empty")
+		return apperror.NewValidationError("Name", "can't be empty")
 	}
 	if params.WeightGrams == nil {
-		return errors.New("Weight is invalid")
+		return apperror.NewValidationError("WeightGrams", "is required")
 	}
 	if *params.WeightGrams <= 0 {
-		return errors.New("Weight is invalid")
+		return apperror.NewValidationError("WeightGrams", "must be greater than zero")
 	}
 	if params.Price == nil {
-		return errors.New("Price is invalid")
+		return apperror.NewValidationError("Price", "is required")
 	}
-	if *params.Price <= 0 {
-		return errors.New("Price is invalid")
+	if !params.Price.IsPositive() {
+		return apperror.NewValidationError("Price", "must be greater than zero")
 	}
 
 	return nil
@@ -0,0 +1,98 @@
+package refund
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sipub-test/internal"
+	"sipub-test/internal/middleware"
+	"sipub-test/internal/payment"
+	"sipub-test/internal/payment_gateway"
+	"sipub-test/internal/response"
+	"sipub-test/pkg/apperror"
+)
+
+type RefundController struct {
+	repository IRefundRepository
+	payments   payment.IPaymentRepository
+	gateway    payment_gateway.IPaymentGateway
+	validator  internal.IValidator[RefundParams]
+	logger     *slog.Logger
+}
+
+func NewRefundController(repository IRefundRepository, payments payment.IPaymentRepository, gateway payment_gateway.IPaymentGateway, validator internal.IValidator[RefundParams], logger *slog.Logger) *RefundController {
+	return &RefundController{repository: repository, payments: payments, gateway: gateway, validator: validator, logger: logger}
+}
+
+// Gives back part or all of a captured payment. The refund is reserved before
+// the gateway is called and only counts as given back once it accepts, the
+// payment is returned with it
+func (c *RefundController) Refund(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	var refundParams RefundParams
+	err := json.NewDecoder(r.Body).Decode(&refundParams)
+	if err != nil {
+		response.BadRequest(w, r, "invalid request body: "+err.Error())
+		return
+	}
+
+	if err := c.validator.Validate(refundParams); err != nil {
+		response.Error(w, r, c.logger, err)
+		return
+	}
+
+	found, err := c.payments.GetOne(r.Context(), id)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
+	}
+	dtoFound := found.ToDTO()
+	if dtoFound.Status != string(payment.StatusCaptured) {
+		response.Error(w, r, c.logger, fmt.Errorf("only captured payments can be refunded, payment is %s: %w", dtoFound.Status, apperror.ErrConflict))
+		return
+	}
+
+	amount := found.Refundable()
+	if refundParams.Amount != nil {
+		if refundParams.Amount.GreaterThan(amount) {
+			response.Error(w, r, c.logger, apperror.NewValidationError("Amount", fmt.Sprintf("can't be more than what is left of the payment (%s)", amount)))
+			return
+		}
+		amount = *refundParams.Amount
+	}
+
+	refundID, err := c.repository.Reserve(r.Context(), id, amount, refundParams)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
+	}
+	if err := c.gateway.Refund(r.Context(), *dtoFound.GatewayReference, amount); err != nil {
+		c.fail(r, refundID)
+		response.Error(w, r, c.logger, fmt.Errorf("failed to refund payment: %w", err))
+		return
+	}
+	if err := c.repository.Complete(r.Context(), refundID); err != nil {
+		response.Error(w, r, c.logger, err)
+		return
+	}
+
+	refunded, err := c.payments.GetOne(r.Context(), id)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
+	}
+	response.JSON(w, http.StatusCreated, refunded.ToDTO())
+}
+
+// The gateway already refused, failing to release the reservation only leaves
+// it pending
+func (c *RefundController) fail(r *http.Request, refundID string) {
+	if err := c.repository.Fail(r.Context(), refundID); err != nil {
+		c.logger.WarnContext(r.Context(), "failed to mark refund as failed",
+			"refundID", refundID,
+			"requestID", middleware.GetRequestID(r.Context()),
+			"error", err,
+		)
+	}
+}
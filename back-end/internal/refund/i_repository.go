@@ -0,0 +1,23 @@
+package refund
+
+import (
+	"context"
+	"sipub-test/pkg/money"
+)
+
+// A refund is reserved before the gateway is called, so the payment is locked
+// while what is left of it is taken, and completed or failed once the gateway
+// answers. A refund left pending means the gateway never answered, it has to
+// be checked with the gateway by hand
+type IRefundRepository interface {
+	// Reserves that amount of a captured payment and returns the id of the
+	// pending refund. Going over what is left of the payment, pending refunds
+	// included, is a conflict
+	Reserve(ctx context.Context, paymentID string, amount money.Money, params RefundParams) (string, error)
+	// The gateway gave the money back. The last refund marks the payment as
+	// refunded, and once no payment of the delivery is captured anymore the
+	// delivery is closed too
+	Complete(ctx context.Context, refundID string) error
+	// The gateway refused the refund, its amount can be refunded again
+	Fail(ctx context.Context, refundID string) error
+}
@@ -0,0 +1,31 @@
+package refund
+
+import "sipub-test/pkg/money"
+
+// Why the money was given back
+type Reason string
+
+const (
+	ReasonCustomerRequest Reason = "customer_request"
+	ReasonDamaged         Reason = "damaged"
+	ReasonNotDelivered    Reason = "not_delivered"
+	ReasonDuplicate       Reason = "duplicate" // Charged twice for the same delivery
+	ReasonFraud           Reason = "fraud"
+	ReasonOther           Reason = "other" // The note has to say why
+)
+
+func (r Reason) IsValid() bool {
+	switch r {
+	case ReasonCustomerRequest, ReasonDamaged, ReasonNotDelivered, ReasonDuplicate, ReasonFraud, ReasonOther:
+		return true
+	}
+	return false
+}
+
+// What the client sends to refund a payment. Without Amount, everything that
+// wasn't given back yet is refunded
+type RefundParams struct {
+	Amount *money.Money
+	Reason *string
+	Note   *string
+}
@@ -0,0 +1,183 @@
+package refund
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sipub-test/db"
+	"sipub-test/internal/delivery"
+	"sipub-test/internal/delivery_status"
+	"sipub-test/internal/payment"
+	"sipub-test/internal/unit_of_work"
+	"sipub-test/pkg/apperror"
+	"sipub-test/pkg/clock"
+	"sipub-test/pkg/money"
+	"sipub-test/pkg/timestamp"
+
+	"github.com/google/uuid"
+)
+
+type MySQLRefundRepository struct {
+	uow   *unit_of_work.UnitOfWork
+	clock clock.Clock
+}
+
+func NewMySQLRefundRepository(uow *unit_of_work.UnitOfWork, clock clock.Clock) *MySQLRefundRepository {
+	return &MySQLRefundRepository{uow: uow, clock: clock}
+}
+
+func (r *MySQLRefundRepository) Reserve(ctx context.Context, paymentID string, amount money.Money, params RefundParams) (string, error) {
+	id := uuid.NewString()
+	createdAt := timestamp.New(r.clock.Now())
+	err := r.uow.Do(ctx, func(ctx context.Context, repos unit_of_work.Repositories) error {
+		// Locked until the commit, two refunds of the same payment can't both
+		// take what is left of it
+		value, status, _, err := lockPayment(ctx, repos.Tx, paymentID, false)
+		if err != nil {
+			return err
+		}
+		if status != payment.StatusCaptured {
+			return fmt.Errorf("only captured payments can be refunded, payment is %s: %w", status, apperror.ErrConflict)
+		}
+
+		// Pending refunds may still be given back, they are taken too
+		var reserved money.Money
+		query := `SELECT COALESCE(SUM(amount), 0) FROM refunds WHERE payment_id = ? AND status <> ?`
+		if err := repos.Tx.QueryRowContext(ctx, query, paymentID, payment.RefundFailed).Scan(&reserved); err != nil {
+			return fmt.Errorf("failed to get refunds: %w", err)
+		}
+		if value.Sub(reserved).Sub(amount).IsNegative() {
+			return fmt.Errorf("only %s of the payment can still be refunded: %w", value.Sub(reserved), apperror.ErrConflict)
+		}
+
+		query = `INSERT INTO refunds (id, payment_id, amount, reason, note, status, createdAt) VALUES (?, ?, ?, ?, ?, ?, ?)`
+		_, err = repos.Tx.ExecContext(ctx, query, id, paymentID, amount, *params.Reason, params.Note, payment.RefundPending, createdAt)
+		if err != nil {
+			return fmt.Errorf("failed to create refund: %w", db.TranslateError(err))
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+func (r *MySQLRefundRepository) Complete(ctx context.Context, refundID string) error {
+	createdAt := timestamp.New(r.clock.Now())
+	return r.uow.Do(ctx, func(ctx context.Context, repos unit_of_work.Repositories) error {
+		var paymentID string
+		query := `SELECT payment_id FROM refunds WHERE id = ?`
+		err := repos.Tx.QueryRowContext(ctx, query, refundID).Scan(&paymentID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("refund %w", apperror.ErrNotFound)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get refund: %w", err)
+		}
+
+		// Locked in the same order as Reserve, the payment before its refunds
+		value, status, deliveryID, err := lockPayment(ctx, repos.Tx, paymentID, true)
+		if err != nil {
+			return err
+		}
+		if err := moveRefund(ctx, repos.Tx, refundID, payment.RefundCompleted); err != nil {
+			return err
+		}
+		if status != payment.StatusCaptured {
+			return nil
+		}
+
+		var refunded money.Money
+		query = `SELECT COALESCE(SUM(amount), 0) FROM refunds WHERE payment_id = ? AND status = ?`
+		if err := repos.Tx.QueryRowContext(ctx, query, paymentID, payment.RefundCompleted).Scan(&refunded); err != nil {
+			return fmt.Errorf("failed to get refunds: %w", err)
+		}
+		if value.Sub(refunded).IsPositive() {
+			return nil
+		}
+
+		query = `UPDATE payments SET status = ? WHERE id = ?`
+		if _, err := repos.Tx.ExecContext(ctx, query, payment.StatusRefunded, paymentID); err != nil {
+			return fmt.Errorf("failed to update payment status: %w", db.TranslateError(err))
+		}
+		return closeDelivery(ctx, repos.Tx, deliveryID, refundID, createdAt)
+	})
+}
+
+func (r *MySQLRefundRepository) Fail(ctx context.Context, refundID string) error {
+	return r.uow.Do(ctx, func(ctx context.Context, repos unit_of_work.Repositories) error {
+		return moveRefund(ctx, repos.Tx, refundID, payment.RefundFailed)
+	})
+}
+
+// A payment soft deleted after a refund was reserved has to be found when the
+// refund completes, the gateway already gave the money back
+func lockPayment(ctx context.Context, tx db.Executor, paymentID string, withDeleted bool) (money.Money, payment.PaymentStatus, string, error) {
+	var value money.Money
+	var status payment.PaymentStatus
+	var deliveryID string
+	query := `SELECT value, status, delivery_id FROM payments WHERE id = ?`
+	if !withDeleted {
+		query += ` AND isDeleted = FALSE`
+	}
+	query += ` FOR UPDATE`
+	err := tx.QueryRowContext(ctx, query, paymentID).Scan(&value, &status, &deliveryID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return money.Zero, "", "", fmt.Errorf("payment %w", apperror.ErrNotFound)
+	}
+	if err != nil {
+		return money.Zero, "", "", fmt.Errorf("failed to get payment: %w", err)
+	}
+	return value, status, deliveryID, nil
+}
+
+// Only pending refunds can be completed or failed
+func moveRefund(ctx context.Context, tx db.Executor, refundID string, to payment.RefundStatus) error {
+	query := `UPDATE refunds SET status = ? WHERE id = ? AND status = ?`
+	result, err := tx.ExecContext(ctx, query, to, refundID, payment.RefundPending)
+	if err != nil {
+		return fmt.Errorf("failed to update refund status: %w", db.TranslateError(err))
+	}
+	count, _ := result.RowsAffected()
+	if count == 0 {
+		return fmt.Errorf("refund %s isn't pending anymore: %w", refundID, apperror.ErrConflict)
+	}
+	return nil
+}
+
+// Once none of its payments is captured, a delivery that hasn't reached the
+// client is cancelled and a delivered one is refunded. Deliveries in transit
+// are left as they are, they can only be cancelled by hand once they arrive
+func closeDelivery(ctx context.Context, tx db.Executor, deliveryID string, refundID string, createdAt timestamp.Time) error {
+	var captured uint
+	query := `SELECT COUNT(*) FROM payments WHERE delivery_id = ? AND status = ? AND isDeleted = FALSE`
+	if err := tx.QueryRowContext(ctx, query, deliveryID, payment.StatusCaptured).Scan(&captured); err != nil {
+		return fmt.Errorf("failed to count captured payments: %w", err)
+	}
+	if captured > 0 {
+		return nil
+	}
+
+	from, err := delivery_status.Lock(ctx, tx, deliveryID)
+	if errors.Is(err, apperror.ErrNotFound) {
+		return nil // Soft deleted, nothing to close
+	}
+	if err != nil {
+		return err
+	}
+
+	var to delivery.Status
+	switch {
+	case from.CanMoveTo(delivery.StatusCancelled):
+		to = delivery.StatusCancelled
+	case from.CanMoveTo(delivery.StatusRefunded):
+		to = delivery.StatusRefunded
+	default:
+		return nil
+	}
+	actor := "refund " + refundID
+	_, err = delivery_status.Move(ctx, tx, deliveryID, from, to, &actor, createdAt)
+	return err
+}
@@ -0,0 +1,248 @@
+package refund_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sipub-test/internal/refund"
+	"sipub-test/internal/unit_of_work"
+	"sipub-test/pkg/apperror"
+	"sipub-test/pkg/clock"
+	"sipub-test/pkg/money"
+	testhelper "sipub-test/pkg/test_helper"
+	"sipub-test/pkg/timestamp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var now = clock.Fixed(time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC))
+
+var createdAt = timestamp.New(now.Now())
+
+// The locked payment and what was already reserved of it
+func expectPayment(mock sqlmock.Sqlmock, status string, reserved float64) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT value, status, delivery_id FROM payments WHERE id = ? AND isDeleted = FALSE FOR UPDATE`)).
+		WithArgs("payment-123").
+		WillReturnRows(sqlmock.NewRows([]string{"value", "status", "delivery_id"}).AddRow(100, status, "delivery-123"))
+	if status != "captured" {
+		return
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT COALESCE(SUM(amount), 0) FROM refunds WHERE payment_id = ? AND status <> ?`)).
+		WithArgs("payment-123", "failed").
+		WillReturnRows(sqlmock.NewRows([]string{"reserved"}).AddRow(reserved))
+}
+
+// The pending refund being completed, its payment locked and what the
+// completed refunds add up to with it
+func expectCompletion(mock sqlmock.Sqlmock, refunded float64) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT payment_id FROM refunds WHERE id = ?`)).
+		WithArgs("refund-123").
+		WillReturnRows(sqlmock.NewRows([]string{"payment_id"}).AddRow("payment-123"))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT value, status, delivery_id FROM payments WHERE id = ? FOR UPDATE`)).
+		WithArgs("payment-123").
+		WillReturnRows(sqlmock.NewRows([]string{"value", "status", "delivery_id"}).AddRow(100, "captured", "delivery-123"))
+	expectRefundStatus(mock, "completed", 1)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT COALESCE(SUM(amount), 0) FROM refunds WHERE payment_id = ? AND status = ?`)).
+		WithArgs("payment-123", "completed").
+		WillReturnRows(sqlmock.NewRows([]string{"refunded"}).AddRow(refunded))
+}
+
+func expectRefundStatus(mock sqlmock.Sqlmock, status string, rows int64) {
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE refunds SET status = ? WHERE id = ? AND status = ?`)).
+		WithArgs(status, "refund-123", "pending").
+		WillReturnResult(sqlmock.NewResult(0, rows))
+}
+
+// The payment was refunded in full, and the delivery has the given number of
+// payments still captured
+func expectFullRefund(mock sqlmock.Sqlmock, captured int) {
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE payments SET status = ? WHERE id = ?`)).
+		WithArgs("refunded", "payment-123").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT COUNT(*) FROM payments WHERE delivery_id = ? AND status = ? AND isDeleted = FALSE`)).
+		WithArgs("delivery-123", "captured").
+		WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(captured))
+}
+
+func expectDeliveryStatus(mock sqlmock.Sqlmock, status string) {
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT status FROM deliveries WHERE id = ? AND isDeleted = FALSE FOR UPDATE`)).
+		WithArgs("delivery-123").
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(status))
+}
+
+func damaged() refund.RefundParams {
+	return refund.RefundParams{Reason: testhelper.StringPointer("damaged")}
+}
+
+func newRefundRepository(t *testing.T) (*refund.MySQLRefundRepository, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock DB: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return refund.NewMySQLRefundRepository(unit_of_work.NewUnitOfWork(db, now), now), mock
+}
+
+func TestReserveRefund(t *testing.T) {
+	t.Run("ShouldReserveWhatIsLeft", func(t *testing.T) {
+		repo, mock := newRefundRepository(t)
+
+		expectPayment(mock, "captured", 20)
+		mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO refunds (id, payment_id, amount, reason, note, status, createdAt) VALUES (?, ?, ?, ?, ?, ?, ?)`)).
+			WithArgs(sqlmock.AnyArg(), "payment-123", money.FromCents(8000), "damaged", nil, "pending", createdAt).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		id, err := repo.Reserve(context.Background(), "payment-123", money.FromCents(8000), damaged())
+
+		assert.NoError(t, err, "Shouldn't contain any errors")
+		assert.NotEmpty(t, id)
+		assert.NoError(t, mock.ExpectationsWereMet(), "Neither the payment nor the delivery should change")
+	})
+	t.Run("ShouldRejectAmountsOverWhatIsLeft", func(t *testing.T) {
+		repo, mock := newRefundRepository(t)
+
+		// Another refund got in after the controller read the payment
+		expectPayment(mock, "captured", 90)
+		mock.ExpectRollback()
+
+		_, err := repo.Reserve(context.Background(), "payment-123", money.FromCents(1001), damaged())
+
+		assert.True(t, errors.Is(err, apperror.ErrConflict))
+		assert.NoError(t, mock.ExpectationsWereMet(), "Nothing should be written")
+	})
+	t.Run("ShouldRejectUncapturedPayments", func(t *testing.T) {
+		repo, mock := newRefundRepository(t)
+
+		expectPayment(mock, "refunded", 0)
+		mock.ExpectRollback()
+
+		_, err := repo.Reserve(context.Background(), "payment-123", money.FromCents(1000), damaged())
+
+		assert.True(t, errors.Is(err, apperror.ErrConflict))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("ShouldNotFindDeletedPayments", func(t *testing.T) {
+		repo, mock := newRefundRepository(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT value, status, delivery_id FROM payments`).
+			WithArgs("payment-123").
+			WillReturnRows(sqlmock.NewRows([]string{"value", "status", "delivery_id"}))
+		mock.ExpectRollback()
+
+		_, err := repo.Reserve(context.Background(), "payment-123", money.FromCents(1000), damaged())
+
+		assert.True(t, errors.Is(err, apperror.ErrNotFound))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestCompleteRefund(t *testing.T) {
+	t.Run("ShouldKeepPartiallyRefundedPaymentsCaptured", func(t *testing.T) {
+		repo, mock := newRefundRepository(t)
+
+		expectCompletion(mock, 50)
+		mock.ExpectCommit()
+
+		err := repo.Complete(context.Background(), "refund-123")
+
+		assert.NoError(t, err, "Shouldn't contain any errors")
+		assert.NoError(t, mock.ExpectationsWereMet(), "Neither the payment nor the delivery should change")
+	})
+	t.Run("ShouldRefundDeliveredDeliveries", func(t *testing.T) {
+		repo, mock := newRefundRepository(t)
+
+		expectCompletion(mock, 100)
+		expectFullRefund(mock, 0)
+		expectDeliveryStatus(mock, "delivered")
+		// Delivered products don't go back to the stock
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE deliveries SET status = ?, updatedAt = ? WHERE id = ?`)).
+			WithArgs("refunded", createdAt, "delivery-123").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`INSERT INTO delivery_status_history`).
+			WithArgs(sqlmock.AnyArg(), "delivery-123", "delivered", "refunded", sqlmock.AnyArg(), createdAt).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		err := repo.Complete(context.Background(), "refund-123")
+
+		assert.NoError(t, err, "Shouldn't contain any errors")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("ShouldLeaveDeliveriesWithOtherCapturedPayments", func(t *testing.T) {
+		repo, mock := newRefundRepository(t)
+
+		expectCompletion(mock, 100)
+		expectFullRefund(mock, 1)
+		mock.ExpectCommit()
+
+		err := repo.Complete(context.Background(), "refund-123")
+
+		assert.NoError(t, err, "Shouldn't contain any errors")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("ShouldLeaveDeliveriesInTransit", func(t *testing.T) {
+		repo, mock := newRefundRepository(t)
+
+		expectCompletion(mock, 100)
+		expectFullRefund(mock, 0)
+		expectDeliveryStatus(mock, "in_transit")
+		mock.ExpectCommit()
+
+		err := repo.Complete(context.Background(), "refund-123")
+
+		assert.NoError(t, err, "Shouldn't contain any errors")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("ShouldOnlyCompletePendingRefunds", func(t *testing.T) {
+		repo, mock := newRefundRepository(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT payment_id FROM refunds`).
+			WithArgs("refund-123").
+			WillReturnRows(sqlmock.NewRows([]string{"payment_id"}).AddRow("payment-123"))
+		mock.ExpectQuery(`SELECT value, status, delivery_id FROM payments`).
+			WithArgs("payment-123").
+			WillReturnRows(sqlmock.NewRows([]string{"value", "status", "delivery_id"}).AddRow(100, "captured", "delivery-123"))
+		expectRefundStatus(mock, "completed", 0)
+		mock.ExpectRollback()
+
+		err := repo.Complete(context.Background(), "refund-123")
+
+		assert.True(t, errors.Is(err, apperror.ErrConflict))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestFailRefund(t *testing.T) {
+	t.Run("ShouldFailPendingRefunds", func(t *testing.T) {
+		repo, mock := newRefundRepository(t)
+
+		mock.ExpectBegin()
+		expectRefundStatus(mock, "failed", 1)
+		mock.ExpectCommit()
+
+		err := repo.Fail(context.Background(), "refund-123")
+
+		assert.NoError(t, err, "Shouldn't contain any errors")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("ShouldOnlyFailPendingRefunds", func(t *testing.T) {
+		repo, mock := newRefundRepository(t)
+
+		mock.ExpectBegin()
+		expectRefundStatus(mock, "failed", 0)
+		mock.ExpectRollback()
+
+		err := repo.Fail(context.Background(), "refund-123")
+
+		assert.True(t, errors.Is(err, apperror.ErrConflict))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
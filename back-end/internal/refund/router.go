@@ -0,0 +1,29 @@
+package refund
+
+import (
+	"net/http"
+)
+
+// Refunds are created under their payment and listed in it, there is no CRUD
+type IRefundController interface {
+	Refund(http.ResponseWriter, *http.Request)
+}
+
+type RefundRouter struct {
+	baseEndPoint string
+	controller   IRefundController
+}
+
+func NewRefundRouter(controller IRefundController) RefundRouter {
+	return RefundRouter{controller: controller}
+}
+
+func (r RefundRouter) Init(mux *http.ServeMux) {
+	r.baseEndPoint = "/payment/{id}"
+
+	r.refund(mux)
+}
+
+func (r RefundRouter) refund(mux *http.ServeMux) {
+	mux.HandleFunc("POST "+r.baseEndPoint+"/refund", r.controller.Refund)
+}
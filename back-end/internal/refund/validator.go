@@ -0,0 +1,28 @@
+package refund
+
+import (
+	"sipub-test/pkg/apperror"
+)
+
+type RefundValidator struct{}
+
+// The amount is only checked against the payment by the controller and the
+// repository
+func (v *RefundValidator) Validate(refund RefundParams) error {
+	if refund.Amount != nil && !refund.Amount.IsPositive() {
+		return apperror.NewValidationError("Amount", "must be greater than zero")
+	}
+	if refund.Reason == nil || *refund.Reason == "" {
+		return apperror.NewValidationError("Reason", "is required")
+	}
+	if !Reason(*refund.Reason).IsValid() {
+		return apperror.NewValidationError("Reason", "must be one of customer_request, damaged, not_delivered, duplicate, fraud or other")
+	}
+	if Reason(*refund.Reason) == ReasonOther && (refund.Note == nil || *refund.Note == "") {
+		return apperror.NewValidationError("Note", "is required when the reason is other")
+	}
+	if refund.Note != nil && len(*refund.Note) > 255 {
+		return apperror.NewValidationError("Note", "can't be longer than 255 characters")
+	}
+	return nil
+}
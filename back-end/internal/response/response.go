@@ -0,0 +1,108 @@
+// Helpers used by every controller to write responses. Errors are always
+// returned as the same JSON body, so the frontend can tell a validation
+// failure (400) from a missing session (401), a missing entity (404) or an
+// outage (500):
+//
+//	{"Code": "validation_failed", "Message": "...", "Details": [...], "RequestID": "..."}
+
+package response
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sipub-test/internal/middleware"
+	"sipub-test/pkg/apperror"
+)
+
+// Machine readable codes, the messages may change but these shouldn't
+const (
+	CodeInvalidRequest   = "invalid_request"
+	CodeValidationFailed = "validation_failed"
+	CodeNotFound         = "not_found"
+	CodeConflict         = "conflict"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeDuplicate        = "duplicate"
+	CodeForeignKey       = "foreign_key_violation"
+	CodePaymentDeclined  = "payment_declined"
+	CodeInternal         = "internal_error"
+)
+
+type FieldError struct {
+	Field   string `json:"Field"`
+	Message string `json:"Message"`
+}
+
+type ErrorBody struct {
+	Code      string       `json:"Code"`
+	Message   string       `json:"Message"`
+	Details   []FieldError `json:"Details,omitempty"`
+	RequestID string       `json:"RequestID,omitempty"`
+}
+
+// Writes v as the JSON body. If the encoding fails the status was already
+// sent, so there isn't anything useful left to do
+func JSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// Used when the request can't even be read (malformed JSON, unknown query
+// parameter...)
+func BadRequest(w http.ResponseWriter, r *http.Request, message string) {
+	writeError(w, r, http.StatusBadRequest, ErrorBody{Code: CodeInvalidRequest, Message: message})
+}
+
+// Maps the domain errors to their status. Anything unknown is treated as an
+// internal error, it is logged and its message is not sent to the client
+// since it may contain details of the database.
+func Error(w http.ResponseWriter, r *http.Request, logger *slog.Logger, err error) {
+	var validationErr *apperror.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		writeError(w, r, http.StatusBadRequest, ErrorBody{
+			Code:    CodeValidationFailed,
+			Message: validationErr.Error(),
+			Details: []FieldError{{Field: validationErr.Field, Message: validationErr.Message}},
+		})
+	case errors.Is(err, apperror.ErrValidation):
+		writeError(w, r, http.StatusBadRequest, ErrorBody{Code: CodeValidationFailed, Message: err.Error()})
+	case errors.Is(err, apperror.ErrNotFound):
+		writeError(w, r, http.StatusNotFound, ErrorBody{Code: CodeNotFound, Message: err.Error()})
+	case errors.Is(err, apperror.ErrUnauthorized):
+		// Tells the client which scheme to send, see auth.Guard
+		w.Header().Set("WWW-Authenticate", `Bearer realm="sipub"`)
+		writeError(w, r, http.StatusUnauthorized, ErrorBody{Code: CodeUnauthorized, Message: err.Error()})
+	case errors.Is(err, apperror.ErrForbidden):
+		writeError(w, r, http.StatusForbidden, ErrorBody{Code: CodeForbidden, Message: err.Error()})
+	case errors.Is(err, apperror.ErrDuplicate):
+		writeError(w, r, http.StatusConflict, ErrorBody{Code: CodeDuplicate, Message: err.Error()})
+	case errors.Is(err, apperror.ErrForeignKey):
+		writeError(w, r, http.StatusConflict, ErrorBody{Code: CodeForeignKey, Message: err.Error()})
+	case errors.Is(err, apperror.ErrConflict):
+		writeError(w, r, http.StatusConflict, ErrorBody{Code: CodeConflict, Message: err.Error()})
+	case errors.Is(err, apperror.ErrPaymentDeclined):
+		writeError(w, r, http.StatusPaymentRequired, ErrorBody{Code: CodePaymentDeclined, Message: err.Error()})
+	default:
+		logger.Error("request failed",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"requestID", middleware.GetRequestID(r.Context()),
+			"error", err,
+		)
+		writeError(w, r, http.StatusInternalServerError, ErrorBody{Code: CodeInternal, Message: "internal server error"})
+	}
+}
+
+// For the responses that have nothing to send back (logout...)
+func Empty(w http.ResponseWriter, status int) {
+	w.WriteHeader(status)
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, status int, body ErrorBody) {
+	body.RequestID = middleware.GetRequestID(r.Context())
+	JSON(w, status, body)
+}
@@ -0,0 +1,91 @@
+package response_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sipub-test/internal/middleware"
+	"sipub-test/internal/response"
+	"sipub-test/pkg/apperror"
+	testhelper "sipub-test/pkg/test_helper"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestError(t *testing.T) {
+	cases := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{"ValidationError", apperror.NewValidationError("Name", "can't be empty"), http.StatusBadRequest, response.CodeValidationFailed},
+		{"WrappedNotFound", fmt.Errorf("product %w", apperror.ErrNotFound), http.StatusNotFound, response.CodeNotFound},
+		{"Conflict", apperror.ErrConflict, http.StatusConflict, response.CodeConflict},
+		{"Unauthorized", fmt.Errorf("invalid email or password: %w", apperror.ErrUnauthorized), http.StatusUnauthorized, response.CodeUnauthorized},
+		{"Forbidden", apperror.ErrForbidden, http.StatusForbidden, response.CodeForbidden},
+		{"Duplicate", fmt.Errorf("failed to create user: %w", apperror.ErrDuplicate), http.StatusConflict, response.CodeDuplicate},
+		{"ForeignKey", apperror.ErrForeignKey, http.StatusConflict, response.CodeForeignKey},
+		{"PaymentDeclined", fmt.Errorf("card declined: %w", apperror.ErrPaymentDeclined), http.StatusPaymentRequired, response.CodePaymentDeclined},
+		{"UnknownError", errors.New("Error 2003: Can't connect to MySQL server"), http.StatusInternalServerError, response.CodeInternal},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/products", nil)
+
+			response.Error(w, r, testhelper.DiscardLogger(), c.err)
+
+			assert.Equal(t, c.status, w.Code)
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+			var body response.ErrorBody
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(t, c.code, body.Code)
+		})
+	}
+
+	t.Run("ShouldAskForABearerToken", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodDelete, "/u/user-123", nil)
+
+		response.Error(w, r, testhelper.DiscardLogger(), apperror.ErrUnauthorized)
+
+		assert.Contains(t, w.Header().Get("WWW-Authenticate"), "Bearer")
+	})
+	t.Run("ShouldNotLeakInternalErrors", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/products", nil)
+
+		response.Error(w, r, testhelper.DiscardLogger(), errors.New("Error 1146: Table 'sipub_test.products' doesn't exist"))
+
+		assert.NotContains(t, w.Body.String(), "sipub_test")
+	})
+	t.Run("ShouldIncludeFieldDetails", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/products", nil)
+
+		response.Error(w, r, testhelper.DiscardLogger(), apperror.NewValidationError("Price", "must be greater than zero"))
+
+		var body response.ErrorBody
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, []response.FieldError{{Field: "Price", Message: "must be greater than zero"}}, body.Details)
+	})
+	t.Run("ShouldIncludeRequestID", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/products/123", nil)
+		r.Header.Set(middleware.RequestIDHeader, "request-123")
+
+		handler := middleware.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			response.Error(w, r, testhelper.DiscardLogger(), apperror.ErrNotFound)
+		}))
+		handler.ServeHTTP(w, r)
+
+		var body response.ErrorBody
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, "request-123", body.RequestID)
+		assert.Equal(t, "request-123", w.Header().Get(middleware.RequestIDHeader))
+	})
+}
@@ -2,137 +2,125 @@ package shopping_cart
 
 import (
 	"encoding/json"
-	"fmt"
+	"log/slog"
 	"net/http"
-	"strings"
+	"sipub-test/internal"
+	"sipub-test/internal/filter"
+	"sipub-test/internal/pagination"
+	"sipub-test/internal/response"
+	"sipub-test/pkg/apperror"
 )
 
 type ShoppingCartController struct {
-	// TODO
-	repository IShoppingCartRepository
+	repository      IShoppingCartRepository
+	validator       internal.IValidator[ShoppingCartParams]
+	amountValidator internal.IValidator[ShoppingCartAmountParams]
+	logger          *slog.Logger
 }
 
-// Used for testing
-func (c *ShoppingCartController) SetRepository(repo IShoppingCartRepository) {
-	c.repository = repo
-}
-
-func NewShoppingCartController() *ShoppingCartController {
-	return &ShoppingCartController{repository: NewMySQLShoppingCartRepository()}
+func NewShoppingCartController(repository IShoppingCartRepository, validator internal.IValidator[ShoppingCartParams], amountValidator internal.IValidator[ShoppingCartAmountParams], logger *slog.Logger) *ShoppingCartController {
+	return &ShoppingCartController{repository: repository, validator: validator, amountValidator: amountValidator, logger: logger}
 }
 
 func (c *ShoppingCartController) Create(w http.ResponseWriter, r *http.Request) {
 	var shoppingCartParam ShoppingCartParams
 	err := json.NewDecoder(r.Body).Decode(&shoppingCartParam)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		response.BadRequest(w, r, "invalid request body: "+err.Error())
 		return
 	}
 
-	// Validation
-
-	createdShoppingCart, err := c.repository.Create(shoppingCartParam)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := c.validator.Validate(shoppingCartParam); err != nil {
+		response.Error(w, r, c.logger, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-
-	if err := json.NewEncoder(w).Encode(createdShoppingCart.ToDTO()); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	createdShoppingCart, err := c.repository.Create(r.Context(), shoppingCartParam)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
 		return
 	}
+
+	response.JSON(w, http.StatusCreated, createdShoppingCart.ToDTO())
 }
 
 func (c *ShoppingCartController) GetAll(w http.ResponseWriter, r *http.Request) {
-	var shoppingCartParams ShoppingCartParams
 	queryParams := r.URL.Query()
 
-	// First it checks to see if the values in the querystring are valid
-	for key := range queryParams {
-		if strings.ToLower(key) != "isactive" &&
-			strings.ToLower(key) != "isdeleted" &&
-			strings.ToLower(key) != "createdat" &&
-			strings.ToLower(key) != "userid" &&
-			strings.ToLower(key) != "addressid" {
-			http.Error(w, fmt.Sprintf("Invalid query parameter: %s", key), http.StatusBadRequest)
-			return
-		}
+	// The accepted fields and operators are declared in filterFields
+	where, err := filter.Parse(queryParams, filterFields)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
 	}
 
-	// If the values are valid it will check what each value is
-	if userID := queryParams.Get("AddressID"); userID != "" {
-		*shoppingCartParams.UserID = userID
+	page, err := pagination.Parse(queryParams, sortFields)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
 	}
 
-	// It now passes the ShoppingCart param as a "filter" and gets the found deliveries
-	foundShoppingCartes, err := c.repository.GetAll(shoppingCartParams)
+	foundShoppingCartes, err := c.repository.GetAll(r.Context(), where, page)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		response.Error(w, r, c.logger, err)
+		return
+	}
+	foundShoppingCartes, hasMore := pagination.Trim(page, foundShoppingCartes)
+
+	total, err := c.repository.Count(r.Context(), where)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
 
 	dtoFoundDeliveries := []ShoppingCartDTO{}
 	for i := 0; i < len(foundShoppingCartes); i++ {
 		dtoFoundDeliveries = append(dtoFoundDeliveries, foundShoppingCartes[i].ToDTO())
 	}
-	// Returns the DTO deliveries
-	if err := json.NewEncoder(w).Encode(dtoFoundDeliveries); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	var lastID string
+	if hasMore {
+		lastID = dtoFoundDeliveries[len(dtoFoundDeliveries)-1].Id
 	}
+	pagination.WriteHeaders(w, r, page, total, hasMore, lastID)
+	// Returns the DTO deliveries
+	response.JSON(w, http.StatusOK, dtoFoundDeliveries)
 }
 
 func (c *ShoppingCartController) GetOne(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
-	shoppingCart, err := c.repository.GetOne(id)
+	shoppingCart, err := c.repository.GetOne(r.Context(), id)
 	if err != nil {
-		fmt.Println(err)
-		http.Error(w, err.Error(), http.StatusNotFound) // The Id was not found but the request did go though
-		return
-	}
-	if err := json.NewEncoder(w).Encode(shoppingCart.ToDTO()); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		response.Error(w, r, c.logger, err)
 		return
 	}
+	response.JSON(w, http.StatusOK, shoppingCart.ToDTO())
 }
 
 func (c *ShoppingCartController) DeleteAll(w http.ResponseWriter, r *http.Request) {
-	var shoppingCartParams ShoppingCartParams
-	queryParams := r.URL.Query()
-
-	if userID := queryParams.Get("UserID"); userID != "" {
-		*shoppingCartParams.UserID = userID
-	}
-	count, err := c.repository.DeleteAll(shoppingCartParams)
+	where, err := filter.Parse(r.URL.Query(), filterFields)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		response.Error(w, r, c.logger, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(count); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	count, err := c.repository.DeleteAll(r.Context(), where)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
 	}
+
+	response.JSON(w, http.StatusOK, count)
 }
 
 func (c *ShoppingCartController) DeleteOne(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
-	count, err := c.repository.DeleteOne(id)
+	count, err := c.repository.DeleteOne(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound) // Did go through, none found
+		response.Error(w, r, c.logger, err)
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(count); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
+	response.JSON(w, http.StatusOK, count)
 }
 
 func (c *ShoppingCartController) Update(w http.ResponseWriter, r *http.Request) {
@@ -140,18 +128,53 @@ func (c *ShoppingCartController) Update(w http.ResponseWriter, r *http.Request)
 	var shoppingCartParams ShoppingCartParams
 	err := json.NewDecoder(r.Body).Decode(&shoppingCartParams)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		response.BadRequest(w, r, "invalid request body: "+err.Error())
+		return
+	}
+	shoppingCart, err := c.repository.Update(r.Context(), id, shoppingCartParams)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, shoppingCart.ToDTO())
+}
+
+func (c *ShoppingCartController) ChangeAmount(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	var amountParams ShoppingCartAmountParams
+	err := json.NewDecoder(r.Body).Decode(&amountParams)
+	if err != nil {
+		response.BadRequest(w, r, "invalid request body: "+err.Error())
 		return
 	}
-	shoppingCart, err := c.repository.Update(id, shoppingCartParams)
+
+	if err := c.amountValidator.Validate(amountParams); err != nil {
+		response.Error(w, r, c.logger, err)
+		return
+	}
+
+	shoppingCart, err := c.repository.ChangeAmount(r.Context(), id, amountParams)
 	if err != nil {
-		fmt.Println(err)
-		http.Error(w, err.Error(), http.StatusNotFound) // Did go through, none found
+		response.Error(w, r, c.logger, err)
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(shoppingCart.ToDTO()); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	response.JSON(w, http.StatusOK, shoppingCart.ToDTO())
+}
+
+func (c *ShoppingCartController) Summary(w http.ResponseWriter, r *http.Request) {
+	userID := r.URL.Query().Get("UserID")
+	if userID == "" {
+		response.Error(w, r, c.logger, apperror.NewValidationError("UserID", "is required"))
+		return
+	}
+
+	summary, err := c.repository.Summary(r.Context(), userID)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
 	}
+
+	response.JSON(w, http.StatusOK, summary.ToDTO())
 }
@@ -1,23 +1,40 @@
 package shopping_cart
 
+import (
+	"context"
+	"sipub-test/internal/filter"
+	"sipub-test/internal/pagination"
+)
+
 type IShoppingCartRepository interface {
 	// Returns the created ShoppingCart
-	Create(ShoppingCartParams) (ShoppingCartModel, error)
+	Create(ctx context.Context, params ShoppingCartParams) (ShoppingCartModel, error)
 
 	// Returns the found deliveriees
 	// NOTE: reusing the same type for a filter and a "constructor" is not
 	// ideal at all, but it will save on code repetition
-	GetAll(filter ShoppingCartParams) ([]ShoppingCartModel, error)
+	GetAll(ctx context.Context, where filter.Filter, page pagination.Page) ([]ShoppingCartModel, error)
+
+	// Returns how many rows match the filter, ignoring the page
+	Count(ctx context.Context, where filter.Filter) (uint, error)
 
 	// Returns the found ShoppingCart
-	GetOne(id string) (ShoppingCartModel, error)
+	GetOne(ctx context.Context, id string) (ShoppingCartModel, error)
 
 	// Returns amount of deleted deliveries
-	DeleteOne(id string) (uint, error)
+	DeleteOne(ctx context.Context, id string) (uint, error)
 
 	// Returns amount of deleted deliveries
-	DeleteAll(filter ShoppingCartParams) (uint, error)
+	DeleteAll(ctx context.Context, where filter.Filter) (uint, error)
 
 	// Returns the updated ShoppingCart
-	Update(id string, newShoppingCart ShoppingCartParams) (ShoppingCartModel, error)
+	Update(ctx context.Context, id string, newShoppingCart ShoppingCartParams) (ShoppingCartModel, error)
+
+	// Sets, increments or decrements the amount of a line, removing it once
+	// the amount reaches zero. A removed line is returned with amount zero
+	ChangeAmount(ctx context.Context, id string, amount ShoppingCartAmountParams) (ShoppingCartModel, error)
+
+	// Returns the user's cart joined with the products and their current
+	// prices, an empty cart is not an error
+	Summary(ctx context.Context, userID string) (ShoppingCartSummaryModel, error)
 }
@@ -1,5 +1,7 @@
 package shopping_cart
 
+import "sipub-test/pkg/money"
+
 // This is what will be used to create/find/update the ShoppingCart model. The
 // fields are used as pointers so they can be nullified
 type ShoppingCartParams struct {
@@ -8,6 +10,20 @@ type ShoppingCartParams struct {
 	ProductAmount *uint
 }
 
+// How PATCH /cart/{id} changes the amount of a line
+type AmountOperation string
+
+const (
+	SetAmount       AmountOperation = "set"
+	IncrementAmount AmountOperation = "inc"
+	DecrementAmount AmountOperation = "dec" // Stops at zero, which removes the line
+)
+
+type ShoppingCartAmountParams struct {
+	Operation     *AmountOperation // Defaults to set
+	ProductAmount *uint
+}
+
 type ShoppingCartDTO struct {
 	Id            string `json:"Id"`
 	UserID        string `json:"UserID"`
@@ -33,3 +49,69 @@ func (d *ShoppingCartModel) ToDTO() ShoppingCartDTO {
 	}
 	return dtoShoppingCart
 }
+
+type ShoppingCartSummaryItemDTO struct {
+	Id            string      `json:"Id"`
+	ProductID     string      `json:"ProductID"`
+	Name          string      `json:"Name"`
+	ProductAmount uint        `json:"ProductAmount"`
+	UnitPrice     money.Money `json:"UnitPrice"`
+	Subtotal      money.Money `json:"Subtotal"`
+	WeightGrams   float32     `json:"WeightGrams"`
+	IsAvailable   bool        `json:"IsAvailable"` // False once the product is inactive or deleted
+}
+
+// The totals only count the available items, the others can't be checked out
+type ShoppingCartSummaryDTO struct {
+	UserID           string                       `json:"UserID"`
+	Items            []ShoppingCartSummaryItemDTO `json:"Items"`
+	ItemCount        uint                         `json:"ItemCount"`
+	TotalWeightGrams float32                      `json:"TotalWeightGrams"`
+	Total            money.Money                  `json:"Total"`
+	HasUnavailable   bool                         `json:"HasUnavailable"`
+}
+
+// A cart line joined with its product
+type ShoppingCartSummaryItemModel struct {
+	ShoppingCartModel
+	name        string
+	price       money.Money
+	weightGrams float32
+	isActive    bool
+	isDeleted   bool
+}
+
+type ShoppingCartSummaryModel struct {
+	userID string
+	items  []ShoppingCartSummaryItemModel
+}
+
+func (s *ShoppingCartSummaryModel) ToDTO() ShoppingCartSummaryDTO {
+	dtoSummary := ShoppingCartSummaryDTO{
+		UserID: s.userID,
+		Items:  []ShoppingCartSummaryItemDTO{},
+	}
+	for _, item := range s.items {
+		amount := float32(item.productAmount)
+		dtoItem := ShoppingCartSummaryItemDTO{
+			Id:            item.id,
+			ProductID:     item.productID,
+			Name:          item.name,
+			ProductAmount: item.productAmount,
+			UnitPrice:     item.price,
+			Subtotal:      item.price.Mul(int64(item.productAmount)),
+			WeightGrams:   item.weightGrams * amount,
+			IsAvailable:   item.isActive && !item.isDeleted,
+		}
+		dtoSummary.Items = append(dtoSummary.Items, dtoItem)
+
+		if !dtoItem.IsAvailable {
+			dtoSummary.HasUnavailable = true
+			continue
+		}
+		dtoSummary.ItemCount += item.productAmount
+		dtoSummary.TotalWeightGrams += dtoItem.WeightGrams
+		dtoSummary.Total = dtoSummary.Total.Add(dtoItem.Subtotal)
+	}
+	return dtoSummary
+}
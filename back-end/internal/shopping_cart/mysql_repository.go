@@ -1,52 +1,51 @@
 package shopping_cart
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
-	"log"
 	"sipub-test/db"
+	"sipub-test/internal/filter"
+	"sipub-test/internal/pagination"
+	"sipub-test/pkg/apperror"
+	"sipub-test/pkg/clock"
+	"sipub-test/pkg/timestamp"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 type MySQLShoppingCartRepository struct {
-	db *sql.DB
+	db    db.Executor
+	clock clock.Clock
 }
 
-// Mainly used for testing, but could be used elsewhere
-func (r *MySQLShoppingCartRepository) SetDB(db *sql.DB) { r.db = db }
-
-func (r *MySQLShoppingCartRepository) createNewShoppingCartTableIfNoneExists() {
-	r.db = db.GetDB()
-
-	createTableQuery := `
-	CREATE TABLE IF NOT EXISTS shopping_cart (
-		id CHAR(36) NOT NULL,
-        user_id CHAR(36) NOT NULL,
-        product_id char(36) NOT NULL,
-        product_amount INT UNSIGNED NOT NULL,
-        FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
-        FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE,
-        PRIMARY KEY (id)
-	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`
-	// https://dev.mysql.com/doc/refman/8.4/en/innodb-benefits.html
-	// Mainly using InnoDB because it supports foreing keys
-	// createdAt is a string because it is simpler to handle. It uses this
-	// format 2006-01-02 15:04:05 (19 chars)
-
-	if _, err := r.db.Exec(createTableQuery); err != nil {
-		log.Fatalf("Failed to create table: %v", err)
-	}
+func NewMySQLShoppingCartRepository(db db.Executor, clock clock.Clock) *MySQLShoppingCartRepository {
+	return &MySQLShoppingCartRepository{db: db, clock: clock}
+}
+
+// How long adding or changing a line holds its products. While held, other
+// users can't add or check out that amount
+const holdDuration = 30 * time.Minute
+
+// Fields the list can be filtered and deleted by
+var filterFields = filter.Schema{
+	"UserID":        {Column: "user_id", Type: filter.String, Operators: []filter.Operator{filter.Eq}, Required: true},
+	"ProductID":     {Column: "product_id", Type: filter.String, Operators: []filter.Operator{filter.Eq, filter.In}},
+	"ProductAmount": {Column: "product_amount", Type: filter.Integer, Operators: filter.IntegerOperators},
 }
 
-func NewMySQLShoppingCartRepository() *MySQLShoppingCartRepository {
-	repo := &MySQLShoppingCartRepository{db: db.GetDB()}
-	repo.createNewShoppingCartTableIfNoneExists()
-	return repo
+// Columns the list can be sorted by
+var sortFields = pagination.Fields{
+	Table:    "shopping_cart",
+	IDColumn: "id",
+	Columns: map[string]string{
+		"ProductAmount": "product_amount",
+	},
 }
 
-func (r *MySQLShoppingCartRepository) Create(params ShoppingCartParams) (ShoppingCartModel, error) {
+func (r *MySQLShoppingCartRepository) Create(ctx context.Context, params ShoppingCartParams) (ShoppingCartModel, error) {
 	id := uuid.NewString()
 
 	// Fields might be nil, but they need to be passed empty/defaulted non nil fields. None of the fields should be nil
@@ -57,21 +56,56 @@ func (r *MySQLShoppingCartRepository) Create(params ShoppingCartParams) (Shoppin
 		productAmount: *params.ProductAmount,
 	}
 
-	query := `INSERT INTO shopping_cart (id, user_id, product_id, product_amount) VALUES (?, ?, ?, ?)`
+	// The product stays locked until the line is written, two carts can't
+	// both take the last units
+	err := db.WithTx(ctx, r.db, func(tx db.Executor) error {
+		available, inCart, err := r.availableStock(ctx, tx, model.userID, model.productID, true)
+		if err != nil {
+			return err
+		}
+		if int64(inCart+model.productAmount) > available {
+			return fmt.Errorf("not enough stock for product %s: %w", model.productID, apperror.ErrConflict)
+		}
+
+		// Adding a product that is already in the cart increments its line, the
+		// unique key on (user_id, product_id) turns the insert into an update
+		query := `
+			INSERT INTO shopping_cart (id, user_id, product_id, product_amount, reservedUntil) VALUES (?, ?, ?, ?, ?)
+			ON DUPLICATE KEY UPDATE product_amount = product_amount + VALUES(product_amount), reservedUntil = VALUES(reservedUntil)`
 
-	_, err := r.db.Exec(query, id, model.userID, model.productID, model.productAmount)
+		res, err := tx.ExecContext(ctx, query, id, model.userID, model.productID, model.productAmount, r.reservedUntil())
+		if err != nil {
+			return fmt.Errorf("failed to create ShoppingCart: %w", db.TranslateError(err))
+		}
+
+		// MySQL reports 2 affected rows when the existing line was updated
+		if count, _ := res.RowsAffected(); count == 1 {
+			return nil
+		}
+
+		query = `SELECT id, user_id, product_id, product_amount FROM shopping_cart WHERE user_id = ? AND product_id = ?`
+		err = tx.QueryRowContext(ctx, query, model.userID, model.productID).Scan(&model.id,
+			&model.userID,
+			&model.productID,
+			&model.productAmount,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to get merged ShoppingCart: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return ShoppingCartModel{}, fmt.Errorf("failed to create ShoppingCart: %w", err)
+		return ShoppingCartModel{}, err
 	}
-
 	return model, nil
 }
 
-func (r *MySQLShoppingCartRepository) GetAll(filter ShoppingCartParams) ([]ShoppingCartModel, error) {
-	query := `SELECT id, user_id, product_id, product_amount FROM shopping_cart WHERE 1=1 AND userID = ?`
-	args := []interface{}{filter.UserID}
+func (r *MySQLShoppingCartRepository) GetAll(ctx context.Context, where filter.Filter, page pagination.Page) ([]ShoppingCartModel, error) {
+	conditions, args := where.SQL()
+	query := `SELECT id, user_id, product_id, product_amount FROM shopping_cart WHERE 1=1` + conditions
+	query, args = sortFields.Apply(page, query, args)
 
-	rows, err := r.db.Query(query, args...)
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get shoppingCart: %w", err)
 	}
@@ -94,11 +128,22 @@ func (r *MySQLShoppingCartRepository) GetAll(filter ShoppingCartParams) ([]Shopp
 	return shopping_cart, nil
 }
 
-func (r *MySQLShoppingCartRepository) GetOne(id string) (ShoppingCartModel, error) {
+func (r *MySQLShoppingCartRepository) Count(ctx context.Context, where filter.Filter) (uint, error) {
+	conditions, args := where.SQL()
+	query := `SELECT COUNT(*) FROM shopping_cart WHERE 1=1` + conditions
+
+	var count uint
+	if err := r.db.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count shopping_cart: %w", err)
+	}
+	return count, nil
+}
+
+func (r *MySQLShoppingCartRepository) GetOne(ctx context.Context, id string) (ShoppingCartModel, error) {
 	query := `SELECT id, user_id, product_id, product_amount FROM shopping_cart WHERE id = ?`
 
 	var shoppingCart ShoppingCartModel
-	row := r.db.QueryRow(query, id)
+	row := r.db.QueryRowContext(ctx, query, id)
 	err := row.Scan(&shoppingCart.id,
 		&shoppingCart.userID,
 		&shoppingCart.productID,
@@ -106,57 +151,200 @@ func (r *MySQLShoppingCartRepository) GetOne(id string) (ShoppingCartModel, erro
 	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return ShoppingCartModel{}, fmt.Errorf("ShoppingCart not found")
+			return ShoppingCartModel{}, fmt.Errorf("shopping cart item %w", apperror.ErrNotFound)
 		}
 		return ShoppingCartModel{}, fmt.Errorf("failed to get ShoppingCart: %w", err)
 	}
 	return shoppingCart, nil
 }
 
-func (r *MySQLShoppingCartRepository) DeleteOne(id string) (uint, error) {
+func (r *MySQLShoppingCartRepository) DeleteOne(ctx context.Context, id string) (uint, error) {
 	query := `DELETE FROM shopping_cart WHERE id = ?`
-	res, err := r.db.Exec(query, id)
+	res, err := r.db.ExecContext(ctx, query, id)
 	if err != nil {
-		return 0, fmt.Errorf("failed to delete ShoppingCart: %w", err)
+		return 0, fmt.Errorf("failed to delete ShoppingCart: %w", db.TranslateError(err))
 	}
 	count, _ := res.RowsAffected()
 	if count == 0 {
-		return 0, fmt.Errorf("no ShoppingCart found with the given ID")
+		return 0, fmt.Errorf("shopping cart item %w", apperror.ErrNotFound)
 	}
 	return uint(count), nil
 }
 
-func (r *MySQLShoppingCartRepository) DeleteAll(filter ShoppingCartParams) (uint, error) {
-	query := `DELETE FROM shopping_cart WHERE 1=1 AND user_id = ?`
-	args := []interface{}{*filter.UserID}
+func (r *MySQLShoppingCartRepository) DeleteAll(ctx context.Context, where filter.Filter) (uint, error) {
+	conditions, args := where.SQL()
+	query := `DELETE FROM shopping_cart WHERE 1=1` + conditions
 
-	res, err := r.db.Exec(query, args...)
+	res, err := r.db.ExecContext(ctx, query, args...)
 	if err != nil {
-		return 0, fmt.Errorf("failed to delete shopping_cart: %w", err)
+		return 0, fmt.Errorf("failed to delete shopping_cart: %w", db.TranslateError(err))
 	}
 	count, _ := res.RowsAffected()
 	return uint(count), nil
 }
 
-func (r *MySQLShoppingCartRepository) Update(id string, newShoppingCart ShoppingCartParams) (ShoppingCartModel, error) {
-	// Since the productAmount is not nil
-	updatedShoppingCart := ShoppingCartModel{
-		productAmount: *newShoppingCart.ProductAmount,
+// Same as setting the amount with ChangeAmount, the stock is checked and the
+// hold renewed. Zero removes the line
+func (r *MySQLShoppingCartRepository) Update(ctx context.Context, id string, newShoppingCart ShoppingCartParams) (ShoppingCartModel, error) {
+	if newShoppingCart.ProductAmount == nil {
+		return ShoppingCartModel{}, apperror.NewValidationError("ProductAmount", "is required")
 	}
-	if *newShoppingCart.ProductAmount == 0 {
-		_, err := r.DeleteOne(id)
+	operation := SetAmount
+	return r.ChangeAmount(ctx, id, ShoppingCartAmountParams{Operation: &operation, ProductAmount: newShoppingCart.ProductAmount})
+}
+
+func (r *MySQLShoppingCartRepository) ChangeAmount(ctx context.Context, id string, amount ShoppingCartAmountParams) (ShoppingCartModel, error) {
+	operation := SetAmount
+	if amount.Operation != nil {
+		operation = *amount.Operation
+	}
+
+	current, err := r.GetOne(ctx, id)
+	if err != nil {
+		return ShoppingCartModel{}, err
+	}
+
+	var model ShoppingCartModel
+	// Same as Create, the product stays locked until the amount is written
+	err = db.WithTx(ctx, r.db, func(tx db.Executor) error {
+		// Only growing a line needs stock, dec always passes
+		if operation != DecrementAmount {
+			available, inCart, err := r.availableStock(ctx, tx, current.userID, current.productID, true)
+			if err != nil {
+				return err
+			}
+			// The locked read has the amount as it is now, not as GetOne saw it
+			wanted := *amount.ProductAmount
+			if operation == IncrementAmount {
+				wanted += inCart
+			}
+			if wanted > inCart && int64(wanted) > available {
+				return fmt.Errorf("not enough stock for product %s: %w", current.productID, apperror.ErrConflict)
+			}
+		}
+
+		// The amount is computed by MySQL so concurrent changes don't overwrite
+		// each other. The column is unsigned, dec can't go below zero
+		var query string
+		args := []interface{}{*amount.ProductAmount, r.reservedUntil(), id}
+		switch operation {
+		case IncrementAmount:
+			query = `UPDATE shopping_cart SET product_amount = product_amount + ?, reservedUntil = ? WHERE id = ?`
+		case DecrementAmount:
+			query = `UPDATE shopping_cart SET product_amount = IF(product_amount > ?, product_amount - ?, 0), reservedUntil = ? WHERE id = ?`
+			args = []interface{}{*amount.ProductAmount, *amount.ProductAmount, r.reservedUntil(), id}
+		default:
+			query = `UPDATE shopping_cart SET product_amount = ?, reservedUntil = ? WHERE id = ?`
+		}
+
+		_, err := tx.ExecContext(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("failed to update shoppingCart: %w", db.TranslateError(err))
+		}
+
+		query = `DELETE FROM shopping_cart WHERE id = ? AND product_amount = 0`
+		res, err := tx.ExecContext(ctx, query, id)
 		if err != nil {
-			return ShoppingCartModel{}, err
+			return fmt.Errorf("failed to delete ShoppingCart: %w", db.TranslateError(err))
+		}
+		if count, _ := res.RowsAffected(); count > 0 {
+			// Only the id is known, the amount of a removed line is zero
+			model = ShoppingCartModel{id: id}
+			return nil
 		}
-		return ShoppingCartModel{}, nil
+
+		model, err = NewMySQLShoppingCartRepository(tx, r.clock).GetOne(ctx, id)
+		return err
+	})
+	if err != nil {
+		return ShoppingCartModel{}, err
 	}
-	query := `UPDATE shopping_cart SET product_amount = ? WHERE id = ?`
+	return model, nil
+}
 
-	_, err := r.db.Exec(query,
-		updatedShoppingCart.productAmount,
-		id)
+func (r *MySQLShoppingCartRepository) Summary(ctx context.Context, userID string) (ShoppingCartSummaryModel, error) {
+	// Purged products cascade to the cart, so every row has its product
+	query := `
+		SELECT
+			c.id, c.user_id, c.product_id, c.product_amount,
+			p.name, p.price, p.weightGrams, p.isActive, p.isDeleted
+		FROM
+			shopping_cart c
+		JOIN
+			products p ON p.id = c.product_id
+		WHERE
+			c.user_id = ?
+		ORDER BY
+			c.id`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
 	if err != nil {
-		return ShoppingCartModel{}, fmt.Errorf("failed to update shoppingCart: %w", err)
+		return ShoppingCartSummaryModel{}, fmt.Errorf("failed to get shopping cart summary: %w", err)
 	}
-	return r.GetOne(id)
+	defer rows.Close()
+
+	summary := ShoppingCartSummaryModel{userID: userID}
+	for rows.Next() {
+		var item ShoppingCartSummaryItemModel
+		err := rows.Scan(&item.id,
+			&item.userID,
+			&item.productID,
+			&item.productAmount,
+			&item.name,
+			&item.price,
+			&item.weightGrams,
+			&item.isActive,
+			&item.isDeleted,
+		)
+		if err != nil {
+			return ShoppingCartSummaryModel{}, fmt.Errorf("failed to scan shopping cart summary: %w", err)
+		}
+		summary.items = append(summary.items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return ShoppingCartSummaryModel{}, fmt.Errorf("failed to iterate shopping cart summary: %w", err)
+	}
+
+	return summary, nil
+}
+
+// Returns how much of the product the user can have in the cart: the stock
+// that is neither reserved by a delivery nor held by another user's cart. Also
+// returns the amount already in the user's cart. With lock the product row is
+// held until the transaction ends
+func (r *MySQLShoppingCartRepository) availableStock(ctx context.Context, executor db.Executor, userID, productID string, lock bool) (int64, uint, error) {
+	// The columns are unsigned, MySQL refuses a negative unsigned result
+	query := `
+		SELECT
+			CAST(p.stock AS SIGNED) - CAST(p.reserved AS SIGNED) - COALESCE((
+				SELECT SUM(o.product_amount) FROM shopping_cart o
+				WHERE o.product_id = p.id AND o.user_id <> ? AND o.reservedUntil > ?
+			), 0),
+			COALESCE((
+				SELECT c.product_amount FROM shopping_cart c
+				WHERE c.product_id = p.id AND c.user_id = ?
+			), 0)
+		FROM
+			products p
+		WHERE
+			p.id = ? AND p.isDeleted = FALSE`
+	if lock {
+		query += ` FOR UPDATE`
+	}
+
+	var available int64
+	var inCart uint
+	now := timestamp.New(r.clock.Now())
+	err := executor.QueryRowContext(ctx, query, userID, now, userID, productID).Scan(&available, &inCart)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, 0, fmt.Errorf("product %w", apperror.ErrNotFound)
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get product stock: %w", err)
+	}
+	return available, inCart, nil
+}
+
+func (r *MySQLShoppingCartRepository) reservedUntil() timestamp.Time {
+	return timestamp.New(r.clock.Now().Add(holdDuration))
 }
@@ -1,10 +1,15 @@
 package shopping_cart_test
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
 	"regexp"
+	"sipub-test/internal/pagination"
 	"sipub-test/internal/shopping_cart"
+	"sipub-test/pkg/apperror"
+	"sipub-test/pkg/clock"
+	"sipub-test/pkg/money"
 	testhelper "sipub-test/pkg/test_helper"
 	"testing"
 
@@ -20,8 +25,7 @@ func TestCreateShoppingCart(t *testing.T) {
 		}
 		defer db.Close()
 
-		repo := &shopping_cart.MySQLShoppingCartRepository{}
-		repo.SetDB(db)
+		repo := shopping_cart.NewMySQLShoppingCartRepository(db, clock.System{})
 
 		params := shopping_cart.ShoppingCartParams{
 			UserID:        testhelper.StringPointer("user-123"),
@@ -29,16 +33,104 @@ func TestCreateShoppingCart(t *testing.T) {
 			ProductAmount: testhelper.UintPointer(5),
 		}
 
+		mock.ExpectBegin()
+		expectStock(mock, "user-123", "product-456", 10, 0)
 		mock.ExpectExec(`INSERT INTO shopping_cart`).
-			WithArgs(sqlmock.AnyArg(), *params.UserID, *params.ProductID, *params.ProductAmount).
+			WithArgs(sqlmock.AnyArg(), *params.UserID, *params.ProductID, *params.ProductAmount, sqlmock.AnyArg() /*reservedUntil*/).
 			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
 
-		cart, err := repo.Create(params)
+		cart, err := repo.Create(context.Background(), params)
 
 		assert.NoError(t, err, "Shouldn't contain any errors")
 		assert.Equal(t, *params.UserID, cart.ToDTO().UserID)
 		assert.Equal(t, *params.ProductID, cart.ToDTO().ProductID)
 		assert.Equal(t, *params.ProductAmount, cart.ToDTO().ProductAmount)
+		assert.NoError(t, mock.ExpectationsWereMet(), "The product should be locked until the line is written")
+	})
+}
+
+func TestCreateShoppingCartMerge(t *testing.T) {
+	t.Run("ShouldIncrementTheExistingLine", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := shopping_cart.NewMySQLShoppingCartRepository(db, clock.System{})
+
+		params := shopping_cart.ShoppingCartParams{
+			UserID:        testhelper.StringPointer("user-123"),
+			ProductID:     testhelper.StringPointer("product-456"),
+			ProductAmount: testhelper.UintPointer(2),
+		}
+
+		mock.ExpectBegin()
+		expectStock(mock, "user-123", "product-456", 10, 3)
+		mock.ExpectExec(`INSERT INTO shopping_cart .* ON DUPLICATE KEY UPDATE product_amount = product_amount \+ VALUES\(product_amount\)`).
+			WithArgs(sqlmock.AnyArg(), "user-123", "product-456", uint(2), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, user_id, product_id, product_amount FROM shopping_cart WHERE user_id = ? AND product_id = ?`)).
+			WithArgs("user-123", "product-456").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "product_id", "product_amount"}).
+				AddRow("cart-123", "user-123", "product-456", 5))
+		mock.ExpectCommit()
+
+		cart, err := repo.Create(context.Background(), params)
+
+		assert.NoError(t, err, "Shouldn't contain any errors")
+		assert.Equal(t, "cart-123", cart.ToDTO().Id, "The existing line should be returned")
+		assert.Equal(t, uint(5), cart.ToDTO().ProductAmount)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestCreateShoppingCartStock(t *testing.T) {
+	t.Run("ShouldRejectMoreThanTheAvailableStock", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := shopping_cart.NewMySQLShoppingCartRepository(db, clock.System{})
+
+		// 3 already in the cart, adding 2 more needs 5
+		mock.ExpectBegin()
+		expectStock(mock, "user-123", "product-456", 4, 3)
+		mock.ExpectRollback()
+
+		_, err = repo.Create(context.Background(), shopping_cart.ShoppingCartParams{
+			UserID:        testhelper.StringPointer("user-123"),
+			ProductID:     testhelper.StringPointer("product-456"),
+			ProductAmount: testhelper.UintPointer(2),
+		})
+
+		assert.True(t, errors.Is(err, apperror.ErrConflict), "Should be reported as a conflict")
+		assert.NoError(t, mock.ExpectationsWereMet(), "Nothing should be inserted")
+	})
+	t.Run("ShouldRejectUnknownProducts", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := shopping_cart.NewMySQLShoppingCartRepository(db, clock.System{})
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`FROM\s+products p`).
+			WillReturnRows(sqlmock.NewRows([]string{"available", "in_cart"}))
+		mock.ExpectRollback()
+
+		_, err = repo.Create(context.Background(), shopping_cart.ShoppingCartParams{
+			UserID:        testhelper.StringPointer("user-123"),
+			ProductID:     testhelper.StringPointer("missing"),
+			ProductAmount: testhelper.UintPointer(1),
+		})
+
+		assert.True(t, errors.Is(err, apperror.ErrNotFound))
 	})
 }
 
@@ -50,18 +142,17 @@ func TestGetAllShoppingCarts(t *testing.T) {
 		}
 		defer db.Close()
 
-		repo := &shopping_cart.MySQLShoppingCartRepository{}
-		repo.SetDB(db)
+		repo := shopping_cart.NewMySQLShoppingCartRepository(db, clock.System{})
 
 		rows := sqlmock.NewRows([]string{"id", "user_id", "product_id", "product_amount"}).
 			AddRow("cart-123", "user-123", "product-456", 5)
 
-		mock.ExpectQuery(`SELECT id, user_id, product_id, product_amount FROM shopping_cart WHERE 1=1 AND userID = ?`).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, user_id, product_id, product_amount FROM shopping_cart WHERE 1=1 AND user_id = ? ORDER BY id ASC`)).
 			WithArgs("user-123").
 			WillReturnRows(rows)
 
-		filter := shopping_cart.ShoppingCartParams{UserID: testhelper.StringPointer("user-123")}
-		carts, err := repo.GetAll(filter)
+		where := testhelper.FilterEqual("UserID", "user_id", "user-123")
+		carts, err := repo.GetAll(context.Background(), where, pagination.Page{})
 
 		assert.NoError(t, err, "Should have no errors")
 		assert.Len(t, carts, 1, "Length should be 1")
@@ -75,21 +166,40 @@ func TestGetAllShoppingCarts(t *testing.T) {
 		}
 		defer db.Close()
 
-		repo := &shopping_cart.MySQLShoppingCartRepository{}
-		repo.SetDB(db)
+		repo := shopping_cart.NewMySQLShoppingCartRepository(db, clock.System{})
 
-		mock.ExpectQuery(`SELECT id, user_id, product_id, product_amount FROM shopping_cart WHERE 1=1 AND userID = ?`).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, user_id, product_id, product_amount FROM shopping_cart WHERE 1=1 AND user_id = ? ORDER BY id ASC`)).
 			WithArgs("nonexistent-user").
 			WillReturnError(fmt.Errorf("failed to get shopping_cart"))
 
-		filter := shopping_cart.ShoppingCartParams{UserID: testhelper.StringPointer("nonexistent-user")}
-		carts, err := repo.GetAll(filter)
+		where := testhelper.FilterEqual("UserID", "user_id", "nonexistent-user")
+		carts, err := repo.GetAll(context.Background(), where, pagination.Page{})
 
 		assert.Error(t, err, "Should have an error")
 		assert.Len(t, carts, 0, "Length should be 0")
 	})
 }
 
+func TestCountShoppingCarts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock DB: %v", err)
+	}
+	defer db.Close()
+
+	repo := shopping_cart.NewMySQLShoppingCartRepository(db, clock.System{})
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT COUNT(*) FROM shopping_cart WHERE 1=1 AND user_id = ?`)).
+		WithArgs("user-123").
+		WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(2))
+
+	count, err := repo.Count(context.Background(), testhelper.FilterEqual("UserID", "user_id", "user-123"))
+
+	assert.NoError(t, err, "Should have no errors")
+	assert.Equal(t, uint(2), count)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestGetShoppingCartByID(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -97,8 +207,7 @@ func TestGetShoppingCartByID(t *testing.T) {
 	}
 	defer db.Close()
 
-	repo := &shopping_cart.MySQLShoppingCartRepository{}
-	repo.SetDB(db)
+	repo := shopping_cart.NewMySQLShoppingCartRepository(db, clock.System{})
 
 	rows := sqlmock.NewRows([]string{"id", "user_id", "product_id", "product_amount"}).
 		AddRow("cart-123", "user-123", "product-456", 5)
@@ -107,7 +216,7 @@ func TestGetShoppingCartByID(t *testing.T) {
 		WithArgs("cart-123").
 		WillReturnRows(rows)
 
-	cart, err := repo.GetOne("cart-123")
+	cart, err := repo.GetOne(context.Background(), "cart-123")
 
 	assert.NoError(t, err, "Should have no errors")
 	assert.Equal(t, "cart-123", cart.ToDTO().Id, "Id should be the same")
@@ -123,20 +232,26 @@ func TestDeleteShoppingCart(t *testing.T) {
 	}
 	defer db.Close()
 
-	repo := &shopping_cart.MySQLShoppingCartRepository{}
-	repo.SetDB(db)
+	repo := shopping_cart.NewMySQLShoppingCartRepository(db, clock.System{})
 
 	mock.ExpectExec(`DELETE FROM shopping_cart WHERE id = ?`).
 		WithArgs("cart-123").
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	count, err := repo.DeleteOne("cart-123")
+	count, err := repo.DeleteOne(context.Background(), "cart-123")
 
 	assert.NoError(t, err)
 	assert.Equal(t, uint(1), count)
 }
 
 func TestUpdateShoppingCart(t *testing.T) {
+	expectLine := func(mock sqlmock.Sqlmock, amount uint) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, user_id, product_id, product_amount FROM shopping_cart WHERE id = ?`)).
+			WithArgs("123").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "product_id", "product_amount"}).
+				AddRow("123", "user-1", "product-1", amount))
+	}
+
 	t.Run("ValidUpdateWithNonZeroAmount", func(t *testing.T) {
 		db, mock, err := sqlmock.New()
 		if err != nil {
@@ -144,35 +259,51 @@ func TestUpdateShoppingCart(t *testing.T) {
 		}
 		defer db.Close()
 
-		repo := &shopping_cart.MySQLShoppingCartRepository{}
-		repo.SetDB(db)
+		repo := shopping_cart.NewMySQLShoppingCartRepository(db, clock.System{})
 
 		newParams := shopping_cart.ShoppingCartParams{
 			ProductAmount: testhelper.UintPointer(5),
 		}
 
-		// Create a new row for existing shopping cart
-		sqlmock.NewRows([]string{"id", "user_id", "product_id", "product_amount"}).
-			AddRow("123", "user-1", "product-1", 3)
-		// UPDATE query
-		mock.ExpectExec(regexp.QuoteMeta(`UPDATE shopping_cart SET product_amount = ? WHERE id = ?`)).
-			WithArgs(5, "123").
-			WillReturnResult(sqlmock.NewResult(1, 1))
-
-		// Final SELECT for updated shopping cart
-		updatedRows := sqlmock.NewRows([]string{"id", "user_id", "product_id", "product_amount"}).
-			AddRow("123", "user-1", "product-1", 5)
-
-		mock.ExpectQuery(`SELECT id, user_id, product_id, product_amount FROM shopping_cart WHERE id = ?`).
+		// Goes through the stock check and renews the hold, like a set
+		expectLine(mock, 3)
+		mock.ExpectBegin()
+		expectStock(mock, "user-1", "product-1", 5, 3)
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE shopping_cart SET product_amount = ?, reservedUntil = ? WHERE id = ?`)).
+			WithArgs(uint(5), sqlmock.AnyArg(), "123").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM shopping_cart WHERE id = ? AND product_amount = 0`)).
 			WithArgs("123").
-			WillReturnRows(updatedRows)
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		expectLine(mock, 5)
+		mock.ExpectCommit()
 
-		shoppingCart, err := repo.Update("123", newParams)
+		shoppingCart, err := repo.Update(context.Background(), "123", newParams)
 
-		log.Println(err)
 		assert.NoError(t, err, "Should contain no errors")
 		assert.Equal(t, "123", shoppingCart.ToDTO().Id, "ID should remain the same")
 		assert.Equal(t, uint(5), shoppingCart.ToDTO().ProductAmount, "Product amount should be updated")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("ShouldRejectMoreThanTheAvailableStock", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := shopping_cart.NewMySQLShoppingCartRepository(db, clock.System{})
+
+		expectLine(mock, 3)
+		mock.ExpectBegin()
+		expectStock(mock, "user-1", "product-1", 4, 3)
+		mock.ExpectRollback()
+
+		_, err = repo.Update(context.Background(), "123", shopping_cart.ShoppingCartParams{ProductAmount: testhelper.UintPointer(5)})
+
+		assert.True(t, errors.Is(err, apperror.ErrConflict))
+		assert.NoError(t, mock.ExpectationsWereMet(), "Nothing should be updated")
 	})
 
 	t.Run("ValidUpdateWithZeroAmount", func(t *testing.T) {
@@ -182,21 +313,248 @@ func TestUpdateShoppingCart(t *testing.T) {
 		}
 		defer db.Close()
 
-		repo := &shopping_cart.MySQLShoppingCartRepository{}
-		repo.SetDB(db)
+		repo := shopping_cart.NewMySQLShoppingCartRepository(db, clock.System{})
 
 		newParams := shopping_cart.ShoppingCartParams{
 			ProductAmount: testhelper.UintPointer(0),
 		}
 
-		// DELETE query when ProductAmount is zero
-		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM shopping_cart WHERE id = ?`)).
+		expectLine(mock, 3)
+		mock.ExpectBegin()
+		expectStock(mock, "user-1", "product-1", 5, 3)
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE shopping_cart SET product_amount = ?, reservedUntil = ? WHERE id = ?`)).
+			WithArgs(uint(0), sqlmock.AnyArg(), "123").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM shopping_cart WHERE id = ? AND product_amount = 0`)).
 			WithArgs("123").
-			WillReturnResult(sqlmock.NewResult(1, 1))
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
 
-		shoppingCart, err := repo.Update("123", newParams)
+		shoppingCart, err := repo.Update(context.Background(), "123", newParams)
 
 		assert.NoError(t, err, "Should not return an error")
-		assert.Equal(t, shopping_cart.ShoppingCartModel{}, shoppingCart, "Should return an empty ShoppingCartModel")
+		assert.Equal(t, uint(0), shoppingCart.ToDTO().ProductAmount, "A removed line has no amount")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("ShouldRequireTheAmount", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := shopping_cart.NewMySQLShoppingCartRepository(db, clock.System{})
+
+		_, err = repo.Update(context.Background(), "123", shopping_cart.ShoppingCartParams{})
+
+		assert.True(t, errors.Is(err, apperror.ErrValidation))
+		assert.NoError(t, mock.ExpectationsWereMet(), "Nothing should be read")
+	})
+}
+
+func TestShoppingCartSummary(t *testing.T) {
+	t.Run("ValidSummary", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := shopping_cart.NewMySQLShoppingCartRepository(db, clock.System{})
+
+		rows := sqlmock.NewRows([]string{"id", "user_id", "product_id", "product_amount", "name", "price", "weightGrams", "isActive", "isDeleted"}).
+			AddRow("cart-1", "user-123", "product-1", 3, "Shirt", 19.99, 200, true, false).
+			AddRow("cart-2", "user-123", "product-2", 1, "Mug", 5.5, 350, true, false).
+			AddRow("cart-3", "user-123", "product-3", 2, "Hat", 10, 100, false, false).
+			AddRow("cart-4", "user-123", "product-4", 1, "Pants", 40, 500, true, true)
+
+		mock.ExpectQuery(`FROM\s+shopping_cart c\s+JOIN\s+products p ON p.id = c.product_id\s+WHERE\s+c.user_id = \?`).
+			WithArgs("user-123").
+			WillReturnRows(rows)
+
+		summary, err := repo.Summary(context.Background(), "user-123")
+		dto := summary.ToDTO()
+
+		assert.NoError(t, err, "Shouldn't contain any errors")
+		assert.Len(t, dto.Items, 4, "Unavailable products should still be listed")
+		assert.Equal(t, money.FromCents(5997), dto.Items[0].Subtotal)
+		assert.Equal(t, float32(600), dto.Items[0].WeightGrams)
+		assert.False(t, dto.Items[2].IsAvailable, "Inactive products are unavailable")
+		assert.False(t, dto.Items[3].IsAvailable, "Deleted products are unavailable")
+		assert.True(t, dto.HasUnavailable)
+		assert.Equal(t, uint(4), dto.ItemCount, "Only available items are counted")
+		assert.Equal(t, float32(950), dto.TotalWeightGrams)
+		assert.Equal(t, money.FromCents(6547), dto.Total)
+	})
+
+	t.Run("EmptyCart", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := shopping_cart.NewMySQLShoppingCartRepository(db, clock.System{})
+
+		mock.ExpectQuery(`FROM\s+shopping_cart c`).
+			WithArgs("user-123").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "product_id", "product_amount", "name", "price", "weightGrams", "isActive", "isDeleted"}))
+
+		summary, err := repo.Summary(context.Background(), "user-123")
+		dto := summary.ToDTO()
+
+		assert.NoError(t, err, "An empty cart is not an error")
+		assert.Equal(t, "user-123", dto.UserID)
+		assert.Empty(t, dto.Items)
+		assert.Equal(t, money.Zero, dto.Total)
+	})
+}
+
+func TestChangeShoppingCartAmount(t *testing.T) {
+	increment := shopping_cart.IncrementAmount
+	decrement := shopping_cart.DecrementAmount
+
+	expectLine := func(mock sqlmock.Sqlmock, amount uint) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, user_id, product_id, product_amount FROM shopping_cart WHERE id = ?`)).
+			WithArgs("cart-123").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "product_id", "product_amount"}).
+				AddRow("cart-123", "user-123", "product-456", amount))
+	}
+
+	t.Run("ShouldIncrement", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := shopping_cart.NewMySQLShoppingCartRepository(db, clock.System{})
+
+		expectLine(mock, 5)
+		mock.ExpectBegin()
+		expectStock(mock, "user-123", "product-456", 7, 5)
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE shopping_cart SET product_amount = product_amount + ?, reservedUntil = ? WHERE id = ?`)).
+			WithArgs(uint(2), sqlmock.AnyArg(), "cart-123").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM shopping_cart WHERE id = ? AND product_amount = 0`)).
+			WithArgs("cart-123").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		expectLine(mock, 7)
+		mock.ExpectCommit()
+
+		cart, err := repo.ChangeAmount(context.Background(), "cart-123", shopping_cart.ShoppingCartAmountParams{
+			Operation:     &increment,
+			ProductAmount: testhelper.UintPointer(2),
+		})
+
+		assert.NoError(t, err, "Shouldn't contain any errors")
+		assert.Equal(t, uint(7), cart.ToDTO().ProductAmount)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("ShouldRejectMoreThanTheAvailableStock", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := shopping_cart.NewMySQLShoppingCartRepository(db, clock.System{})
+
+		expectLine(mock, 5)
+		mock.ExpectBegin()
+		expectStock(mock, "user-123", "product-456", 6, 5)
+		mock.ExpectRollback()
+
+		_, err = repo.ChangeAmount(context.Background(), "cart-123", shopping_cart.ShoppingCartAmountParams{
+			Operation:     &increment,
+			ProductAmount: testhelper.UintPointer(2),
+		})
+
+		assert.True(t, errors.Is(err, apperror.ErrConflict))
+		assert.NoError(t, mock.ExpectationsWereMet(), "Nothing should be updated")
+	})
+
+	t.Run("ShouldCheckTheAmountSeenUnderTheLock", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := shopping_cart.NewMySQLShoppingCartRepository(db, clock.System{})
+
+		// Another request grew the line to 5 after it was read
+		expectLine(mock, 3)
+		mock.ExpectBegin()
+		expectStock(mock, "user-123", "product-456", 6, 5)
+		mock.ExpectRollback()
+
+		_, err = repo.ChangeAmount(context.Background(), "cart-123", shopping_cart.ShoppingCartAmountParams{
+			Operation:     &increment,
+			ProductAmount: testhelper.UintPointer(2),
+		})
+
+		assert.True(t, errors.Is(err, apperror.ErrConflict))
+		assert.NoError(t, mock.ExpectationsWereMet())
 	})
+
+	t.Run("ShouldRemoveTheLineAtZero", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := shopping_cart.NewMySQLShoppingCartRepository(db, clock.System{})
+
+		// Decrementing never needs stock
+		expectLine(mock, 5)
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE shopping_cart SET product_amount = IF(product_amount > ?, product_amount - ?, 0), reservedUntil = ? WHERE id = ?`)).
+			WithArgs(uint(10), uint(10), sqlmock.AnyArg(), "cart-123").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM shopping_cart WHERE id = ? AND product_amount = 0`)).
+			WithArgs("cart-123").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		cart, err := repo.ChangeAmount(context.Background(), "cart-123", shopping_cart.ShoppingCartAmountParams{
+			Operation:     &decrement,
+			ProductAmount: testhelper.UintPointer(10),
+		})
+
+		assert.NoError(t, err, "Shouldn't contain any errors")
+		assert.Equal(t, "cart-123", cart.ToDTO().Id)
+		assert.Equal(t, uint(0), cart.ToDTO().ProductAmount, "A removed line has no amount")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("ShouldReportUnknownLines", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := shopping_cart.NewMySQLShoppingCartRepository(db, clock.System{})
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, user_id, product_id, product_amount FROM shopping_cart WHERE id = ?`)).
+			WithArgs("missing").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "product_id", "product_amount"}))
+
+		_, err = repo.ChangeAmount(context.Background(), "missing", shopping_cart.ShoppingCartAmountParams{
+			ProductAmount: testhelper.UintPointer(3),
+		})
+
+		assert.True(t, errors.Is(err, apperror.ErrNotFound), "An unknown line should be reported")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func expectStock(mock sqlmock.Sqlmock, userID, productID string, available int64, inCart uint) {
+	mock.ExpectQuery(`FROM\s+products p\s+WHERE\s+p.id = \? AND p.isDeleted = FALSE FOR UPDATE`).
+		WithArgs(userID, sqlmock.AnyArg() /*now*/, userID, productID).
+		WillReturnRows(sqlmock.NewRows([]string{"available", "in_cart"}).AddRow(available, inCart))
 }
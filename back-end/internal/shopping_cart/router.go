@@ -5,16 +5,22 @@ import (
 	"sipub-test/internal"
 )
 
+// The cart has routes on top of the CRUD ones
+type IShoppingCartController interface {
+	internal.IController
+
+	ChangeAmount(http.ResponseWriter, *http.Request)
+
+	Summary(http.ResponseWriter, *http.Request)
+}
+
 type ShoppingCartRouter struct {
 	baseEndPoint string
-	controller   internal.IController
+	controller   IShoppingCartController
 }
 
-func NewShoppingCartRouter() ShoppingCartRouter {
-	router := ShoppingCartRouter{
-		controller: NewShoppingCartController(),
-	}
-	return router
+func NewShoppingCartRouter(controller IShoppingCartController) ShoppingCartRouter {
+	return ShoppingCartRouter{controller: controller}
 }
 
 func (r ShoppingCartRouter) Init(mux *http.ServeMux) {
@@ -22,10 +28,12 @@ func (r ShoppingCartRouter) Init(mux *http.ServeMux) {
 
 	r.create(mux)
 	r.getAll(mux)
+	r.summary(mux)
 	r.getOne(mux)
 	r.deleteAll(mux)
 	r.deleteOne(mux)
 	r.update(mux)
+	r.changeAmount(mux)
 }
 
 func (r ShoppingCartRouter) create(mux *http.ServeMux) {
@@ -36,6 +44,11 @@ func (r ShoppingCartRouter) getAll(mux *http.ServeMux) {
 	mux.HandleFunc("GET "+r.baseEndPoint, r.controller.GetAll)
 }
 
+// More specific than /{id}, so the mux picks it over getOne
+func (r ShoppingCartRouter) summary(mux *http.ServeMux) {
+	mux.HandleFunc("GET "+r.baseEndPoint+"/summary", r.controller.Summary)
+}
+
 func (r ShoppingCartRouter) getOne(mux *http.ServeMux) {
 	mux.HandleFunc("GET "+r.baseEndPoint+"/{id}", r.controller.GetOne)
 }
@@ -51,3 +64,7 @@ func (r ShoppingCartRouter) deleteOne(mux *http.ServeMux) {
 func (r ShoppingCartRouter) update(mux *http.ServeMux) {
 	mux.HandleFunc("PUT "+r.baseEndPoint+"/{id}", r.controller.Update)
 }
+
+func (r ShoppingCartRouter) changeAmount(mux *http.ServeMux) {
+	mux.HandleFunc("PATCH "+r.baseEndPoint+"/{id}", r.controller.ChangeAmount)
+}
@@ -1,7 +1,7 @@
 package shopping_cart
 
 import (
-	"errors"
+	"sipub-test/pkg/apperror"
 )
 
 type ShoppingCartValidator struct{}
@@ -10,7 +10,34 @@ type ShoppingCartValidator struct{}
 
 func (v *ShoppingCartValidator) Validate(shoppingCart ShoppingCartParams) error {
 	if shoppingCart.UserID == nil {
-		return errors.New("Empty UserID")
+		return apperror.NewValidationError("UserID", "is required")
+	}
+	if shoppingCart.ProductID == nil {
+		return apperror.NewValidationError("ProductID", "is required")
+	}
+	if shoppingCart.ProductAmount == nil || *shoppingCart.ProductAmount == 0 {
+		return apperror.NewValidationError("ProductAmount", "must be greater than zero")
+	}
+	return nil
+}
+
+type ShoppingCartAmountValidator struct{}
+
+func (v *ShoppingCartAmountValidator) Validate(amount ShoppingCartAmountParams) error {
+	if amount.ProductAmount == nil {
+		return apperror.NewValidationError("ProductAmount", "is required")
+	}
+	if amount.Operation == nil {
+		return nil
+	}
+	switch *amount.Operation {
+	case SetAmount:
+	case IncrementAmount, DecrementAmount:
+		if *amount.ProductAmount == 0 {
+			return apperror.NewValidationError("ProductAmount", "must be greater than zero")
+		}
+	default:
+		return apperror.NewValidationError("Operation", "must be one of set, inc or dec")
 	}
 	return nil
 }
@@ -0,0 +1,114 @@
+// Runs writes that span several repositories in a single transaction. The
+// callback receives the same repositories used by the controllers, but bound
+// to the transaction, and whatever it returns decides between commit and
+// rollback:
+//
+//	err := uow.Do(ctx, func(ctx context.Context, repos unit_of_work.Repositories) error {
+//		created, err := repos.Delivery.Create(ctx, params)
+//		...
+//	})
+
+package unit_of_work
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sipub-test/db"
+	"sipub-test/internal/address"
+	"sipub-test/internal/delivery"
+	"sipub-test/internal/delivery_product"
+	"sipub-test/internal/payment"
+	"sipub-test/internal/product"
+	"sipub-test/internal/shopping_cart"
+	"sipub-test/internal/user"
+	"sipub-test/internal/user_address"
+	"sipub-test/internal/user_delivery"
+	"sipub-test/pkg/clock"
+)
+
+// Returned when Do is called from inside another Do. MySQL has no real nested
+// transactions, the inner call would silently run outside of the outer one
+var ErrNestedUnitOfWork = errors.New("unit of work already running in this context")
+
+// Marks the contexts handed to the callbacks
+type txKey struct{}
+
+// Every repository bound to the same transaction
+type Repositories struct {
+	Address         address.IAddressRepository
+	Delivery        delivery.IDeliveryRepository
+	DeliveryProduct delivery_product.IDeliveryProductRepository
+	Payment         payment.IPaymentRepository
+	Product         product.IProductRepository
+	ShoppingCart    shopping_cart.IShoppingCartRepository
+	User            user.IUserRepository
+	UserAddress     user_address.IUserAddressRepository
+	UserDelivery    user_delivery.IUserDeliveryRepository
+
+	// For the queries no repository covers, like locking rows
+	Tx db.Executor
+}
+
+type UnitOfWork struct {
+	db    *sql.DB
+	clock clock.Clock
+}
+
+func NewUnitOfWork(db *sql.DB, clock clock.Clock) *UnitOfWork {
+	return &UnitOfWork{db: db, clock: clock}
+}
+
+// Commits if fn returns nil, rolls back otherwise. A panic in fn also rolls
+// back before being propagated
+func (u *UnitOfWork) Do(ctx context.Context, fn func(ctx context.Context, repos Repositories) error) (err error) {
+	if InTransaction(ctx) {
+		return ErrNestedUnitOfWork
+	}
+
+	tx, err := u.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(context.WithValue(ctx, txKey{}, true), u.repositories(tx)); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			return errors.Join(err, fmt.Errorf("failed to rollback transaction: %w", rollbackErr))
+		}
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", db.TranslateError(err))
+	}
+	return nil
+}
+
+// Whether ctx was handed out by Do
+func InTransaction(ctx context.Context) bool {
+	inTx, _ := ctx.Value(txKey{}).(bool)
+	return inTx
+}
+
+func (u *UnitOfWork) repositories(tx *sql.Tx) Repositories {
+	return Repositories{
+		Address:         address.NewMySQLAddressRepository(tx, u.clock),
+		Delivery:        delivery.NewMySQLDeliveryRepository(tx, u.clock),
+		DeliveryProduct: delivery_product.NewMySQLDeliveryProductRepository(tx),
+		Payment:         payment.NewMySQLPaymentRepository(tx, u.clock),
+		Product:         product.NewMySQLProductRepository(tx, u.clock),
+		ShoppingCart:    shopping_cart.NewMySQLShoppingCartRepository(tx, u.clock),
+		User:            user.NewMySQLUserRepository(tx, u.clock),
+		UserAddress:     user_address.NewMySQLUserAddressRepository(tx),
+		UserDelivery:    user_delivery.NewMySQLUserDeliveryRepository(tx),
+		Tx:              tx,
+	}
+}
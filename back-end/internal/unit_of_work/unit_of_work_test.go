@@ -0,0 +1,106 @@
+package unit_of_work_test
+
+import (
+	"context"
+	"errors"
+	"sipub-test/internal/unit_of_work"
+	"sipub-test/internal/user_delivery"
+	"sipub-test/pkg/clock"
+	testhelper "sipub-test/pkg/test_helper"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDo(t *testing.T) {
+	t.Run("ShouldCommitTheRepositoriesWrites", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		uow := unit_of_work.NewUnitOfWork(db, clock.System{})
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`INSERT INTO user_delivery`).
+			WithArgs(sqlmock.AnyArg(), "delivery-123", "user-123").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		err = uow.Do(context.Background(), func(ctx context.Context, repos unit_of_work.Repositories) error {
+			_, err := repos.UserDelivery.Create(ctx, user_delivery.UserDeliveryParams{
+				DeliveryID: testhelper.StringPointer("delivery-123"),
+				UserID:     testhelper.StringPointer("user-123"),
+			})
+			return err
+		})
+
+		assert.NoError(t, err, "Shouldn't contain any errors")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("ShouldRollbackWhenTheCallbackFails", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		uow := unit_of_work.NewUnitOfWork(db, clock.System{})
+		callbackErr := errors.New("out of stock")
+
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+
+		err = uow.Do(context.Background(), func(ctx context.Context, repos unit_of_work.Repositories) error {
+			return callbackErr
+		})
+
+		assert.ErrorIs(t, err, callbackErr, "The callback error should be returned as is")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("ShouldRollbackOnPanic", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		uow := unit_of_work.NewUnitOfWork(db, clock.System{})
+
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+
+		assert.Panics(t, func() {
+			uow.Do(context.Background(), func(ctx context.Context, repos unit_of_work.Repositories) error {
+				panic("boom")
+			})
+		})
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("ShouldRejectNestedCalls", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		uow := unit_of_work.NewUnitOfWork(db, clock.System{})
+
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+
+		err = uow.Do(context.Background(), func(ctx context.Context, repos unit_of_work.Repositories) error {
+			assert.True(t, unit_of_work.InTransaction(ctx))
+			return uow.Do(ctx, func(ctx context.Context, repos unit_of_work.Repositories) error {
+				t.Error("The nested callback shouldn't run")
+				return nil
+			})
+		})
+
+		assert.ErrorIs(t, err, unit_of_work.ErrNestedUnitOfWork)
+		assert.False(t, unit_of_work.InTransaction(context.Background()))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
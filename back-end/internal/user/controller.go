@@ -3,217 +3,212 @@ package user
 import (
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"sipub-test/internal"
+	"sipub-test/internal/auth"
+	"sipub-test/internal/filter"
+	"sipub-test/internal/middleware"
+	"sipub-test/internal/pagination"
+	"sipub-test/internal/response"
+	"sipub-test/pkg/apperror"
 	"strconv"
-	"strings"
 )
 
+// The CPF is masked in every response unless GetOne is called with it
+const revealCpfKey = "revealCpf"
+
 type UserController struct {
-	// TODO
-	validator  UserValidator
 	repository IUserRepository
+	validator  internal.IValidator[UserParams]
+	verifier   IVerificationSender
+	logger     *slog.Logger
 }
 
-// Used for testing
-func (c *UserController) SetRepository(repo IUserRepository) {
-	c.repository = repo
-}
-
-func NewUserController() *UserController {
-	return &UserController{repository: NewMySQLUserRepository()}
+func NewUserController(repository IUserRepository, validator internal.IValidator[UserParams], verifier IVerificationSender, logger *slog.Logger) *UserController {
+	return &UserController{repository: repository, validator: validator, verifier: verifier, logger: logger}
 }
 
 func (c *UserController) Create(w http.ResponseWriter, r *http.Request) {
 	var userParam UserParams
 	err := json.NewDecoder(r.Body).Decode(&userParam)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		response.BadRequest(w, r, "invalid request body: "+err.Error())
 		return
 	}
 
 	if err := c.validator.Validate(userParam); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		response.Error(w, r, c.logger, err)
 		return
 	}
 
-	createdUser, err := c.repository.Create(userParam)
+	createdUser, err := c.repository.Create(r.Context(), userParam)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		response.Error(w, r, c.logger, err)
 		return
 	}
+	c.sendVerification(r, createdUser.ToDTO().Id)
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-
-	if err := json.NewEncoder(w).Encode(createdUser.ToDTO()); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
+	response.JSON(w, http.StatusCreated, createdUser.ToDTO())
 }
 
 func (c *UserController) GetAll(w http.ResponseWriter, r *http.Request) {
-	var userParams UserParams
 	queryParams := r.URL.Query()
 
-	// First it checks to see if the values in the querystring are valid
-	for key := range queryParams {
-		if strings.ToLower(key) != "isactive" &&
-			strings.ToLower(key) != "isdeleted" &&
-			strings.ToLower(key) != "createdAt" &&
-			strings.ToLower(key) != "email" &&
-			strings.ToLower(key) != "cpf" &&
-			strings.ToLower(key) != "name" {
-			http.Error(w, fmt.Sprintf("Invalid query parameter: %s", key), http.StatusBadRequest)
-			return
-		}
-	}
-
-	// If the values are valid it will check what each value is
-	if isActive := queryParams.Get("IsActive"); isActive != "" {
-		isActiveValue, err := strconv.ParseBool(isActive)
-		if err == nil {
-			*userParams.IsActive = isActiveValue
-		}
-	}
-
-	if isDeleted := queryParams.Get("IsDeleted"); isDeleted != "" {
-		isDeletedValue, err := strconv.ParseBool(isDeleted)
-		if err == nil {
-			*userParams.IsDeleted = isDeletedValue
-		}
-	}
-
-	if createdAt := queryParams.Get("CreatedAt"); createdAt != "" {
-		*userParams.CreatedAt = createdAt
-	}
-
-	if email := queryParams.Get("WeightGrams"); email != "" {
-		*userParams.Email = email
+	// The accepted fields and operators are declared in filterFields
+	where, err := filter.Parse(queryParams, filterFields)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
 	}
 
-	if cpf := queryParams.Get("Price"); cpf != "" {
-		*userParams.Name = cpf
+	page, err := pagination.Parse(queryParams, sortFields)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
 	}
 
-	if name := queryParams.Get("Name"); name != "" {
-		*userParams.Name = name
+	foundUsers, err := c.repository.GetAll(r.Context(), where, page)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
 	}
+	foundUsers, hasMore := pagination.Trim(page, foundUsers)
 
-	// NOTE: Why don't I just parse it from the json? Well, if the json is nil,
-	// then it will generate an error and if the json isn't but a field is,
-	// that is another possible error. This way, although repetitive, will make
-	// it simple to understand. Where as having multiple nested `if`s might not
-
-	// It now passes the user param as a "filter" and gets the found users
-	foundUsers, err := c.repository.GetAll(userParams)
+	total, err := c.repository.Count(r.Context(), where)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		response.Error(w, r, c.logger, err)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
 
 	dtoFoundUsers := []UserDTO{}
 	for i := 0; i < len(foundUsers); i++ {
 		dtoFoundUsers = append(dtoFoundUsers, foundUsers[i].ToDTO())
 	}
-	// Returns the DTO users
-	if err := json.NewEncoder(w).Encode(dtoFoundUsers); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	var lastID string
+	if hasMore {
+		lastID = dtoFoundUsers[len(dtoFoundUsers)-1].Id
 	}
+	pagination.WriteHeaders(w, r, page, total, hasMore, lastID)
+	// Returns the DTO users
+	response.JSON(w, http.StatusOK, dtoFoundUsers)
 }
 
 func (c *UserController) GetOne(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
-	user, err := c.repository.GetOne(id)
+	revealCpf := false
+	if value := r.URL.Query().Get(revealCpfKey); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			response.Error(w, r, c.logger, apperror.NewValidationError(revealCpfKey, "must be true or false"))
+			return
+		}
+		revealCpf = parsed
+	}
+	// Only the user itself sees the whole CPF, the router lets the session
+	// through with auth.Guard.Optional
+	if revealCpf {
+		session, ok := auth.SessionFrom(r.Context())
+		if !ok {
+			response.Error(w, r, c.logger, fmt.Errorf("%s needs a session: %w", revealCpfKey, apperror.ErrUnauthorized))
+			return
+		}
+		if session.UserID != id {
+			response.Error(w, r, c.logger, fmt.Errorf("only the user itself can see the whole CPF: %w", apperror.ErrForbidden))
+			return
+		}
+	}
+
+	user, err := c.repository.GetOne(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound) // The Id was not found but the request did go though
+		response.Error(w, r, c.logger, err)
 		return
 	}
-	if err := json.NewEncoder(w).Encode(user.ToDTO()); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if revealCpf {
+		response.JSON(w, http.StatusOK, user.ToUnmaskedDTO())
 		return
 	}
+	response.JSON(w, http.StatusOK, user.ToDTO())
 }
 
 func (c *UserController) DeleteAll(w http.ResponseWriter, r *http.Request) {
-	var userParams UserParams
-	queryParams := r.URL.Query()
-
-	if isActive := queryParams.Get("IsActive"); isActive != "" {
-		isActiveValue, err := strconv.ParseBool(isActive)
-		if err == nil {
-			*userParams.IsActive = isActiveValue
-		}
+	where, err := filter.Parse(r.URL.Query(), filterFields)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
 	}
 
-	if isDeleted := queryParams.Get("IsDeleted"); isDeleted != "" {
-		isDeletedValue, err := strconv.ParseBool(isDeleted)
-		if err == nil {
-			*userParams.IsDeleted = isDeletedValue
-		}
+	count, err := c.repository.DeleteAll(r.Context(), where)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
 	}
 
-	if createdAt := queryParams.Get("CreatedAt"); createdAt != "" {
-		*userParams.CreatedAt = createdAt
-	}
+	response.JSON(w, http.StatusOK, count)
+}
 
-	if email := queryParams.Get("WeightGrams"); email != "" {
-		*userParams.Email = email
+func (c *UserController) DeleteOne(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	count, err := c.repository.DeleteOne(r.Context(), id)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
 	}
 
-	if cpf := queryParams.Get("Price"); cpf != "" {
-		*userParams.Name = cpf
-	}
+	response.JSON(w, http.StatusOK, count)
+}
 
-	if name := queryParams.Get("Name"); name != "" {
-		*userParams.Name = name
+func (c *UserController) Update(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	var userParams UserParams
+	err := json.NewDecoder(r.Body).Decode(&userParams)
+	if err != nil {
+		response.BadRequest(w, r, "invalid request body: "+err.Error())
+		return
 	}
-
-	count, err := c.repository.DeleteAll(userParams)
+	user, err := c.repository.Update(r.Context(), id, userParams)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		response.Error(w, r, c.logger, err)
 		return
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(count); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	// Changing the email clears the verification
+	if userParams.Email != nil && !user.IsEmailVerified() {
+		c.sendVerification(r, id)
 	}
+
+	response.JSON(w, http.StatusOK, user.ToDTO())
 }
 
-func (c *UserController) DeleteOne(w http.ResponseWriter, r *http.Request) {
+func (c *UserController) Restore(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
-	count, err := c.repository.DeleteOne(id)
+	user, err := c.repository.Restore(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound) // Did go through, none found
+		response.Error(w, r, c.logger, err)
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(count); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
+	response.JSON(w, http.StatusOK, user.ToDTO())
 }
 
-func (c *UserController) Update(w http.ResponseWriter, r *http.Request) {
+func (c *UserController) Purge(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
-	var userParams UserParams
-	err := json.NewDecoder(r.Body).Decode(&userParams)
+	count, err := c.repository.Purge(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		response.Error(w, r, c.logger, err)
 		return
 	}
-	user, err := c.repository.Update(id, userParams)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound) // Did go through, none found
-	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(user.ToDTO()); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	response.JSON(w, http.StatusOK, count)
+}
+
+// The user is already saved, failing to mail the token doesn't fail the
+// request. It can be sent again with POST /u/{id}/verify-email/resend
+func (c *UserController) sendVerification(r *http.Request, id string) {
+	if _, err := c.verifier.Send(r.Context(), id); err != nil {
+		c.logger.WarnContext(r.Context(), "failed to send verification email",
+			"userID", id,
+			"requestID", middleware.GetRequestID(r.Context()),
+			"error", err,
+		)
 	}
 }
@@ -1,23 +1,38 @@
 package user
 
+import (
+	"context"
+	"sipub-test/internal/filter"
+	"sipub-test/internal/pagination"
+)
+
 type IUserRepository interface {
 	// Returns the created user
-	Create(UserParams) (UserModel, error)
+	Create(ctx context.Context, params UserParams) (UserModel, error)
 
 	// Returns the found users
 	// NOTE: reusing the same type for a filter and a "constructor" is not
 	// ideal at all, but it will save on code repetition
-	GetAll(filter UserParams) ([]UserModel, error)
+	GetAll(ctx context.Context, where filter.Filter, page pagination.Page) ([]UserModel, error)
+
+	// Returns how many rows match the filter, ignoring the page
+	Count(ctx context.Context, where filter.Filter) (uint, error)
 
 	// Returns the found user
-	GetOne(id string) (UserModel, error)
+	GetOne(ctx context.Context, id string) (UserModel, error)
 
-	// Returns amount of deleted users
-	DeleteOne(id string) (uint, error)
+	// Soft deletes, returns amount of deleted users
+	DeleteOne(ctx context.Context, id string) (uint, error)
 
-	// Returns amount of deleted users
-	DeleteAll(filter UserParams) (uint, error)
+	// Soft deletes, returns amount of deleted users
+	DeleteAll(ctx context.Context, where filter.Filter) (uint, error)
 
 	// Returns the updated user
-	Update(id string, newUser UserParams) (UserModel, error)
+	Update(ctx context.Context, id string, newUser UserParams) (UserModel, error)
+
+	// Undoes a soft delete, returns the restored user
+	Restore(ctx context.Context, id string) (UserModel, error)
+
+	// Removes the row for good, whether it was soft deleted or not
+	Purge(ctx context.Context, id string) (uint, error)
 }
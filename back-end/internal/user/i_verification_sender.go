@@ -0,0 +1,12 @@
+package user
+
+import (
+	"context"
+	"sipub-test/pkg/timestamp"
+)
+
+// Mails a token that verifies the current email of the user and returns when
+// it expires, implemented by email_verification.Sender
+type IVerificationSender interface {
+	Send(ctx context.Context, userID string) (timestamp.Time, error)
+}
@@ -1,5 +1,10 @@
 package user
 
+import (
+	"sipub-test/pkg/cpf"
+	"sipub-test/pkg/timestamp"
+)
+
 // This is what will be used to create/find/update the user model. The
 // fields are used as pointers so they can be nullified
 type UserParams struct {
@@ -9,14 +14,19 @@ type UserParams struct {
 	Email     *string
 	Cpf       *string
 	Name      *string
+	Password  *string // Only stored as a bcrypt hash, never returned
 }
 
 type UserDTO struct {
-	Id        string `json:"Id"`
-	CreatedAt string `json:"CreatedAt"`
-	Email     string `json:"Email"`
-	Cpf       string `json:"Cpf"`
-	Name      string `json:"Name"`
+	Id        string  `json:"Id"`
+	CreatedAt string  `json:"CreatedAt"`
+	UpdatedAt string  `json:"UpdatedAt"`
+	DeletedAt *string `json:"DeletedAt,omitempty"`
+	Email     string  `json:"Email"`
+	Cpf       string  `json:"Cpf"` // Masked unless asked for, see ToUnmaskedDTO
+	Name      string  `json:"Name"`
+
+	EmailVerifiedAt *string `json:"EmailVerifiedAt,omitempty"`
 }
 
 type UserModel struct {
@@ -24,19 +34,33 @@ type UserModel struct {
 	// inheritance. Explained in COMMENTS.md
 	id        string // ID will be a uuid
 	isActive  bool
-	isDeleted bool // Soft deletion
-	createdAt string
+	isDeleted bool            // Soft deletion
+	deletedAt *timestamp.Time // Set while soft deleted
+	createdAt timestamp.Time
+	updatedAt timestamp.Time // Changed by every update
 
-	email string
-	cpf   string
-	name  string
+	email           string          // Trimmed and lower cased
+	emailVerifiedAt *timestamp.Time // Nil until verified, and again once the email changes
+	cpf             string          // Only the 11 digits
+	name            string
 }
 
 func (u *UserModel) ToDTO() UserDTO {
-	dtoUser := UserDTO{Id: u.id, CreatedAt: u.createdAt, Email: u.email, Cpf: u.cpf, Name: u.name}
+	dtoUser := u.ToUnmaskedDTO()
+	dtoUser.Cpf = cpf.Mask(u.cpf)
 	return dtoUser
 }
 
+// Has the whole CPF, only for GET /u/{id}?revealCpf=true
+func (u *UserModel) ToUnmaskedDTO() UserDTO {
+	dtoUser := UserDTO{Id: u.id, CreatedAt: u.createdAt.String(), UpdatedAt: u.updatedAt.String(), DeletedAt: timestamp.Format(u.deletedAt), Email: u.email, Cpf: u.cpf, Name: u.name, EmailVerifiedAt: timestamp.Format(u.emailVerifiedAt)}
+	return dtoUser
+}
+
+func (u *UserModel) IsEmailVerified() bool {
+	return u.emailVerifiedAt != nil
+}
+
 func (u *UserModel) GetIsActive() bool {
 	return u.isActive
 }
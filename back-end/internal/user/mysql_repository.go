@@ -1,65 +1,76 @@
 package user
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
-	"log"
 	"sipub-test/db"
+	"sipub-test/internal/filter"
+	"sipub-test/internal/pagination"
+	"sipub-test/pkg/apperror"
+	"sipub-test/pkg/clock"
+	"sipub-test/pkg/cpf"
+	"sipub-test/pkg/email"
 	"sipub-test/pkg/nilcheck"
-	"time"
+	"sipub-test/pkg/password"
+	"sipub-test/pkg/timestamp"
 
 	"github.com/google/uuid"
 )
 
 type MySQLUserRepository struct {
-	db *sql.DB
+	db    db.Executor
+	clock clock.Clock
 }
 
-// Mainly used for testing, but could be used elsewhere
-func (r *MySQLUserRepository) SetDB(db *sql.DB) { r.db = db }
-
-func (r *MySQLUserRepository) createNewUserTableIfNoneExists() {
-	r.db = db.GetDB()
-
-	createTableQuery := `
-	CREATE TABLE IF NOT EXISTS users (
-		id CHAR(36) NOT NULL,
-		isActive BOOLEAN NOT NULL DEFAULT TRUE,
-		isDeleted BOOLEAN NOT NULL DEFAULT FALSE,
-        createdAt CHAR(19) NOT NULL,
-		email CHAR(100) NOT NULL,
-		cpf CHAR(11) NOT NULL,
-		name VARCHAR(255) NOT NULL,
-		PRIMARY KEY (id)
-	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`
-	// https://dev.mysql.com/doc/refman/8.4/en/innodb-benefits.html
-	// Mainly using InnoDB because it supports foreing keys
-	// createdAt is a string because it is simpler to handle. It uses this
-	// format 2006-01-02 15:04:05 (19 chars)
+func NewMySQLUserRepository(db db.Executor, clock clock.Clock) *MySQLUserRepository {
+	return &MySQLUserRepository{db: db, clock: clock}
+}
 
-	if _, err := r.db.Exec(createTableQuery); err != nil {
-		log.Fatalf("Failed to create table: %v", err)
-	}
+// Fields the list can be filtered and deleted by
+var filterFields = filter.Schema{
+	"IsActive":  {Column: "isActive", Type: filter.Bool, Operators: []filter.Operator{filter.In}},
+	"IsDeleted": {Column: "isDeleted", Type: filter.Bool, Operators: []filter.Operator{filter.Eq}},
+	"CreatedAt": {Column: "createdAt", Type: filter.Time, Operators: filter.TimeOperators},
+	"Name":      {Column: "name", Type: filter.String, Operators: []filter.Operator{filter.Like, filter.Eq}},
+	"Email":     {Column: "email", Type: filter.String, Operators: []filter.Operator{filter.Like, filter.Eq}},
+	"Cpf":       {Column: "cpf", Type: filter.Cpf, Operators: []filter.Operator{filter.Eq}},
 }
 
-func NewMySQLUserRepository() *MySQLUserRepository {
-	repo := &MySQLUserRepository{db: db.GetDB()}
-	repo.createNewUserTableIfNoneExists()
-	return repo
+// Columns the list can be sorted by
+var sortFields = pagination.Fields{
+	Table:    "users",
+	IDColumn: "id",
+	Columns: map[string]string{
+		"Name":      "name",
+		"Email":     "email",
+		"CreatedAt": "createdAt",
+	},
+	Default: []pagination.Sort{{Field: "CreatedAt", Column: "createdAt", Desc: true}},
 }
 
-func (r *MySQLUserRepository) Create(params UserParams) (UserModel, error) {
+func (r *MySQLUserRepository) Create(ctx context.Context, params UserParams) (UserModel, error) {
 	id := uuid.NewString()
+	cpfDigits, err := normalizeCpf(*params.Cpf)
+	if err != nil {
+		return UserModel{}, err
+	}
+	address, err := normalizeEmail(*params.Email)
+	if err != nil {
+		return UserModel{}, err
+	}
+	passwordHash, err := hashPassword(*params.Password)
+	if err != nil {
+		return UserModel{}, err
+	}
 
-	// Round price to 2 decimal places, if not, there will be floating number
-	// innacuracy
-	timeCreated := time.Now().Format("2006-01-02 15:04:05")
+	timeCreated := timestamp.New(r.clock.Now())
 
-	query := `INSERT INTO users (id, isActive, isDeleted, createdAt, email, cpf, name) VALUES (?, ?, ?, ?, ?, ?, ?)`
-	_, err := r.db.Exec(query, id, *params.IsActive, *params.IsDeleted, timeCreated, *params.Email, *params.Cpf, *params.Name)
+	query := `INSERT INTO users (id, isActive, isDeleted, createdAt, updatedAt, email, cpf, name, passwordHash) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err = r.db.ExecContext(ctx, query, id, *params.IsActive, *params.IsDeleted, timeCreated, timeCreated, address, cpfDigits, *params.Name, passwordHash)
 	if err != nil {
-		return UserModel{}, fmt.Errorf("failed to create user: %w", err)
+		return UserModel{}, fmt.Errorf("failed to create user: %w", translateUniqueError(err))
 	}
 
 	return UserModel{
@@ -67,38 +78,19 @@ func (r *MySQLUserRepository) Create(params UserParams) (UserModel, error) {
 		isActive:  *params.IsActive,
 		isDeleted: *params.IsDeleted,
 		createdAt: timeCreated,
-		email:     *params.Email,
-		cpf:       *params.Cpf,
+		updatedAt: timeCreated,
+		email:     address,
+		cpf:       cpfDigits,
 		name:      *params.Name,
 	}, nil
 }
 
-func (r *MySQLUserRepository) GetAll(filter UserParams) ([]UserModel, error) {
-	query := `SELECT id, isActive, isDeleted, createdAt, email, cpf, name FROM users WHERE 1=1`
-	args := []interface{}{}
-
-	if filter.IsActive != nil {
-		query += " AND isActive = ?"
-		args = append(args, *filter.IsActive)
-	}
-	if filter.IsDeleted != nil {
-		query += " AND isDeleted = ?"
-		args = append(args, *filter.IsDeleted)
-	}
-	if filter.Email != nil {
-		query += " AND email LIKE ?"
-		args = append(args, "%"+*filter.Email+"%")
-	}
-	if filter.Cpf != nil {
-		query += " AND cpf = ?"
-		args = append(args, *filter.Cpf)
-	}
-	if filter.Name != nil {
-		query += " AND name LIKE ?"
-		args = append(args, "%"+*filter.Name+"%")
-	}
+func (r *MySQLUserRepository) GetAll(ctx context.Context, where filter.Filter, page pagination.Page) ([]UserModel, error) {
+	conditions, args := where.SQL()
+	query := `SELECT id, isActive, isDeleted, createdAt, updatedAt, email, cpf, name, emailVerifiedAt, deletedAt FROM users WHERE 1=1` + conditions
+	query, args = sortFields.Apply(page, query, args)
 
-	rows, err := r.db.Query(query, args...)
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get users: %w", err)
 	}
@@ -107,7 +99,7 @@ func (r *MySQLUserRepository) GetAll(filter UserParams) ([]UserModel, error) {
 	var users []UserModel
 	for rows.Next() {
 		var user UserModel
-		if err := rows.Scan(&user.id, &user.isActive, &user.isDeleted, &user.createdAt, &user.email, &user.cpf, &user.name); err != nil {
+		if err := rows.Scan(&user.id, &user.isActive, &user.isDeleted, &user.createdAt, &user.updatedAt, &user.email, &user.cpf, &user.name, &user.emailVerifiedAt, &user.deletedAt); err != nil {
 			return nil, fmt.Errorf("failed to scan user: %w", err)
 		}
 		users = append(users, user)
@@ -116,83 +108,180 @@ func (r *MySQLUserRepository) GetAll(filter UserParams) ([]UserModel, error) {
 	return users, nil
 }
 
-func (r *MySQLUserRepository) GetOne(id string) (UserModel, error) {
-	query := `SELECT id, isActive, isDeleted, createdAt, email, cpf, name FROM users WHERE id = ?`
+func (r *MySQLUserRepository) Count(ctx context.Context, where filter.Filter) (uint, error) {
+	conditions, args := where.SQL()
+	query := `SELECT COUNT(*) FROM users WHERE 1=1` + conditions
+
+	var count uint
+	if err := r.db.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count users: %w", err)
+	}
+	return count, nil
+}
+
+func (r *MySQLUserRepository) GetOne(ctx context.Context, id string) (UserModel, error) {
+	query := `SELECT id, isActive, isDeleted, createdAt, updatedAt, email, cpf, name, emailVerifiedAt FROM users WHERE id = ? AND isDeleted = FALSE`
 	var user UserModel
-	row := r.db.QueryRow(query, id)
-	if err := row.Scan(&user.id, &user.isActive, &user.isDeleted, &user.createdAt, &user.email, &user.cpf, &user.name); err != nil {
+	row := r.db.QueryRowContext(ctx, query, id)
+	if err := row.Scan(&user.id, &user.isActive, &user.isDeleted, &user.createdAt, &user.updatedAt, &user.email, &user.cpf, &user.name, &user.emailVerifiedAt); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return UserModel{}, fmt.Errorf("user not found")
+			return UserModel{}, fmt.Errorf("user %w", apperror.ErrNotFound)
 		}
 		return UserModel{}, fmt.Errorf("failed to get user: %w", err)
 	}
 	return user, nil
 }
 
-func (r *MySQLUserRepository) DeleteOne(id string) (uint, error) {
-	query := `DELETE FROM users WHERE id = ?`
-	res, err := r.db.Exec(query, id)
+func (r *MySQLUserRepository) DeleteOne(ctx context.Context, id string) (uint, error) {
+	deletedAt := timestamp.New(r.clock.Now())
+	query := `UPDATE users SET isDeleted = TRUE, deletedAt = ? WHERE id = ? AND isDeleted = FALSE`
+	res, err := r.db.ExecContext(ctx, query, deletedAt, id)
 	if err != nil {
-		return 0, fmt.Errorf("failed to delete user: %w", err)
+		return 0, fmt.Errorf("failed to delete user: %w", db.TranslateError(err))
 	}
 	count, _ := res.RowsAffected()
 	if count == 0 {
-		return 0, fmt.Errorf("failed to delete user: %w", err)
+		return 0, fmt.Errorf("user %w", apperror.ErrNotFound)
 	}
 	return uint(count), nil
 }
 
-func (r *MySQLUserRepository) DeleteAll(filter UserParams) (uint, error) {
-	query := `DELETE FROM users WHERE 1=1`
-	args := []interface{}{}
+func (r *MySQLUserRepository) DeleteAll(ctx context.Context, where filter.Filter) (uint, error) {
+	conditions, args := where.SQL()
+	query := `UPDATE users SET isDeleted = TRUE, deletedAt = ? WHERE 1=1` + conditions
+	args = append([]interface{}{timestamp.New(r.clock.Now())}, args...)
 
-	if filter.IsActive != nil {
-		query += " AND isActive = ?"
-		args = append(args, *filter.IsActive)
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete users: %w", db.TranslateError(err))
 	}
-	if filter.IsDeleted != nil {
-		query += " AND isDeleted = ?"
-		args = append(args, *filter.IsDeleted)
+	count, _ := res.RowsAffected()
+	return uint(count), nil
+}
+
+func (r *MySQLUserRepository) Update(ctx context.Context, id string, newUser UserParams) (UserModel, error) {
+	previousUser, err := r.GetOne(ctx, id)
+	if err != nil {
+		return UserModel{}, err
 	}
-	if filter.Email != nil {
-		query += " AND email LIKE ?"
-		args = append(args, "%"+*filter.Name+"%")
+	// This will check nil arguments and change only the non-nil ones
+	updatedUser := UserModel{
+		isActive:        nilcheck.NotNilBool(newUser.IsActive, previousUser.isActive),
+		email:           previousUser.email,
+		emailVerifiedAt: previousUser.emailVerifiedAt,
+		cpf:             previousUser.cpf,
+		name:            nilcheck.NotNilString(newUser.Name, previousUser.name),
 	}
-	if filter.Cpf != nil {
-		query += " AND cpf = ?"
-		args = append(args, "%"+*filter.Name+"%")
+	if newUser.Email != nil {
+		if updatedUser.email, err = normalizeEmail(*newUser.Email); err != nil {
+			return UserModel{}, err
+		}
 	}
-	if filter.Name != nil {
-		query += " AND name LIKE ?"
-		args = append(args, "%"+*filter.Name+"%")
+	// The new address has to be verified again
+	if updatedUser.email != previousUser.email {
+		updatedUser.emailVerifiedAt = nil
 	}
+	if newUser.Cpf != nil {
+		if updatedUser.cpf, err = normalizeCpf(*newUser.Cpf); err != nil {
+			return UserModel{}, err
+		}
+	}
+	// Nil keeps the current hash
+	var passwordHash *string
+	if newUser.Password != nil {
+		hash, err := hashPassword(*newUser.Password)
+		if err != nil {
+			return UserModel{}, err
+		}
+		passwordHash = &hash
+	}
+	now := timestamp.New(r.clock.Now())
 
-	res, err := r.db.Exec(query, args...)
+	err = db.WithTx(ctx, r.db, func(tx db.Executor) error {
+		query := `UPDATE users SET isActive = ?, email = ?, emailVerifiedAt = ?, cpf = ?, name = ?, passwordHash = COALESCE(?, passwordHash), updatedAt = ? WHERE id = ? AND isDeleted = FALSE`
+		_, err := tx.ExecContext(ctx, query, updatedUser.isActive, updatedUser.email, updatedUser.emailVerifiedAt, updatedUser.cpf, updatedUser.name, passwordHash, now, id)
+		if err != nil {
+			return fmt.Errorf("failed to update user: %w", translateUniqueError(err))
+		}
+		if passwordHash == nil {
+			return nil
+		}
+		// A new password logs out every session, the client logs in again
+		query = `UPDATE sessions SET revokedAt = ? WHERE user_id = ? AND revokedAt IS NULL`
+		if _, err := tx.ExecContext(ctx, query, now, id); err != nil {
+			return fmt.Errorf("failed to revoke sessions: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return 0, fmt.Errorf("failed to delete users: %w", err)
+		return UserModel{}, err
+	}
+	return r.GetOne(ctx, id)
+}
+
+func (r *MySQLUserRepository) Restore(ctx context.Context, id string) (UserModel, error) {
+	query := `UPDATE users SET isDeleted = FALSE, deletedAt = NULL WHERE id = ? AND isDeleted = TRUE`
+	res, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return UserModel{}, fmt.Errorf("failed to restore user: %w", db.TranslateError(err))
 	}
 	count, _ := res.RowsAffected()
-	return uint(count), nil
+	if count == 0 {
+		return UserModel{}, fmt.Errorf("deleted user %w", apperror.ErrNotFound)
+	}
+	return r.GetOne(ctx, id)
 }
 
-func (r *MySQLUserRepository) Update(id string, newUser UserParams) (UserModel, error) {
-	previousUser, err := r.GetOne(id)
+func (r *MySQLUserRepository) Purge(ctx context.Context, id string) (uint, error) {
+	query := `DELETE FROM users WHERE id = ?`
+	res, err := r.db.ExecContext(ctx, query, id)
 	if err != nil {
-		return UserModel{}, err
+		return 0, fmt.Errorf("failed to purge user: %w", db.TranslateError(err))
 	}
-	// This will check nil arguments and change only the non-nil ones
-	updatedUser := UserModel{
-		isActive:  nilcheck.NotNilBool(newUser.IsActive, previousUser.isActive),
-		isDeleted: nilcheck.NotNilBool(newUser.IsDeleted, previousUser.isDeleted),
-		email:     nilcheck.NotNilString(newUser.Email, previousUser.email),
-		cpf:       nilcheck.NotNilString(newUser.Cpf, previousUser.cpf),
-		name:      nilcheck.NotNilString(newUser.Name, previousUser.name),
+	count, _ := res.RowsAffected()
+	if count == 0 {
+		return 0, fmt.Errorf("user %w", apperror.ErrNotFound)
+	}
+	return uint(count), nil
+}
+
+// Stored as the 11 digits, so 123.456.789-09 and 12345678909 are the same
+// user for the unique index
+func normalizeCpf(value string) (string, error) {
+	digits, err := cpf.Normalize(value)
+	if err != nil {
+		return "", apperror.NewValidationError("Cpf", err.Error())
 	}
-	query := `UPDATE users SET isActive = ?, isDeleted = ?, email = ?, cpf = ?, name = ? WHERE id = ?`
+	return digits, nil
+}
 
-	_, err = r.db.Exec(query, updatedUser.isActive, updatedUser.isDeleted, updatedUser.email, updatedUser.cpf, updatedUser.name, id)
+// Trimmed and lower cased, Ana@Example.com can't sign up again as
+// ana@example.com
+func normalizeEmail(value string) (string, error) {
+	address, err := email.Normalize(value)
 	if err != nil {
-		return UserModel{}, fmt.Errorf("failed to update user: %w", err)
+		return "", apperror.NewValidationError("Email", err.Error())
+	}
+	return address, nil
+}
+
+func hashPassword(value string) (string, error) {
+	hash, err := password.Hash(value)
+	if errors.Is(err, password.ErrTooShort) || errors.Is(err, password.ErrTooLong) {
+		return "", apperror.NewValidationError("Password", err.Error())
+	}
+	return hash, err
+}
+
+// Both the email and the CPF are unique, the key tells which one another
+// user already has
+func translateUniqueError(err error) error {
+	err = db.TranslateError(err)
+	switch db.DuplicateKey(err) {
+	case "uq_users_email":
+		return fmt.Errorf("email is already used by another user: %w", err)
+	case "uq_users_cpf":
+		return fmt.Errorf("cpf is already used by another user: %w", err)
 	}
-	return r.GetOne(id)
+	return err
 }
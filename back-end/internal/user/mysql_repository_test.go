@@ -1,16 +1,35 @@
 package user_test
 
 import (
+	"context"
+	"database/sql/driver"
 	"fmt"
 	"regexp"
+	"sipub-test/internal/filter"
+	"sipub-test/internal/pagination"
 	"sipub-test/internal/user"
+	"sipub-test/pkg/apperror"
+	"sipub-test/pkg/clock"
+	"sipub-test/pkg/password"
 	testhelper "sipub-test/pkg/test_helper"
+	"sipub-test/pkg/timestamp"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
 	"github.com/stretchr/testify/assert"
 )
 
+// Matches the bcrypt hash of the password, the hash itself changes with the
+// salt
+type bcryptOf string
+
+func (b bcryptOf) Match(value driver.Value) bool {
+	hash, ok := value.(string)
+	return ok && password.Matches(hash, string(b))
+}
+
 func TestCreateUser(t *testing.T) {
 	t.Run("ValidCreate", func(t *testing.T) {
 		db, mock, err := sqlmock.New()
@@ -19,30 +38,121 @@ func TestCreateUser(t *testing.T) {
 		}
 		defer db.Close()
 
-		repo := &user.MySQLUserRepository{}
-		repo.SetDB(db)
+		repo := user.NewMySQLUserRepository(db, clock.System{})
 
 		params := user.UserParams{
 			IsActive:  testhelper.BoolPointer(true),
 			IsDeleted: testhelper.BoolPointer(false),
-			Email:     testhelper.StringPointer("testuser@example.com"),
-			Cpf:       testhelper.StringPointer("12345678901"),
+			Email:     testhelper.StringPointer(" TestUser@Example.com "),
+			Cpf:       testhelper.StringPointer("123.456.789-09"),
 			Name:      testhelper.StringPointer("Test User"),
+			Password:  testhelper.StringPointer("correct horse"),
 		}
 
 		mock.ExpectExec(`INSERT INTO users`).
-			WithArgs(sqlmock.AnyArg() /* id determined at function */, true, false, sqlmock.AnyArg() /*time determined at function*/, "testuser@example.com", "12345678901", "Test User").
+			WithArgs(sqlmock.AnyArg() /* id determined at function */, true, false, sqlmock.AnyArg() /*time determined at function*/, sqlmock.AnyArg(), "testuser@example.com", "12345678909", "Test User", bcryptOf("correct horse")).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 
-		user, err := repo.Create(params)
+		user, err := repo.Create(context.Background(), params)
 
 		assert.NoError(t, err, "Shouldn't contain any errors")
 		// Won't check for id since it is created in the repository
 		assert.Equal(t, *params.IsActive, user.GetIsActive())
-		assert.Equal(t, *params.Email, user.ToDTO().Email)
-		assert.Equal(t, *params.Cpf, user.ToDTO().Cpf)
+		assert.Equal(t, "testuser@example.com", user.ToDTO().Email, "Email should be normalized")
+		assert.False(t, user.IsEmailVerified(), "A new email shouldn't be verified")
+		assert.Equal(t, "***.456.789-**", user.ToDTO().Cpf, "Cpf should be masked")
+		assert.Equal(t, "12345678909", user.ToUnmaskedDTO().Cpf, "Cpf should be stored as digits")
 		assert.Equal(t, *params.Name, user.ToDTO().Name)
 	})
+	t.Run("ShouldRejectAnInvalidCpf", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := user.NewMySQLUserRepository(db, clock.System{})
+
+		_, err = repo.Create(context.Background(), user.UserParams{
+			IsActive:  testhelper.BoolPointer(true),
+			IsDeleted: testhelper.BoolPointer(false),
+			Email:     testhelper.StringPointer("testuser@example.com"),
+			Cpf:       testhelper.StringPointer("123.456.789-01"),
+			Name:      testhelper.StringPointer("Test User"),
+			Password:  testhelper.StringPointer("correct horse"),
+		})
+
+		assert.ErrorIs(t, err, apperror.ErrValidation)
+		assert.NoError(t, mock.ExpectationsWereMet(), "Nothing should be inserted")
+	})
+	t.Run("ShouldReturnDuplicateForATakenCpf", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := user.NewMySQLUserRepository(db, clock.System{})
+		mock.ExpectExec(`INSERT INTO users`).
+			WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry '12345678909' for key 'users.uq_users_cpf'"})
+
+		_, err = repo.Create(context.Background(), user.UserParams{
+			IsActive:  testhelper.BoolPointer(true),
+			IsDeleted: testhelper.BoolPointer(false),
+			Email:     testhelper.StringPointer("testuser@example.com"),
+			Cpf:       testhelper.StringPointer("12345678909"),
+			Name:      testhelper.StringPointer("Test User"),
+			Password:  testhelper.StringPointer("correct horse"),
+		})
+
+		assert.ErrorIs(t, err, apperror.ErrDuplicate)
+		assert.Contains(t, err.Error(), "cpf is already used")
+		assert.NotContains(t, err.Error(), "12345678909", "The driver message shouldn't leak the CPF")
+	})
+	t.Run("ShouldReturnDuplicateForATakenEmail", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := user.NewMySQLUserRepository(db, clock.System{})
+		mock.ExpectExec(`INSERT INTO users`).
+			WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'testuser@example.com' for key 'users.uq_users_email'"})
+
+		_, err = repo.Create(context.Background(), user.UserParams{
+			IsActive:  testhelper.BoolPointer(true),
+			IsDeleted: testhelper.BoolPointer(false),
+			Email:     testhelper.StringPointer("TESTUSER@example.com"),
+			Cpf:       testhelper.StringPointer("52998224725"),
+			Name:      testhelper.StringPointer("Test User"),
+			Password:  testhelper.StringPointer("correct horse"),
+		})
+
+		assert.ErrorIs(t, err, apperror.ErrDuplicate)
+		assert.Contains(t, err.Error(), "email is already used")
+	})
+	t.Run("ShouldRejectAnInvalidEmail", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := user.NewMySQLUserRepository(db, clock.System{})
+
+		_, err = repo.Create(context.Background(), user.UserParams{
+			IsActive:  testhelper.BoolPointer(true),
+			IsDeleted: testhelper.BoolPointer(false),
+			Email:     testhelper.StringPointer("Test User <testuser@example.com>"),
+			Cpf:       testhelper.StringPointer("12345678909"),
+			Name:      testhelper.StringPointer("Test User"),
+			Password:  testhelper.StringPointer("correct horse"),
+		})
+
+		assert.ErrorIs(t, err, apperror.ErrValidation)
+		assert.NoError(t, mock.ExpectationsWereMet(), "Nothing should be inserted")
+	})
 }
 
 func TestGetAllUsers(t *testing.T) {
@@ -53,17 +163,16 @@ func TestGetAllUsers(t *testing.T) {
 		}
 		defer db.Close()
 
-		repo := &user.MySQLUserRepository{}
-		repo.SetDB(db)
+		repo := user.NewMySQLUserRepository(db, clock.System{})
 
-		rows := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "email", "cpf", "name"}).
-			AddRow("123", true, false, "2025-01-15 12:00:00", "testuser@example.com", "12345678901", "Test User")
+		rows := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "updatedAt", "email", "cpf", "name", "emailVerifiedAt", "deletedAt"}).
+			AddRow("123", true, false, "2025-01-15 12:00:00", "2025-01-15 12:00:00", "testuser@example.com", "12345678909", "Test User", nil, nil)
 
-		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, email, cpf, name FROM users`).
+		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, updatedAt, email, cpf, name, emailVerifiedAt, deletedAt FROM users`).
 			WillReturnRows(rows)
 
-		filter := user.UserParams{}
-		users, err := repo.GetAll(filter)
+		where := filter.Filter{}
+		users, err := repo.GetAll(context.Background(), where, pagination.Page{})
 
 		assert.NoError(t, err, "Should have no errors")
 		assert.Len(t, users, 1, "Length should be 1")
@@ -76,15 +185,14 @@ func TestGetAllUsers(t *testing.T) {
 		}
 		defer db.Close()
 
-		repo := &user.MySQLUserRepository{}
-		repo.SetDB(db)
+		repo := user.NewMySQLUserRepository(db, clock.System{})
 
 		// Should return "failed to get users"
-		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, email, cpf, name FROM users`).
+		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, updatedAt, email, cpf, name, emailVerifiedAt, deletedAt FROM users`).
 			WillReturnError(fmt.Errorf("failed to get users"))
 
-		filter := user.UserParams{Email: testhelper.StringPointer("nonexistent@example.com")}
-		users, err := repo.GetAll(filter)
+		where := testhelper.FilterEqual("Email", "email", "nonexistent@example.com")
+		users, err := repo.GetAll(context.Background(), where, pagination.Page{})
 
 		assert.Error(t, err, "Should have an error")
 		assert.Len(t, users, 0, "Length should be 0")
@@ -98,23 +206,22 @@ func TestGetUserByID(t *testing.T) {
 	}
 	defer db.Close()
 
-	repo := &user.MySQLUserRepository{}
-	repo.SetDB(db)
+	repo := user.NewMySQLUserRepository(db, clock.System{})
 
-	rows := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "email", "cpf", "name"}).
-		AddRow("123", true, false, "2025-01-15 12:00:00", "testuser@example.com", "12345678901", "Test User")
+	rows := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "updatedAt", "email", "cpf", "name", "emailVerifiedAt"}).
+		AddRow("123", true, false, "2025-01-15 12:00:00", "2025-01-15 12:00:00", "testuser@example.com", "12345678909", "Test User", nil)
 
-	mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, email, cpf, name FROM users WHERE id = ?`).
+	mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, updatedAt, email, cpf, name, emailVerifiedAt FROM users WHERE id = ?`).
 		WithArgs("123").
 		WillReturnRows(rows)
 
-	user, err := repo.GetOne("123")
+	user, err := repo.GetOne(context.Background(), "123")
 
 	assert.NoError(t, err, "Should have no errors")
 	assert.Equal(t, "123", user.ToDTO().Id, "Id should be the same")
 	assert.Equal(t, "testuser@example.com", user.ToDTO().Email, "Email should be the same")
-	assert.Equal(t, "12345678901", user.ToDTO().Cpf, "Cpf should be the same")
-	assert.Equal(t, "2025-01-15 12:00:00", user.ToDTO().CreatedAt, "Date created should be the same")
+	assert.Equal(t, "***.456.789-**", user.ToDTO().Cpf, "Cpf should be masked")
+	assert.Equal(t, "2025-01-15T12:00:00Z", user.ToDTO().CreatedAt, "Date created should be RFC 3339")
 	assert.Equal(t, "Test User", user.ToDTO().Name, "Name should be the same")
 }
 
@@ -125,14 +232,15 @@ func TestDeleteUser(t *testing.T) {
 	}
 	defer db.Close()
 
-	repo := &user.MySQLUserRepository{}
-	repo.SetDB(db)
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	repo := user.NewMySQLUserRepository(db, clock.Fixed(now))
 
-	mock.ExpectExec(`DELETE FROM users WHERE id = ?`).
-		WithArgs("123").
+	// The row is only flagged, Purge is what removes it
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE users SET isDeleted = TRUE, deletedAt = ? WHERE id = ? AND isDeleted = FALSE`)).
+		WithArgs(timestamp.New(now), "123").
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	count, err := repo.DeleteOne("123")
+	count, err := repo.DeleteOne(context.Background(), "123")
 
 	assert.NoError(t, err)
 	assert.Equal(t, uint(1), count)
@@ -146,46 +254,51 @@ func TestUpdateUser(t *testing.T) {
 		}
 		defer db.Close()
 
-		repo := &user.MySQLUserRepository{}
-		repo.SetDB(db)
+		now := time.Date(2025, 1, 16, 8, 30, 0, 0, time.UTC)
+		repo := user.NewMySQLUserRepository(db, clock.Fixed(now))
 
 		newParams := user.UserParams{
 			IsActive:  testhelper.BoolPointer(false),
 			IsDeleted: testhelper.BoolPointer(true),
-			Email:     testhelper.StringPointer("updateduser@example.com"),
-			Cpf:       testhelper.StringPointer("10987654321"),
+			Email:     testhelper.StringPointer(" UpdatedUser@Example.com"),
+			Cpf:       testhelper.StringPointer("109.876.543-57"),
 			Name:      testhelper.StringPointer("Updated User"),
 		}
 
 		// Create a new row
-		rows := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "email", "cpf", "name"}).
-			AddRow("123", true, false, "2025-01-15 12:00:00", "testuser@example.com", "12345678901", "Original User")
+		rows := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "updatedAt", "email", "cpf", "name", "emailVerifiedAt"}).
+			AddRow("123", true, false, "2025-01-15 12:00:00", "2025-01-15 12:00:00", "testuser@example.com", "12345678909", "Original User", "2025-01-15 13:00:00")
 
 		// Initial SELECT for GetOne
-		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, email, cpf, name FROM users WHERE id = ?`).
+		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, updatedAt, email, cpf, name, emailVerifiedAt FROM users WHERE id = ?`).
 			WithArgs("123").
 			WillReturnRows(rows)
 
 		// UPDATE query
-		mock.ExpectExec(regexp.QuoteMeta(`UPDATE users SET isActive = ?, isDeleted = ?, email = ?, cpf = ?, name = ? WHERE id = ?`)).
-			WithArgs(false, true, "updateduser@example.com", "10987654321", "Updated User", "123").
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE users SET isActive = ?, email = ?, emailVerifiedAt = ?, cpf = ?, name = ?, passwordHash = COALESCE(?, passwordHash), updatedAt = ? WHERE id = ? AND isDeleted = FALSE`)).
+			WithArgs(false, "updateduser@example.com", nil /* the new email isn't verified */, "10987654357", "Updated User", nil /* the password is kept */, timestamp.New(now), "123").
 			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
 
 		// Final SELECT for updated user
-		updatedRows := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "email", "cpf", "name"}).
-			AddRow("123", false, true, "2025-01-15 12:00:00", "updateduser@example.com", "10987654321", "Updated User")
+		updatedRows := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "updatedAt", "email", "cpf", "name", "emailVerifiedAt"}).
+			AddRow("123", false, true, "2025-01-15 12:00:00", "2025-01-16 08:30:00", "updateduser@example.com", "10987654357", "Updated User", nil)
 
-		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, email, cpf, name FROM users WHERE id = ?`).
+		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, updatedAt, email, cpf, name, emailVerifiedAt FROM users WHERE id = ?`).
 			WithArgs("123").
 			WillReturnRows(updatedRows)
 
-		user, err := repo.Update("123", newParams)
+		user, err := repo.Update(context.Background(), "123", newParams)
 
 		assert.NoError(t, err, "Should contain no errors")
 		assert.Equal(t, "123", user.ToDTO().Id, "Id should remain the same")
 		assert.Equal(t, "updateduser@example.com", user.ToDTO().Email, "Email should be updated")
-		assert.Equal(t, "10987654321", user.ToDTO().Cpf, "Cpf should be updated")
+		assert.Equal(t, "10987654357", user.ToUnmaskedDTO().Cpf, "Cpf should be updated")
 		assert.Equal(t, "Updated User", user.ToDTO().Name, "Name should be updated")
+		assert.Equal(t, "2025-01-15T12:00:00Z", user.ToDTO().CreatedAt, "CreatedAt should remain the same")
+		assert.Equal(t, "2025-01-16T08:30:00Z", user.ToDTO().UpdatedAt, "UpdatedAt should be moved")
+		assert.False(t, user.IsEmailVerified(), "The new email should need a verification")
 	})
 
 	t.Run("Update with Null Params", func(t *testing.T) {
@@ -195,14 +308,14 @@ func TestUpdateUser(t *testing.T) {
 		}
 		defer db.Close()
 
-		repo := &user.MySQLUserRepository{}
-		repo.SetDB(db)
+		repo := user.NewMySQLUserRepository(db, clock.System{})
+		verifiedAt := timestamp.New(time.Date(2025, 1, 15, 13, 0, 0, 0, time.UTC))
 
-		existingUser := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "email", "cpf", "name"}).
-			AddRow("123", true, false, "2025-01-15 12:00:00", "testuser@example.com", "12345678901", "Original User")
+		existingUser := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "updatedAt", "email", "cpf", "name", "emailVerifiedAt"}).
+			AddRow("123", true, false, "2025-01-15 12:00:00", "2025-01-15 12:00:00", "testuser@example.com", "12345678909", "Original User", "2025-01-15 13:00:00")
 
 		// Expect the `GetOne` call to return the existing user
-		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, email, cpf, name FROM users WHERE id = ?`).
+		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, updatedAt, email, cpf, name, emailVerifiedAt FROM users WHERE id = ?`).
 			WithArgs("123").
 			WillReturnRows(existingUser)
 
@@ -213,23 +326,93 @@ func TestUpdateUser(t *testing.T) {
 		}
 
 		// Expect the `UPDATE` query with values including the updated fields and the unchanged fields
-		mock.ExpectExec(regexp.QuoteMeta(`UPDATE users SET isActive = ?, isDeleted = ?, email = ?, cpf = ?, name = ? WHERE id = ?`)).
-			WithArgs(false, false, "testuser@example.com", "12345678901", "Partially Updated User", "123").
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE users SET isActive = ?, email = ?, emailVerifiedAt = ?, cpf = ?, name = ?, passwordHash = COALESCE(?, passwordHash), updatedAt = ? WHERE id = ? AND isDeleted = FALSE`)).
+			WithArgs(false, "testuser@example.com", verifiedAt, "12345678909", "Partially Updated User", nil, sqlmock.AnyArg(), "123").
 			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
 
 		// Expect the `GetOne` call after the update to return the updated user
-		updatedUser := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "email", "cpf", "name"}).
-			AddRow("123", false, false, "2025-01-15 12:00:00", "testuser@example.com", "12345678901", "Partially Updated User")
+		updatedUser := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "updatedAt", "email", "cpf", "name", "emailVerifiedAt"}).
+			AddRow("123", false, false, "2025-01-15 12:00:00", "2025-01-15 12:00:00", "testuser@example.com", "12345678909", "Partially Updated User", "2025-01-15 13:00:00")
 
-		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, email, cpf, name FROM users WHERE id = ?`).
+		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, updatedAt, email, cpf, name, emailVerifiedAt FROM users WHERE id = ?`).
 			WithArgs("123").
 			WillReturnRows(updatedUser)
 
-		user, err := repo.Update("123", newParams)
+		user, err := repo.Update(context.Background(), "123", newParams)
 
 		assert.NoError(t, err, "Should not fail when updating with partial fields")
 		assert.Equal(t, "123", user.ToDTO().Id, "Id should remain the same")
 		assert.Equal(t, false, user.GetIsActive(), "IsActive should match the updated value")
 		assert.Equal(t, "Partially Updated User", user.ToDTO().Name, "Name should be updated")
+		assert.Equal(t, "2025-01-15T13:00:00Z", *user.ToDTO().EmailVerifiedAt, "The same email should stay verified")
+	})
+	t.Run("ShouldHashANewPasswordAndRevokeTheSessions", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		now := time.Date(2025, 1, 16, 8, 30, 0, 0, time.UTC)
+		repo := user.NewMySQLUserRepository(db, clock.Fixed(now))
+		row := func() *sqlmock.Rows {
+			return sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "updatedAt", "email", "cpf", "name", "emailVerifiedAt"}).
+				AddRow("123", true, false, "2025-01-15 12:00:00", "2025-01-15 12:00:00", "testuser@example.com", "12345678909", "Original User", nil)
+		}
+
+		mock.ExpectQuery(`SELECT id, isActive`).WithArgs("123").WillReturnRows(row())
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE users SET`).
+			WithArgs(true, "testuser@example.com", nil, "12345678909", "Original User", bcryptOf("a new password"), timestamp.New(now), "123").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE sessions SET revokedAt = ? WHERE user_id = ? AND revokedAt IS NULL`)).
+			WithArgs(timestamp.New(now), "123").
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectCommit()
+		mock.ExpectQuery(`SELECT id, isActive`).WithArgs("123").WillReturnRows(row())
+
+		_, err = repo.Update(context.Background(), "123", user.UserParams{Password: testhelper.StringPointer("a new password")})
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("ShouldRejectAShortPassword", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := user.NewMySQLUserRepository(db, clock.System{})
+		mock.ExpectQuery(`SELECT id, isActive`).
+			WithArgs("123").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "updatedAt", "email", "cpf", "name", "emailVerifiedAt"}).
+				AddRow("123", true, false, "2025-01-15 12:00:00", "2025-01-15 12:00:00", "testuser@example.com", "12345678909", "Original User", nil))
+
+		_, err = repo.Update(context.Background(), "123", user.UserParams{Password: testhelper.StringPointer("short")})
+
+		assert.ErrorIs(t, err, apperror.ErrValidation)
+		assert.NoError(t, mock.ExpectationsWereMet(), "Nothing should be updated")
+	})
+	t.Run("ShouldRejectAnInvalidCpf", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock DB: %v", err)
+		}
+		defer db.Close()
+
+		repo := user.NewMySQLUserRepository(db, clock.System{})
+
+		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, updatedAt, email, cpf, name, emailVerifiedAt FROM users WHERE id = ?`).
+			WithArgs("123").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "updatedAt", "email", "cpf", "name", "emailVerifiedAt"}).
+				AddRow("123", true, false, "2025-01-15 12:00:00", "2025-01-15 12:00:00", "testuser@example.com", "12345678909", "Original User", nil))
+
+		_, err = repo.Update(context.Background(), "123", user.UserParams{Cpf: testhelper.StringPointer("111.111.111-11")})
+
+		assert.ErrorIs(t, err, apperror.ErrValidation)
+		assert.NoError(t, mock.ExpectationsWereMet(), "Nothing should be updated")
 	})
 }
@@ -3,18 +3,20 @@ package user
 import (
 	"net/http"
 	"sipub-test/internal"
+	"sipub-test/internal/auth"
 )
 
+// Creating a user (signing up) and reading them is public. A user can only
+// change, delete or purge itself, deleting in bulk and restoring are left to
+// the admins
 type UserRouter struct {
 	baseEndPoint string
-	controller   internal.IController
+	controller   internal.ISoftDeleteController
+	guard        *auth.Guard
 }
 
-func NewUserRouter() UserRouter {
-	router := UserRouter{
-		controller: NewUserController(),
-	}
-	return router
+func NewUserRouter(controller internal.ISoftDeleteController, guard *auth.Guard) UserRouter {
+	return UserRouter{controller: controller, guard: guard}
 }
 
 func (r UserRouter) Init(mux *http.ServeMux) {
@@ -26,6 +28,8 @@ func (r UserRouter) Init(mux *http.ServeMux) {
 	r.deleteAll(mux)
 	r.deleteOne(mux)
 	r.update(mux)
+	r.restore(mux)
+	r.purge(mux)
 }
 
 func (r UserRouter) create(mux *http.ServeMux) {
@@ -37,17 +41,25 @@ func (r UserRouter) getAll(mux *http.ServeMux) {
 }
 
 func (r UserRouter) getOne(mux *http.ServeMux) {
-	mux.HandleFunc("GET "+r.baseEndPoint+"/{id}", r.controller.GetOne)
+	mux.HandleFunc("GET "+r.baseEndPoint+"/{id}", r.guard.Optional(r.controller.GetOne))
 }
 
 func (r UserRouter) deleteAll(mux *http.ServeMux) {
-	mux.HandleFunc("DELETE "+r.baseEndPoint, r.controller.DeleteAll)
+	mux.HandleFunc("DELETE "+r.baseEndPoint, r.guard.Admin(r.controller.DeleteAll))
 }
 
 func (r UserRouter) deleteOne(mux *http.ServeMux) {
-	mux.HandleFunc("DELETE "+r.baseEndPoint+"/{id}", r.controller.DeleteOne)
+	mux.HandleFunc("DELETE "+r.baseEndPoint+"/{id}", r.guard.Self(r.controller.DeleteOne))
 }
 
 func (r UserRouter) update(mux *http.ServeMux) {
-	mux.HandleFunc("PUT "+r.baseEndPoint+"/{id}", r.controller.Update)
+	mux.HandleFunc("PUT "+r.baseEndPoint+"/{id}", r.guard.Self(r.controller.Update))
+}
+
+func (r UserRouter) restore(mux *http.ServeMux) {
+	mux.HandleFunc("POST "+r.baseEndPoint+"/{id}/restore", r.guard.Admin(r.controller.Restore))
+}
+
+func (r UserRouter) purge(mux *http.ServeMux) {
+	mux.HandleFunc("DELETE "+r.baseEndPoint+"/{id}/purge", r.guard.Self(r.controller.Purge))
 }
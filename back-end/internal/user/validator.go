@@ -1,7 +1,10 @@
 package user
 
 import (
-	"errors"
+	"sipub-test/pkg/apperror"
+	"sipub-test/pkg/cpf"
+	"sipub-test/pkg/email"
+	"sipub-test/pkg/password"
 )
 
 type UserValidator struct{}
@@ -10,28 +13,40 @@ type UserValidator struct{}
 
 func (v *UserValidator) Validate(user UserParams) error {
 	if user.Name == nil {
-		return errors.New("Invalid name")
+		return apperror.NewValidationError("Name", "is required")
 	}
 	if *user.Name == "" {
-		return errors.New("invalid Name")
+		return apperror.NewValidationError("Name", "can't be empty")
 	}
 	if user.Email == nil {
-		return errors.New("Invalid Price")
+		return apperror.NewValidationError("Email", "is required")
 	}
 	if *user.Email == "" {
-		return errors.New("Invalid Price")
+		return apperror.NewValidationError("Email", "can't be empty")
+	}
+	if _, err := email.Normalize(*user.Email); err != nil {
+		return apperror.NewValidationError("Email", err.Error())
 	}
 	if user.Cpf == nil {
-		return errors.New("Invalid WeightGrams")
+		return apperror.NewValidationError("Cpf", "is required")
+	}
+	if *user.Cpf == "" {
+		return apperror.NewValidationError("Cpf", "can't be empty")
+	}
+	if _, err := cpf.Normalize(*user.Cpf); err != nil {
+		return apperror.NewValidationError("Cpf", err.Error())
+	}
+	if user.Password == nil {
+		return apperror.NewValidationError("Password", "is required")
 	}
-	if *user.Cpf <= "" {
-		return errors.New("Invalid WeightGrams")
+	if err := password.Validate(*user.Password); err != nil {
+		return apperror.NewValidationError("Password", err.Error())
 	}
 	if user.IsActive == nil {
-		return errors.New("Invalid IsActive")
+		return apperror.NewValidationError("IsActive", "is required")
 	}
 	if user.IsDeleted == nil {
-		return errors.New("Invalid IsDeleted")
+		return apperror.NewValidationError("IsDeleted", "is required")
 	}
 	return nil
 }
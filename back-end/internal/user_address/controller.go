@@ -2,140 +2,122 @@ package user_address
 
 import (
 	"encoding/json"
-	"fmt"
+	"log/slog"
 	"net/http"
-	"strings"
+	"sipub-test/internal/filter"
+	"sipub-test/internal/pagination"
+	"sipub-test/internal/response"
+	"sipub-test/pkg/apperror"
 )
 
 type UserAddressController struct {
-	// TODO
 	repository IUserAddressRepository
+	logger     *slog.Logger
 }
 
-// Used for testing
-func (c *UserAddressController) SetRepository(repo IUserAddressRepository) {
-	c.repository = repo
-}
-
-func NewUserAddressController() *UserAddressController {
-	return &UserAddressController{repository: NewMySQLUserAddressRepository()}
+func NewUserAddressController(repository IUserAddressRepository, logger *slog.Logger) *UserAddressController {
+	return &UserAddressController{repository: repository, logger: logger}
 }
 
 func (c *UserAddressController) Create(w http.ResponseWriter, r *http.Request) {
 	var userAddressParam UserAddressParams
 	err := json.NewDecoder(r.Body).Decode(&userAddressParam)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		response.BadRequest(w, r, "invalid request body: "+err.Error())
 		return
 	}
 
-	{ // Validation is simpler because there are only two fields
-		if userAddressParam.UserID == "" || userAddressParam.AddressID == "" {
-			http.Error(w, "Invalid userID or addressID", http.StatusInternalServerError)
-		}
+	// Validation is simpler because there are only two fields
+	if userAddressParam.UserID == "" {
+		response.Error(w, r, c.logger, apperror.NewValidationError("UserID", "can't be empty"))
+		return
 	}
-
-	createdUserAddress, err := c.repository.Create(userAddressParam)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if userAddressParam.AddressID == "" {
+		response.Error(w, r, c.logger, apperror.NewValidationError("AddressID", "can't be empty"))
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-
-	if err := json.NewEncoder(w).Encode(createdUserAddress); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	createdUserAddress, err := c.repository.Create(r.Context(), userAddressParam)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
 		return
 	}
+
+	response.JSON(w, http.StatusCreated, createdUserAddress)
 }
 
 func (c *UserAddressController) GetAll(w http.ResponseWriter, r *http.Request) {
-	var userAddressParams UserAddressParams
 	queryParams := r.URL.Query()
 
-	// First it checks to see if the values in the querystring are valid
-	for key := range queryParams {
-		if strings.ToLower(key) != "userid" {
-			http.Error(w, fmt.Sprintf("Invalid query parameter: %s", key), http.StatusBadRequest)
-			return
-		}
+	// The accepted fields and operators are declared in filterFields
+	where, err := filter.Parse(queryParams, filterFields)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
 	}
 
-	if userID := queryParams.Get("userID"); userID != "" {
-		userAddressParams.UserID = userID
+	page, err := pagination.Parse(queryParams, sortFields)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
 	}
 
-	// NOTE: Why don't I just parse it from the json? Well, if the json is nil,
-	// then it will generate an error and if the json isn't but a field is,
-	// that is another possible error. This way, although repetitive, will make
-	// it simple to understand. Where as having multiple nested `if`s might not
-
-	// It now passes the userAddress param as a "filter" and gets the found userAddresses
-	foundUserAddresses, err := c.repository.GetAll(userAddressParams)
+	foundUserAddresses, err := c.repository.GetAll(r.Context(), where, page)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		response.Error(w, r, c.logger, err)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
+	foundUserAddresses, hasMore := pagination.Trim(page, foundUserAddresses)
 
-	if err := json.NewEncoder(w).Encode(foundUserAddresses); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	total, err := c.repository.Count(r.Context(), where)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
 		return
 	}
+
+	var lastID string
+	if hasMore {
+		lastID = foundUserAddresses[len(foundUserAddresses)-1].GetID()
+	}
+	pagination.WriteHeaders(w, r, page, total, hasMore, lastID)
+	response.JSON(w, http.StatusOK, foundUserAddresses)
 }
 
 func (c *UserAddressController) GetOne(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
-	userAddress, err := c.repository.GetOne(id)
+	userAddress, err := c.repository.GetOne(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound) // The Id was not found but the request did go though
-		return
-	}
-	if err := json.NewEncoder(w).Encode(userAddress); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		response.Error(w, r, c.logger, err)
 		return
 	}
+	response.JSON(w, http.StatusOK, userAddress)
 }
 
 func (c *UserAddressController) DeleteAll(w http.ResponseWriter, r *http.Request) {
-	var userAddressParams UserAddressParams
-	queryParams := r.URL.Query()
-
-	if userID := queryParams.Get("userID"); userID != "" {
-		userAddressParams.UserID = userID
-	}
-
-	if addressID := queryParams.Get("addressID"); addressID != "" {
-		userAddressParams.AddressID = addressID
+	where, err := filter.Parse(r.URL.Query(), filterFields)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
 	}
 
-	count, err := c.repository.DeleteAll(userAddressParams)
+	count, err := c.repository.DeleteAll(r.Context(), where)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		response.Error(w, r, c.logger, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(count); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
+	response.JSON(w, http.StatusOK, count)
 }
 
 func (c *UserAddressController) DeleteOne(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
-	count, err := c.repository.DeleteOne(id)
+	count, err := c.repository.DeleteOne(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound) // Did go through, none found
+		response.Error(w, r, c.logger, err)
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(count); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
+	response.JSON(w, http.StatusOK, count)
 }
 
 func (c *UserAddressController) Update(w http.ResponseWriter, r *http.Request) {
@@ -1,22 +1,31 @@
 package user_address
 
+import (
+	"context"
+	"sipub-test/internal/filter"
+	"sipub-test/internal/pagination"
+)
+
 type IUserAddressRepository interface {
 	// Returns the created user
-	Create(UserAddressParams) (UserAddressModel, error)
+	Create(ctx context.Context, params UserAddressParams) (UserAddressModel, error)
 
 	// Returns the found users
 	// NOTE: reusing the same type for a filter and a "constructor" is not
 	// ideal at all, but it will save on code repetition
-	GetAll(filter UserAddressParams) ([]UserAddressModel, error)
+	GetAll(ctx context.Context, where filter.Filter, page pagination.Page) ([]UserAddressModel, error)
+
+	// Returns how many rows match the filter, ignoring the page
+	Count(ctx context.Context, where filter.Filter) (uint, error)
 
 	// Returns the found user
-	GetOne(id string) (UserAddressModel, error)
+	GetOne(ctx context.Context, id string) (UserAddressModel, error)
 
 	// Returns amount of deleted users
-	DeleteOne(id string) (uint, error)
+	DeleteOne(ctx context.Context, id string) (uint, error)
 
 	// Returns amount of deleted users
-	DeleteAll(filter UserAddressParams) (uint, error)
+	DeleteAll(ctx context.Context, where filter.Filter) (uint, error)
 
 	// Won't be used
 	// Update(id string, newUserAddress UserAddressParams) (UserAddressModel, error)
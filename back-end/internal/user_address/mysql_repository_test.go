@@ -1,8 +1,11 @@
 package user_address_test
 
 import (
+	"context"
 	"fmt"
+	"sipub-test/internal/pagination"
 	"sipub-test/internal/user_address"
+	testhelper "sipub-test/pkg/test_helper"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
@@ -17,8 +20,7 @@ func TestCreateUserAddress(t *testing.T) {
 		}
 		defer db.Close()
 
-		repo := &user_address.MySQLUserAddressRepository{}
-		repo.SetDB(db)
+		repo := user_address.NewMySQLUserAddressRepository(db)
 
 		params := user_address.UserAddressParams{
 			UserID:    "user-123",    // UserID of an existing user
@@ -29,7 +31,7 @@ func TestCreateUserAddress(t *testing.T) {
 			WithArgs(sqlmock.AnyArg() /* id determined at function */, "user-123", "address-456").
 			WillReturnResult(sqlmock.NewResult(1, 1))
 
-		userAddress, err := repo.Create(params)
+		userAddress, err := repo.Create(context.Background(), params)
 
 		assert.NoError(t, err, "Shouldn't contain any errors")
 		assert.Equal(t, params.UserID, userAddress.UserID)
@@ -45,8 +47,7 @@ func TestGetAllUserAddresses(t *testing.T) {
 		}
 		defer db.Close()
 
-		repo := &user_address.MySQLUserAddressRepository{}
-		repo.SetDB(db)
+		repo := user_address.NewMySQLUserAddressRepository(db)
 
 		rows := sqlmock.NewRows([]string{"id", "user_id", "address_id"}).
 			AddRow("123", "user-123", "address-456")
@@ -54,8 +55,8 @@ func TestGetAllUserAddresses(t *testing.T) {
 		mock.ExpectQuery(`SELECT id, user_id, address_id FROM user_address`).
 			WillReturnRows(rows)
 
-		filter := user_address.UserAddressParams{UserID: "user-123"}
-		userAddresses, err := repo.GetAll(filter)
+		where := testhelper.FilterEqual("UserID", "user_id", "user-123")
+		userAddresses, err := repo.GetAll(context.Background(), where, pagination.Page{})
 
 		assert.NoError(t, err, "Should have no errors")
 		assert.Len(t, userAddresses, 1, "Length should be 1")
@@ -68,15 +69,14 @@ func TestGetAllUserAddresses(t *testing.T) {
 		}
 		defer db.Close()
 
-		repo := &user_address.MySQLUserAddressRepository{}
-		repo.SetDB(db)
+		repo := user_address.NewMySQLUserAddressRepository(db)
 
 		// Should return "failed to get user_address"
 		mock.ExpectQuery(`SELECT id, user_iD, address_id FROM user_address`).
 			WillReturnError(fmt.Errorf("failed to get user_address"))
 
-		filter := user_address.UserAddressParams{UserID: "nonexistent-user-id"}
-		userAddresses, err := repo.GetAll(filter)
+		where := testhelper.FilterEqual("UserID", "user_id", "nonexistent-user-id")
+		userAddresses, err := repo.GetAll(context.Background(), where, pagination.Page{})
 
 		assert.Error(t, err, "Should have an error")
 		assert.Len(t, userAddresses, 0, "Length should be 0")
@@ -90,8 +90,7 @@ func TestGetUserAddressByID(t *testing.T) {
 	}
 	defer db.Close()
 
-	repo := &user_address.MySQLUserAddressRepository{}
-	repo.SetDB(db)
+	repo := user_address.NewMySQLUserAddressRepository(db)
 
 	rows := sqlmock.NewRows([]string{"id", "user_id", "addressID"}).
 		AddRow("123", "user-123", "address-456")
@@ -100,7 +99,7 @@ func TestGetUserAddressByID(t *testing.T) {
 		WithArgs("123").
 		WillReturnRows(rows)
 
-	userAddress, err := repo.GetOne("123")
+	userAddress, err := repo.GetOne(context.Background(), "123")
 
 	assert.NoError(t, err, "Should have no errors")
 	assert.Equal(t, "user-123", userAddress.UserID, "UserID should be the same")
@@ -114,14 +113,13 @@ func TestDeleteUserAddress(t *testing.T) {
 	}
 	defer db.Close()
 
-	repo := &user_address.MySQLUserAddressRepository{}
-	repo.SetDB(db)
+	repo := user_address.NewMySQLUserAddressRepository(db)
 
 	mock.ExpectExec(`DELETE FROM user_address WHERE id = ?`).
 		WithArgs("123").
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	count, err := repo.DeleteOne("123")
+	count, err := repo.DeleteOne(context.Background(), "123")
 
 	assert.NoError(t, err)
 	assert.Equal(t, uint(1), count)
@@ -10,11 +10,8 @@ type UserAddressRouter struct {
 	controller   internal.IController
 }
 
-func NewUserAddressRouter() UserAddressRouter {
-	router := UserAddressRouter{
-		controller: NewUserAddressController(),
-	}
-	return router
+func NewUserAddressRouter(controller internal.IController) UserAddressRouter {
+	return UserAddressRouter{controller: controller}
 }
 
 func (r UserAddressRouter) Init(mux *http.ServeMux) {
@@ -2,133 +2,123 @@ package user_delivery
 
 import (
 	"encoding/json"
-	"fmt"
+	"log/slog"
 	"net/http"
-	"strings"
+	"sipub-test/internal"
+	"sipub-test/internal/filter"
+	"sipub-test/internal/pagination"
+	"sipub-test/internal/response"
 )
 
 type UserDeliveryController struct {
-	// TODO
 	repository IUserDeliveryRepository
+	validator  internal.IValidator[UserDeliveryParams]
+	logger     *slog.Logger
 }
 
-// Used for testing
-func (c *UserDeliveryController) SetRepository(repo IUserDeliveryRepository) {
-	c.repository = repo
-}
-
-func NewUserDeliveryController() *UserDeliveryController {
-	return &UserDeliveryController{repository: NewMySQLUserDeliveryRepository()}
+func NewUserDeliveryController(repository IUserDeliveryRepository, validator internal.IValidator[UserDeliveryParams], logger *slog.Logger) *UserDeliveryController {
+	return &UserDeliveryController{repository: repository, validator: validator, logger: logger}
 }
 
 func (c *UserDeliveryController) Create(w http.ResponseWriter, r *http.Request) {
 	var deliveryParam UserDeliveryParams
 	err := json.NewDecoder(r.Body).Decode(&deliveryParam)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		response.BadRequest(w, r, "invalid request body: "+err.Error())
 		return
 	}
 
-	// Validation
-
-	createdDelivery, err := c.repository.Create(deliveryParam)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := c.validator.Validate(deliveryParam); err != nil {
+		response.Error(w, r, c.logger, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-
-	if err := json.NewEncoder(w).Encode(createdDelivery.ToDTO()); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	createdDelivery, err := c.repository.Create(r.Context(), deliveryParam)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
 		return
 	}
+
+	response.JSON(w, http.StatusCreated, createdDelivery.ToDTO())
 }
 
 func (c *UserDeliveryController) GetAll(w http.ResponseWriter, r *http.Request) {
-	var deliveryParams UserDeliveryParams
 	queryParams := r.URL.Query()
 
-	// First it checks to see if the values in the querystring are valid. IT ONLY CHECKS FOR DELIVERY_ID
-	for key := range queryParams {
-		if strings.ToLower(key) != "deliveryid" {
-			http.Error(w, fmt.Sprintf("Invalid query parameter: %s", key), http.StatusBadRequest)
-			return
-		}
+	// The accepted fields and operators are declared in filterFields
+	where, err := filter.Parse(queryParams, filterFields)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
 	}
 
-	// If the values are valid it will check what each value is
-	if deliveryID := queryParams.Get("deliveryID"); deliveryID != "" {
-		*deliveryParams.DeliveryID = deliveryID
+	page, err := pagination.Parse(queryParams, sortFields)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
+	}
+
+	foundDeliveryes, err := c.repository.GetAll(r.Context(), where, page)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
 	}
+	foundDeliveryes, hasMore := pagination.Trim(page, foundDeliveryes)
 
-	// It now passes the delivery param as a "filter" and gets the found deliveries
-	foundDeliveryes, err := c.repository.GetAll(deliveryParams)
+	total, err := c.repository.Count(r.Context(), where)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		response.Error(w, r, c.logger, err)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
 
 	dtoFoundDeliveries := []UserDeliveryDTO{}
 	for i := 0; i < len(foundDeliveryes); i++ {
 		dtoFoundDeliveries = append(dtoFoundDeliveries, foundDeliveryes[i].ToDTO())
 	}
-	// Returns the DTO deliveries
-	if err := json.NewEncoder(w).Encode(dtoFoundDeliveries); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	var lastID string
+	if hasMore {
+		lastID = dtoFoundDeliveries[len(dtoFoundDeliveries)-1].Id
 	}
+	pagination.WriteHeaders(w, r, page, total, hasMore, lastID)
+	// Returns the DTO deliveries
+	response.JSON(w, http.StatusOK, dtoFoundDeliveries)
 }
 
 func (c *UserDeliveryController) GetOne(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
-	delivery, err := c.repository.GetOne(id)
+	delivery, err := c.repository.GetOne(r.Context(), id)
 	if err != nil {
-		fmt.Println(err)
-		http.Error(w, err.Error(), http.StatusNotFound) // The Id was not found but the request did go though
-		return
-	}
-	if err := json.NewEncoder(w).Encode(delivery.ToDTO()); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		response.Error(w, r, c.logger, err)
 		return
 	}
+	response.JSON(w, http.StatusOK, delivery.ToDTO())
 }
 
 func (c *UserDeliveryController) DeleteAll(w http.ResponseWriter, r *http.Request) {
-	var deliveryParams UserDeliveryParams
-	queryParams := r.URL.Query()
-
-	if deliveryID := queryParams.Get("AddressID"); deliveryID != "" {
-		*deliveryParams.DeliveryID = deliveryID
-	}
-	count, err := c.repository.DeleteAll(deliveryParams)
+	where, err := filter.Parse(r.URL.Query(), filterFields)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		response.Error(w, r, c.logger, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(count); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	count, err := c.repository.DeleteAll(r.Context(), where)
+	if err != nil {
+		response.Error(w, r, c.logger, err)
+		return
 	}
+
+	response.JSON(w, http.StatusOK, count)
 }
 
 func (c *UserDeliveryController) DeleteOne(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
-	count, err := c.repository.DeleteOne(id)
+	count, err := c.repository.DeleteOne(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound) // Did go through, none found
+		response.Error(w, r, c.logger, err)
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(count); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
+	response.JSON(w, http.StatusOK, count)
 }
 
 func (c *UserDeliveryController) Update(w http.ResponseWriter, r *http.Request) {
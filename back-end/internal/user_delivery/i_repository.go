@@ -1,22 +1,31 @@
 package user_delivery
 
+import (
+	"context"
+	"sipub-test/internal/filter"
+	"sipub-test/internal/pagination"
+)
+
 type IUserDeliveryRepository interface {
 	// Returns the created userDelivery
-	Create(UserDeliveryParams) (UserDeliveryModel, error)
+	Create(ctx context.Context, params UserDeliveryParams) (UserDeliveryModel, error)
 
 	// Returns the found userDeliverys
 	// NOTE: reusing the same type for a filter and a "constructor" is not
 	// ideal at all, but it will save on code repetition
-	GetAll(filter UserDeliveryParams) ([]UserDeliveryModel, error)
+	GetAll(ctx context.Context, where filter.Filter, page pagination.Page) ([]UserDeliveryModel, error)
+
+	// Returns how many rows match the filter, ignoring the page
+	Count(ctx context.Context, where filter.Filter) (uint, error)
 
 	// Returns the found userDelivery
-	GetOne(id string) (UserDeliveryModel, error)
+	GetOne(ctx context.Context, id string) (UserDeliveryModel, error)
 
 	// Returns amount of deleted userDelivery
-	DeleteOne(id string) (uint, error)
+	DeleteOne(ctx context.Context, id string) (uint, error)
 
 	// Returns amount of deleted userDelivery
-	DeleteAll(filter UserDeliveryParams) (uint, error)
+	DeleteAll(ctx context.Context, where filter.Filter) (uint, error)
 
 	// Not used, delivery-product should not be updated
 	// Update(id string, newUserDelivery UserDeliveryParams) (UserDeliveryModel, error)
@@ -1,51 +1,40 @@
 package user_delivery
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
-	"log"
 	"sipub-test/db"
+	"sipub-test/internal/filter"
+	"sipub-test/internal/pagination"
+	"sipub-test/pkg/apperror"
 
 	"github.com/google/uuid"
 )
 
 type MySQLUserDeliveryRepository struct {
-	db *sql.DB
+	db db.Executor
 }
 
-// Mainly used for testing, but could be used elsewhere
-func (r *MySQLUserDeliveryRepository) SetDB(db *sql.DB) { r.db = db }
-
-func (r *MySQLUserDeliveryRepository) createNewUserDeliveryTableIfNoneExists() {
-	r.db = db.GetDB()
-
-	createTableQuery := `
-	CREATE TABLE IF NOT EXISTS user_delivery (
-		id CHAR(36) NOT NULL,
-        delivery_id char(36) NOT NULL,
-        user_id char(36) NOT NULL,
-        FOREIGN KEY (delivery_id) REFERENCES deliveries(id) ON DELETE CASCADE,
-        FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
-        PRIMARY KEY (id)
-	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`
-	// https://dev.mysql.com/doc/refman/8.4/en/innodb-benefits.html
-	// Mainly using InnoDB because it supports foreing keys
-	// createdAt is a string because it is simpler to handle. It uses this
-	// format 2006-01-02 15:04:05 (19 chars)
-
-	if _, err := r.db.Exec(createTableQuery); err != nil {
-		log.Fatalf("Failed to create table: %v", err)
-	}
+func NewMySQLUserDeliveryRepository(db db.Executor) *MySQLUserDeliveryRepository {
+	return &MySQLUserDeliveryRepository{db: db}
+}
+
+// Fields the list can be filtered and deleted by
+var filterFields = filter.Schema{
+	"UserID":     {Column: "user_id", Type: filter.String, Operators: []filter.Operator{filter.Eq, filter.In}},
+	"DeliveryID": {Column: "delivery_id", Type: filter.String, Operators: []filter.Operator{filter.Eq, filter.In}},
 }
 
-func NewMySQLUserDeliveryRepository() *MySQLUserDeliveryRepository {
-	repo := &MySQLUserDeliveryRepository{db: db.GetDB()}
-	repo.createNewUserDeliveryTableIfNoneExists()
-	return repo
+// Columns the list can be sorted by
+var sortFields = pagination.Fields{
+	Table:    "user_delivery",
+	IDColumn: "id",
+	Columns:  map[string]string{},
 }
 
-func (r *MySQLUserDeliveryRepository) Create(params UserDeliveryParams) (UserDeliveryModel, error) {
+func (r *MySQLUserDeliveryRepository) Create(ctx context.Context, params UserDeliveryParams) (UserDeliveryModel, error) {
 	id := uuid.NewString()
 
 	// Fields might be nil, but they need to be passed empty/defaulted non nil fields
@@ -57,26 +46,20 @@ func (r *MySQLUserDeliveryRepository) Create(params UserDeliveryParams) (UserDel
 
 	query := `INSERT INTO user_delivery (id, delivery_id, user_id) VALUES (?, ?, ?)`
 
-	_, err := r.db.Exec(query, id, model.deliveryID, model.userID)
+	_, err := r.db.ExecContext(ctx, query, id, model.deliveryID, model.userID)
 	if err != nil {
-		fmt.Println(err)
-		return UserDeliveryModel{}, fmt.Errorf("failed to create delivery: %w", err)
+		return UserDeliveryModel{}, fmt.Errorf("failed to create delivery: %w", db.TranslateError(err))
 	}
 
 	return model, nil
 }
 
-func (r *MySQLUserDeliveryRepository) GetAll(filter UserDeliveryParams) ([]UserDeliveryModel, error) {
-	query := `SELECT id, delivery_id, user_id FROM user_delivery WHERE 1=1`
-	args := []interface{}{}
+func (r *MySQLUserDeliveryRepository) GetAll(ctx context.Context, where filter.Filter, page pagination.Page) ([]UserDeliveryModel, error) {
+	conditions, args := where.SQL()
+	query := `SELECT id, delivery_id, user_id FROM user_delivery WHERE 1=1` + conditions
+	query, args = sortFields.Apply(page, query, args)
 
-	// Only looks for userid
-	if filter.UserID != nil {
-		query += " AND user_id = ?"
-		args = append(args, *filter.DeliveryID)
-	}
-
-	rows, err := r.db.Query(query, args...)
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get userDelivery: %w", err)
 	}
@@ -95,41 +78,57 @@ func (r *MySQLUserDeliveryRepository) GetAll(filter UserDeliveryParams) ([]UserD
 	return userDelivery, nil
 }
 
-func (r *MySQLUserDeliveryRepository) GetOne(id string) (UserDeliveryModel, error) {
+func (r *MySQLUserDeliveryRepository) Count(ctx context.Context, where filter.Filter) (uint, error) {
+	conditions, args := where.SQL()
+	query := `SELECT COUNT(*) FROM user_delivery WHERE 1=1` + conditions
+
+	var count uint
+	if err := r.db.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count user_delivery: %w", err)
+	}
+	return count, nil
+}
+
+func (r *MySQLUserDeliveryRepository) GetOne(ctx context.Context, id string) (UserDeliveryModel, error) {
 	query := `SELECT id, delivery_id, user_id FROM user_delivery WHERE id = ?`
 
 	var delivery UserDeliveryModel
-	row := r.db.QueryRow(query, id)
+	row := r.db.QueryRowContext(ctx, query, id)
 	err := row.Scan(&delivery.id, &delivery.deliveryID, &delivery.userID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return UserDeliveryModel{}, fmt.Errorf("delivery not found")
+			return UserDeliveryModel{}, fmt.Errorf("user delivery %w", apperror.ErrNotFound)
 		}
 		return UserDeliveryModel{}, fmt.Errorf("failed to get delivery: %w", err)
 	}
 	return delivery, nil
 }
 
-func (r *MySQLUserDeliveryRepository) DeleteOne(id string) (uint, error) {
+func (r *MySQLUserDeliveryRepository) DeleteOne(ctx context.Context, id string) (uint, error) {
 	query := `DELETE FROM user_delivery WHERE id = ?`
-	res, err := r.db.Exec(query, id)
+	res, err := r.db.ExecContext(ctx, query, id)
 	if err != nil {
-		return 0, fmt.Errorf("failed to delete delivery: %w", err)
+		return 0, fmt.Errorf("failed to delete delivery: %w", db.TranslateError(err))
 	}
 	count, _ := res.RowsAffected()
 	if count == 0 {
-		return 0, fmt.Errorf("no delivery found with the given ID")
+		return 0, fmt.Errorf("user delivery %w", apperror.ErrNotFound)
 	}
 	return uint(count), nil
 }
 
-func (r *MySQLUserDeliveryRepository) DeleteAll(filter UserDeliveryParams) (uint, error) {
-	query := `DELETE FROM user_delivery WHERE 1=1 AND user_id = ?`
-	args := []interface{}{*filter.UserID}
+func (r *MySQLUserDeliveryRepository) DeleteAll(ctx context.Context, where filter.Filter) (uint, error) {
+	// Listing every row is fine, deleting all of them by accident isn't
+	if !where.Has("UserID") {
+		return 0, apperror.NewValidationError("UserID", "is required")
+	}
+
+	conditions, args := where.SQL()
+	query := `DELETE FROM user_delivery WHERE 1=1` + conditions
 
-	res, err := r.db.Exec(query, args...)
+	res, err := r.db.ExecContext(ctx, query, args...)
 	if err != nil {
-		return 0, fmt.Errorf("failed to delete user_delivery: %w", err)
+		return 0, fmt.Errorf("failed to delete user_delivery: %w", db.TranslateError(err))
 	}
 	count, _ := res.RowsAffected()
 	return uint(count), nil
@@ -1,7 +1,10 @@
 package user_delivery_test
 
 import (
+	"context"
 	"regexp"
+	"sipub-test/internal/filter"
+	"sipub-test/internal/pagination"
 	"sipub-test/internal/user_delivery"
 	testhelper "sipub-test/pkg/test_helper"
 	"testing"
@@ -18,8 +21,7 @@ func TestCreateUserDelivery(t *testing.T) {
 		}
 		defer db.Close()
 
-		repo := &user_delivery.MySQLUserDeliveryRepository{}
-		repo.SetDB(db)
+		repo := user_delivery.NewMySQLUserDeliveryRepository(db)
 
 		params := user_delivery.UserDeliveryParams{
 			DeliveryID: testhelper.StringPointer("order-123"),
@@ -30,7 +32,7 @@ func TestCreateUserDelivery(t *testing.T) {
 			WithArgs(sqlmock.AnyArg(), "order-123", "user-123").
 			WillReturnResult(sqlmock.NewResult(1, 1))
 
-		result, err := repo.Create(params)
+		result, err := repo.Create(context.Background(), params)
 
 		assert.NoError(t, err, "Shouldn't contain any errors")
 		assert.Equal(t, "order-123", result.ToDTO().DeliveryID, "DeliveryID should match")
@@ -46,8 +48,7 @@ func TestGetAllUserDeliveries(t *testing.T) {
 		}
 		defer db.Close()
 
-		repo := &user_delivery.MySQLUserDeliveryRepository{}
-		repo.SetDB(db)
+		repo := user_delivery.NewMySQLUserDeliveryRepository(db)
 
 		rows := sqlmock.NewRows([]string{"id", "delivery_id", "user_id"}).
 			AddRow("delivery-123", "order-123", "user-123")
@@ -55,8 +56,8 @@ func TestGetAllUserDeliveries(t *testing.T) {
 		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, delivery_id, user_id FROM user_delivery WHERE 1=1`)).
 			WillReturnRows(rows)
 
-		filter := user_delivery.UserDeliveryParams{}
-		results, err := repo.GetAll(filter)
+		where := filter.Filter{}
+		results, err := repo.GetAll(context.Background(), where, pagination.Page{})
 
 		assert.NoError(t, err, "Shouldn't contain any errors")
 		assert.Len(t, results, 1, "Result length should be 1")
@@ -72,8 +73,7 @@ func TestGetUserDeliveryByID(t *testing.T) {
 	}
 	defer db.Close()
 
-	repo := &user_delivery.MySQLUserDeliveryRepository{}
-	repo.SetDB(db)
+	repo := user_delivery.NewMySQLUserDeliveryRepository(db)
 
 	rows := sqlmock.NewRows([]string{"id", "delivery_id", "user_id"}).
 		AddRow("delivery-123", "order-123", "user-123")
@@ -82,7 +82,7 @@ func TestGetUserDeliveryByID(t *testing.T) {
 		WithArgs("delivery-123").
 		WillReturnRows(rows)
 
-	result, err := repo.GetOne("delivery-123")
+	result, err := repo.GetOne(context.Background(), "delivery-123")
 
 	assert.NoError(t, err, "Shouldn't contain any errors")
 	assert.Equal(t, "delivery-123", result.ToDTO().Id, "ID should match")
@@ -97,14 +97,13 @@ func TestDeleteUserDelivery(t *testing.T) {
 	}
 	defer db.Close()
 
-	repo := &user_delivery.MySQLUserDeliveryRepository{}
-	repo.SetDB(db)
+	repo := user_delivery.NewMySQLUserDeliveryRepository(db)
 
 	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM user_delivery WHERE id = ?`)).
 		WithArgs("delivery-123").
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	count, err := repo.DeleteOne("delivery-123")
+	count, err := repo.DeleteOne(context.Background(), "delivery-123")
 
 	assert.NoError(t, err, "Shouldn't contain any errors")
 	assert.Equal(t, uint(1), count, "Affected row count should be 1")
@@ -118,18 +117,15 @@ func TestDeleteAllUserDeliveries(t *testing.T) {
 		}
 		defer db.Close()
 
-		repo := &user_delivery.MySQLUserDeliveryRepository{}
-		repo.SetDB(db)
+		repo := user_delivery.NewMySQLUserDeliveryRepository(db)
 
 		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM user_delivery WHERE 1=1 AND user_id = ?`)).
 			WithArgs("user-123").
 			WillReturnResult(sqlmock.NewResult(1, 3))
 
-		filter := user_delivery.UserDeliveryParams{
-			UserID: testhelper.StringPointer("user-123"),
-		}
+		where := testhelper.FilterEqual("UserID", "user_id", "user-123")
 
-		count, err := repo.DeleteAll(filter)
+		count, err := repo.DeleteAll(context.Background(), where)
 
 		assert.NoError(t, err, "Shouldn't contain any errors")
 		assert.Equal(t, uint(3), count, "Affected row count should be 3")
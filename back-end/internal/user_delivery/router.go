@@ -10,11 +10,8 @@ type UserDeliveryRouter struct {
 	controller   internal.IController
 }
 
-func NewUserDeliveryRouter() UserDeliveryRouter {
-	router := UserDeliveryRouter{
-		controller: NewUserDeliveryController(),
-	}
-	return router
+func NewUserDeliveryRouter(controller internal.IController) UserDeliveryRouter {
+	return UserDeliveryRouter{controller: controller}
 }
 
 func (r UserDeliveryRouter) Init(mux *http.ServeMux) {
@@ -1,7 +1,7 @@
 package user_delivery
 
 import (
-	"errors"
+	"sipub-test/pkg/apperror"
 )
 
 type UserDeliveryValidator struct{}
@@ -10,7 +10,7 @@ type UserDeliveryValidator struct{}
 
 func (v *UserDeliveryValidator) Validate(delivery UserDeliveryParams) error {
 	if delivery.DeliveryID == nil {
-		return errors.New("Empty DeliveryID")
+		return apperror.NewValidationError("DeliveryID", "is required")
 	}
 	return nil
 }
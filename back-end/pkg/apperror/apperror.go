@@ -0,0 +1,51 @@
+// Domain errors shared by every layer. Repositories and validators wrap these
+// so the controllers can pick a status code with errors.Is instead of guessing
+// based on which call failed.
+
+package apperror
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrConflict   = errors.New("conflict")
+	ErrValidation = errors.New("validation failed")
+
+	// The request has no valid session (401), or has one that can't act on
+	// the entity (403)
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+
+	// Returned by the repositories when the database refuses a write, see
+	// db.TranslateError
+	ErrDuplicate  = errors.New("duplicate entry")
+	ErrForeignKey = errors.New("referenced entity doesn't exist or is still in use")
+
+	// Returned by the payment gateways when the card or the amount is refused.
+	// Unlike the other errors it is not a bug in the request, the client can
+	// try again with another card
+	ErrPaymentDeclined = errors.New("payment declined")
+)
+
+// Returned by the validators, Field is the name of the invalid param as the
+// client sent it (Name, WeightGrams...)
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func NewValidationError(field string, message string) *ValidationError {
+	return &ValidationError{Field: field, Message: message}
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// errors.Is(err, ErrValidation) is true for every ValidationError
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
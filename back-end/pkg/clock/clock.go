@@ -0,0 +1,20 @@
+// The repositories ask a Clock for the current time instead of calling
+// time.Now directly, this way tests can freeze the time and assert on it.
+
+package clock
+
+import "time"
+
+type Clock interface {
+	Now() time.Time
+}
+
+// Uses the system time, used outside of tests
+type System struct{}
+
+func (System) Now() time.Time { return time.Now() }
+
+// Always returns the same time
+type Fixed time.Time
+
+func (f Fixed) Now() time.Time { return time.Time(f) }
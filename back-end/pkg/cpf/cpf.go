@@ -0,0 +1,64 @@
+// Brazilian taxpayer numbers. The users send them with or without the dots
+// and dash (123.456.789-09), the database keeps only the 11 digits, and the
+// responses show them masked since the number is personal data.
+
+package cpf
+
+import (
+	"errors"
+	"strings"
+)
+
+const length = 11
+
+var (
+	ErrLength      = errors.New("must have 11 digits")
+	ErrRepeated    = errors.New("can't be a single repeated digit")
+	ErrCheckDigits = errors.New("has invalid check digits")
+)
+
+// Returns the 11 digits of a valid CPF. Dots, the dash and spaces are
+// dropped, anything else makes it invalid
+func Normalize(value string) (string, error) {
+	digits := strings.NewReplacer(".", "", "-", "", " ", "").Replace(value)
+	if len(digits) != length {
+		return "", ErrLength
+	}
+	for _, digit := range digits {
+		if digit < '0' || digit > '9' {
+			return "", ErrLength
+		}
+	}
+	// 000.000.000-00, 111.111.111-11... pass the checksum but were never issued
+	if strings.Count(digits, digits[:1]) == length {
+		return "", ErrRepeated
+	}
+	if checkDigit(digits[:9]) != digits[9] || checkDigit(digits[:10]) != digits[10] {
+		return "", ErrCheckDigits
+	}
+	return digits, nil
+}
+
+// Modulo 11 of the digits weighted from len+1 down to 2
+func checkDigit(digits string) byte {
+	sum := 0
+	weight := len(digits) + 1
+	for i := 0; i < len(digits); i++ {
+		sum += int(digits[i]-'0') * weight
+		weight--
+	}
+	rest := sum % 11
+	if rest < 2 {
+		return '0'
+	}
+	return byte('0' + 11 - rest)
+}
+
+// Keeps only the middle six digits, 12345678909 is ***.456.789-**. Values
+// that aren't 11 digits are fully hidden
+func Mask(digits string) string {
+	if len(digits) != length {
+		return strings.Repeat("*", len(digits))
+	}
+	return "***." + digits[3:6] + "." + digits[6:9] + "-**"
+}
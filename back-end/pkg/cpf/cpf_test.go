@@ -0,0 +1,47 @@
+package cpf_test
+
+import (
+	"sipub-test/pkg/cpf"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	t.Run("ShouldAcceptFormattedAndPlainDigits", func(t *testing.T) {
+		for _, value := range []string{"123.456.789-09", "12345678909", " 123 456 789 09 "} {
+			digits, err := cpf.Normalize(value)
+
+			assert.NoError(t, err, value)
+			assert.Equal(t, "12345678909", digits, value)
+		}
+	})
+	t.Run("ShouldAcceptACheckDigitOfZero", func(t *testing.T) {
+		digits, err := cpf.Normalize("529.982.247-25")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "52998224725", digits)
+	})
+	t.Run("ShouldRejectInvalidNumbers", func(t *testing.T) {
+		for value, expected := range map[string]error{
+			"":               cpf.ErrLength,
+			"1234567890":     cpf.ErrLength,
+			"123456789091":   cpf.ErrLength,
+			"123.456.789-0a": cpf.ErrLength,
+			"123/456/789-09": cpf.ErrLength,
+			"111.111.111-11": cpf.ErrRepeated,
+			"00000000000":    cpf.ErrRepeated,
+			"123.456.789-01": cpf.ErrCheckDigits,
+			"12345678919":    cpf.ErrCheckDigits,
+		} {
+			_, err := cpf.Normalize(value)
+
+			assert.ErrorIs(t, err, expected, value)
+		}
+	})
+}
+
+func TestMask(t *testing.T) {
+	assert.Equal(t, "***.456.789-**", cpf.Mask("12345678909"))
+	assert.Equal(t, "*****", cpf.Mask("12345"))
+}
@@ -0,0 +1,46 @@
+// Email addresses as the users table keeps them: the bare address of RFC 5322,
+// without a display name, trimmed and lower cased so the unique index sees
+// Ana@Example.com and ana@example.com as the same user.
+
+package email
+
+import (
+	"errors"
+	"net/mail"
+	"strings"
+)
+
+// RFC 5321 limits, the whole path and the part before the @
+const (
+	maxLength      = 254
+	maxLocalLength = 64
+)
+
+var (
+	ErrInvalid = errors.New("must be a valid email address, like name@example.com")
+	ErrTooLong = errors.New("can't be longer than 254 characters")
+)
+
+func Normalize(value string) (string, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if len(value) > maxLength {
+		return "", ErrTooLong
+	}
+	// ParseAddress also accepts "Ana <ana@example.com>" and comments, only
+	// the address alone is stored
+	parsed, err := mail.ParseAddress(value)
+	if err != nil || parsed.Address != value {
+		return "", ErrInvalid
+	}
+	at := strings.LastIndex(value, "@")
+	if at > maxLocalLength {
+		return "", ErrTooLong
+	}
+	// A dotless domain is valid for the RFC but is never a mailbox we can
+	// reach, it is usually a typo (ana@gmail)
+	domain := value[at+1:]
+	if !strings.Contains(domain, ".") || strings.HasPrefix(domain, "[") {
+		return "", ErrInvalid
+	}
+	return value, nil
+}
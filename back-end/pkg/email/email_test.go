@@ -0,0 +1,44 @@
+package email_test
+
+import (
+	"sipub-test/pkg/email"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	t.Run("ShouldTrimAndLowerCase", func(t *testing.T) {
+		for value, expected := range map[string]string{
+			"  Ana@Example.COM ":       "ana@example.com",
+			"ana.souza+loja@gmail.com": "ana.souza+loja@gmail.com",
+			"o'neil@mail.example.org":  "o'neil@mail.example.org",
+		} {
+			normalized, err := email.Normalize(value)
+
+			assert.NoError(t, err, value)
+			assert.Equal(t, expected, normalized, value)
+		}
+	})
+	t.Run("ShouldRejectInvalidAddresses", func(t *testing.T) {
+		for value, expected := range map[string]error{
+			"":                                       email.ErrInvalid,
+			"ana":                                    email.ErrInvalid,
+			"ana@":                                   email.ErrInvalid,
+			"@example.com":                           email.ErrInvalid,
+			"ana@@example.com":                       email.ErrInvalid,
+			"ana @example.com":                       email.ErrInvalid,
+			"ana..souza@example.com":                 email.ErrInvalid,
+			"ana@gmail":                              email.ErrInvalid,
+			"ana@[127.0.0.1]":                        email.ErrInvalid,
+			"Ana <ana@example.com>":                  email.ErrInvalid,
+			strings.Repeat("a", 65) + "@a.com":       email.ErrTooLong,
+			"a@" + strings.Repeat("a", 250) + ".com": email.ErrTooLong,
+		} {
+			_, err := email.Normalize(value)
+
+			assert.ErrorIs(t, err, expected, value)
+		}
+	})
+}
@@ -0,0 +1,272 @@
+// Amounts of money kept as integer cents. Prices, payments and totals go
+// through this type instead of float32, so adding them up never drifts and
+// the JSON always has at most two decimals. The columns are DECIMAL(12,2).
+
+package money
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ISO 4217 code
+type Currency string
+
+const BRL Currency = "BRL"
+
+// The store only sells in one currency, the database doesn't keep it
+const DefaultCurrency = BRL
+
+// How a value with more than two decimals is brought back to cents
+type RoundingMode int
+
+const (
+	HalfUp   RoundingMode = iota // 0.125 -> 0.13, -0.125 -> -0.13
+	HalfEven                     // 0.125 -> 0.12, 0.135 -> 0.14
+	Down                         // Towards zero
+	Up                           // Away from zero
+)
+
+var ErrInvalidAmount = errors.New("invalid amount")
+
+type Money struct {
+	cents    int64
+	currency Currency // Empty means DefaultCurrency, so the zero value is usable
+}
+
+var Zero = Money{}
+
+func New(cents int64, currency Currency) Money {
+	// Kept empty for the default, values can be compared with ==
+	if currency == DefaultCurrency {
+		currency = ""
+	}
+	return Money{cents: cents, currency: currency}
+}
+
+func FromCents(cents int64) Money {
+	return New(cents, DefaultCurrency)
+}
+
+// Only for values that already are floats, like the config. Amounts coming
+// from the client or the database go through Parse
+func FromFloat(value float64, mode RoundingMode) Money {
+	// Formatting first keeps 0.1 as "0.1" instead of 0.1000000000000000055
+	parsed, err := Parse(strconv.FormatFloat(value, 'f', -1, 64), mode)
+	if err != nil {
+		return FromCents(int64(math.Round(value * 100)))
+	}
+	return parsed
+}
+
+// Reads a decimal like "25.5" or "-3.14159". Digits past the cents are
+// rounded with mode
+func Parse(value string, mode RoundingMode) (Money, error) {
+	text := strings.TrimSpace(value)
+	negative := strings.HasPrefix(text, "-")
+	text = strings.TrimPrefix(strings.TrimPrefix(text, "-"), "+")
+
+	whole, fraction, _ := strings.Cut(text, ".")
+	if whole == "" && fraction == "" || !digitsOnly(whole) || !digitsOnly(fraction) {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, value)
+	}
+	if len(whole) > 16 {
+		return Money{}, fmt.Errorf("%w: %q is too large", ErrInvalidAmount, value)
+	}
+
+	fraction += "00"
+	cents, _ := strconv.ParseInt(whole+fraction[:2], 10, 64)
+	rest := strings.TrimRight(fraction[2:], "0")
+	if rest != "" && roundsAway(rest, cents, mode) {
+		cents++
+	}
+	if negative {
+		cents = -cents
+	}
+	return FromCents(cents), nil
+}
+
+func digitsOnly(value string) bool {
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Whether the dropped digits make the cents go one further from zero
+func roundsAway(rest string, cents int64, mode RoundingMode) bool {
+	switch mode {
+	case Down:
+		return false
+	case Up:
+		return true
+	case HalfEven:
+		if rest == "5" {
+			return cents%2 == 1
+		}
+	}
+	return rest[0] >= '5'
+}
+
+func (m Money) Cents() int64 {
+	return m.cents
+}
+
+func (m Money) Currency() Currency {
+	if m.currency == "" {
+		return DefaultCurrency
+	}
+	return m.currency
+}
+
+// Mixing currencies is a programming error, there is nothing to convert with
+func (m Money) sameCurrency(other Money) {
+	if m.Currency() != other.Currency() {
+		panic(fmt.Sprintf("money: can't mix %s and %s", m.Currency(), other.Currency()))
+	}
+}
+
+func (m Money) Add(other Money) Money {
+	m.sameCurrency(other)
+	return New(m.cents+other.cents, m.Currency())
+}
+
+func (m Money) Sub(other Money) Money {
+	m.sameCurrency(other)
+	return New(m.cents-other.cents, m.Currency())
+}
+
+func (m Money) Mul(quantity int64) Money {
+	return New(m.cents*quantity, m.Currency())
+}
+
+// Divides by n, rounding the leftover cents with mode
+func (m Money) Div(n int64, mode RoundingMode) Money {
+	quotient, remainder := m.cents/n, m.cents%n
+	if remainder != 0 {
+		away := false
+		twice := 2 * abs(remainder)
+		switch mode {
+		case Up:
+			away = true
+		case HalfUp:
+			away = twice >= abs(n)
+		case HalfEven:
+			away = twice > abs(n) || twice == abs(n) && quotient%2 != 0
+		}
+		if away {
+			if (m.cents < 0) != (n < 0) {
+				quotient--
+			} else {
+				quotient++
+			}
+		}
+	}
+	return New(quotient, m.Currency())
+}
+
+func abs(value int64) int64 {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
+
+// -1, 0 or 1
+func (m Money) Cmp(other Money) int {
+	m.sameCurrency(other)
+	switch {
+	case m.cents < other.cents:
+		return -1
+	case m.cents > other.cents:
+		return 1
+	}
+	return 0
+}
+
+func (m Money) GreaterThan(other Money) bool {
+	return m.Cmp(other) > 0
+}
+
+func (m Money) LessThan(other Money) bool {
+	return m.Cmp(other) < 0
+}
+
+func (m Money) IsZero() bool {
+	return m.cents == 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.cents < 0
+}
+
+func (m Money) IsPositive() bool {
+	return m.cents > 0
+}
+
+// Always two decimals, like "25.50"
+func (m Money) String() string {
+	sign := ""
+	cents := m.cents
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+// A JSON number, the currency is implied
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// Accepts numbers and numeric strings. Null leaves the amount as it was, like
+// encoding/json does for the builtin types. Exponents are refused instead of
+// going through a float, 1e2 has to be sent as 100
+func (m *Money) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	text := strings.Trim(string(data), `"`)
+	if strings.ContainsAny(text, "eE") {
+		return fmt.Errorf("%w: %q, exponents aren't accepted, send a plain decimal like 100.50", ErrInvalidAmount, text)
+	}
+	parsed, err := Parse(text, HalfUp)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Sent as a decimal string, so DECIMAL columns store it exactly
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+// The MySQL driver returns DECIMAL columns as []byte. NULL can only be read
+// into a *Money
+func (m *Money) Scan(src interface{}) error {
+	var err error
+	switch value := src.(type) {
+	case []byte:
+		*m, err = Parse(string(value), HalfUp)
+	case string:
+		*m, err = Parse(value, HalfUp)
+	case float64:
+		*m = FromFloat(value, HalfUp)
+	case float32:
+		*m = FromFloat(float64(value), HalfUp)
+	case int64:
+		*m = FromCents(value * 100)
+	default:
+		return fmt.Errorf("%w: can't scan %T", ErrInvalidAmount, src)
+	}
+	return err
+}
@@ -0,0 +1,168 @@
+package money_test
+
+import (
+	"encoding/json"
+	"errors"
+	"sipub-test/pkg/money"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	t.Run("ShouldReadDecimals", func(t *testing.T) {
+		for text, cents := range map[string]int64{
+			"25.5":   2550,
+			"25.50":  2550,
+			"25":     2500,
+			".5":     50,
+			"-3.10":  -310,
+			"0.01":   1,
+			"+12.34": 1234,
+		} {
+			parsed, err := money.Parse(text, money.HalfUp)
+
+			assert.NoError(t, err, text)
+			assert.Equal(t, cents, parsed.Cents(), text)
+		}
+	})
+	t.Run("ShouldRoundWithTheMode", func(t *testing.T) {
+		for _, tc := range []struct {
+			text  string
+			mode  money.RoundingMode
+			cents int64
+		}{
+			{"0.125", money.HalfUp, 13},
+			{"-0.125", money.HalfUp, -13},
+			{"0.124", money.HalfUp, 12},
+			{"0.125", money.HalfEven, 12},
+			{"0.135", money.HalfEven, 14},
+			{"0.1251", money.HalfEven, 13},
+			{"0.129", money.Down, 12},
+			{"-0.129", money.Down, -12},
+			{"0.121", money.Up, 13},
+			{"25.499999", money.HalfUp, 2550},
+		} {
+			parsed, err := money.Parse(tc.text, tc.mode)
+
+			assert.NoError(t, err, tc.text)
+			assert.Equal(t, tc.cents, parsed.Cents(), "%s with mode %d", tc.text, tc.mode)
+		}
+	})
+	t.Run("ShouldRejectNonNumbers", func(t *testing.T) {
+		for _, text := range []string{"", "-", "abc", "1.2.3", "1e5", "12,50"} {
+			_, err := money.Parse(text, money.HalfUp)
+
+			assert.True(t, errors.Is(err, money.ErrInvalidAmount), "%q should be rejected", text)
+		}
+	})
+}
+
+func TestArithmetic(t *testing.T) {
+	t.Run("ShouldNotDrift", func(t *testing.T) {
+		total := money.Zero
+		for i := 0; i < 10; i++ {
+			total = total.Add(money.FromFloat(0.1, money.HalfUp))
+		}
+
+		assert.Equal(t, money.FromCents(100), total, "Ten times 0.10 should be exactly 1.00")
+	})
+	t.Run("ShouldMultiplyAndSubtract", func(t *testing.T) {
+		price := money.FromCents(1999)
+
+		assert.Equal(t, "39.98", price.Mul(2).String())
+		assert.Equal(t, "-0.01", price.Sub(money.FromCents(2000)).String())
+	})
+	t.Run("ShouldDivideWithTheMode", func(t *testing.T) {
+		ten := money.FromCents(1000)
+
+		assert.Equal(t, int64(333), ten.Div(3, money.HalfUp).Cents())
+		assert.Equal(t, int64(334), ten.Div(3, money.Up).Cents())
+		assert.Equal(t, int64(2), money.FromCents(5).Div(2, money.HalfEven).Cents())
+		assert.Equal(t, int64(3), money.FromCents(5).Div(2, money.HalfUp).Cents())
+		assert.Equal(t, int64(-3), money.FromCents(-5).Div(2, money.HalfUp).Cents())
+	})
+	t.Run("ShouldCompare", func(t *testing.T) {
+		assert.True(t, money.FromCents(2).GreaterThan(money.FromCents(1)))
+		assert.True(t, money.FromCents(1).LessThan(money.FromCents(2)))
+		assert.Equal(t, money.Zero, money.FromCents(0), "The zero value is in the default currency")
+	})
+	t.Run("ShouldRefuseToMixCurrencies", func(t *testing.T) {
+		assert.Panics(t, func() {
+			money.FromCents(100).Add(money.New(100, "USD"))
+		})
+	})
+}
+
+func TestMarshaling(t *testing.T) {
+	t.Run("ShouldWriteTwoDecimals", func(t *testing.T) {
+		data, err := json.Marshal(map[string]money.Money{"Price": money.FromCents(2550)})
+
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"Price": 25.50}`, string(data))
+		assert.Contains(t, string(data), "25.50")
+	})
+	t.Run("ShouldReadNumbersAndStrings", func(t *testing.T) {
+		var body struct {
+			Price *money.Money
+			Fee   money.Money
+		}
+		err := json.Unmarshal([]byte(`{"Price": 25.499999, "Fee": "3.5"}`), &body)
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2550), body.Price.Cents())
+		assert.Equal(t, int64(350), body.Fee.Cents())
+	})
+	t.Run("ShouldRejectOtherValues", func(t *testing.T) {
+		var price money.Money
+
+		assert.Error(t, json.Unmarshal([]byte(`true`), &price))
+	})
+	t.Run("ShouldIgnoreNull", func(t *testing.T) {
+		for _, tc := range []struct {
+			body  string
+			cents int64
+		}{
+			{`{"Price": null}`, 2550},
+			{`{"Price": 3.5}`, 350},
+			{`{}`, 2550},
+		} {
+			body := struct{ Price money.Money }{Price: money.FromCents(2550)}
+			err := json.Unmarshal([]byte(tc.body), &body)
+
+			assert.NoError(t, err, tc.body)
+			assert.Equal(t, tc.cents, body.Price.Cents(), tc.body)
+		}
+
+		var body struct{ Price *money.Money }
+		assert.NoError(t, json.Unmarshal([]byte(`{"Price": null}`), &body))
+		assert.Nil(t, body.Price, "A pointer should stay nil")
+	})
+	t.Run("ShouldRejectExponents", func(t *testing.T) {
+		for _, body := range []string{`1e2`, `1E2`, `1.5e-3`, `"2e1"`, `-1e+2`} {
+			var price money.Money
+			err := json.Unmarshal([]byte(body), &price)
+
+			assert.True(t, errors.Is(err, money.ErrInvalidAmount), "%s should be rejected", body)
+			assert.ErrorContains(t, err, "exponents aren't accepted", body)
+			assert.True(t, price.IsZero(), "%s shouldn't change the amount", body)
+		}
+	})
+	t.Run("ShouldScanTheDriverValues", func(t *testing.T) {
+		for _, src := range []interface{}{[]byte("25.50"), "25.50", 25.5, float32(25.5)} {
+			var price money.Money
+
+			assert.NoError(t, price.Scan(src), "%T", src)
+			assert.Equal(t, int64(2550), price.Cents(), "%T", src)
+		}
+
+		var price money.Money
+		assert.Error(t, price.Scan(nil), "NULL needs a *Money")
+	})
+	t.Run("ShouldBeSentAsADecimal", func(t *testing.T) {
+		value, err := money.FromCents(-1205).Value()
+
+		assert.NoError(t, err)
+		assert.Equal(t, "-12.05", value)
+	})
+}
@@ -1,5 +1,7 @@
 package nilcheck
 
+import "sipub-test/pkg/money"
+
 func NotNilBool(newVal *bool, oldVal bool) bool {
 	if newVal != nil {
 		return *newVal
@@ -27,3 +29,10 @@ func NotNilString(newVal *string, oldVal string) string {
 	}
 	return oldVal
 }
+
+func NotNilMoney(newVal *money.Money, oldVal money.Money) money.Money {
+	if newVal != nil {
+		return *newVal
+	}
+	return oldVal
+}
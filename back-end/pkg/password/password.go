@@ -0,0 +1,62 @@
+// Passwords are only kept as bcrypt hashes, with the salt and the cost inside
+// of the hash itself. Raising the cost later doesn't break the old hashes.
+
+package password
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	minLength = 8
+	// bcrypt ignores everything after the 72nd byte, a longer password would
+	// be accepted with a different ending
+	maxBytes = 72
+)
+
+var (
+	ErrTooShort = errors.New("must have at least 8 characters")
+	ErrTooLong  = errors.New("can't be longer than 72 bytes")
+)
+
+// Compared when the email has no account, see MatchesNothing
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+	return hash
+})
+
+func Validate(plain string) error {
+	if utf8.RuneCountInString(plain) < minLength {
+		return ErrTooShort
+	}
+	if len(plain) > maxBytes {
+		return ErrTooLong
+	}
+	return nil
+}
+
+func Hash(plain string) (string, error) {
+	if err := Validate(plain); err != nil {
+		return "", err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(plain), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+func Matches(hash string, plain string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(plain)) == nil
+}
+
+// Takes as long as Matches, used when there is no hash to compare with so the
+// response time doesn't tell which emails have an account
+func MatchesNothing(plain string) {
+	bcrypt.CompareHashAndPassword(dummyHash(), []byte(plain))
+}
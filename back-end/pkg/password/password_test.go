@@ -0,0 +1,47 @@
+package password_test
+
+import (
+	"sipub-test/pkg/password"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	for value, expected := range map[string]error{
+		"":                      password.ErrTooShort,
+		"1234567":               password.ErrTooShort,
+		"çãé1234":               password.ErrTooShort,
+		strings.Repeat("a", 73): password.ErrTooLong,
+		strings.Repeat("ç", 37): password.ErrTooLong,
+		"12345678":              nil,
+		strings.Repeat("a", 72): nil,
+		"correct horse battery": nil,
+	} {
+		assert.ErrorIs(t, password.Validate(value), expected, value)
+	}
+}
+
+func TestHash(t *testing.T) {
+	t.Run("ShouldOnlyMatchTheSamePassword", func(t *testing.T) {
+		hash, err := password.Hash("correct horse")
+
+		assert.NoError(t, err)
+		assert.NotContains(t, hash, "correct horse")
+		assert.True(t, password.Matches(hash, "correct horse"))
+		assert.False(t, password.Matches(hash, "correct horse "))
+		assert.False(t, password.Matches(hash, ""))
+	})
+	t.Run("ShouldSaltEachHash", func(t *testing.T) {
+		first, _ := password.Hash("correct horse")
+		second, _ := password.Hash("correct horse")
+
+		assert.NotEqual(t, first, second)
+	})
+	t.Run("ShouldRefuseAnInvalidPassword", func(t *testing.T) {
+		_, err := password.Hash("short")
+
+		assert.ErrorIs(t, err, password.ErrTooShort)
+	})
+}
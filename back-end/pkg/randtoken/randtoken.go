@@ -0,0 +1,29 @@
+// Opaque tokens sent to the clients (email verification, sessions, password
+// resets). The database only keeps their hash, a leaked table can't be used
+// to sign in or verify an email.
+
+package randtoken
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+// 256 random bits, URL safe so it can be put in a link later
+func New() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+// The token is random enough that a plain SHA-256 is enough, unlike a
+// password. The same token always gives the same hash, so it can be looked up
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
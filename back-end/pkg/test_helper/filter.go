@@ -0,0 +1,11 @@
+package testhelper
+
+import "sipub-test/internal/filter"
+
+// Builds a filter with a single equality, the same a `?Field=value`
+// querystring would produce
+func FilterEqual(field string, column string, value interface{}) filter.Filter {
+	return filter.Filter{Conditions: []filter.Condition{
+		{Field: field, Column: column, Type: filter.String, Operator: filter.Eq, Values: []interface{}{value}},
+	}}
+}
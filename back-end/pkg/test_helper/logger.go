@@ -0,0 +1,11 @@
+package testhelper
+
+import (
+	"io"
+	"log/slog"
+)
+
+// Used by the controllers in tests, so the output isn't polluted by the logs
+func DiscardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
@@ -1,5 +1,7 @@
 package testhelper
 
+import "sipub-test/pkg/money"
+
 func UintPointer(u uint) *uint {
 	return &u
 }
@@ -15,3 +17,7 @@ func FloatPointer(f float32) *float32 {
 func StringPointer(s string) *string {
 	return &s
 }
+
+func MoneyPointer(m money.Money) *money.Money {
+	return &m
+}
@@ -0,0 +1,91 @@
+// Points in time as the database keeps them, DATETIME(6) columns in UTC. The
+// time zone of the server never reaches the rows, and the DTOs show the value
+// as RFC 3339 so the clients don't have to guess it either.
+
+package timestamp
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// The text form of DATETIME columns, in case the driver doesn't parse them.
+// The fraction is optional when parsing
+const columnLayout = "2006-01-02 15:04:05"
+
+var ErrInvalidTime = errors.New("invalid time")
+
+type Time struct {
+	time time.Time // Always UTC, with at most microseconds like the columns
+}
+
+func New(t time.Time) Time {
+	return Time{time: t.UTC().Truncate(time.Microsecond)}
+}
+
+// Reads a RFC 3339 timestamp or a column value, which is taken as UTC
+func Parse(value string) (Time, error) {
+	if parsed, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return New(parsed), nil
+	}
+	if parsed, err := time.Parse(columnLayout, value); err == nil {
+		return New(parsed), nil
+	}
+	return Time{}, fmt.Errorf("%w: %q", ErrInvalidTime, value)
+}
+
+func (t Time) Time() time.Time {
+	return t.time
+}
+
+func (t Time) Add(d time.Duration) Time {
+	return New(t.time.Add(d))
+}
+
+func (t Time) IsZero() bool {
+	return t.time.IsZero()
+}
+
+// RFC 3339, with the fraction only when there is one
+func (t Time) String() string {
+	return t.time.Format(time.RFC3339Nano)
+}
+
+// For the optional columns, like deletedAt
+func Format(t *Time) *string {
+	if t == nil {
+		return nil
+	}
+	formatted := t.String()
+	return &formatted
+}
+
+// Sent as a time.Time in UTC. db.InitializeDB sets the location of the
+// driver to UTC too, so it is written as is
+func (t Time) Value() (driver.Value, error) {
+	return t.time, nil
+}
+
+func (t *Time) Scan(src interface{}) error {
+	switch value := src.(type) {
+	case time.Time:
+		*t = New(value)
+		return nil
+	case []byte:
+		return t.scanText(string(value))
+	case string:
+		return t.scanText(value)
+	}
+	return fmt.Errorf("%w: can't scan %T", ErrInvalidTime, src)
+}
+
+func (t *Time) scanText(value string) error {
+	parsed, err := Parse(value)
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
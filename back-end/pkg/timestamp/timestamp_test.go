@@ -0,0 +1,70 @@
+package timestamp_test
+
+import (
+	"errors"
+	"sipub-test/pkg/timestamp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("ShouldKeepUTCMicroseconds", func(t *testing.T) {
+		saoPaulo := time.FixedZone("BRT", -3*60*60)
+		created := timestamp.New(time.Date(2025, 1, 15, 9, 0, 0, 123456789, saoPaulo))
+
+		assert.Equal(t, time.Date(2025, 1, 15, 12, 0, 0, 123456000, time.UTC), created.Time())
+		assert.Equal(t, "2025-01-15T12:00:00.123456Z", created.String())
+	})
+	t.Run("ShouldLeaveOutAnEmptyFraction", func(t *testing.T) {
+		created := timestamp.New(time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC))
+
+		assert.Equal(t, "2025-01-15T12:00:00Z", created.String())
+	})
+}
+
+func TestParse(t *testing.T) {
+	t.Run("ShouldReadBothLayouts", func(t *testing.T) {
+		expected := time.Date(2025, 1, 15, 12, 0, 0, 500000000, time.UTC)
+		for _, text := range []string{
+			"2025-01-15T12:00:00.5Z",
+			"2025-01-15T09:00:00.5-03:00",
+			"2025-01-15 12:00:00.500000",
+		} {
+			parsed, err := timestamp.Parse(text)
+
+			assert.NoError(t, err, text)
+			assert.Equal(t, expected, parsed.Time(), text)
+		}
+	})
+	t.Run("ShouldRejectOtherText", func(t *testing.T) {
+		_, err := timestamp.Parse("15/01/2025")
+
+		assert.True(t, errors.Is(err, timestamp.ErrInvalidTime))
+	})
+}
+
+func TestScan(t *testing.T) {
+	expected := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	for _, src := range []interface{}{
+		[]byte("2025-01-15 12:00:00.000000"),
+		"2025-01-15 12:00:00",
+		time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC),
+	} {
+		var scanned timestamp.Time
+
+		assert.NoError(t, scanned.Scan(src))
+		assert.Equal(t, expected, scanned.Time())
+	}
+
+	var scanned timestamp.Time
+	assert.Error(t, scanned.Scan(nil), "NULL columns are scanned into a *Time")
+}
+
+func TestFormat(t *testing.T) {
+	deletedAt := timestamp.New(time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC))
+
+	assert.Equal(t, "2025-01-15T12:00:00Z", *timestamp.Format(&deletedAt))
+	assert.Nil(t, timestamp.Format(nil))
+}
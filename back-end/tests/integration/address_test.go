@@ -8,6 +8,8 @@ import (
 	"net/http/httptest"
 	"regexp"
 	"sipub-test/internal/address"
+	"sipub-test/pkg/clock"
+	testhelper "sipub-test/pkg/test_helper"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
@@ -21,13 +23,11 @@ func TestControllerCreate(t *testing.T) {
 		defer db.Close()
 
 		// Arrange
-		controller := address.AddressController{}
-		repo := &address.MySQLAddressRepository{}
-		repo.SetDB(db)
-		controller.SetRepository(repo)
+		repo := address.NewMySQLAddressRepository(db, clock.System{})
+		controller := address.NewAddressController(repo, &address.AddressValidator{}, testhelper.DiscardLogger())
 
 		mock.ExpectExec(`INSERT INTO addresses`).
-			WithArgs(sqlmock.AnyArg(), true, false, sqlmock.AnyArg(), "Test Street", "123", "Test Neighborhood", sqlmock.AnyArg(), "Test City", "NY", "USA", float64(0), float64(0), "Test Address").
+			WithArgs(sqlmock.AnyArg(), true, false, sqlmock.AnyArg(), sqlmock.AnyArg(), "Test Street", "123", "Test Neighborhood", sqlmock.AnyArg(), "Test City", "NY", "USA", float64(0), float64(0), "Test Address").
 			WillReturnResult(sqlmock.NewResult(1, 1))
 
 		requestBody := `{
@@ -66,10 +66,8 @@ func TestControllerCreate(t *testing.T) {
 		defer db.Close()
 
 		// Arrange
-		controller := address.AddressController{}
-		repo := &address.MySQLAddressRepository{}
-		repo.SetDB(db)
-		controller.SetRepository(repo)
+		repo := address.NewMySQLAddressRepository(db, clock.System{})
+		controller := address.NewAddressController(repo, &address.AddressValidator{}, testhelper.DiscardLogger())
 
 		requestBody := `{
 			"Number": "123",
@@ -91,18 +89,18 @@ func TestControllerGetAll(t *testing.T) {
 		assert.NoError(t, err)
 		defer db.Close()
 
-		controller := address.AddressController{}
-		repo := &address.MySQLAddressRepository{}
-		repo.SetDB(db)
-		controller.SetRepository(repo)
+		repo := address.NewMySQLAddressRepository(db, clock.System{})
+		controller := address.NewAddressController(repo, &address.AddressValidator{}, testhelper.DiscardLogger())
 
 		rows := sqlmock.NewRows([]string{
-			"id", "isActive", "isDeleted", "createdAt", "street", "number", "neighborhood", "complement", "city", "state", "country", "latitude", "longitude", "name",
+			"id", "isActive", "isDeleted", "createdAt", "updatedAt", "street", "number", "neighborhood", "complement", "city", "state", "country", "latitude", "longitude", "name", "deletedAt",
 		}).
-			AddRow("1", true, false, "2023-01-01 12:00:00", "Test Street", "123", "Test Neighborhood", "", "Test City", "NY", "USA", 0, 0, "Test Address")
+			AddRow("1", true, false, "2023-01-01 12:00:00", "2023-01-01 12:00:00", "Test Street", "123", "Test Neighborhood", "", "Test City", "NY", "USA", 0, 0, "Test Address", nil)
 
-		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, street, number, neighborhood, complement, city, state, country, latitude, longitude, name FROM addresses`).
+		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, updatedAt, street, number, neighborhood, complement, city, state, country, latitude, longitude, name, deletedAt FROM addresses`).
 			WillReturnRows(rows)
+		mock.ExpectQuery(`SELECT COUNT\(\*\) FROM addresses`).
+			WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
 
 		r := httptest.NewRequest(http.MethodGet, "http://localhost:8080/addresses", nil)
 		w := httptest.NewRecorder()
@@ -123,16 +121,16 @@ func TestControllerGetAll(t *testing.T) {
 		assert.NoError(t, err)
 		defer db.Close()
 
-		controller := address.AddressController{}
-		repo := &address.MySQLAddressRepository{}
-		repo.SetDB(db)
-		controller.SetRepository(repo)
+		repo := address.NewMySQLAddressRepository(db, clock.System{})
+		controller := address.NewAddressController(repo, &address.AddressValidator{}, testhelper.DiscardLogger())
 
 		rows := sqlmock.NewRows([]string{
-			"id", "isActive", "isDeleted", "createdAt", "street", "number", "neighborhood", "complement", "city", "state", "country", "latitude", "longitude", "name",
+			"id", "isActive", "isDeleted", "createdAt", "updatedAt", "street", "number", "neighborhood", "complement", "city", "state", "country", "latitude", "longitude", "name", "deletedAt",
 		})
-		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, street, number, neighborhood, complement, city, state, country, latitude, longitude, name FROM addresses`).
+		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, updatedAt, street, number, neighborhood, complement, city, state, country, latitude, longitude, name, deletedAt FROM addresses`).
 			WillReturnRows(rows)
+		mock.ExpectQuery(`SELECT COUNT\(\*\) FROM addresses`).
+			WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(0))
 
 		r := httptest.NewRequest(http.MethodGet, "http://localhost:8080/addresses", nil)
 		w := httptest.NewRecorder()
@@ -147,15 +145,58 @@ func TestControllerGetAll(t *testing.T) {
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 
+	t.Run("ShouldPageAddressesWithoutName", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		repo := address.NewMySQLAddressRepository(db, clock.System{})
+		controller := address.NewAddressController(repo, &address.AddressValidator{}, testhelper.DiscardLogger())
+
+		columns := []string{
+			"id", "isActive", "isDeleted", "createdAt", "updatedAt", "street", "number", "neighborhood", "complement", "city", "state", "country", "latitude", "longitude", "name", "deletedAt",
+		}
+		// The column is nullable, a NULL anchor would end the list early
+		mock.ExpectQuery(regexp.QuoteMeta(`ORDER BY COALESCE(name, '') ASC, id ASC LIMIT ? OFFSET ?`)).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow("1", true, false, "2023-01-01 12:00:00", "2023-01-01 12:00:00", "Test Street", "123", "Test Neighborhood", "", "Test City", "NY", "USA", 0, 0, "", nil).
+				AddRow("2", true, false, "2023-01-01 12:00:00", "2023-01-01 12:00:00", "Test Street", "123", "Test Neighborhood", "", "Test City", "NY", "USA", 0, 0, "Test Address", nil))
+		mock.ExpectQuery(`SELECT COUNT\(\*\) FROM addresses`).
+			WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(2))
+
+		w := httptest.NewRecorder()
+		controller.GetAll(w, httptest.NewRequest(http.MethodGet, "http://localhost:8080/addresses?sort=Name&limit=1", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		next := regexp.MustCompile(`<([^>]+)>; rel="next"`).FindStringSubmatch(w.Header().Get("Link"))
+		if !assert.Len(t, next, 2, "There should be a next page") {
+			return
+		}
+
+		mock.ExpectQuery(regexp.QuoteMeta(`(COALESCE(name, '') > (SELECT COALESCE(name, '') FROM addresses WHERE id = ?)) OR (COALESCE(name, '') = (SELECT COALESCE(name, '') FROM addresses WHERE id = ?) AND id > ?)`)).
+			WithArgs(false, "1", "1", "1", 2, 0).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow("2", true, false, "2023-01-01 12:00:00", "2023-01-01 12:00:00", "Test Street", "123", "Test Neighborhood", "", "Test City", "NY", "USA", 0, 0, "Test Address", nil))
+		mock.ExpectQuery(`SELECT COUNT\(\*\) FROM addresses`).
+			WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(2))
+
+		w = httptest.NewRecorder()
+		controller.GetAll(w, httptest.NewRequest(http.MethodGet, "http://localhost:8080"+next[1], nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response []address.AddressDTO
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Len(t, response, 1)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
 	t.Run("ShouldReturnInvalidQueryParam", func(t *testing.T) {
 		db, _, err := sqlmock.New()
 		assert.NoError(t, err)
 		defer db.Close()
 
-		controller := address.AddressController{}
-		repo := &address.MySQLAddressRepository{}
-		repo.SetDB(db)
-		controller.SetRepository(repo)
+		repo := address.NewMySQLAddressRepository(db, clock.System{})
+		controller := address.NewAddressController(repo, &address.AddressValidator{}, testhelper.DiscardLogger())
 
 		r := httptest.NewRequest(http.MethodGet, "http://localhost:8080/addresses?invalid_param=10", nil)
 		w := httptest.NewRecorder()
@@ -174,14 +215,12 @@ func TestControllerDeleteOne(t *testing.T) {
 		}
 		defer db.Close()
 
-		controller := &address.AddressController{}
-		repo := &address.MySQLAddressRepository{}
-		repo.SetDB(db)
-		controller.SetRepository(repo)
+		repo := address.NewMySQLAddressRepository(db, clock.System{})
+		controller := address.NewAddressController(repo, &address.AddressValidator{}, testhelper.DiscardLogger())
 
 		id := "123e4567-e89b-12d3-a456-426614174000"
-		mock.ExpectExec(`DELETE FROM addresses WHERE id = ?`).
-			WithArgs(id).
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE addresses SET isDeleted = TRUE, deletedAt = ? WHERE id = ? AND isDeleted = FALSE`)).
+			WithArgs(sqlmock.AnyArg(), id).
 			WillReturnResult(sqlmock.NewResult(0, 1))
 
 		r := httptest.NewRequest(http.MethodDelete, "http://localhost:8080/addresses/"+id, nil)
@@ -200,14 +239,12 @@ func TestControllerDeleteOne(t *testing.T) {
 		}
 		defer db.Close()
 
-		controller := &address.AddressController{}
-		repo := &address.MySQLAddressRepository{}
-		repo.SetDB(db)
-		controller.SetRepository(repo)
+		repo := address.NewMySQLAddressRepository(db, clock.System{})
+		controller := address.NewAddressController(repo, &address.AddressValidator{}, testhelper.DiscardLogger())
 
 		id := "123e4567-e89b-12d3-a456-426614174000"
-		mock.ExpectExec(`DELETE FROM addresses WHERE id = ?`).
-			WithArgs(id).
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE addresses SET isDeleted = TRUE, deletedAt = ? WHERE id = ? AND isDeleted = FALSE`)).
+			WithArgs(sqlmock.AnyArg(), id).
 			WillReturnResult(sqlmock.NewResult(0, 0))
 
 		r := httptest.NewRequest(http.MethodDelete, "http://localhost:8080/addresses/"+id, nil)
@@ -226,20 +263,18 @@ func TestControllerGetOne(t *testing.T) {
 		assert.NoError(t, err)
 		defer db.Close()
 
-		controller := &address.AddressController{}
-		repo := &address.MySQLAddressRepository{}
-		repo.SetDB(db)
-		controller.SetRepository(repo)
+		repo := address.NewMySQLAddressRepository(db, clock.System{})
+		controller := address.NewAddressController(repo, &address.AddressValidator{}, testhelper.DiscardLogger())
 
 		id := "123e4567-e89b-12d3-a456-426614174000"
 
 		rows := sqlmock.NewRows([]string{
 			"id", "isActive", "isDeleted",
-			"createdAt", "street", "number", "neighborhood", "complement", "city", "state", "country", "latitude", "longitude", "name",
+			"createdAt", "updatedAt", "street", "number", "neighborhood", "complement", "city", "state", "country", "latitude", "longitude", "name",
 		}).
-			AddRow(id, true, false, "2023-01-01 00:00:00", "Main St", "123", "Downtown", "", "City", "NY", "USA", float64(0), float64(0), "Test Address")
+			AddRow(id, true, false, "2023-01-01 00:00:00", "2023-01-01 00:00:00", "Main St", "123", "Downtown", "", "City", "NY", "USA", float64(0), float64(0), "Test Address")
 
-		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, street, number, neighborhood, complement, city, state, country, latitude, longitude, name FROM addresses WHERE id = ?`).
+		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, updatedAt, street, number, neighborhood, complement, city, state, country, latitude, longitude, name FROM addresses WHERE id = ?`).
 			WithArgs(id).
 			WillReturnRows(rows)
 
@@ -262,13 +297,11 @@ func TestControllerGetOne(t *testing.T) {
 		assert.NoError(t, err)
 		defer db.Close()
 
-		controller := &address.AddressController{}
-		repo := &address.MySQLAddressRepository{}
-		repo.SetDB(db)
-		controller.SetRepository(repo)
+		repo := address.NewMySQLAddressRepository(db, clock.System{})
+		controller := address.NewAddressController(repo, &address.AddressValidator{}, testhelper.DiscardLogger())
 
 		id := "123e4567-e89b-12d3-a456-426614174000"
-		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, street, number, neighborhood, complement, city, state, country, latitude, longitude, name FROM addresses WHERE id = ?`).
+		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, updatedAt, street, number, neighborhood, complement, city, state, country, latitude, longitude, name FROM addresses WHERE id = ?`).
 			WithArgs(id).
 			WillReturnError(sql.ErrNoRows)
 
@@ -288,13 +321,11 @@ func TestControllerDeleteAll(t *testing.T) {
 		assert.NoError(t, err)
 		defer db.Close()
 
-		repo := &address.MySQLAddressRepository{}
-		repo.SetDB(db)
-		controller := &address.AddressController{}
-		controller.SetRepository(repo)
+		repo := address.NewMySQLAddressRepository(db, clock.System{})
+		controller := address.NewAddressController(repo, &address.AddressValidator{}, testhelper.DiscardLogger())
 
-		mock.ExpectExec(`DELETE FROM addresses WHERE 1=1 AND street LIKE ?`).
-			WithArgs("%Main%").
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE addresses SET isDeleted = TRUE, deletedAt = ? WHERE 1=1 AND street LIKE ? AND isDeleted = ?`)).
+			WithArgs(sqlmock.AnyArg(), "%Main%", false).
 			WillReturnResult(sqlmock.NewResult(0, 3)) // Simulate 3 rows deleted
 
 		r := httptest.NewRequest(http.MethodDelete, "http://localhost:8080/addresses?Street=Main", nil)
@@ -317,38 +348,36 @@ func TestControllerUpdate(t *testing.T) {
 		assert.NoError(t, err)
 		defer db.Close()
 
-		controller := &address.AddressController{}
-		repo := &address.MySQLAddressRepository{}
-		repo.SetDB(db)
-		controller.SetRepository(repo)
+		repo := address.NewMySQLAddressRepository(db, clock.System{})
+		controller := address.NewAddressController(repo, &address.AddressValidator{}, testhelper.DiscardLogger())
 
 		id := "123e4567-e89b-12d3-a456-426614174000"
 
 		// Mock previous address fetch
 		rowsBeforeUpdate := sqlmock.NewRows([]string{
-			"id", "isActive", "isDeleted", "createdAt", "street", "number", "neighborhood", "complement", "city", "state", "country", "latitude", "longitude",
+			"id", "isActive", "isDeleted", "createdAt", "updatedAt", "street", "number", "neighborhood", "complement", "city", "state", "country", "latitude", "longitude",
 			"name",
 		}).
-			AddRow(id, true, false, "2023-01-01 00:00:00", "Main St", "123", "Downtown", "", "City", "NY", "USA", float64(0), float64(0), "Old Address")
+			AddRow(id, true, false, "2023-01-01 00:00:00", "2023-01-01 00:00:00", "Main St", "123", "Downtown", "", "City", "NY", "USA", float64(0), float64(0), "Old Address")
 
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, isActive, isDeleted, createdAt, street, number, neighborhood, complement, city, state, country, latitude, longitude, name FROM addresses WHERE id = ?`)).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, isActive, isDeleted, createdAt, updatedAt, street, number, neighborhood, complement, city, state, country, latitude, longitude, name FROM addresses WHERE id = ?`)).
 			WithArgs(id).
 			WillReturnRows(rowsBeforeUpdate)
 
 			// Mock update query
-		mock.ExpectExec(regexp.QuoteMeta(`UPDATE addresses SET isActive = ?, isDeleted = ?, street = ?, number = ?, neighborhood = ?, complement = ?, city = ?, state = ?, country = ?, latitude = ?, longitude = ?, name = ? WHERE id = ?`)).
-			WithArgs(true, false, "New St", "456", "Downtown", "", "City", "NY", "USA", float64(0), float64(0), "Updated Address", id).
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE addresses SET isActive = ?, street = ?, number = ?, neighborhood = ?, complement = ?, city = ?, state = ?, country = ?, latitude = ?, longitude = ?, name = ?, updatedAt = ? WHERE id = ? AND isDeleted = FALSE`)).
+			WithArgs(true, "New St", "456", "Downtown", "", "City", "NY", "USA", float64(0), float64(0), "Updated Address", sqlmock.AnyArg(), id).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 
 			// Mock updated address fetch
 		rowsAfterUpdate := sqlmock.NewRows([]string{
-			"id", "isActive", "isDeleted", "createdAt", "street", "number", "neighborhood", "complement", "city", "state", "country", "latitude", "longitude", "name",
+			"id", "isActive", "isDeleted", "createdAt", "updatedAt", "street", "number", "neighborhood", "complement", "city", "state", "country", "latitude", "longitude", "name",
 		}).
 			AddRow(id, true, false,
-				"2023-01-01 00:00:00", "New St", "456", "Downtown", "", "City", "NY", "USA", float64(0), float64(0), "Updated Address")
+				"2023-01-01 00:00:00", "2023-01-01 00:00:00", "New St", "456", "Downtown", "", "City", "NY", "USA", float64(0), float64(0), "Updated Address")
 
 		mock.ExpectQuery(regexp.QuoteMeta(`
-        SELECT id, isActive, isDeleted, createdAt, street, number, neighborhood, complement, city, state, country, latitude, longitude, name FROM addresses WHERE id = ?
+        SELECT id, isActive, isDeleted, createdAt, updatedAt, street, number, neighborhood, complement, city, state, country, latitude, longitude, name FROM addresses WHERE id = ?
         `)).
 			WithArgs(id).
 			WillReturnRows(rowsAfterUpdate)
@@ -0,0 +1,303 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sipub-test/internal/auth"
+	"sipub-test/internal/mailer"
+	"sipub-test/internal/user"
+	"sipub-test/pkg/clock"
+	"sipub-test/pkg/password"
+	"sipub-test/pkg/randtoken"
+	testhelper "sipub-test/pkg/test_helper"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var loggedInAt = time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+
+type mailSpy struct {
+	sent []mailer.Message
+}
+
+func (m *mailSpy) Send(ctx context.Context, message mailer.Message) error {
+	m.sent = append(m.sent, message)
+	return nil
+}
+
+func newAuthController(db *sql.DB, mail mailer.IMailer) *auth.AuthController {
+	clk := clock.Fixed(loggedInAt)
+	repository := auth.NewMySQLAuthRepository(db, clk)
+	return auth.NewAuthController(repository, auth.NewTokenIssuer(clk, 15*time.Minute, time.Hour),
+		auth.NewPasswordResetSender(repository, mail, clk, time.Hour),
+		&auth.LoginValidator{}, &auth.RefreshValidator{}, &auth.PasswordResetValidator{}, &auth.PasswordResetConfirmValidator{}, testhelper.DiscardLogger())
+}
+
+func expectCredentials(t *testing.T, mock sqlmock.Sqlmock) {
+	hash, err := password.Hash("correct horse")
+	assert.NoError(t, err)
+	mock.ExpectQuery(`SELECT id, passwordHash FROM users`).
+		WithArgs("ana@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "passwordHash"}).AddRow("user-123", hash))
+}
+
+func TestAuthControllerLogin(t *testing.T) {
+	t.Run("ShouldReturnTheTokens", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		controller := newAuthController(db, &mailSpy{})
+		expectCredentials(t, mock)
+		mock.ExpectExec(`INSERT INTO sessions`).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		r := httptest.NewRequest(http.MethodPost, "http://localhost:8080/auth/login", bytes.NewReader([]byte(`{"Email": " Ana@Example.com", "Password": "correct horse"}`)))
+		w := httptest.NewRecorder()
+
+		controller.Login(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response auth.TokensDTO
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "Bearer", response.TokenType)
+		assert.NotEmpty(t, response.AccessToken)
+		assert.NotEqual(t, response.AccessToken, response.RefreshToken)
+		assert.Equal(t, "2025-01-15T12:15:00Z", response.AccessExpiresAt)
+		assert.Equal(t, "2025-01-15T13:00:00Z", response.RefreshExpiresAt)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("ShouldRefuseAWrongPassword", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		controller := newAuthController(db, &mailSpy{})
+		expectCredentials(t, mock)
+
+		r := httptest.NewRequest(http.MethodPost, "http://localhost:8080/auth/login", bytes.NewReader([]byte(`{"Email": "ana@example.com", "Password": "wrong horse"}`)))
+		w := httptest.NewRecorder()
+
+		controller.Login(w, r)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid email or password")
+		assert.NoError(t, mock.ExpectationsWereMet(), "No session should be created")
+	})
+	t.Run("ShouldAnswerTheSameForAnUnknownEmail", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		controller := newAuthController(db, &mailSpy{})
+		mock.ExpectQuery(`SELECT id, passwordHash FROM users`).
+			WithArgs("nobody@example.com").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "passwordHash"}))
+
+		r := httptest.NewRequest(http.MethodPost, "http://localhost:8080/auth/login", bytes.NewReader([]byte(`{"Email": "nobody@example.com", "Password": "correct horse"}`)))
+		w := httptest.NewRecorder()
+
+		controller.Login(w, r)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid email or password")
+	})
+	t.Run("ShouldRequireThePassword", func(t *testing.T) {
+		db, _, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		controller := newAuthController(db, &mailSpy{})
+
+		r := httptest.NewRequest(http.MethodPost, "http://localhost:8080/auth/login", bytes.NewReader([]byte(`{"Email": "ana@example.com"}`)))
+		w := httptest.NewRecorder()
+
+		controller.Login(w, r)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "Password")
+	})
+}
+
+func TestAuthControllerRefresh(t *testing.T) {
+	t.Run("ShouldReturnNewTokens", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		controller := newAuthController(db, &mailSpy{})
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT s.id, s.user_id, s.refreshExpiresAt FROM sessions`).
+			WithArgs(randtoken.Hash("refresh-123")).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "refreshExpiresAt"}).AddRow("session-123", "user-123", "2025-01-15 13:00:00"))
+		mock.ExpectExec(`UPDATE sessions SET accessTokenHash`).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		r := httptest.NewRequest(http.MethodPost, "http://localhost:8080/auth/refresh", bytes.NewReader([]byte(`{"RefreshToken": "refresh-123"}`)))
+		w := httptest.NewRecorder()
+
+		controller.Refresh(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response auth.TokensDTO
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.NotEqual(t, "refresh-123", response.RefreshToken, "The refresh token should be rotated")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestAuthControllerLogout(t *testing.T) {
+	t.Run("ShouldRevokeTheSession", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		controller := newAuthController(db, &mailSpy{})
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE sessions SET revokedAt = ? WHERE id = ? AND revokedAt IS NULL`)).
+			WithArgs(sqlmock.AnyArg(), "session-123").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		r := httptest.NewRequest(http.MethodPost, "http://localhost:8080/auth/logout", nil)
+		r = r.WithContext(auth.WithSession(r.Context(), auth.Session{ID: "session-123", UserID: "user-123"}))
+		w := httptest.NewRecorder()
+
+		controller.Logout(w, r)
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestAuthControllerPasswordReset(t *testing.T) {
+	t.Run("ShouldMailTheToken", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mail := &mailSpy{}
+		controller := newAuthController(db, mail)
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT id FROM users`).
+			WithArgs("ana@example.com").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("user-123"))
+		mock.ExpectExec(`DELETE FROM password_reset_tokens`).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`INSERT INTO password_reset_tokens`).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		r := httptest.NewRequest(http.MethodPost, "http://localhost:8080/auth/password-reset", bytes.NewReader([]byte(`{"Email": "Ana@Example.com"}`)))
+		w := httptest.NewRecorder()
+
+		controller.RequestPasswordReset(w, r)
+
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		if assert.Len(t, mail.sent, 1) {
+			assert.Equal(t, "ana@example.com", mail.sent[0].To)
+			assert.Contains(t, mail.sent[0].Body, "/auth/password-reset/confirm")
+		}
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("ShouldAnswerTheSameForAnUnknownEmail", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mail := &mailSpy{}
+		controller := newAuthController(db, mail)
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT id FROM users`).
+			WithArgs("nobody@example.com").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectRollback()
+
+		r := httptest.NewRequest(http.MethodPost, "http://localhost:8080/auth/password-reset", bytes.NewReader([]byte(`{"Email": "nobody@example.com"}`)))
+		w := httptest.NewRecorder()
+
+		controller.RequestPasswordReset(w, r)
+
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		assert.Empty(t, mail.sent)
+	})
+	t.Run("ShouldRejectAShortPassword", func(t *testing.T) {
+		db, _, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		controller := newAuthController(db, &mailSpy{})
+
+		r := httptest.NewRequest(http.MethodPost, "http://localhost:8080/auth/password-reset/confirm", bytes.NewReader([]byte(`{"Token": "token-123", "Password": "short"}`)))
+		w := httptest.NewRecorder()
+
+		controller.ConfirmPasswordReset(w, r)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "Password")
+	})
+}
+
+// The user routes go through the guard, a user can only change itself
+func TestUserRoutesNeedASession(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	clk := clock.Fixed(loggedInAt)
+	guard := auth.NewGuard(auth.NewMySQLAuthRepository(db, clk), testhelper.DiscardLogger())
+	controller := user.NewUserController(user.NewMySQLUserRepository(db, clk), &user.UserValidator{}, &verificationSpy{}, testhelper.DiscardLogger())
+	mux := http.NewServeMux()
+	user.NewUserRouter(controller, guard).Init(mux)
+
+	t.Run("ShouldRefuseAnAnonymousUpdate", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/u/user-456", bytes.NewReader([]byte(`{"Name": "Hacked"}`))))
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Header().Get("WWW-Authenticate"), "Bearer")
+	})
+	// user-123 is logged in with access-123 and isn't an admin
+	expectSession := func() {
+		mock.ExpectQuery(`SELECT s.id, s.user_id, u.isAdmin FROM sessions`).
+			WithArgs(randtoken.Hash("access-123"), sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "isAdmin"}).AddRow("session-123", "user-123", false))
+	}
+	for name, c := range map[string]struct {
+		method string
+		target string
+	}{
+		"ShouldRefuseToDeleteAnotherUser":  {http.MethodDelete, "/u/user-456"},
+		"ShouldRefuseToDeleteEveryUser":    {http.MethodDelete, "/u"},
+		"ShouldRefuseToDeleteUsersInBulk":  {http.MethodDelete, "/u?Name=Test"},
+		"ShouldRefuseToRestoreAnotherUser": {http.MethodPost, "/u/user-456/restore"},
+		"ShouldRefuseToRestoreItself":      {http.MethodPost, "/u/user-123/restore"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			expectSession()
+			r := httptest.NewRequest(c.method, c.target, nil)
+			r.Header.Set("Authorization", "Bearer access-123")
+			w := httptest.NewRecorder()
+
+			mux.ServeHTTP(w, r)
+
+			assert.Equal(t, http.StatusForbidden, w.Code)
+			assert.NoError(t, mock.ExpectationsWereMet(), "Nothing should be deleted or restored")
+		})
+	}
+	t.Run("ShouldLetAnyoneSignUp", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/u", bytes.NewReader([]byte(`{}`))))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, "The body should reach the validator")
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"sipub-test/internal/delivery_product"
+	testhelper "sipub-test/pkg/test_helper"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
@@ -19,13 +20,11 @@ func TestDeliveryProductController_Create(t *testing.T) {
 		assert.NoError(t, err)
 		defer db.Close()
 
-		controller := &delivery_product.DeliveryProductController{}
-		repo := &delivery_product.MySQLDeliveryRepository{}
-		repo.SetDB(db)
-		controller.SetRepository(repo)
+		repo := delivery_product.NewMySQLDeliveryProductRepository(db)
+		controller := delivery_product.NewDeliveryProductController(repo, &delivery_product.DeliveryProductValidator{}, testhelper.DiscardLogger())
 
 		mock.ExpectExec(`INSERT INTO delivery_product`).
-			WithArgs(sqlmock.AnyArg(), "delivery-id", "product-id", 10).
+			WithArgs(sqlmock.AnyArg(), "delivery-id", "product-id", 10, nil).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 
 		requestBody := `{
@@ -53,10 +52,8 @@ func TestDeliveryProductController_Create(t *testing.T) {
 		assert.NoError(t, err)
 		defer db.Close()
 
-		controller := &delivery_product.DeliveryProductController{}
-		repo := &delivery_product.MySQLDeliveryRepository{}
-		repo.SetDB(db)
-		controller.SetRepository(repo)
+		repo := delivery_product.NewMySQLDeliveryProductRepository(db)
+		controller := delivery_product.NewDeliveryProductController(repo, &delivery_product.DeliveryProductValidator{}, testhelper.DiscardLogger())
 
 		requestBody := `{"DeliveryID": "delivery-id"}` // Missing required fields
 		r := httptest.NewRequest(http.MethodPost, "/delivery-product", bytes.NewReader([]byte(requestBody)))
@@ -74,16 +71,16 @@ func TestDeliveryProductController_GetAll(t *testing.T) {
 		assert.NoError(t, err)
 		defer db.Close()
 
-		controller := &delivery_product.DeliveryProductController{}
-		repo := &delivery_product.MySQLDeliveryRepository{}
-		repo.SetDB(db)
-		controller.SetRepository(repo)
+		repo := delivery_product.NewMySQLDeliveryProductRepository(db)
+		controller := delivery_product.NewDeliveryProductController(repo, &delivery_product.DeliveryProductValidator{}, testhelper.DiscardLogger())
 
-		rows := sqlmock.NewRows([]string{"id", "delivery_id", "product_id", "product_amount"}).
-			AddRow("id-1", "delivery-id", "product-id", 10)
+		rows := sqlmock.NewRows([]string{"id", "delivery_id", "product_id", "product_amount", "unit_price"}).
+			AddRow("id-1", "delivery-id", "product-id", 10, nil)
 
-		mock.ExpectQuery(`SELECT id, delivery_id, product_id, product_amount FROM delivery_product WHERE 1=1`).
+		mock.ExpectQuery(`SELECT id, delivery_id, product_id, product_amount, unit_price FROM delivery_product WHERE 1=1`).
 			WillReturnRows(rows)
+		mock.ExpectQuery(`SELECT COUNT\(\*\) FROM delivery_product`).
+			WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
 
 		r := httptest.NewRequest(http.MethodGet, "/delivery-product", nil)
 		w := httptest.NewRecorder()
@@ -106,10 +103,8 @@ func TestDeliveryProductController_GetAll(t *testing.T) {
 		assert.NoError(t, err)
 		defer db.Close()
 
-		controller := &delivery_product.DeliveryProductController{}
-		repo := &delivery_product.MySQLDeliveryRepository{}
-		repo.SetDB(db)
-		controller.SetRepository(repo)
+		repo := delivery_product.NewMySQLDeliveryProductRepository(db)
+		controller := delivery_product.NewDeliveryProductController(repo, &delivery_product.DeliveryProductValidator{}, testhelper.DiscardLogger())
 
 		r := httptest.NewRequest(http.MethodGet, "/delivery-product?invalid_param=10", nil)
 		w := httptest.NewRecorder()
@@ -126,16 +121,14 @@ func TestDeliveryProductController_GetOne(t *testing.T) {
 		assert.NoError(t, err)
 		defer db.Close()
 
-		controller := &delivery_product.DeliveryProductController{}
-		repo := &delivery_product.MySQLDeliveryRepository{}
-		repo.SetDB(db)
-		controller.SetRepository(repo)
+		repo := delivery_product.NewMySQLDeliveryProductRepository(db)
+		controller := delivery_product.NewDeliveryProductController(repo, &delivery_product.DeliveryProductValidator{}, testhelper.DiscardLogger())
 
 		id := "123e4567-e89b-12d3-a456-426614174000"
-		row := sqlmock.NewRows([]string{"id", "delivery_id", "product_id", "product_amount"}).
-			AddRow(id, "delivery-id", "product-id", 10)
+		row := sqlmock.NewRows([]string{"id", "delivery_id", "product_id", "product_amount", "unit_price"}).
+			AddRow(id, "delivery-id", "product-id", 10, nil)
 
-		mock.ExpectQuery(`SELECT id, delivery_id, product_id, product_amount FROM delivery_product WHERE id = ?`).
+		mock.ExpectQuery(`SELECT id, delivery_id, product_id, product_amount, unit_price FROM delivery_product WHERE id = ?`).
 			WithArgs(id).
 			WillReturnRows(row)
 
@@ -157,13 +150,11 @@ func TestDeliveryProductController_GetOne(t *testing.T) {
 		assert.NoError(t, err)
 		defer db.Close()
 
-		controller := &delivery_product.DeliveryProductController{}
-		repo := &delivery_product.MySQLDeliveryRepository{}
-		repo.SetDB(db)
-		controller.SetRepository(repo)
+		repo := delivery_product.NewMySQLDeliveryProductRepository(db)
+		controller := delivery_product.NewDeliveryProductController(repo, &delivery_product.DeliveryProductValidator{}, testhelper.DiscardLogger())
 
 		id := "non-existent-id"
-		mock.ExpectQuery(`SELECT id, delivery_id, product_id, product_amount FROM delivery_product WHERE id = ?`).
+		mock.ExpectQuery(`SELECT id, delivery_id, product_id, product_amount, unit_price FROM delivery_product WHERE id = ?`).
 			WithArgs(id).
 			WillReturnError(sql.ErrNoRows)
 
@@ -183,10 +174,8 @@ func TestDeliveryProductController_DeleteOne(t *testing.T) {
 		assert.NoError(t, err)
 		defer db.Close()
 
-		controller := &delivery_product.DeliveryProductController{}
-		repo := &delivery_product.MySQLDeliveryRepository{}
-		repo.SetDB(db)
-		controller.SetRepository(repo)
+		repo := delivery_product.NewMySQLDeliveryProductRepository(db)
+		controller := delivery_product.NewDeliveryProductController(repo, &delivery_product.DeliveryProductValidator{}, testhelper.DiscardLogger())
 
 		id := "123e4567-e89b-12d3-a456-426614174000"
 
@@ -209,10 +198,8 @@ func TestDeliveryProductController_DeleteOne(t *testing.T) {
 		assert.NoError(t, err)
 		defer db.Close()
 
-		controller := &delivery_product.DeliveryProductController{}
-		repo := &delivery_product.MySQLDeliveryRepository{}
-		repo.SetDB(db)
-		controller.SetRepository(repo)
+		repo := delivery_product.NewMySQLDeliveryProductRepository(db)
+		controller := delivery_product.NewDeliveryProductController(repo, &delivery_product.DeliveryProductValidator{}, testhelper.DiscardLogger())
 
 		id := "non-existent-id"
 
@@ -237,10 +224,8 @@ func TestDeliveryProductController_DeleteAll(t *testing.T) {
 		assert.NoError(t, err)
 		defer db.Close()
 
-		controller := &delivery_product.DeliveryProductController{}
-		repo := &delivery_product.MySQLDeliveryRepository{}
-		repo.SetDB(db)
-		controller.SetRepository(repo)
+		repo := delivery_product.NewMySQLDeliveryProductRepository(db)
+		controller := delivery_product.NewDeliveryProductController(repo, &delivery_product.DeliveryProductValidator{}, testhelper.DiscardLogger())
 
 		mock.ExpectExec(`DELETE FROM delivery_product WHERE 1=1`).
 			WillReturnResult(sqlmock.NewResult(0, 10)) // Assume 10 rows deleted
@@ -259,10 +244,8 @@ func TestDeliveryProductController_DeleteAll(t *testing.T) {
 		assert.NoError(t, err)
 		defer db.Close()
 
-		controller := &delivery_product.DeliveryProductController{}
-		repo := &delivery_product.MySQLDeliveryRepository{}
-		repo.SetDB(db)
-		controller.SetRepository(repo)
+		repo := delivery_product.NewMySQLDeliveryProductRepository(db)
+		controller := delivery_product.NewDeliveryProductController(repo, &delivery_product.DeliveryProductValidator{}, testhelper.DiscardLogger())
 
 		mock.ExpectExec(`DELETE FROM delivery_product WHERE 1=1`).
 			WillReturnResult(sqlmock.NewResult(0, 0)) // No rows to delete
@@ -0,0 +1,86 @@
+package integration
+
+import (
+	"bytes"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"sipub-test/internal/delivery_product"
+	"sipub-test/internal/inventory"
+	"sipub-test/internal/unit_of_work"
+	"sipub-test/pkg/clock"
+	testhelper "sipub-test/pkg/test_helper"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// The price of a line is what the balance of the delivery charges, only the
+// checkout can set it
+func TestDeliveryProductIgnoresTheClientPrice(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	controller := newDeliveryProductController(db)
+
+	mock.ExpectBegin()
+	expectDeliveryProductStock(mock, 10)
+	mock.ExpectExec(`INSERT INTO delivery_product`).
+		WithArgs(sqlmock.AnyArg(), "delivery-123", "product-123", 10, nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`UPDATE products SET reserved = reserved \+ \?`).
+		WithArgs(10, "product-123").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO stock_movements`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	body := `{"DeliveryID": "delivery-123", "ProductID": "product-123", "ProductAmount": 10, "UnitPrice": 0.01}`
+	w := httptest.NewRecorder()
+	controller.Create(w, httptest.NewRequest(http.MethodPost, "/delivery_product", bytes.NewReader([]byte(body))))
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.NotContains(t, w.Body.String(), "UnitPrice", "The line should go by the product price")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Lines added by hand take their stock, like the checkout
+func TestDeliveryProductRejectsMissingStock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	controller := newDeliveryProductController(db)
+
+	mock.ExpectBegin()
+	expectDeliveryProductStock(mock, 9)
+	mock.ExpectRollback()
+
+	body := `{"DeliveryID": "delivery-123", "ProductID": "product-123", "ProductAmount": 10}`
+	w := httptest.NewRecorder()
+	controller.Create(w, httptest.NewRequest(http.MethodPost, "/delivery_product", bytes.NewReader([]byte(body))))
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet(), "Nothing should be inserted")
+}
+
+// Wired like main.go
+func newDeliveryProductController(db *sql.DB) *delivery_product.DeliveryProductController {
+	repo := inventory.NewMySQLDeliveryProductRepository(delivery_product.NewMySQLDeliveryProductRepository(db), unit_of_work.NewUnitOfWork(db, clock.System{}), clock.System{})
+	return delivery_product.NewDeliveryProductController(repo, &delivery_product.DeliveryProductValidator{}, testhelper.DiscardLogger())
+}
+
+// An open delivery with no movements and the given stock left
+func expectDeliveryProductStock(mock sqlmock.Sqlmock, available int64) {
+	mock.ExpectQuery(`SELECT 1 FROM deliveries`).
+		WithArgs("delivery-123").
+		WillReturnRows(sqlmock.NewRows([]string{"1"}).AddRow(1))
+	mock.ExpectQuery(`FROM stock_movements`).
+		WithArgs("delivery-123").
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "quantity", "reason"}))
+	mock.ExpectQuery(`FROM\s+products p`).
+		WithArgs(sqlmock.AnyArg(), "product-123").
+		WillReturnRows(sqlmock.NewRows([]string{"available"}).AddRow(available))
+}
@@ -0,0 +1,69 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sipub-test/internal/auth"
+	"sipub-test/internal/delivery_status"
+	"sipub-test/internal/unit_of_work"
+	"sipub-test/pkg/clock"
+	"sipub-test/pkg/randtoken"
+	testhelper "sipub-test/pkg/test_helper"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// The history records who changed the status, it can't be picked by the client
+func TestDeliveryTransitionTakesTheActorFromTheSession(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	clk := clock.Fixed(loggedInAt)
+	guard := auth.NewGuard(auth.NewMySQLAuthRepository(db, clk), testhelper.DiscardLogger())
+	controller := delivery_status.NewDeliveryStatusController(delivery_status.NewMySQLDeliveryStatusRepository(db, unit_of_work.NewUnitOfWork(db, clk), clk), &delivery_status.TransitionValidator{}, testhelper.DiscardLogger())
+	mux := http.NewServeMux()
+	delivery_status.NewDeliveryStatusRouter(controller, guard).Init(mux)
+
+	body := `{"Status": "paid", "Actor": "admin"}`
+
+	t.Run("ShouldRefuseAnAnonymousChange", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/deliveries/delivery-123/transitions", bytes.NewReader([]byte(body))))
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.NoError(t, mock.ExpectationsWereMet(), "Nothing should be changed")
+	})
+	t.Run("ShouldRecordTheLoggedInUser", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT s.id, s.user_id, u.isAdmin FROM sessions`).
+			WithArgs(randtoken.Hash("access-123"), sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "isAdmin"}).AddRow("session-123", "user-123", false))
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT status FROM deliveries`).
+			WithArgs("delivery-123").
+			WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("pending"))
+		mock.ExpectExec(`UPDATE deliveries SET status`).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`INSERT INTO delivery_status_history`).
+			WithArgs(sqlmock.AnyArg(), "delivery-123", "pending", "paid", "user-123", sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		r := httptest.NewRequest(http.MethodPost, "/deliveries/delivery-123/transitions", bytes.NewReader([]byte(body)))
+		r.Header.Set("Authorization", "Bearer access-123")
+		w := httptest.NewRecorder()
+
+		mux.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var response delivery_status.StatusChangeDTO
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "user-123", *response.Actor, "The Actor sent in the body should be ignored")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,129 @@
+package integration
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sipub-test/internal/email_verification"
+	"sipub-test/internal/mailer"
+	"sipub-test/internal/user"
+	"sipub-test/pkg/clock"
+	testhelper "sipub-test/pkg/test_helper"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var verifiedAt = time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+
+func newEmailVerificationController(db *sql.DB) *email_verification.EmailVerificationController {
+	repository := email_verification.NewMySQLEmailVerificationRepository(db, clock.Fixed(verifiedAt))
+	sender := email_verification.NewSender(repository, mailer.NewLogMailer("no-reply@sipub.local", testhelper.DiscardLogger()), clock.Fixed(verifiedAt), time.Hour)
+	return email_verification.NewEmailVerificationController(repository, sender, user.NewMySQLUserRepository(db, clock.Fixed(verifiedAt)), &email_verification.VerifyValidator{}, testhelper.DiscardLogger())
+}
+
+func TestEmailVerificationControllerVerify(t *testing.T) {
+	t.Run("ShouldReturnTheVerifiedUser", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		controller := newEmailVerificationController(db)
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT t.email, t.expiresAt, u.email FROM email_verification_tokens`).
+			WithArgs("user-123", sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"email", "expiresAt", "email"}).AddRow("test@example.com", "2025-01-15 13:00:00", "test@example.com"))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE users SET emailVerifiedAt = ? WHERE id = ?`)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM email_verification_tokens WHERE user_id = ?`)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, updatedAt, email, cpf, name, emailVerifiedAt FROM users WHERE id = ?`).
+			WithArgs("user-123").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "updatedAt", "email", "cpf", "name", "emailVerifiedAt"}).
+				AddRow("user-123", true, false, "2025-01-01 00:00:00", "2025-01-01 00:00:00", "test@example.com", "12345678909", "Test User", "2025-01-15 12:00:00"))
+
+		r := httptest.NewRequest(http.MethodPost, "http://localhost:8080/u/user-123/verify-email", bytes.NewReader([]byte(`{"Token": "token-123"}`)))
+		r.SetPathValue("id", "user-123")
+		w := httptest.NewRecorder()
+
+		controller.Verify(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response user.UserDTO
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		if assert.NotNil(t, response.EmailVerifiedAt) {
+			assert.Equal(t, "2025-01-15T12:00:00Z", *response.EmailVerifiedAt)
+		}
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("ShouldRejectAMissingToken", func(t *testing.T) {
+		db, _, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		controller := newEmailVerificationController(db)
+
+		r := httptest.NewRequest(http.MethodPost, "http://localhost:8080/u/user-123/verify-email", bytes.NewReader([]byte(`{}`)))
+		r.SetPathValue("id", "user-123")
+		w := httptest.NewRecorder()
+
+		controller.Verify(w, r)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestEmailVerificationControllerResend(t *testing.T) {
+	t.Run("ShouldReturnWhenTheTokenExpires", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		controller := newEmailVerificationController(db)
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT email, emailVerifiedAt IS NOT NULL FROM users`).
+			WithArgs("user-123").
+			WillReturnRows(sqlmock.NewRows([]string{"email", "verified"}).AddRow("test@example.com", false))
+		mock.ExpectExec(`DELETE FROM email_verification_tokens`).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`INSERT INTO email_verification_tokens`).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		r := httptest.NewRequest(http.MethodPost, "http://localhost:8080/u/user-123/verify-email/resend", nil)
+		r.SetPathValue("id", "user-123")
+		w := httptest.NewRecorder()
+
+		controller.Resend(w, r)
+
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		assert.JSONEq(t, `{"ExpiresAt": "2025-01-15T13:00:00Z"}`, w.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("ShouldReturnConflictWhenAlreadyVerified", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		controller := newEmailVerificationController(db)
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT email, emailVerifiedAt IS NOT NULL FROM users`).
+			WithArgs("user-123").
+			WillReturnRows(sqlmock.NewRows([]string{"email", "verified"}).AddRow("test@example.com", true))
+		mock.ExpectRollback()
+
+		r := httptest.NewRequest(http.MethodPost, "http://localhost:8080/u/user-123/verify-email/resend", nil)
+		r.SetPathValue("id", "user-123")
+		w := httptest.NewRecorder()
+
+		controller.Resend(w, r)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+}
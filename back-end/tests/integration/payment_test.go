@@ -0,0 +1,160 @@
+package integration
+
+// Capturing through the controller, with the fake gateway in place of the
+// provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sipub-test/internal/payment"
+	"sipub-test/internal/payment_gateway"
+	"sipub-test/internal/response"
+	"sipub-test/pkg/clock"
+	"sipub-test/pkg/money"
+	testhelper "sipub-test/pkg/test_helper"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func newPaymentController(t *testing.T) (*payment.PaymentController, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	repo := payment.NewMySQLPaymentRepository(db, clock.System{})
+	gateway := payment_gateway.NewFakeGateway([]string{"4000000000000002"}, money.Zero)
+	return payment.NewPaymentController(repo, &payment.PaymentValidator{}, gateway, &payment.CaptureValidator{}, testhelper.DiscardLogger()), mock
+}
+
+// GetOne returning the payment in the given status, with the given refunds
+func expectPayment(mock sqlmock.Sqlmock, status string, reference interface{}, refunds ...float64) {
+	mock.ExpectQuery(`SELECT id, isDeleted, createdAt, delivery_id, value, status, gatewayReference, failureReason FROM payments`).
+		WithArgs("payment-123").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "isDeleted", "createdAt", "delivery_id", "value", "status", "gatewayReference", "failureReason"}).
+			AddRow("payment-123", false, "2025-01-15 12:00:00", "delivery-123", 150.50, status, reference, nil))
+
+	rows := sqlmock.NewRows([]string{"id", "payment_id", "amount", "reason", "note", "createdAt"})
+	for _, amount := range refunds {
+		rows.AddRow("refund-123", "payment-123", amount, "damaged", nil, "2025-01-15 12:00:00")
+	}
+	mock.ExpectQuery(`SELECT id, payment_id, amount, reason, note, createdAt FROM refunds`).
+		WithArgs("payment-123", payment.RefundCompleted).
+		WillReturnRows(rows)
+}
+
+func expectPaymentStatus(mock sqlmock.Sqlmock, from string, to string, reference interface{}, failureReason interface{}) {
+	mock.ExpectExec(`UPDATE payments SET status`).
+		WithArgs(payment.PaymentStatus(to), reference, failureReason, "payment-123", payment.PaymentStatus(from)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func paymentRequest(body string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "http://localhost:8080/payment/payment-123/capture", bytes.NewReader([]byte(body)))
+	r.SetPathValue("id", "payment-123")
+	return r
+}
+
+func TestPaymentControllerCapture(t *testing.T) {
+	t.Run("ShouldAuthorizeAndCapture", func(t *testing.T) {
+		controller, mock := newPaymentController(t)
+
+		expectPayment(mock, "pending", nil)
+		expectPaymentStatus(mock, "pending", "authorized", "fake_payment-123", nil)
+		expectPayment(mock, "authorized", "fake_payment-123")
+		expectPaymentStatus(mock, "authorized", "captured", nil, nil)
+		expectPayment(mock, "captured", "fake_payment-123")
+
+		w := httptest.NewRecorder()
+		controller.Capture(w, paymentRequest(`{"CardNumber": "4242424242424242"}`))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var dto payment.PaymentDTO
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &dto))
+		assert.Equal(t, "captured", dto.Status)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("ShouldCaptureAuthorizedPaymentsWithoutCard", func(t *testing.T) {
+		controller, mock := newPaymentController(t)
+
+		expectPayment(mock, "authorized", "fake_payment-123")
+		expectPaymentStatus(mock, "authorized", "captured", nil, nil)
+		expectPayment(mock, "captured", "fake_payment-123")
+
+		w := httptest.NewRecorder()
+		controller.Capture(w, paymentRequest(""))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("ShouldRecordDeclinedCards", func(t *testing.T) {
+		controller, mock := newPaymentController(t)
+
+		expectPayment(mock, "pending", nil)
+		expectPaymentStatus(mock, "pending", "failed", nil, "card declined: payment declined")
+		expectPayment(mock, "failed", nil)
+
+		w := httptest.NewRecorder()
+		controller.Capture(w, paymentRequest(`{"CardNumber": "4000000000000002"}`))
+
+		assert.Equal(t, http.StatusPaymentRequired, w.Code)
+		var body response.ErrorBody
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, response.CodePaymentDeclined, body.Code)
+		assert.NoError(t, mock.ExpectationsWereMet(), "The payment should be marked as failed")
+	})
+	t.Run("ShouldRetryFailedPaymentsWithAnotherCard", func(t *testing.T) {
+		controller, mock := newPaymentController(t)
+
+		// The failed payment still counts in the balance, it is paid by
+		// capturing it again rather than by a new payment
+		expectPayment(mock, "failed", nil)
+		expectPaymentStatus(mock, "failed", "authorized", "fake_payment-123", nil)
+		expectPayment(mock, "authorized", "fake_payment-123")
+		expectPaymentStatus(mock, "authorized", "captured", nil, nil)
+		expectPayment(mock, "captured", "fake_payment-123")
+
+		w := httptest.NewRecorder()
+		controller.Capture(w, paymentRequest(`{"CardNumber": "4242424242424242"}`))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var dto payment.PaymentDTO
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &dto))
+		assert.Equal(t, "captured", dto.Status)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("ShouldRequireACardToAuthorize", func(t *testing.T) {
+		controller, mock := newPaymentController(t)
+
+		expectPayment(mock, "pending", nil)
+
+		w := httptest.NewRecorder()
+		controller.Capture(w, paymentRequest(`{}`))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("ShouldRejectMalformedCards", func(t *testing.T) {
+		controller, mock := newPaymentController(t)
+
+		w := httptest.NewRecorder()
+		controller.Capture(w, paymentRequest(`{"CardNumber": "4242-4242"}`))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.NoError(t, mock.ExpectationsWereMet(), "Nothing should be read")
+	})
+	t.Run("ShouldRejectCapturedPayments", func(t *testing.T) {
+		controller, mock := newPaymentController(t)
+
+		expectPayment(mock, "captured", "fake_payment-123")
+
+		w := httptest.NewRecorder()
+		controller.Capture(w, paymentRequest(""))
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -10,6 +10,10 @@ import (
 	"net/http/httptest"
 	"regexp"
 	"sipub-test/internal/product"
+	"sipub-test/internal/response"
+	"sipub-test/pkg/clock"
+	"sipub-test/pkg/money"
+	testhelper "sipub-test/pkg/test_helper"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
@@ -24,13 +28,11 @@ func TestProductControllerCreate(t *testing.T) {
 		defer db.Close()
 
 		// Arrange
-		controller := product.ProductController{}
-		repo := &product.MySQLProductRepository{}
-		repo.SetDB(db)
-		controller.SetRepository(repo)
+		repo := product.NewMySQLProductRepository(db, clock.System{})
+		controller := product.NewProductController(repo, &product.ProductValidator{}, testhelper.DiscardLogger())
 
 		mock.ExpectExec(`INSERT INTO products`).
-			WithArgs(sqlmock.AnyArg(), true, false, sqlmock.AnyArg(), 500.0, 25.50, "Test Product").
+			WithArgs(sqlmock.AnyArg(), true, false, sqlmock.AnyArg(), sqlmock.AnyArg(), 500.0, money.FromCents(2550), "Test Product").
 			WillReturnResult(sqlmock.NewResult(1, 1))
 
 		requestBody := `{
@@ -51,7 +53,7 @@ func TestProductControllerCreate(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, "Test Product", response.Name)
 		assert.EqualValues(t, 500, response.WeightGrams)
-		assert.EqualValues(t, 25.50, response.Price)
+		assert.Equal(t, money.FromCents(2550), response.Price)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 
@@ -61,10 +63,8 @@ func TestProductControllerCreate(t *testing.T) {
 		defer db.Close()
 
 		// Arrange
-		controller := product.ProductController{}
-		repo := &product.MySQLProductRepository{}
-		repo.SetDB(db)
-		controller.SetRepository(repo)
+		repo := product.NewMySQLProductRepository(db, clock.System{})
+		controller := product.NewProductController(repo, &product.ProductValidator{}, testhelper.DiscardLogger())
 
 		requestBody := `{
 			"WeightGrams": 500,
@@ -76,6 +76,11 @@ func TestProductControllerCreate(t *testing.T) {
 		controller.Create(w, r)
 
 		assert.Equal(t, http.StatusBadRequest, w.Code)
+		var response response.ErrorBody
+		err = json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err, "Errors should be returned as JSON")
+		assert.Equal(t, "validation_failed", response.Code)
+		assert.Equal(t, "IsActive", response.Details[0].Field)
 	})
 }
 
@@ -85,16 +90,16 @@ func TestProductControllerGetAll(t *testing.T) {
 		assert.NoError(t, err)
 		defer db.Close()
 
-		controller := product.ProductController{}
-		repo := &product.MySQLProductRepository{}
-		repo.SetDB(db)
-		controller.SetRepository(repo)
+		repo := product.NewMySQLProductRepository(db, clock.System{})
+		controller := product.NewProductController(repo, &product.ProductValidator{}, testhelper.DiscardLogger())
 
-		rows := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "weightGrams", "price", "name"}).
-			AddRow("1", true, false, "2023-01-01 12:00:00", 500.0, 25.50, "Test Product")
+		rows := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "updatedAt", "weightGrams", "price", "name", "deletedAt", "stock", "reserved"}).
+			AddRow("1", true, false, "2023-01-01 12:00:00", "2023-01-01 12:00:00", 500.0, 25.50, "Test Product", nil, 10, 0)
 
-		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, weightGrams, price, name FROM products`).
+		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, updatedAt, weightGrams, price, name, deletedAt, stock, reserved FROM products`).
 			WillReturnRows(rows)
+		mock.ExpectQuery(`SELECT COUNT\(\*\) FROM products`).
+			WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
 
 		r := httptest.NewRequest(http.MethodGet, "http://localhost:8080/products", nil)
 		w := httptest.NewRecorder()
@@ -115,14 +120,14 @@ func TestProductControllerGetAll(t *testing.T) {
 		assert.NoError(t, err)
 		defer db.Close()
 
-		controller := product.ProductController{}
-		repo := &product.MySQLProductRepository{}
-		repo.SetDB(db)
-		controller.SetRepository(repo)
+		repo := product.NewMySQLProductRepository(db, clock.System{})
+		controller := product.NewProductController(repo, &product.ProductValidator{}, testhelper.DiscardLogger())
 
-		rows := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "weightGrams", "price", "name"})
-		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, weightGrams, price, name FROM products`).
+		rows := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "updatedAt", "weightGrams", "price", "name", "deletedAt", "stock", "reserved"})
+		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, updatedAt, weightGrams, price, name, deletedAt, stock, reserved FROM products`).
 			WillReturnRows(rows)
+		mock.ExpectQuery(`SELECT COUNT\(\*\) FROM products`).
+			WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(0))
 
 		r := httptest.NewRequest(http.MethodGet, "http://localhost:8080/products", nil)
 		w := httptest.NewRecorder()
@@ -142,10 +147,8 @@ func TestProductControllerGetAll(t *testing.T) {
 		assert.NoError(t, err)
 		defer db.Close()
 
-		controller := product.ProductController{}
-		repo := &product.MySQLProductRepository{}
-		repo.SetDB(db)
-		controller.SetRepository(repo)
+		repo := product.NewMySQLProductRepository(db, clock.System{})
+		controller := product.NewProductController(repo, &product.ProductValidator{}, testhelper.DiscardLogger())
 
 		r := httptest.NewRequest(http.MethodGet, "http://localhost:8080/products?invalid_param=10", nil)
 		w := httptest.NewRecorder()
@@ -164,20 +167,18 @@ func TestProductControllerGetOne(t *testing.T) {
 		}
 		defer db.Close()
 
-		controller := &product.ProductController{}
-		repo := &product.MySQLProductRepository{}
-		repo.SetDB(db)
-		controller.SetRepository(repo)
+		repo := product.NewMySQLProductRepository(db, clock.System{})
+		controller := product.NewProductController(repo, &product.ProductValidator{}, testhelper.DiscardLogger())
 
 		id := "123e4567-e89b-12d3-a456-426614174000"
 
 		rows := sqlmock.NewRows([]string{
 			"id", "isActive", "isDeleted",
-			"createdAt", "weightGrams", "price", "name",
+			"createdAt", "updatedAt", "weightGrams", "price", "name", "stock", "reserved",
 		}).
-			AddRow(id, true, false, "2023-01-01 00:00:00", 500, 25.50, "Test Product")
-		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt,
-        weightGrams, price, name FROM products WHERE id = ?`).
+			AddRow(id, true, false, "2023-01-01 00:00:00", "2023-01-01 00:00:00", 500, 25.50, "Test Product", 10, 0)
+		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, updatedAt,
+        weightGrams, price, name, stock, reserved FROM products WHERE id = ?`).
 			WithArgs(id).
 			WillReturnRows(rows)
 
@@ -203,18 +204,17 @@ func TestProductControllerGetOne(t *testing.T) {
 		}
 		defer db.Close()
 
-		controller := &product.ProductController{}
-		repo := &product.MySQLProductRepository{}
-		repo.SetDB(db)
-		controller.SetRepository(repo)
+		repo := product.NewMySQLProductRepository(db, clock.System{})
+		controller := product.NewProductController(repo, &product.ProductValidator{}, testhelper.DiscardLogger())
 
 		id := "123e4567-e89b-12d3-a456-426614174000"
-		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, weightGrams, price, name FROM products WHERE id = ?`).
+		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, updatedAt, weightGrams, price, name, stock, reserved FROM products WHERE id = ?`).
 			WithArgs(id).
 			WillReturnError(sql.ErrNoRows)
 
 		r := httptest.NewRequest(http.MethodGet, "http://localhost:8080/products/"+id, nil)
 		w := httptest.NewRecorder()
+		r.SetPathValue("id", id)
 
 		controller.GetOne(w, r)
 
@@ -230,14 +230,12 @@ func TestProductControllerDeleteOne(t *testing.T) {
 		}
 		defer db.Close()
 
-		controller := &product.ProductController{}
-		repo := &product.MySQLProductRepository{}
-		repo.SetDB(db)
-		controller.SetRepository(repo)
+		repo := product.NewMySQLProductRepository(db, clock.System{})
+		controller := product.NewProductController(repo, &product.ProductValidator{}, testhelper.DiscardLogger())
 
 		id := "123e4567-e89b-12d3-a456-426614174000"
-		mock.ExpectExec(`DELETE FROM products WHERE id = ?`).
-			WithArgs(id).
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE products SET isDeleted = TRUE, deletedAt = ? WHERE id = ? AND isDeleted = FALSE`)).
+			WithArgs(sqlmock.AnyArg(), id).
 			WillReturnResult(sqlmock.NewResult(0, 1))
 
 		r := httptest.NewRequest(http.MethodDelete, "http://localhost:8080/products/"+id, nil)
@@ -256,14 +254,12 @@ func TestProductControllerDeleteOne(t *testing.T) {
 		}
 		defer db.Close()
 
-		controller := &product.ProductController{}
-		repo := &product.MySQLProductRepository{}
-		repo.SetDB(db)
-		controller.SetRepository(repo)
+		repo := product.NewMySQLProductRepository(db, clock.System{})
+		controller := product.NewProductController(repo, &product.ProductValidator{}, testhelper.DiscardLogger())
 
 		id := "123e4567-e89b-12d3-a456-426614174000"
-		mock.ExpectExec(`DELETE FROM products WHERE id = ?`).
-			WithArgs(id).
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE products SET isDeleted = TRUE, deletedAt = ? WHERE id = ? AND isDeleted = FALSE`)).
+			WithArgs(sqlmock.AnyArg(), id).
 			WillReturnResult(sqlmock.NewResult(0, 0))
 
 		r := httptest.NewRequest(http.MethodDelete, "http://localhost:8080/products/"+id, nil)
@@ -284,13 +280,11 @@ func TestProductControllerDeleteAll(t *testing.T) {
 		}
 		defer db.Close()
 
-		repo := &product.MySQLProductRepository{}
-		repo.SetDB(db)
-		controller := &product.ProductController{}
-		controller.SetRepository(repo)
+		repo := product.NewMySQLProductRepository(db, clock.System{})
+		controller := product.NewProductController(repo, &product.ProductValidator{}, testhelper.DiscardLogger())
 
-		mock.ExpectExec(`DELETE FROM products WHERE 1=1 AND name LIKE ?`).
-			WithArgs("%Test%").
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE products SET isDeleted = TRUE, deletedAt = ? WHERE 1=1 AND name LIKE ? AND isDeleted = ?`)).
+			WithArgs(sqlmock.AnyArg(), "%Test%", false).
 			WillReturnResult(sqlmock.NewResult(0, 3)) // Simulate 3 rows deleted
 
 		r := httptest.NewRequest(http.MethodDelete, "http://localhost:8080/products?Name=Test", nil)
@@ -315,31 +309,29 @@ func TestProductControllerUpdate(t *testing.T) {
 		}
 		defer db.Close()
 
-		controller := &product.ProductController{}
-		repo := &product.MySQLProductRepository{}
-		repo.SetDB(db)
-		controller.SetRepository(repo)
+		repo := product.NewMySQLProductRepository(db, clock.System{})
+		controller := product.NewProductController(repo, &product.ProductValidator{}, testhelper.DiscardLogger())
 
 		id := "123e4567-e89b-12d3-a456-426614174000"
 
 		// Mock previous product fetch
-		rowsBeforeUpdate := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "weightGrams", "price", "name"}).
-			AddRow(id, true, false, "2023-01-01 00:00:00", 500.0, 25.50, "Old Product")
+		rowsBeforeUpdate := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "updatedAt", "weightGrams", "price", "name", "stock", "reserved"}).
+			AddRow(id, true, false, "2023-01-01 00:00:00", "2023-01-01 00:00:00", 500.0, 25.50, "Old Product", 10, 0)
 
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, isActive, isDeleted, createdAt, weightGrams, price, name FROM products WHERE id = ?`)).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, isActive, isDeleted, createdAt, updatedAt, weightGrams, price, name, stock, reserved FROM products WHERE id = ?`)).
 			WithArgs(id).
 			WillReturnRows(rowsBeforeUpdate)
 
 		// Mock update query
-		mock.ExpectExec(regexp.QuoteMeta(`UPDATE products SET isActive = ?, isDeleted = ?, weightGrams = ?, price = ?, name = ? WHERE id = ?`)).
-			WithArgs(true, false, 600.0, 25.50, "Updated Name", id).
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE products SET isActive = ?, weightGrams = ?, price = ?, name = ?, updatedAt = ? WHERE id = ? AND isDeleted = FALSE`)).
+			WithArgs(true, 600.0, money.FromCents(2550), "Updated Name", sqlmock.AnyArg(), id).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 
 		// Mock updated product fetch
-		rowsAfterUpdate := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "weightGrams", "price", "name"}).
-			AddRow(id, true, false, "2023-01-01 00:00:00", 600.0, 25.50, "Updated Name")
+		rowsAfterUpdate := sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "updatedAt", "weightGrams", "price", "name", "stock", "reserved"}).
+			AddRow(id, true, false, "2023-01-01 00:00:00", "2023-01-01 00:00:00", 600.0, 25.50, "Updated Name", 10, 0)
 
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, isActive, isDeleted, createdAt, weightGrams, price, name FROM products WHERE id = ?`)).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, isActive, isDeleted, createdAt, updatedAt, weightGrams, price, name, stock, reserved FROM products WHERE id = ?`)).
 			WithArgs(id).
 			WillReturnRows(rowsAfterUpdate)
 
@@ -0,0 +1,184 @@
+package integration
+
+// Refunding through the controller: the refund is reserved, the gateway is
+// called, then the refund is completed and, when nothing is left captured, the
+// delivery is closed
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sipub-test/internal/payment"
+	"sipub-test/internal/payment_gateway"
+	"sipub-test/internal/refund"
+	"sipub-test/internal/unit_of_work"
+	"sipub-test/pkg/clock"
+	"sipub-test/pkg/money"
+	testhelper "sipub-test/pkg/test_helper"
+	"sipub-test/pkg/timestamp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var refundedAt = timestamp.New(time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC))
+
+func newRefundController(t *testing.T) (*refund.RefundController, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	now := clock.Fixed(refundedAt.Time())
+	gateway := payment_gateway.NewFakeGateway(nil, money.Zero)
+	return refund.NewRefundController(refund.NewMySQLRefundRepository(unit_of_work.NewUnitOfWork(db, now), now), payment.NewMySQLPaymentRepository(db, now), gateway, &refund.RefundValidator{}, testhelper.DiscardLogger()), mock
+}
+
+// The locked payment, what was already reserved of it and the pending refund
+func expectReserve(mock sqlmock.Sqlmock, reserved float64, amount money.Money, reason string, note interface{}) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT value, status, delivery_id FROM payments`).
+		WithArgs("payment-123").
+		WillReturnRows(sqlmock.NewRows([]string{"value", "status", "delivery_id"}).AddRow(150.50, "captured", "delivery-123"))
+	mock.ExpectQuery(`SELECT COALESCE\(SUM\(amount\), 0\) FROM refunds`).
+		WithArgs("payment-123", payment.RefundFailed).
+		WillReturnRows(sqlmock.NewRows([]string{"reserved"}).AddRow(reserved))
+	mock.ExpectExec(`INSERT INTO refunds`).
+		WithArgs(sqlmock.AnyArg(), "payment-123", amount, reason, note, payment.RefundPending, refundedAt).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+}
+
+// The gateway gave the money back, the completed refunds add up to refunded
+func expectComplete(mock sqlmock.Sqlmock, refunded float64) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT payment_id FROM refunds`).
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"payment_id"}).AddRow("payment-123"))
+	mock.ExpectQuery(`SELECT value, status, delivery_id FROM payments`).
+		WithArgs("payment-123").
+		WillReturnRows(sqlmock.NewRows([]string{"value", "status", "delivery_id"}).AddRow(150.50, "captured", "delivery-123"))
+	mock.ExpectExec(`UPDATE refunds SET status = \?`).
+		WithArgs(payment.RefundCompleted, sqlmock.AnyArg(), payment.RefundPending).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT COALESCE\(SUM\(amount\), 0\) FROM refunds`).
+		WithArgs("payment-123", payment.RefundCompleted).
+		WillReturnRows(sqlmock.NewRows([]string{"refunded"}).AddRow(refunded))
+}
+
+func refundRequest(body string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "http://localhost:8080/payment/payment-123/refund", bytes.NewReader([]byte(body)))
+	r.SetPathValue("id", "payment-123")
+	return r
+}
+
+func TestRefundController(t *testing.T) {
+	t.Run("ShouldRefundPartOfAPayment", func(t *testing.T) {
+		controller, mock := newRefundController(t)
+
+		expectPayment(mock, "captured", "fake_payment-123")
+		expectReserve(mock, 0, money.FromCents(5000), "damaged", "Broken box")
+		expectComplete(mock, 50)
+		mock.ExpectCommit()
+		expectPayment(mock, "captured", "fake_payment-123", 50)
+
+		w := httptest.NewRecorder()
+		controller.Refund(w, refundRequest(`{"Amount": 50, "Reason": "damaged", "Note": "Broken box"}`))
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var dto payment.PaymentDTO
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &dto))
+		assert.Equal(t, "captured", dto.Status, "Part of the payment is still captured")
+		assert.Equal(t, money.FromCents(5000), dto.RefundedValue)
+		assert.Len(t, dto.Refunds, 1)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("ShouldRefundTheRestAndCancelTheDelivery", func(t *testing.T) {
+		controller, mock := newRefundController(t)
+
+		expectPayment(mock, "captured", "fake_payment-123", 50)
+		expectReserve(mock, 50, money.FromCents(10050), "customer_request", nil)
+		expectComplete(mock, 150.50)
+		mock.ExpectExec(`UPDATE payments SET status = \? WHERE id = \?`).
+			WithArgs(payment.StatusRefunded, "payment-123").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(`SELECT COUNT\(\*\) FROM payments`).
+			WithArgs("delivery-123", payment.StatusCaptured).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery(`SELECT status FROM deliveries`).
+			WithArgs("delivery-123").
+			WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("paid"))
+		mock.ExpectQuery(`SELECT product_id, quantity, reason FROM stock_movements`).
+			WithArgs("delivery-123").
+			WillReturnRows(sqlmock.NewRows([]string{"product_id", "quantity", "reason"}))
+		mock.ExpectExec(`UPDATE deliveries SET status = \?, updatedAt = \?`).
+			WithArgs("cancelled", refundedAt, "delivery-123").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`INSERT INTO delivery_status_history`).
+			WithArgs(sqlmock.AnyArg(), "delivery-123", "paid", "cancelled", sqlmock.AnyArg(), refundedAt).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		expectPayment(mock, "refunded", "fake_payment-123", 50, 100.50)
+
+		w := httptest.NewRecorder()
+		controller.Refund(w, refundRequest(`{"Reason": "customer_request"}`))
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var dto payment.PaymentDTO
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &dto))
+		assert.Equal(t, "refunded", dto.Status)
+		assert.Equal(t, money.FromCents(15050), dto.RefundedValue)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("ShouldFailTheRefundTheGatewayRefuses", func(t *testing.T) {
+		controller, mock := newRefundController(t)
+
+		// Authorized by another gateway, the fake one doesn't know it
+		expectPayment(mock, "captured", "other_payment-123")
+		expectReserve(mock, 0, money.FromCents(5000), "damaged", nil)
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE refunds SET status = \?`).
+			WithArgs(payment.RefundFailed, sqlmock.AnyArg(), payment.RefundPending).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		w := httptest.NewRecorder()
+		controller.Refund(w, refundRequest(`{"Amount": 50, "Reason": "damaged"}`))
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.NoError(t, mock.ExpectationsWereMet(), "The reserved refund should be failed")
+	})
+	t.Run("ShouldRejectAmountsOverWhatIsLeft", func(t *testing.T) {
+		controller, mock := newRefundController(t)
+
+		expectPayment(mock, "captured", "fake_payment-123", 100)
+
+		w := httptest.NewRecorder()
+		controller.Refund(w, refundRequest(`{"Amount": 50.51, "Reason": "damaged"}`))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.NoError(t, mock.ExpectationsWereMet(), "Nothing should be recorded")
+	})
+	t.Run("ShouldRejectUncapturedPayments", func(t *testing.T) {
+		controller, mock := newRefundController(t)
+
+		expectPayment(mock, "authorized", "fake_payment-123")
+
+		w := httptest.NewRecorder()
+		controller.Refund(w, refundRequest(`{"Reason": "duplicate"}`))
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("ShouldRequireAReason", func(t *testing.T) {
+		controller, mock := newRefundController(t)
+
+		w := httptest.NewRecorder()
+		controller.Refund(w, refundRequest(`{"Amount": 10}`))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.NoError(t, mock.ExpectationsWereMet(), "Nothing should be read")
+	})
+}
@@ -0,0 +1,198 @@
+package integration
+
+// Builds the routes the same way main does, but with fakes instead of MySQL, to
+// make sure the requests reach the controller through the mux
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sipub-test/internal"
+	"sipub-test/internal/filter"
+	"sipub-test/internal/pagination"
+	"sipub-test/internal/product"
+	"sipub-test/pkg/apperror"
+	testhelper "sipub-test/pkg/test_helper"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Records what reached the repository
+type fakeProductRepository struct {
+	created  []product.ProductParams
+	gotIDs   []string
+	products map[string]string // id : name
+	pages    []pagination.Page
+	wheres   []filter.Filter
+	listed   []product.ProductModel
+	restored []string
+	purged   []string
+	total    uint
+}
+
+func (f *fakeProductRepository) Create(ctx context.Context, params product.ProductParams) (product.ProductModel, error) {
+	f.created = append(f.created, params)
+	var model product.ProductModel
+	model.SetName(*params.Name)
+	return model, nil
+}
+
+func (f *fakeProductRepository) GetAll(ctx context.Context, where filter.Filter, page pagination.Page) ([]product.ProductModel, error) {
+	f.pages = append(f.pages, page)
+	f.wheres = append(f.wheres, where)
+	return f.listed, nil
+}
+
+func (f *fakeProductRepository) Count(ctx context.Context, where filter.Filter) (uint, error) {
+	return f.total, nil
+}
+
+func (f *fakeProductRepository) GetOne(ctx context.Context, id string) (product.ProductModel, error) {
+	f.gotIDs = append(f.gotIDs, id)
+	name, exists := f.products[id]
+	if !exists {
+		return product.ProductModel{}, errors.New("product not found")
+	}
+	var model product.ProductModel
+	model.SetName(name)
+	return model, nil
+}
+
+func (f *fakeProductRepository) DeleteOne(ctx context.Context, id string) (uint, error) {
+	return 1, nil
+}
+
+func (f *fakeProductRepository) DeleteAll(ctx context.Context, where filter.Filter) (uint, error) {
+	return 0, nil
+}
+
+func (f *fakeProductRepository) Update(ctx context.Context, id string, newProduct product.ProductParams) (product.ProductModel, error) {
+	return f.GetOne(ctx, id)
+}
+
+func (f *fakeProductRepository) Restore(ctx context.Context, id string) (product.ProductModel, error) {
+	f.restored = append(f.restored, id)
+	return f.GetOne(ctx, id)
+}
+
+func (f *fakeProductRepository) Purge(ctx context.Context, id string) (uint, error) {
+	f.purged = append(f.purged, id)
+	return 1, nil
+}
+
+// Refuses every product
+type rejectingValidator struct{}
+
+func (rejectingValidator) Validate(product.ProductParams) error {
+	return apperror.NewValidationError("Name", "rejected")
+}
+
+func newProductMux(repo product.IProductRepository, validator internal.IValidator[product.ProductParams]) *http.ServeMux {
+	mux := http.NewServeMux()
+	controller := product.NewProductController(repo, validator, testhelper.DiscardLogger())
+	product.NewProductRouter(controller).Init(mux)
+	return mux
+}
+
+func TestProductRoutesWithFakes(t *testing.T) {
+	t.Run("ShouldRouteGetOneToTheRepository", func(t *testing.T) {
+		repo := &fakeProductRepository{products: map[string]string{"abc": "Fake Product"}}
+		mux := newProductMux(repo, &product.ProductValidator{})
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/products/abc", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, []string{"abc"}, repo.gotIDs, "The path value should reach the repository")
+		var response product.ProductDTO
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "Fake Product", response.Name)
+	})
+	t.Run("ShouldUseTheInjectedValidator", func(t *testing.T) {
+		repo := &fakeProductRepository{}
+		mux := newProductMux(repo, rejectingValidator{})
+
+		requestBody := `{"Name": "Test Product", "WeightGrams": 500, "Price": 25.50, "IsActive": true, "IsDeleted": false}`
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/products", strings.NewReader(requestBody)))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Empty(t, repo.created, "The repository shouldn't be reached")
+	})
+	t.Run("ShouldPaginateGetAll", func(t *testing.T) {
+		repo := &fakeProductRepository{total: 3}
+		for _, name := range []string{"A", "B", "C"} {
+			var model product.ProductModel
+			model.SetName(name)
+			repo.listed = append(repo.listed, model)
+		}
+		mux := newProductMux(repo, &product.ProductValidator{})
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/products?limit=2&offset=0&sort=-Price", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response []product.ProductDTO
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Len(t, response, 2, "The extra row should be dropped")
+		assert.Equal(t, "3", w.Header().Get(pagination.TotalCountHeader))
+		assert.Contains(t, w.Header().Get(pagination.LinkHeader), `rel="next"`)
+		if assert.Len(t, repo.pages, 1) {
+			assert.EqualValues(t, 2, repo.pages[0].Limit)
+			assert.Equal(t, "price", repo.pages[0].Sort[0].Column)
+		}
+	})
+	t.Run("ShouldRejectUnknownSortFields", func(t *testing.T) {
+		repo := &fakeProductRepository{}
+		mux := newProductMux(repo, &product.ProductValidator{})
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/products?sort=id", nil))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Empty(t, repo.pages, "The repository shouldn't be reached")
+	})
+	t.Run("ShouldHideDeletedProductsUnlessAsked", func(t *testing.T) {
+		repo := &fakeProductRepository{}
+		mux := newProductMux(repo, &product.ProductValidator{})
+
+		for _, target := range []string{"/products", "/products?includeDeleted=true", "/products?IsDeleted=true"} {
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+			assert.Equal(t, http.StatusOK, w.Code)
+		}
+
+		if assert.Len(t, repo.wheres, 3) {
+			assert.Equal(t, []filter.Condition{{Field: "IsDeleted", Column: "isDeleted", Type: filter.Bool, Operator: filter.Eq, Values: []interface{}{false}}}, repo.wheres[0].Conditions)
+			assert.Empty(t, repo.wheres[1].Conditions, "includeDeleted should list everything")
+			assert.Equal(t, []interface{}{true}, repo.wheres[2].Conditions[0].Values, "An explicit IsDeleted filter should win")
+		}
+	})
+	t.Run("ShouldRouteRestoreAndPurge", func(t *testing.T) {
+		repo := &fakeProductRepository{products: map[string]string{"abc": "Fake Product"}}
+		mux := newProductMux(repo, &product.ProductValidator{})
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/products/abc/restore", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/products/abc/purge", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		assert.Equal(t, []string{"abc"}, repo.restored)
+		assert.Equal(t, []string{"abc"}, repo.purged)
+	})
+	t.Run("ShouldNotMatchUnknownMethods", func(t *testing.T) {
+		mux := newProductMux(&fakeProductRepository{}, &product.ProductValidator{})
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodPatch, "/products/abc", nil))
+
+		assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	})
+}
@@ -2,17 +2,36 @@ package integration
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"regexp"
+	"sipub-test/internal/auth"
 	"sipub-test/internal/user"
+	"sipub-test/pkg/clock"
+	testhelper "sipub-test/pkg/test_helper"
+	"sipub-test/pkg/timestamp"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
 	"github.com/stretchr/testify/assert"
 )
 
+// Records the users a verification was sent to instead of mailing a token
+type verificationSpy struct {
+	sentTo []string
+	err    error
+}
+
+func (s *verificationSpy) Send(ctx context.Context, userID string) (timestamp.Time, error) {
+	s.sentTo = append(s.sentTo, userID)
+	return timestamp.Time{}, s.err
+}
+
 func TestUserControllerCreate(t *testing.T) {
 	t.Run("ShouldReturnSuccess", func(t *testing.T) {
 		db, mock, err := sqlmock.New()
@@ -20,19 +39,19 @@ func TestUserControllerCreate(t *testing.T) {
 		defer db.Close()
 
 		// Arrange
-		controller := user.UserController{}
-		repo := &user.MySQLUserRepository{}
-		repo.SetDB(db)
-		controller.SetRepository(repo)
+		repo := user.NewMySQLUserRepository(db, clock.System{})
+		verifier := &verificationSpy{}
+		controller := user.NewUserController(repo, &user.UserValidator{}, verifier, testhelper.DiscardLogger())
 
 		mock.ExpectExec(`INSERT INTO users`).
-			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 
 		requestBody := `{
 			"Email": "test@example.com",
-			"Cpf": "12345678901",
+			"Cpf": "123.456.789-09",
 			"Name": "Test User",
+			"Password": "correct horse",
 			"IsActive": true,
 			"IsDeleted": false
 		}`
@@ -47,22 +66,124 @@ func TestUserControllerCreate(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, "Test User", response.Name)
 		assert.Equal(t, "test@example.com", response.Email)
+		assert.Equal(t, "***.456.789-**", response.Cpf)
+		assert.Equal(t, []string{response.Id}, verifier.sentTo, "The new email should get a token")
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 
+	t.Run("ShouldCreateEvenIfTheEmailFails", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		repo := user.NewMySQLUserRepository(db, clock.System{})
+		verifier := &verificationSpy{err: errors.New("mail server down")}
+		controller := user.NewUserController(repo, &user.UserValidator{}, verifier, testhelper.DiscardLogger())
+
+		mock.ExpectExec(`INSERT INTO users`).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		requestBody := `{"Email": "test@example.com", "Cpf": "12345678909", "Name": "Test User", "Password": "correct horse", "IsActive": true, "IsDeleted": false}`
+		r := httptest.NewRequest(http.MethodPost, "http://localhost:8080/u", bytes.NewReader([]byte(requestBody)))
+		w := httptest.NewRecorder()
+
+		controller.Create(w, r)
+
+		assert.Equal(t, http.StatusCreated, w.Code, "The token can be sent again later")
+		assert.Len(t, verifier.sentTo, 1)
+	})
+
+	t.Run("ShouldRejectAnInvalidEmail", func(t *testing.T) {
+		db, _, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		repo := user.NewMySQLUserRepository(db, clock.System{})
+		verifier := &verificationSpy{}
+		controller := user.NewUserController(repo, &user.UserValidator{}, verifier, testhelper.DiscardLogger())
+
+		requestBody := `{"Email": "test@example", "Cpf": "12345678909", "Name": "Test User", "Password": "correct horse", "IsActive": true, "IsDeleted": false}`
+		r := httptest.NewRequest(http.MethodPost, "http://localhost:8080/u", bytes.NewReader([]byte(requestBody)))
+		w := httptest.NewRecorder()
+
+		controller.Create(w, r)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "Email")
+		assert.Empty(t, verifier.sentTo)
+	})
+
+	t.Run("ShouldReturnConflictForATakenCpf", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		repo := user.NewMySQLUserRepository(db, clock.System{})
+		verifier := &verificationSpy{}
+		controller := user.NewUserController(repo, &user.UserValidator{}, verifier, testhelper.DiscardLogger())
+
+		mock.ExpectExec(`INSERT INTO users`).
+			WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry '12345678909' for key 'users.uq_users_cpf'"})
+
+		requestBody := `{"Email": "other@example.com", "Cpf": "12345678909", "Name": "Other User", "Password": "correct horse", "IsActive": true, "IsDeleted": false}`
+		r := httptest.NewRequest(http.MethodPost, "http://localhost:8080/u", bytes.NewReader([]byte(requestBody)))
+		w := httptest.NewRecorder()
+
+		controller.Create(w, r)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("ShouldRequireAPassword", func(t *testing.T) {
+		db, _, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		controller := user.NewUserController(user.NewMySQLUserRepository(db, clock.System{}), &user.UserValidator{}, &verificationSpy{}, testhelper.DiscardLogger())
+
+		requestBody := `{"Email": "test@example.com", "Cpf": "12345678909", "Name": "Test User", "Password": "short", "IsActive": true, "IsDeleted": false}`
+		r := httptest.NewRequest(http.MethodPost, "http://localhost:8080/u", bytes.NewReader([]byte(requestBody)))
+		w := httptest.NewRecorder()
+
+		controller.Create(w, r)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "Password")
+		assert.NotContains(t, w.Body.String(), "short", "The password shouldn't be echoed")
+	})
+
+	t.Run("ShouldRejectAnInvalidCpf", func(t *testing.T) {
+		db, _, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		repo := user.NewMySQLUserRepository(db, clock.System{})
+		verifier := &verificationSpy{}
+		controller := user.NewUserController(repo, &user.UserValidator{}, verifier, testhelper.DiscardLogger())
+
+		requestBody := `{"Email": "test@example.com", "Cpf": "111.111.111-11", "Name": "Test User", "Password": "correct horse", "IsActive": true, "IsDeleted": false}`
+		r := httptest.NewRequest(http.MethodPost, "http://localhost:8080/u", bytes.NewReader([]byte(requestBody)))
+		w := httptest.NewRecorder()
+
+		controller.Create(w, r)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "Cpf")
+	})
+
 	t.Run("ShouldReturnInvalidRequest", func(t *testing.T) {
 		db, _, err := sqlmock.New()
 		assert.NoError(t, err)
 		defer db.Close()
 
 		// Arrange
-		controller := user.UserController{}
-		repo := &user.MySQLUserRepository{}
-		repo.SetDB(db)
-		controller.SetRepository(repo)
+		repo := user.NewMySQLUserRepository(db, clock.System{})
+		verifier := &verificationSpy{}
+		controller := user.NewUserController(repo, &user.UserValidator{}, verifier, testhelper.DiscardLogger())
 
 		requestBody := `{
-			"Cpf": "12345678901",
+			"Cpf": "12345678909",
 			"Email": "test@example.com"
 		}` // Missing required Name field
 		r := httptest.NewRequest(http.MethodPost, "http://localhost:8080/u", bytes.NewReader([]byte(requestBody)))
@@ -80,16 +201,17 @@ func TestUserControllerGetAll(t *testing.T) {
 		assert.NoError(t, err)
 		defer db.Close()
 
-		controller := user.UserController{}
-		repo := &user.MySQLUserRepository{}
-		repo.SetDB(db)
-		controller.SetRepository(repo)
+		repo := user.NewMySQLUserRepository(db, clock.System{})
+		verifier := &verificationSpy{}
+		controller := user.NewUserController(repo, &user.UserValidator{}, verifier, testhelper.DiscardLogger())
 
-		rows := sqlmock.NewRows([]string{"id", "createdAt", "email", "cpf", "name", "isActive", "isDeleted"}).
-			AddRow("1", true, false, "2023-01-01 12:00:00", "test@example.com", "12345678901", "Test User")
+		rows := sqlmock.NewRows([]string{"id", "createdAt", "updatedAt", "email", "cpf", "name", "emailVerifiedAt", "isActive", "isDeleted", "deletedAt"}).
+			AddRow("1", true, false, "2023-01-01 12:00:00", "2023-01-01 12:00:00", "test@example.com", "12345678909", "Test User", nil, nil)
 
-		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, email, cpf, name FROM users WHERE 1=1`).
+		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, updatedAt, email, cpf, name, emailVerifiedAt, deletedAt FROM users WHERE 1=1`).
 			WillReturnRows(rows)
+		mock.ExpectQuery(`SELECT COUNT\(\*\) FROM users`).
+			WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
 
 		r := httptest.NewRequest(http.MethodGet, "http://localhost:8080/u", nil)
 		w := httptest.NewRecorder()
@@ -110,14 +232,15 @@ func TestUserControllerGetAll(t *testing.T) {
 		assert.NoError(t, err)
 		defer db.Close()
 
-		controller := user.UserController{}
-		repo := &user.MySQLUserRepository{}
-		repo.SetDB(db)
-		controller.SetRepository(repo)
+		repo := user.NewMySQLUserRepository(db, clock.System{})
+		verifier := &verificationSpy{}
+		controller := user.NewUserController(repo, &user.UserValidator{}, verifier, testhelper.DiscardLogger())
 
-		rows := sqlmock.NewRows([]string{"id", "createdAt", "email", "cpf", "name", "isActive", "isDeleted"})
-		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, email, cpf, name FROM users WHERE 1=1`).
+		rows := sqlmock.NewRows([]string{"id", "createdAt", "updatedAt", "email", "cpf", "name", "emailVerifiedAt", "isActive", "isDeleted", "deletedAt"})
+		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, updatedAt, email, cpf, name, emailVerifiedAt, deletedAt FROM users WHERE 1=1`).
 			WillReturnRows(rows)
+		mock.ExpectQuery(`SELECT COUNT\(\*\) FROM users`).
+			WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(0))
 
 		r := httptest.NewRequest(http.MethodGet, "http://localhost:8080/u", nil)
 		w := httptest.NewRecorder()
@@ -137,10 +260,9 @@ func TestUserControllerGetAll(t *testing.T) {
 		assert.NoError(t, err)
 		defer db.Close()
 
-		controller := user.UserController{}
-		repo := &user.MySQLUserRepository{}
-		repo.SetDB(db)
-		controller.SetRepository(repo)
+		repo := user.NewMySQLUserRepository(db, clock.System{})
+		verifier := &verificationSpy{}
+		controller := user.NewUserController(repo, &user.UserValidator{}, verifier, testhelper.DiscardLogger())
 
 		r := httptest.NewRequest(http.MethodGet, "http://localhost:8080/u?invalid_param=10", nil)
 		w := httptest.NewRecorder()
@@ -157,18 +279,17 @@ func TestUserControllerGetOne(t *testing.T) {
 		assert.NoError(t, err)
 		defer db.Close()
 
-		controller := &user.UserController{}
-		repo := &user.MySQLUserRepository{}
-		repo.SetDB(db)
-		controller.SetRepository(repo)
+		repo := user.NewMySQLUserRepository(db, clock.System{})
+		verifier := &verificationSpy{}
+		controller := user.NewUserController(repo, &user.UserValidator{}, verifier, testhelper.DiscardLogger())
 
 		id := "123e4567-e89b-12d3-a456-426614174000"
 
 		rows := sqlmock.NewRows([]string{
-			"id", "createdAt", "email", "cpf", "name", "isActive", "isDeleted",
+			"id", "createdAt", "updatedAt", "email", "cpf", "name", "emailVerifiedAt", "isActive", "isDeleted",
 		}).
-			AddRow(id, true, false, "2023-01-01 00:00:00", "test@example.com", "12345678901", "Test User")
-		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, email, cpf, name FROM users WHERE id = ?`).
+			AddRow(id, true, false, "2023-01-01 00:00:00", "2023-01-01 00:00:00", "test@example.com", "12345678909", "Test User", nil)
+		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, updatedAt, email, cpf, name, emailVerifiedAt FROM users WHERE id = ?`).
 			WithArgs(id).
 			WillReturnRows(rows)
 
@@ -185,24 +306,72 @@ func TestUserControllerGetOne(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, id, response.Id)
 		assert.Equal(t, "Test User", response.Name)
+		assert.Equal(t, "***.456.789-**", response.Cpf)
+	})
+	t.Run("ShouldRevealTheCpfWhenAsked", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		repo := user.NewMySQLUserRepository(db, clock.System{})
+		verifier := &verificationSpy{}
+		controller := user.NewUserController(repo, &user.UserValidator{}, verifier, testhelper.DiscardLogger())
+
+		id := "123e4567-e89b-12d3-a456-426614174000"
+		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, updatedAt, email, cpf, name, emailVerifiedAt FROM users WHERE id = ?`).
+			WithArgs(id).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "isActive", "isDeleted", "createdAt", "updatedAt", "email", "cpf", "name", "emailVerifiedAt"}).
+				AddRow(id, true, false, "2023-01-01 00:00:00", "2023-01-01 00:00:00", "test@example.com", "12345678909", "Test User", nil))
+
+		r := httptest.NewRequest(http.MethodGet, "http://localhost:8080/u/"+id+"?revealCpf=true", nil)
+		r = r.WithContext(auth.WithSession(r.Context(), auth.Session{ID: "session-123", UserID: id}))
+		r.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+
+		controller.GetOne(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response user.UserDTO
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "12345678909", response.Cpf)
+	})
+	t.Run("ShouldOnlyRevealTheCpfToTheUserItself", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		controller := user.NewUserController(user.NewMySQLUserRepository(db, clock.System{}), &user.UserValidator{}, &verificationSpy{}, testhelper.DiscardLogger())
+		id := "123e4567-e89b-12d3-a456-426614174000"
+
+		anonymous := httptest.NewRequest(http.MethodGet, "http://localhost:8080/u/"+id+"?revealCpf=true", nil)
+		anonymous.SetPathValue("id", id)
+		other := anonymous.WithContext(auth.WithSession(anonymous.Context(), auth.Session{ID: "session-456", UserID: "another-user"}))
+		anonymousW, otherW := httptest.NewRecorder(), httptest.NewRecorder()
+
+		controller.GetOne(anonymousW, anonymous)
+		controller.GetOne(otherW, other)
+
+		assert.Equal(t, http.StatusUnauthorized, anonymousW.Code)
+		assert.Equal(t, http.StatusForbidden, otherW.Code)
+		assert.NoError(t, mock.ExpectationsWereMet(), "The user shouldn't be read")
 	})
 	t.Run("ShouldReturn404IfUserNotFound", func(t *testing.T) {
 		db, mock, err := sqlmock.New()
 		assert.NoError(t, err)
 		defer db.Close()
 
-		controller := &user.UserController{}
-		repo := &user.MySQLUserRepository{}
-		repo.SetDB(db)
-		controller.SetRepository(repo)
+		repo := user.NewMySQLUserRepository(db, clock.System{})
+		verifier := &verificationSpy{}
+		controller := user.NewUserController(repo, &user.UserValidator{}, verifier, testhelper.DiscardLogger())
 
 		id := "123e4567-e89b-12d3-a456-426614174000"
-		mock.ExpectQuery(`SELECT id, createdAt, email, cpf, name, isActive, isDeleted FROM users WHERE id = ?`).
+		mock.ExpectQuery(`SELECT id, isActive, isDeleted, createdAt, updatedAt, email, cpf, name, emailVerifiedAt FROM users WHERE id = ?`).
 			WithArgs(id).
 			WillReturnError(sql.ErrNoRows)
 
 		r := httptest.NewRequest(http.MethodGet, "http://localhost:8080/u/"+id, nil)
 		w := httptest.NewRecorder()
+		r.SetPathValue("id", id)
 
 		controller.GetOne(w, r)
 
@@ -216,14 +385,13 @@ func TestUserControllerDeleteOne(t *testing.T) {
 		assert.NoError(t, err)
 		defer db.Close()
 
-		controller := &user.UserController{}
-		repo := &user.MySQLUserRepository{}
-		repo.SetDB(db)
-		controller.SetRepository(repo)
+		repo := user.NewMySQLUserRepository(db, clock.System{})
+		verifier := &verificationSpy{}
+		controller := user.NewUserController(repo, &user.UserValidator{}, verifier, testhelper.DiscardLogger())
 
 		id := "123e4567-e89b-12d3-a456-426614174000"
-		mock.ExpectExec(`DELETE FROM users WHERE id = ?`).
-			WithArgs(id).
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE users SET isDeleted = TRUE, deletedAt = ? WHERE id = ? AND isDeleted = FALSE`)).
+			WithArgs(sqlmock.AnyArg(), id).
 			WillReturnResult(sqlmock.NewResult(0, 1))
 
 		r := httptest.NewRequest(http.MethodDelete, "http://localhost:8080/u/"+id, nil)
@@ -240,14 +408,13 @@ func TestUserControllerDeleteOne(t *testing.T) {
 		assert.NoError(t, err)
 		defer db.Close()
 
-		controller := &user.UserController{}
-		repo := &user.MySQLUserRepository{}
-		repo.SetDB(db)
-		controller.SetRepository(repo)
+		repo := user.NewMySQLUserRepository(db, clock.System{})
+		verifier := &verificationSpy{}
+		controller := user.NewUserController(repo, &user.UserValidator{}, verifier, testhelper.DiscardLogger())
 
 		id := "123e4567-e89b-12d3-a456-426614174000"
-		mock.ExpectExec(`DELETE FROM users WHERE id = ?`).
-			WithArgs(id).
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE users SET isDeleted = TRUE, deletedAt = ? WHERE id = ? AND isDeleted = FALSE`)).
+			WithArgs(sqlmock.AnyArg(), id).
 			WillReturnResult(sqlmock.NewResult(0, 0))
 
 		r := httptest.NewRequest(http.MethodDelete, "http://localhost:8080/u/"+id, nil)
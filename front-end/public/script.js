@@ -20,6 +20,7 @@ document
       Email: document.getElementById("email").value,
       Cpf: document.getElementById("cpf").value,
       Name: document.getElementById("nome").value,
+      Password: document.getElementById("senha").value,
     };
 
     // Make sure the parseLatLong function is defined before it's called